@@ -0,0 +1,18 @@
+package main
+
+import "time"
+
+// clock is the time source of the interval and schedule loops, so tests can
+// drive them through days of runs without waiting.
+type clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+	Sleep(d time.Duration)
+}
+
+// realClock is the clock backed by package time.
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (realClock) Sleep(d time.Duration)                  { time.Sleep(d) }
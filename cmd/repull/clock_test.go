@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock that only moves when told to: After and Sleep advance
+// it by the requested duration at once, so a loop driven by it runs through
+// hours of schedule in microseconds and always the same way.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock(now time.Time) *fakeClock { return &fakeClock{now: now} }
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by d, e.g. to simulate a slow check.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.Advance(d)
+	ch := make(chan time.Time, 1)
+	ch <- c.Now()
+	return ch
+}
+
+func (c *fakeClock) Sleep(d time.Duration) { c.Advance(d) }
+
+// recordChecks returns a check that records the time of each call on clk,
+// takes durations[i] (if given) to run, and cancels the returned context
+// after n calls.
+func recordChecks(clk *fakeClock, n int, durations map[int]time.Duration) (context.Context, func(), *[]time.Time) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var runs []time.Time
+	check := func() {
+		runs = append(runs, clk.Now())
+		clk.Advance(durations[len(runs)-1])
+		if len(runs) == n {
+			cancel()
+		}
+	}
+	return ctx, check, &runs
+}
+
+func assertTimes(t *testing.T, got []time.Time, want []time.Time) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("checks ran at %v, want %v", got, want)
+	}
+	for i := range want {
+		if !got[i].Equal(want[i]) {
+			t.Errorf("check %d ran at %s, want %s", i, got[i].Format(time.DateTime), want[i].Format(time.DateTime))
+		}
+	}
+}
+
+func TestWaitInitialDelayUsesClock(t *testing.T) {
+	start := time.Date(2026, time.June, 11, 12, 0, 0, 0, time.UTC)
+	clk := newFakeClock(start)
+	if !waitInitialDelay(t.Context(), clk, 10*time.Minute) {
+		t.Fatal("waitInitialDelay() = false, want true")
+	}
+	if got := clk.Now().Sub(start); got != 10*time.Minute {
+		t.Errorf("waited %s, want 10m", got)
+	}
+}
+
+func TestIntervalLoop(t *testing.T) {
+	start := time.Date(2026, time.June, 11, 12, 0, 0, 0, time.UTC)
+	at := func(min int) time.Time { return start.Add(time.Duration(min) * time.Minute) }
+
+	tests := []struct {
+		name      string
+		durations map[int]time.Duration
+		want      []time.Time
+	}{
+		{
+			name: "ticks on a fixed grid",
+			want: []time.Time{at(0), at(5), at(10), at(15)},
+		},
+		{
+			name:      "check time does not shift the grid",
+			durations: map[int]time.Duration{1: 2 * time.Minute},
+			want:      []time.Time{at(0), at(5), at(10), at(15)},
+		},
+		{
+			// The second check runs until 5+12=17, overrunning the ticks at
+			// 10 and 15: one check follows at once, then back on the grid.
+			name:      "overrun ticks collapse into one immediate check",
+			durations: map[int]time.Duration{1: 12 * time.Minute},
+			want:      []time.Time{at(0), at(5), at(17), at(20)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clk := newFakeClock(start)
+			ctx, check, runs := recordChecks(clk, len(tt.want), tt.durations)
+			intervalLoop(ctx, clk, nil, 5*time.Minute, true, check)
+			assertTimes(t, *runs, tt.want)
+		})
+	}
+}
+
+// TestIntervalLoopNoRunOnStart verifies that with --no-run-on-start the
+// first check waits for the first tick.
+func TestIntervalLoopNoRunOnStart(t *testing.T) {
+	start := time.Date(2026, time.June, 11, 12, 0, 0, 0, time.UTC)
+	clk := newFakeClock(start)
+	ctx, check, runs := recordChecks(clk, 2, nil)
+
+	intervalLoop(ctx, clk, nil, 5*time.Minute, false, check)
+
+	assertTimes(t, *runs, []time.Time{start.Add(5 * time.Minute), start.Add(10 * time.Minute)})
+}
+
+// TestIntervalLoopWindowChange verifies that crossing into another
+// --interval-schedule window switches the interval after the next check.
+func TestIntervalLoopWindowChange(t *testing.T) {
+	windows, err := parseIntervalSchedule("08:00-18:00=300,18:00-08:00=3600")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2026, time.June, 11, 17, 50, 0, 0, time.UTC)
+	clk := newFakeClock(start)
+	ctx, check, runs := recordChecks(clk, 5, nil)
+
+	intervalLoop(ctx, clk, windows, time.Hour, true, check)
+
+	at := func(h, m int) time.Time { return time.Date(2026, time.June, 11, h, m, 0, 0, time.UTC) }
+	assertTimes(t, *runs, []time.Time{at(17, 50), at(17, 55), at(18, 0), at(19, 0), at(20, 0)})
+}
+
+func TestScheduleLoop(t *testing.T) {
+	target := time.Date(2026, time.June, 11, 3, 0, 0, 0, time.UTC)
+	start := time.Date(2026, time.June, 11, 23, 30, 0, 0, time.UTC)
+	day := func(d int) time.Time { return time.Date(2026, time.June, d, 3, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name      string
+		durations map[int]time.Duration
+		want      []time.Time
+	}{
+		{
+			name: "rolls over to the next day",
+			want: []time.Time{day(12), day(13), day(14)},
+		},
+		{
+			// The first check runs for 25 hours and overruns the 13th's
+			// slot, which is skipped rather than run late.
+			name:      "overrun slot is skipped",
+			durations: map[int]time.Duration{0: 25 * time.Hour},
+			want:      []time.Time{day(12), day(14), day(15)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clk := newFakeClock(start)
+			ctx, check, runs := recordChecks(clk, len(tt.want), tt.durations)
+			scheduleLoop(ctx, clk, target, check)
+			assertTimes(t, *runs, tt.want)
+		})
+	}
+}
+
+// TestScheduleLoopAcrossDST verifies the daily run keeps its wall-clock time
+// through a 23-hour day.
+func TestScheduleLoopAcrossDST(t *testing.T) {
+	oslo, err := time.LoadLocation("Europe/Oslo")
+	if err != nil {
+		t.Skipf("tzdata not available: %v", err)
+	}
+	target := time.Date(2026, time.March, 1, 23, 0, 0, 0, oslo)
+	clk := newFakeClock(time.Date(2026, time.March, 28, 12, 0, 0, 0, oslo))
+	ctx, check, runs := recordChecks(clk, 3, nil)
+
+	scheduleLoop(ctx, clk, target, check)
+
+	at := func(d int) time.Time { return time.Date(2026, time.March, d, 23, 0, 0, 0, oslo) }
+	assertTimes(t, *runs, []time.Time{at(28), at(29), at(30)})
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// waitInitialDelay waits d before loop mode's first run (--initial-delay),
+// so deploying repull does not set off a fleet-wide check straight away.
+// Returns false if ctx is done first. A zero delay returns at once.
+func waitInitialDelay(ctx context.Context, clk clock, d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+	log.Printf("[INFO] Waiting %s before the first check (--initial-delay)", d)
+	select {
+	case <-clk.After(d):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// shutdownContext is done once SIGINT or SIGTERM arrives. The returned stop
+// restores the default signal behavior, so it should be called as soon as
+// the wait it guards is over.
+func shutdownContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWaitInitialDelay(t *testing.T) {
+	if !waitInitialDelay(t.Context(), realClock{}, 0) {
+		t.Error("waitInitialDelay(0) = false, want true")
+	}
+	if !waitInitialDelay(t.Context(), realClock{}, time.Millisecond) {
+		t.Error("waitInitialDelay(1ms) = false, want true")
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	start := time.Now()
+	if waitInitialDelay(ctx, realClock{}, time.Hour) {
+		t.Error("waitInitialDelay() on a cancelled context = true, want false")
+	}
+	if time.Since(start) > time.Second {
+		t.Error("waitInitialDelay() did not return promptly on cancellation")
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fanuelsen/repull/internal/docker"
+	"github.com/fanuelsen/repull/internal/notify"
+	"github.com/fanuelsen/repull/internal/sanitize"
+	"github.com/fanuelsen/repull/internal/updater"
+)
+
+// doctorReport collects the results of --doctor checks and prints each one
+// as it completes.
+type doctorReport struct {
+	failed bool
+}
+
+func (r *doctorReport) pass(check, format string, args ...any) {
+	fmt.Printf("[PASS] %s: %s\n", check, fmt.Sprintf(format, args...))
+}
+
+func (r *doctorReport) fail(check, format string, args ...any) {
+	r.failed = true
+	fmt.Printf("[FAIL] %s: %s\n", check, fmt.Sprintf(format, args...))
+}
+
+// info reports a finding that is worth knowing but not an error, such as a
+// host binary not running in a container.
+func (r *doctorReport) info(check, format string, args ...any) {
+	fmt.Printf("[INFO] %s: %s\n", check, fmt.Sprintf(format, args...))
+}
+
+// runDoctor checks the environment repull needs — Docker connectivity and API
+// version, self-detection, opted-in containers, and notifier reachability —
+// and prints a pass/fail report to stdout. Nothing is pulled, recreated or
+// sent. Returns the process exit code: non-zero if any check failed.
+func runDoctor() int {
+	r := &doctorReport{}
+
+	notifier, err := notify.NewDiscordNotifier(*discordWebhook)
+	switch {
+	case err != nil:
+		r.fail("Discord", "%v", err)
+	case notifier == nil:
+		r.info("Discord", "not configured")
+	default:
+		if err := notifier.Check(); err != nil {
+			r.fail("Discord", "webhook unreachable: %v", err)
+		} else {
+			r.pass("Discord", "webhook reachable")
+		}
+	}
+
+	cli, err := docker.NewClient()
+	if err != nil {
+		r.fail("Docker", "cannot connect: %v", err)
+		return r.exitCode()
+	}
+	defer cli.Close()
+	r.pass("Docker", "connected to %s", cli.DaemonHost())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	if v, err := cli.ServerVersion(ctx); err != nil {
+		r.fail("API version", "%v", err)
+	} else {
+		r.pass("API version", "daemon %s (API %s), client negotiated API %s", v.Version, v.APIVersion, cli.ClientVersion())
+	}
+
+	containers, err := docker.ListRunningContainers(ctx, cli)
+	if err != nil {
+		r.fail("Containers", "cannot list: %v", err)
+		return r.exitCode()
+	}
+
+	if self, ok := updater.FindSelf(containers); ok {
+		r.pass("Self-detection", "running in container %s (%s)", sanitize.String(strings.TrimPrefix(self.Name, "/")), docker.ShortID(self.ID))
+	} else {
+		r.info("Self-detection", "not running in a detectable container (fine for a host binary; self-update needs it)")
+	}
+
+	optedIn := updater.FilterOptedInContainers(containers)
+	if len(optedIn) == 0 {
+		r.fail("Opted-in", "none of %d running container(s) has %s=true", len(containers), updater.EnableLabel)
+	} else {
+		names := make([]string, 0, len(optedIn))
+		for _, c := range optedIn {
+			names = append(names, sanitize.String(strings.TrimPrefix(c.Name, "/")))
+		}
+		r.pass("Opted-in", "%d container(s): %s", len(optedIn), strings.Join(names, ", "))
+	}
+
+	return r.exitCode()
+}
+
+// exitCode prints the summary line and returns the process exit code.
+func (r *doctorReport) exitCode() int {
+	if r.failed {
+		fmt.Println("Some checks failed.")
+		return 1
+	}
+	fmt.Println("All checks passed.")
+	return 0
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/docker/docker/api/types/registry"
+	"github.com/fanuelsen/repull/internal/docker"
+	"github.com/fanuelsen/repull/internal/ecr"
+	"github.com/fanuelsen/repull/internal/sanitize"
+)
+
+// setupECR makes pulls from ECR registries use a freshly fetched token
+// instead of config.json. A failed token fetch is logged and the pull falls
+// back to config.json, so one bad region does not stop unrelated updates.
+func setupECR(region string) error {
+	creds, err := ecr.CredentialsFromEnv()
+	if err != nil {
+		return err
+	}
+	provider := ecr.NewProvider(creds, region)
+	docker.SetCredentialSource(func(domain string) (registry.AuthConfig, bool) {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		user, pass, ok, err := provider.Auth(ctx, domain)
+		if !ok {
+			return registry.AuthConfig{}, false
+		}
+		if err != nil {
+			log.Printf("[WARN] Failed to get ECR token for %s: %s", sanitize.String(domain), sanitize.String(err.Error()))
+			return registry.AuthConfig{}, false
+		}
+		return registry.AuthConfig{ServerAddress: domain, Username: user, Password: pass}, true
+	})
+	return nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"maps"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/client"
+	"github.com/fanuelsen/repull/internal/docker"
+	"github.com/fanuelsen/repull/internal/sanitize"
+	"github.com/fanuelsen/repull/internal/updater"
+)
+
+// runEnable opts the named container in to automatic updates (--enable).
+// Returns the process exit code.
+func runEnable(name string) int {
+	cli, err := docker.NewClient()
+	if err != nil {
+		log.Printf("[ERROR] Failed to create Docker client: %v", err)
+		return 1
+	}
+	defer cli.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+	if err := enableContainer(ctx, cli, name, *dryRun); err != nil {
+		log.Printf("[ERROR] --enable %s: %s", sanitize.String(name), sanitize.String(err.Error()))
+		return 1
+	}
+	return 0
+}
+
+// enableContainer adds the io.repull.enable=true label to an existing
+// container, e.g. one started with a plain `docker run`. Docker cannot
+// change the labels of a container, so it is recreated like an update:
+// same config and image, plus the label. A container that already has the
+// label is left alone.
+func enableContainer(ctx context.Context, cli *client.Client, name string, dryRun bool) error {
+	c, err := cli.ContainerInspect(ctx, name)
+	if err != nil {
+		return err
+	}
+	if c.ContainerJSONBase == nil || c.Config == nil {
+		return fmt.Errorf("incomplete inspect data")
+	}
+	containerName := strings.TrimPrefix(c.Name, "/")
+	if c.Config.Labels[updater.EnableLabel] == "true" {
+		log.Printf("[INFO] %s already has %s=true, nothing to do", sanitize.String(containerName), updater.EnableLabel)
+		return nil
+	}
+
+	// The replacement is created from the tag, which may have moved on
+	// since the container started.
+	if id, err := docker.GetImageID(ctx, cli, c.Config.Image); err == nil && id != c.Image {
+		log.Printf("[WARN] %s now points to another local image than %s runs; the recreated container will run %s", sanitize.String(c.Config.Image), sanitize.String(containerName), docker.ShortID(strings.TrimPrefix(id, "sha256:")))
+	}
+
+	if dryRun {
+		log.Printf("[DRY-RUN] Would recreate %s with %s=true", sanitize.String(containerName), updater.EnableLabel)
+		return nil
+	}
+	log.Printf("[WARN] Recreating %s to add %s=true: Docker cannot add labels in place, so the container is stopped, removed and started again", sanitize.String(containerName), updater.EnableLabel)
+
+	// The labels map is shared with the inspect response; copy it.
+	labels := maps.Clone(c.Config.Labels)
+	if labels == nil {
+		labels = make(map[string]string, 1)
+	}
+	labels[updater.EnableLabel] = "true"
+	config := *c.Config
+	config.Labels = labels
+	c.Config = &config
+
+	newID, err := docker.RecreateContainer(ctx, cli, c, make(docker.RecreatedContainers), docker.RecreateOptions{})
+	if err != nil {
+		return err
+	}
+	log.Printf("[INFO] %s is now managed by repull (new container %s)", sanitize.String(containerName), docker.ShortID(newID))
+	return nil
+}
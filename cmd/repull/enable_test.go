@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+)
+
+// fakeDaemon serves handler as a Docker daemon and returns a client for it.
+func fakeDaemon(t *testing.T, handler http.HandlerFunc) *client.Client {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	cli, err := client.NewClientWithOpts(client.WithHost("tcp://"+strings.TrimPrefix(srv.URL, "http://")), client.WithVersion("1.47"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cli.Close() })
+	return cli
+}
+
+// TestEnableContainerAddsLabel verifies --enable recreates the container
+// with io.repull.enable=true and keeps its other labels.
+func TestEnableContainerAddsLabel(t *testing.T) {
+	var mu sync.Mutex
+	var created *container.Config
+	cli := fakeDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/containers/web/json"):
+			json.NewEncoder(w).Encode(container.InspectResponse{
+				ContainerJSONBase: &container.ContainerJSONBase{
+					ID:         "abcdef1234567890",
+					Name:       "/web",
+					Image:      "sha256:current",
+					HostConfig: &container.HostConfig{NetworkMode: "bridge"},
+				},
+				Config: &container.Config{Image: "nginx:latest", Labels: map[string]string{"team": "web"}},
+			})
+		case r.Method == http.MethodGet && strings.Contains(r.URL.Path, "/images/"):
+			w.Write([]byte(`{"Id":"sha256:current"}`))
+		case strings.HasSuffix(r.URL.Path, "/containers/create"):
+			var body struct {
+				container.Config
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			mu.Lock()
+			created = &body.Config
+			mu.Unlock()
+			w.Write([]byte(`{"Id":"fedcba0987654321"}`))
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+
+	if err := enableContainer(t.Context(), cli, "web", false); err != nil {
+		t.Fatalf("enableContainer() error = %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if created == nil {
+		t.Fatal("container was not recreated")
+	}
+	if created.Labels["io.repull.enable"] != "true" || created.Labels["team"] != "web" {
+		t.Errorf("recreated labels = %v, want io.repull.enable=true and team=web", created.Labels)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/fanuelsen/repull/internal/sanitize"
+	"github.com/fanuelsen/repull/internal/updater"
+)
+
+// planEntry is one group a run would update.
+type planEntry struct {
+	group      string
+	image      string
+	containers []string
+}
+
+// stdinIsTerminal reports whether stdin is attached to a terminal. Prompting
+// only makes sense there; piped or detached runs behave non-interactively.
+func stdinIsTerminal() bool {
+	fi, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+// confirm prints the plan to out and asks for confirmation on in. Anything
+// but an explicit yes — including EOF — declines.
+func confirm(in io.Reader, out io.Writer, plan []planEntry) bool {
+	fmt.Fprintln(out, "The following services will be recreated:")
+	for _, p := range plan {
+		fmt.Fprintf(out, "  %s (%s): %s\n", sanitize.String(p.group), sanitize.String(p.image), sanitize.String(strings.Join(p.containers, ", ")))
+	}
+	fmt.Fprint(out, "Proceed? [y/N] ")
+
+	answer, _ := bufio.NewReader(in).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+// runInteractive computes the plan with a dry run, asks for confirmation,
+// and then updates only the planned groups. The dry run already pulled the
+// images, so the real run finds them local.
+func runInteractive(ctx context.Context, cli *client.Client, groups map[string][]container.InspectResponse, opts updater.Options) error {
+	var plan []planEntry
+	planOpts := opts
+	planOpts.DryRun = true
+	planOpts.Planned = func(groupKey, imageName, latestID string, outdated []container.InspectResponse) {
+		entry := planEntry{group: groupKey, image: imageName}
+		for _, c := range outdated {
+			entry.containers = append(entry.containers, strings.TrimPrefix(c.Name, "/"))
+		}
+		plan = append(plan, entry)
+	}
+	if err := updater.UpdateGroups(ctx, cli, groups, planOpts); err != nil {
+		return err
+	}
+
+	if len(plan) == 0 {
+		fmt.Println("Nothing to update.")
+		return nil
+	}
+	if !confirm(os.Stdin, os.Stdout, plan) {
+		fmt.Println("Aborted, nothing was changed.")
+		return nil
+	}
+
+	opts.Groups = make(map[string]bool)
+	for _, p := range plan {
+		opts.Groups[p.group] = true
+	}
+	return updater.UpdateGroups(ctx, cli, groups, opts)
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestConfirm(t *testing.T) {
+	plan := []planEntry{{group: "app:web", image: "app:latest", containers: []string{"app-web-1"}}}
+
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{input: "y\n", want: true},
+		{input: "Y\n", want: true},
+		{input: "yes\n", want: true},
+		{input: " yes \n", want: true},
+		{input: "\n", want: false},
+		{input: "n\n", want: false},
+		{input: "yep\n", want: false},
+		{input: "", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.TrimSpace(tt.input), func(t *testing.T) {
+			var out bytes.Buffer
+			if got := confirm(strings.NewReader(tt.input), &out, plan); got != tt.want {
+				t.Errorf("confirm(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+			if !strings.Contains(out.String(), "app:web") || !strings.Contains(out.String(), "Proceed? [y/N]") {
+				t.Errorf("confirm() output missing plan or prompt: %q", out.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// intervalWindow is one entry of an --interval-schedule spec: between start
+// and end (minutes since midnight) the loop runs every interval.
+type intervalWindow struct {
+	start    int
+	end      int
+	interval time.Duration
+}
+
+// contains reports whether minute (minutes since midnight) falls inside the
+// window. The start is inclusive, the end exclusive; a window whose end is
+// before its start crosses midnight, and one whose start equals its end
+// covers the whole day.
+func (w intervalWindow) contains(minute int) bool {
+	switch {
+	case w.start == w.end:
+		return true
+	case w.start < w.end:
+		return minute >= w.start && minute < w.end
+	default:
+		return minute >= w.start || minute < w.end
+	}
+}
+
+// parseIntervalSchedule parses a spec like "08:00-18:00=300,18:00-08:00=3600"
+// into windows. Each interval must be at least 60 seconds, the same floor as
+// --interval.
+func parseIntervalSchedule(spec string) ([]intervalWindow, error) {
+	var windows []intervalWindow
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		span, secs, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("%q: missing =SECONDS", entry)
+		}
+		from, to, ok := strings.Cut(span, "-")
+		if !ok {
+			return nil, fmt.Errorf("%q: window must be HH:MM-HH:MM", entry)
+		}
+		start, err := parseScheduleTime(from)
+		if err != nil {
+			return nil, fmt.Errorf("%q: start: %w", entry, err)
+		}
+		end, err := parseScheduleTime(to)
+		if err != nil {
+			return nil, fmt.Errorf("%q: end: %w", entry, err)
+		}
+		n, err := strconv.Atoi(secs)
+		if err != nil || n < 60 {
+			return nil, fmt.Errorf("%q: interval must be at least 60 seconds", entry)
+		}
+		windows = append(windows, intervalWindow{
+			start:    start.Hour()*60 + start.Minute(),
+			end:      end.Hour()*60 + end.Minute(),
+			interval: time.Duration(n) * time.Second,
+		})
+	}
+	return windows, nil
+}
+
+// coversDay reports whether every minute of the day falls in some window.
+func coversDay(windows []intervalWindow) bool {
+	for m := 0; m < 24*60; m++ {
+		if _, ok := windowInterval(windows, m); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// windowInterval returns the interval of the first window containing minute.
+func windowInterval(windows []intervalWindow, minute int) (time.Duration, bool) {
+	for _, w := range windows {
+		if w.contains(minute) {
+			return w.interval, true
+		}
+	}
+	return 0, false
+}
+
+// loopInterval returns the loop interval in effect at now: the matching
+// --interval-schedule window, falling back to the plain interval for times
+// no window covers.
+func loopInterval(windows []intervalWindow, fallback time.Duration, now time.Time) time.Duration {
+	if d, ok := windowInterval(windows, now.Hour()*60+now.Minute()); ok {
+		return d
+	}
+	return fallback
+}
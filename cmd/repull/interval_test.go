@@ -0,0 +1,80 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseIntervalSchedule(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		wantErr bool
+		want    int
+	}{
+		{name: "two windows", spec: "08:00-18:00=300,18:00-08:00=3600", want: 2},
+		{name: "spaces tolerated", spec: "08:00-18:00=300, 18:00-08:00=3600", want: 2},
+		{name: "missing seconds", spec: "08:00-18:00", wantErr: true},
+		{name: "missing dash", spec: "08:00=300", wantErr: true},
+		{name: "bad time", spec: "25:00-18:00=300", wantErr: true},
+		{name: "interval too short", spec: "08:00-18:00=30", wantErr: true},
+		{name: "interval not a number", spec: "08:00-18:00=fast", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseIntervalSchedule(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseIntervalSchedule(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			}
+			if err == nil && len(got) != tt.want {
+				t.Errorf("parseIntervalSchedule(%q) = %d windows, want %d", tt.spec, len(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestLoopInterval(t *testing.T) {
+	windows, err := parseIntervalSchedule("08:00-18:00=300,18:00-08:00=3600")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(hour, minute int) time.Time {
+		return time.Date(2026, time.June, 11, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want time.Duration
+	}{
+		{name: "business hours", now: at(12, 0), want: 300 * time.Second},
+		{name: "window start is inclusive", now: at(8, 0), want: 300 * time.Second},
+		{name: "window end is exclusive", now: at(18, 0), want: time.Hour},
+		{name: "before midnight", now: at(23, 59), want: time.Hour},
+		{name: "after midnight", now: at(0, 30), want: time.Hour},
+		{name: "just before morning", now: at(7, 59), want: time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := loopInterval(windows, time.Minute, tt.now); got != tt.want {
+				t.Errorf("loopInterval(%s) = %s, want %s", tt.now.Format("15:04"), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoopIntervalFallback(t *testing.T) {
+	windows, err := parseIntervalSchedule("08:00-18:00=300")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if coversDay(windows) {
+		t.Error("coversDay() = true, want false for a daytime-only window")
+	}
+	now := time.Date(2026, time.June, 11, 20, 0, 0, 0, time.UTC)
+	if got := loopInterval(windows, 15*time.Minute, now); got != 15*time.Minute {
+		t.Errorf("loopInterval() = %s, want fallback 15m", got)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"strings"
+)
+
+// listFlag is a repeatable flag whose values may also be comma-separated:
+// --exclude-image a --exclude-image b,c gives [a b c]. Values from the
+// environment default are replaced, not extended, once the flag is given.
+type listFlag struct {
+	values []string
+	set    bool
+}
+
+// newListFlag registers a listFlag with the default taken from a
+// comma-separated environment value.
+func newListFlag(name, env, usage string) *listFlag {
+	l := &listFlag{values: splitList(env)}
+	flag.Var(l, name, usage)
+	return l
+}
+
+func (l *listFlag) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(l.values, ",")
+}
+
+func (l *listFlag) Set(v string) error {
+	if !l.set {
+		l.values = nil
+		l.set = true
+	}
+	l.values = append(l.values, splitList(v)...)
+	return nil
+}
+
+// splitList splits a comma-separated list, dropping empty entries.
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"flag"
+	"slices"
+	"testing"
+)
+
+func TestListFlag(t *testing.T) {
+	tests := []struct {
+		name string
+		env  string
+		args []string
+		want []string
+	}{
+		{name: "env default", env: "postgres:*, redis:*", want: []string{"postgres:*", "redis:*"}},
+		{name: "repeated and comma-separated", args: []string{"-x", "a", "-x", "b,c"}, want: []string{"a", "b", "c"}},
+		{name: "flag replaces env", env: "postgres:*", args: []string{"-x", "mysql:*"}, want: []string{"mysql:*"}},
+		{name: "empty", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			l := &listFlag{values: splitList(tt.env)}
+			fs.Var(l, "x", "")
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(l.values, tt.want) {
+				t.Errorf("values = %v, want %v", l.values, tt.want)
+			}
+		})
+	}
+}
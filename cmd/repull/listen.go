@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/client"
+	"github.com/fanuelsen/repull/internal/push"
+	"github.com/fanuelsen/repull/internal/updater"
+)
+
+// runQueue collects the run requests that arrive while a run is in
+// progress — images announced by webhooks, and full checks from a poller
+// running alongside — so a burst of them becomes one follow-up run.
+type runQueue struct {
+	mu      sync.Mutex
+	pending map[string]bool
+	full    bool
+	ready   chan struct{}
+}
+
+func newRunQueue() *runQueue {
+	return &runQueue{pending: make(map[string]bool), ready: make(chan struct{}, 1)}
+}
+
+// add queues refs and wakes the runner. It never blocks.
+func (q *runQueue) add(refs []string) {
+	q.mu.Lock()
+	for _, ref := range refs {
+		q.pending[ref] = true
+	}
+	q.mu.Unlock()
+	q.wake()
+}
+
+// addFull queues a check of every group and wakes the runner. It never
+// blocks.
+func (q *runQueue) addFull() {
+	q.mu.Lock()
+	q.full = true
+	q.mu.Unlock()
+	q.wake()
+}
+
+func (q *runQueue) wake() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// take returns the queued images, sorted, and empties the queue. full is
+// true if a full check was queued; it covers every queued image, so refs
+// is then nil.
+func (q *runQueue) take() (refs []string, full bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	full = q.full
+	q.full = false
+	if !full {
+		refs = make([]string, 0, len(q.pending))
+		for ref := range q.pending {
+			refs = append(refs, ref)
+		}
+		sort.Strings(refs)
+	}
+	clear(q.pending)
+	return refs, full
+}
+
+// runQueued performs the runs q asks for, one at a time, until ctx is done.
+// run gets the images to check, or nil for a full check. Because there is
+// a single runner, a webhook and a poll arriving close together never
+// recreate the same containers twice: if both are queued before a run
+// starts, they become one full check.
+func runQueued(ctx context.Context, q *runQueue, run func(images []string)) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.ready:
+		}
+		refs, full := q.take()
+		if !full && len(refs) == 0 {
+			continue
+		}
+		run(refs)
+	}
+}
+
+// runWebhookListener serves registry push webhooks on addr and runs an
+// update of the matching groups for every push (see push.Handler). Runs
+// never overlap: pushes that arrive during a run are handled right after
+// it. With initial set, a full check runs first to catch pushes made while
+// repull was down.
+//
+// If poll is not nil it runs alongside the listener as a safety net for
+// missed webhooks: every check it makes is queued as a full check on the
+// same runner, and folds in any pushes queued with it.
+func runWebhookListener(cli *client.Client, opts updater.Options, addr, secret string, initial bool, poll func(ctx context.Context, check func())) {
+	ctx, stop := shutdownContext()
+	defer stop()
+
+	queue := newRunQueue()
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           push.Handler(secret, queue.add),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("[ERROR] Webhook listener: %v", err)
+		}
+	}()
+
+	if initial {
+		queue.addFull()
+	}
+	if poll != nil {
+		go poll(ctx, queue.addFull)
+	}
+
+	runQueued(ctx, queue, func(images []string) {
+		run := opts
+		if images == nil {
+			log.Println("[INFO] Running full check...")
+		} else {
+			run.Images = images
+			log.Printf("[INFO] Running check for %d pushed image(s)...", len(images))
+		}
+		if err := runOnce(cli, run); err != nil {
+			log.Printf("[ERROR] Update failed: %v", err)
+		}
+		log.Println("[INFO] Check complete, waiting for webhooks...")
+	})
+
+	log.Println("[INFO] Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	srv.Shutdown(shutdownCtx)
+	cancel()
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRunQueueCoalesces(t *testing.T) {
+	q := newRunQueue()
+	q.add([]string{"docker.io/team/app:1.2"})
+	q.add([]string{"harbor.example.com/library/api:latest", "docker.io/team/app:1.2"})
+
+	select {
+	case <-q.ready:
+	default:
+		t.Fatal("queue not ready after add")
+	}
+	select {
+	case <-q.ready:
+		t.Fatal("two wake-ups for one batch of pushes")
+	default:
+	}
+
+	want := []string{"docker.io/team/app:1.2", "harbor.example.com/library/api:latest"}
+	if got, full := q.take(); !reflect.DeepEqual(got, want) || full {
+		t.Errorf("take() = %v, %v, want %v, false", got, full, want)
+	}
+	if got, full := q.take(); len(got) != 0 || full {
+		t.Errorf("take() after take() = %v, %v, want empty", got, full)
+	}
+}
+
+// TestRunQueuedDedupsWebhookAndTick verifies a webhook and a poller tick
+// arriving close together cause a single full run, not a push run followed
+// by a full run that would recreate the same containers again.
+func TestRunQueuedDedupsWebhookAndTick(t *testing.T) {
+	q := newRunQueue()
+	q.add([]string{"docker.io/team/app:1.2"}) // webhook
+	q.addFull()                               // poller tick
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var runs [][]string
+	done := make(chan struct{})
+	go func() {
+		runQueued(ctx, q, func(images []string) {
+			runs = append(runs, images)
+			cancel()
+		})
+		close(done)
+	}()
+	<-done
+
+	if len(runs) != 1 || runs[0] != nil {
+		t.Errorf("runs = %v, want one full run", runs)
+	}
+	if refs, full := q.take(); len(refs) != 0 || full {
+		t.Errorf("queue after run = %v, %v, want empty", refs, full)
+	}
+}
+
+// TestRunQueuedNoOverlap verifies triggers arriving during a run wait for
+// it and are folded into one follow-up run.
+func TestRunQueuedNoOverlap(t *testing.T) {
+	q := newRunQueue()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var mu sync.Mutex
+	var runs [][]string
+	running, release := make(chan struct{}), make(chan struct{})
+	go runQueued(ctx, q, func(images []string) {
+		mu.Lock()
+		runs = append(runs, images)
+		n := len(runs)
+		mu.Unlock()
+		if n == 1 {
+			close(running)
+			<-release
+		}
+	})
+
+	q.add([]string{"docker.io/team/app:1.2"})
+	<-running
+	q.addFull()
+	q.add([]string{"docker.io/team/api:2.0"})
+	close(release)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		n := len(runs)
+		mu.Unlock()
+		if n >= 2 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond) // let any extra run show up
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := [][]string{{"docker.io/team/app:1.2"}, nil}
+	if !reflect.DeepEqual(runs, want) {
+		t.Errorf("runs = %v, want %v", runs, want)
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// errLocked is returned by acquireLock when another process holds the lock.
+var errLocked = errors.New("lock is held by another repull process")
+
+// lockHolder describes the process recorded in the lock file at path, for
+// the contention message; "" if the file names none.
+func lockHolder(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	if pid := strings.TrimSpace(string(data)); pid != "" {
+		return " (pid " + pid + ")"
+	}
+	return ""
+}
+
+// lockedError wraps errLocked with the lock file and its holder.
+func lockedError(path string) error {
+	return fmt.Errorf("%w: %s%s; is a cron or manual run still going?", errLocked, path, lockHolder(path))
+}
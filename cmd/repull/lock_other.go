@@ -0,0 +1,10 @@
+//go:build !unix
+
+package main
+
+import "errors"
+
+// acquireLock is not supported on this platform: flock is Unix-only.
+func acquireLock(path string, wait bool) (release func(), err error) {
+	return nil, errors.New("--lock-file is not supported on this platform")
+}
@@ -0,0 +1,76 @@
+//go:build unix
+
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAcquireLockContention(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "repull.lock")
+
+	release, err := acquireLock(path, false)
+	if err != nil {
+		t.Fatalf("acquireLock() error = %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if got := strings.TrimSpace(string(data)); got != strconv.Itoa(os.Getpid()) {
+		t.Errorf("lock file holds %q, want this process's PID", got)
+	}
+
+	// flock locks belong to the open file, so a second open contends even
+	// within one process, as a second repull would.
+	_, err = acquireLock(path, false)
+	if !errors.Is(err, errLocked) {
+		t.Fatalf("second acquireLock() error = %v, want errLocked", err)
+	}
+	if !strings.Contains(err.Error(), "pid "+strconv.Itoa(os.Getpid())) {
+		t.Errorf("error %q does not name the holder", err)
+	}
+
+	release()
+	release2, err := acquireLock(path, false)
+	if err != nil {
+		t.Fatalf("acquireLock() after release error = %v", err)
+	}
+	release2()
+}
+
+func TestAcquireLockWait(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "repull.lock")
+	release, err := acquireLock(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	acquired := make(chan error, 1)
+	go func() {
+		release2, err := acquireLock(path, true)
+		if err == nil {
+			release2()
+		}
+		acquired <- err
+	}()
+
+	select {
+	case err := <-acquired:
+		t.Fatalf("waiting acquireLock() returned %v while the lock was held", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	release()
+	select {
+	case err := <-acquired:
+		if err != nil {
+			t.Errorf("waiting acquireLock() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("waiting acquireLock() did not return after release")
+	}
+}
@@ -0,0 +1,50 @@
+//go:build unix
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// acquireLock takes an exclusive flock on path, creating the file if needed,
+// and records this process's PID in it. The lock is tied to the open file,
+// so the kernel releases it if the process dies; a stale file left behind
+// does not block the next run. If another process holds the lock,
+// acquireLock fails with errLocked, or with wait blocks until it is free.
+// The returned release unlocks it again.
+func acquireLock(path string, wait bool) (release func(), err error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("opening lock file: %w", err)
+	}
+
+	err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		if !wait {
+			f.Close()
+			return nil, lockedError(path)
+		}
+		log.Printf("[INFO] Waiting for %s, held by another repull process%s (--lock-wait)", path, lockHolder(path))
+		err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX)
+	}
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("locking %s: %w", path, err)
+	}
+
+	// The PID is informational only; failing to write it is harmless.
+	if err := f.Truncate(0); err == nil {
+		f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+
+	return func() {
+		f.Truncate(0)
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
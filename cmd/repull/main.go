@@ -5,15 +5,23 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"maps"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/client"
 	"github.com/fanuelsen/repull/internal/docker"
+	"github.com/fanuelsen/repull/internal/events"
 	"github.com/fanuelsen/repull/internal/notify"
+	"github.com/fanuelsen/repull/internal/registry"
+	"github.com/fanuelsen/repull/internal/state"
+	"github.com/fanuelsen/repull/internal/status"
 	"github.com/fanuelsen/repull/internal/updater"
+	"github.com/fanuelsen/repull/internal/useragent"
 )
 
 // version is set at build time via -ldflags.
@@ -24,12 +32,98 @@ var version = "dev"
 var (
 	interval       = flag.Int("interval", envInt("REPULL_INTERVAL"), "Run every N seconds (0 = single run)")
 	schedule       = flag.String("schedule", os.Getenv("REPULL_SCHEDULE"), "Run at specific time daily (HH:MM format, e.g., 23:00)")
+	initialDelay   = flag.Duration("initial-delay", envDuration("REPULL_INITIAL_DELAY"), "In loop mode, wait this long before the first check (e.g. 10m)")
+	noRunOnStart   = flag.Bool("no-run-on-start", envBool("REPULL_NO_RUN_ON_START"), "In loop mode, skip the check at startup and wait for the first interval")
+	intervalSched  = flag.String("interval-schedule", os.Getenv("REPULL_INTERVAL_SCHEDULE"), "Vary the loop interval by time of day (e.g., 08:00-18:00=300,18:00-08:00=3600)")
+	listenWebhook  = flag.String("listen-webhook", os.Getenv("REPULL_LISTEN_WEBHOOK"), "Update when a registry push webhook arrives on this address (e.g. :9000); combine with --interval or --schedule to also poll")
+	webhookSecret  = flag.String("webhook-secret", os.Getenv("REPULL_WEBHOOK_SECRET"), "Shared secret --listen-webhook requests must present (X-Repull-Secret header, Authorization header or ?secret=)")
+	webhookSecretF = flag.String("webhook-secret-file", os.Getenv("REPULL_WEBHOOK_SECRET_FILE"), "Read the --listen-webhook secret from this file (e.g. a mounted secret)")
 	dryRun         = flag.Bool("dry-run", envBool("REPULL_DRY_RUN"), "Show what would be updated without making changes")
+	maintenance    = flag.Bool("maintenance-mode", envBool("REPULL_MAINTENANCE_MODE"), "Like --dry-run, but also send an \"update available\" notification for every update it would make")
 	cleanup        = flag.Bool("cleanup", envBool("REPULL_CLEANUP"), "Remove the replaced image after a successful update")
+	pullOnly       = flag.Bool("pull-only", envBool("REPULL_PULL_ONLY"), "Pull new images but never recreate containers")
+	prefetch       = flag.Bool("prefetch", envBool("REPULL_PREFETCH"), "Pull new images without recreating and stage them in --state-file; the next regular run recreates from them without pulling")
+	onePerRun      = flag.Bool("one-per-run", envBool("REPULL_ONE_PER_RUN"), "Recreate at most one group per run; defer the rest to later runs")
+	noStart        = flag.Bool("no-start", envBool("REPULL_NO_START"), "Recreate outdated containers but leave the replacements stopped")
+	skipMissing    = flag.Bool("skip-missing-images", envBool("REPULL_SKIP_MISSING_IMAGES"), "Skip a group whose image tag was deleted upstream instead of failing the run")
+	summarize      = flag.Bool("summarize-unchanged", envBool("REPULL_SUMMARIZE_UNCHANGED"), "Log one summary line per run instead of a line per unchanged image")
+	debug          = flag.Bool("debug", envBool("REPULL_DEBUG"), "Log debug details, including per-image lines hidden by --summarize-unchanged")
+	digestLen      = flag.Int("digest-display-length", envIntDefault("REPULL_DIGEST_DISPLAY_LENGTH", updater.DefaultDigestLength), "Characters of an image digest shown in logs and notifications, including the sha256: prefix (0 = full digest)")
+	keepImages     = flag.Int("keep-images", envInt("REPULL_KEEP_IMAGES"), "Keep the N most recently deployed images per repository and remove older unused ones (0 = disabled)")
+	eventSocket    = flag.String("event-socket", os.Getenv("REPULL_EVENT_SOCKET"), "Stream JSON run events to clients of this Unix socket (e.g. /run/repull.sock)")
+	maxImageSize   = flag.String("max-image-size", os.Getenv("REPULL_MAX_IMAGE_SIZE"), "Skip images whose compressed size exceeds this (e.g. 2GB); checked against the registry before pulling")
+	minFreeDisk    = flag.String("min-free-disk", os.Getenv("REPULL_MIN_FREE_DISK"), "Skip pulls while the Docker data root has less than this free (e.g. 2GB; Linux, repull on the Docker host)")
+	parallelPulls  = flag.Int("max-parallel-pulls", envInt("REPULL_MAX_PARALLEL_PULLS"), "Pull up to N images at once before updating the groups one at a time (0 = pull as each group is reached)")
+	inspectConc    = flag.Int("inspect-concurrency", envIntDefault("REPULL_INSPECT_CONCURRENCY", docker.DefaultInspectConcurrency), "Inspect up to N opted-in containers at once when listing them at the start of a run")
+	checkBase      = flag.Bool("check-base-images", envBool("REPULL_CHECK_BASE_IMAGES"), "Warn when an image's OCI base image (org.opencontainers.image.base.*) has changed since it was built")
+	maxFailures    = flag.Int("max-consecutive-failures", envInt("REPULL_MAX_CONSECUTIVE_FAILURES"), "Halt a run and send an alert once this many groups failed in a row (0 = disabled)")
+	restartLoop    = flag.Int("restart-loop-threshold", envInt("REPULL_RESTART_LOOP_THRESHOLD"), "Skip containers restarted at least this many times and started within the last 10 minutes (0 = disabled)")
+	maxLoad        = flag.Float64("max-load", envFloat("REPULL_MAX_LOAD"), "Before each recreate, wait until the 1-minute load average is below this (Linux only; 0 = disabled)")
+	minAge         = flag.Duration("min-container-age", envDuration("REPULL_MIN_CONTAINER_AGE"), "Only recreate containers running for at least this long (e.g. 168h)")
+	stopTimeout    = flag.Duration("stop-timeout", envDuration("REPULL_STOP_TIMEOUT"), "Grace period for stopping containers on recreate that set no stop timeout of their own (e.g. 30s; 0 = Docker's default of 10s)")
+	selfStop       = flag.Int("self-stop-timeout", envInt("REPULL_SELF_STOP_TIMEOUT"), "Seconds a replaced repull instance gets to stop gracefully on self-update (0 = kill immediately)")
+	selfMaxTries   = flag.Int("self-update-max-attempts", envIntDefault("REPULL_SELF_UPDATE_MAX_ATTEMPTS", 3), "Skip updating a repull instance to an image after this many failed attempts within 24h, notifying once instead (0 = retry on every run)")
+	leftoverGrace  = flag.Duration("leftover-grace", envDurationDefault("REPULL_LEFTOVER_GRACE", 5*time.Minute), "At startup, only remove self-update leftovers that exited at least this long ago")
+	oldNameTmpl    = flag.String("old-name-template", envString("REPULL_OLD_NAME_TEMPLATE", docker.DefaultOldNameTemplate), "Go template for renamed old containers; fields: Name and ShortID (both required), Digest, Timestamp")
+	stateFile      = flag.String("state-file", os.Getenv("REPULL_STATE_FILE"), "Persist state (e.g. recreate times for io.repull.max-frequency) to this file")
+	lockFile       = flag.String("lock-file", os.Getenv("REPULL_LOCK_FILE"), "Hold an exclusive lock on this file during each run, so two repull processes never update at once (e.g. /run/repull.lock)")
+	lockWait       = flag.Bool("lock-wait", envBool("REPULL_LOCK_WAIT"), "With --lock-file, wait for another process's run to finish instead of failing")
+	ecrAuth        = flag.Bool("ecr-auth", envBool("REPULL_ECR_AUTH"), "Fetch fresh Amazon ECR tokens for *.dkr.ecr.*.amazonaws.com images using AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY")
+	ecrRegion      = flag.String("ecr-region", os.Getenv("REPULL_ECR_REGION"), "AWS region for ECR token requests (default: the region in each registry's hostname)")
+	userAgent      = flag.String("user-agent", os.Getenv("REPULL_USER_AGENT"), "User-Agent for repull's own HTTP requests (default repull/<version>)")
 	dockerHost     = flag.String("docker-host", "", "Docker daemon socket (default: from DOCKER_HOST env)")
 	discordWebhook = flag.String("discord-webhook", os.Getenv("REPULL_DISCORD_WEBHOOK"), "Discord webhook URL for notifications")
+	notifyDebounce = flag.Duration("notify-debounce", envDuration("REPULL_NOTIFY_DEBOUNCE"), "Coalesce update notifications per group until no update arrived for this long (e.g. 30m)")
+	notifySummary  = flag.Bool("notify-summary", envBool("REPULL_NOTIFY_SUMMARY"), "Send the updates and errors of a run to Discord as one summary at its end instead of a message each")
+	projectHooks   = flag.String("project-webhook", os.Getenv("REPULL_PROJECT_WEBHOOK"), "Route notifications per compose project to its own Discord webhook (e.g. myapp=https://...,other=https://...)")
+	discordFile    = flag.String("discord-webhook-file", os.Getenv("REPULL_DISCORD_WEBHOOK_FILE"), "Read the Discord webhook URL from this file (e.g. a mounted secret)")
+	notifyFile     = flag.String("notify-file", os.Getenv("REPULL_NOTIFY_FILE"), "Also append notifications as JSON lines to this file (e.g. for promtail or fluentd)")
+	discordSev     = flag.String("discord-severity", os.Getenv("REPULL_DISCORD_SEVERITY"), "Only send these severities to Discord webhooks: update, error, self-update or a list (default: all)")
+	notifyFileSev  = flag.String("notify-file-severity", os.Getenv("REPULL_NOTIFY_FILE_SEVERITY"), "Only write these severities to --notify-file: update, error, self-update or a list (default: all)")
+	smtpHost       = flag.String("smtp-host", os.Getenv("REPULL_SMTP_HOST"), "Also send notifications by email through this SMTP server; updates of one run go out as one digest")
+	smtpPort       = flag.Int("smtp-port", envIntDefault("REPULL_SMTP_PORT", 587), "SMTP server port; 465 uses implicit TLS, others STARTTLS when offered")
+	smtpUser       = flag.String("smtp-user", os.Getenv("REPULL_SMTP_USER"), "SMTP username (PLAIN or LOGIN authentication, over TLS only)")
+	smtpPass       = flag.String("smtp-pass", os.Getenv("REPULL_SMTP_PASS"), "SMTP password")
+	smtpFrom       = flag.String("smtp-from", os.Getenv("REPULL_SMTP_FROM"), "Sender address of notification emails")
+	smtpTo         = newListFlag("smtp-to", os.Getenv("REPULL_SMTP_TO"), "Recipients of notification emails (repeatable)")
+	emailSev       = flag.String("email-severity", os.Getenv("REPULL_EMAIL_SEVERITY"), "Only email these severities: update, error, self-update or a list (default: all)")
+	ntfyURL        = flag.String("ntfy-url", envString("REPULL_NTFY_URL", notify.DefaultNtfyURL), "ntfy server to publish push notifications to")
+	ntfyTopic      = flag.String("ntfy-topic", os.Getenv("REPULL_NTFY_TOPIC"), "Also send push notifications to this ntfy topic; errors at high priority")
+	ntfyToken      = flag.String("ntfy-token", os.Getenv("REPULL_NTFY_TOKEN"), "Access token for a protected ntfy topic")
+	ntfySev        = flag.String("ntfy-severity", os.Getenv("REPULL_NTFY_SEVERITY"), "Only publish these severities to ntfy: update, error, self-update or a list (default: all)")
+	tmplUpdate     = flag.String("template-update", os.Getenv("REPULL_TEMPLATE_UPDATE"), "Render update notifications with the Go template in this file (fields: .Service .Image .OldDigest .NewDigest .Notes)")
+	tmplError      = flag.String("template-error", os.Getenv("REPULL_TEMPLATE_ERROR"), "Render error notifications with the Go template in this file (fields: .Service .Error)")
+	tmplSummary    = flag.String("template-summary", os.Getenv("REPULL_TEMPLATE_SUMMARY"), "Render --notify-debounce summaries with the Go template in this file (update fields plus .Count)")
+	statusAddr     = flag.String("status-addr", os.Getenv("REPULL_STATUS_ADDR"), "Serve the last run and per-group results as JSON on GET /status at this address (e.g. :8080)")
+	pprofAddr      = flag.String("pprof-addr", os.Getenv("REPULL_PPROF_ADDR"), "Serve net/http/pprof profiles on /debug/pprof/ at this address (e.g. localhost:6060), for diagnosing slow runs")
+	kumaURL        = flag.String("kuma-url", os.Getenv("REPULL_KUMA_URL"), "Uptime Kuma push URL to report run health to (https://<host>/api/push/<token>)")
+	testNotify     = flag.Bool("test-notify", false, "Send a sample update and error notification to every configured backend, then exit")
+	channelFile    = flag.String("channel-file", os.Getenv("REPULL_CHANNEL_FILE"), "Pin image repositories to approved tags from this file (repository: tag per line); reread every run")
+	excludeImages  = newListFlag("exclude-image", os.Getenv("REPULL_EXCLUDE_IMAGE"), "Never update images matching these globs, regardless of labels (e.g. 'postgres:*,redis:*'; repeatable)")
+	networks       = newListFlag("network", os.Getenv("REPULL_NETWORK"), "Only update containers attached to one of these Docker networks (e.g. frontend-net; repeatable)")
+	onlyMutable    = flag.Bool("only-mutable-tags", envBool("REPULL_ONLY_MUTABLE_TAGS"), "Only update containers running a mutable tag (see --mutable-tags); skip pinned releases such as v1.2.3")
+	mutableTags    = newListFlag("mutable-tags", os.Getenv("REPULL_MUTABLE_TAGS"), "Tags --only-mutable-tags treats as mutable (default latest,stable,edge; repeatable)")
+	groupBy        = flag.String("group-by", envString("REPULL_GROUP_BY", "service"), "How to group containers for updates: service (compose project:service) or none (every container alone)")
+	missingNetwork = flag.String("on-missing-network", envString("REPULL_ON_MISSING_NETWORK", docker.MissingNetworkFail), "When a network a container was on is gone by the time its replacement joins it: fail (roll back), skip (leave it off) or recreate (with the captured driver and options)")
+	inventoryOut   = flag.String("inventory-out", "", "Write the opted-in containers (group, image, digest, repull labels, networks) to this JSON file and exit without updating")
+	planOut        = flag.String("plan-out", "", "With --dry-run, write the intended updates to this JSON plan file")
+	applyPlan      = flag.String("apply-plan", "", "Execute exactly the updates in this plan file (from --plan-out)")
+	promote        = flag.String("promote", "", "Roll out the rest of a canary group (e.g. myapp:web) whose canary runs the latest image, then exit")
+	interactive    = flag.Bool("interactive", false, "Show the update plan and ask for confirmation before recreating (single-run mode, terminal only)")
+	assumeYes      = flag.Bool("yes", false, "With --interactive, skip the confirmation prompt")
+	doctor         = flag.Bool("doctor", false, "Check Docker connectivity, self-detection, opted-in containers and notifiers, then exit")
+	enable         = flag.String("enable", "", "Opt an existing container in by recreating it with io.repull.enable=true, then exit")
 )
 
+// envString returns an environment variable for use as a flag default, or
+// def when it is unset.
+func envString(name, def string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return def
+}
+
 // envInt parses an integer environment variable for use as a flag default.
 // An unset variable yields 0; an invalid value is fatal — silently falling
 // back to 0 would turn a typo into an unintended single-run mode.
@@ -45,6 +139,20 @@ func envInt(name string) int {
 	return n
 }
 
+// envIntDefault parses an integer environment variable for use as a flag
+// default, returning def when it is unset. An invalid value is fatal.
+func envIntDefault(name string, def int) int {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Fatalf("[ERROR] Invalid %s %q: must be a number", name, v)
+	}
+	return n
+}
+
 // envBool parses a boolean environment variable for use as a flag default.
 // An unset variable yields false; any value strconv.ParseBool does not accept
 // (e.g. "yes", "on") is fatal — REPULL_DRY_RUN=True silently meaning false
@@ -61,9 +169,91 @@ func envBool(name string) bool {
 	return b
 }
 
+// envDuration parses a duration environment variable (e.g. "30m") for use as
+// a flag default. An unset variable yields 0; an invalid value is fatal.
+func envDuration(name string) time.Duration {
+	v := os.Getenv(name)
+	if v == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Fatalf("[ERROR] Invalid %s %q: must be a duration such as 30m", name, v)
+	}
+	return d
+}
+
+// envDurationDefault parses a duration environment variable for use as a
+// flag default, returning def when it is unset. An invalid value is fatal.
+func envDurationDefault(name string, def time.Duration) time.Duration {
+	if os.Getenv(name) == "" {
+		return def
+	}
+	return envDuration(name)
+}
+
+// envFloat parses a decimal environment variable (e.g. "4.0") for use as a
+// flag default. An unset variable yields 0; an invalid value is fatal.
+func envFloat(name string) float64 {
+	v := os.Getenv(name)
+	if v == "" {
+		return 0
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		log.Fatalf("[ERROR] Invalid %s %q: must be a number such as 4.0", name, v)
+	}
+	return f
+}
+
+// secretValue returns a secret given either directly or as a path to a file
+// holding it, as with Docker and Kubernetes secrets. Surrounding whitespace,
+// including the trailing newline most editors add, is trimmed from the file
+// contents. Setting both is an error — silently preferring one would leave
+// the user guessing which secret is actually in use.
+func secretValue(name, value, path string) (string, error) {
+	if path == "" {
+		return value, nil
+	}
+	if value != "" {
+		return "", fmt.Errorf("--%s and --%s-file are mutually exclusive", name, name)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("reading --%s-file: %w", name, err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
 func main() {
 	flag.Parse()
 
+	if *userAgent == "" {
+		*userAgent = "repull/" + version
+	}
+	useragent.Set(*userAgent)
+
+	// Resolve secrets given as files before anything uses them.
+	webhook, err := secretValue("discord-webhook", *discordWebhook, *discordFile)
+	if err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+	*discordWebhook = webhook
+	secret, err := secretValue("webhook-secret", *webhookSecret, *webhookSecretF)
+	if err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+	*webhookSecret = secret
+
+	// Set DOCKER_HOST if provided via flag
+	if *dockerHost != "" {
+		os.Setenv("DOCKER_HOST", *dockerHost)
+	}
+
+	// Labels on repull's own container are the lowest-precedence source,
+	// below flags and environment; read them before validating.
+	applySelfLabels()
+
 	// Validate: interval and schedule are mutually exclusive
 	if *interval > 0 && *schedule != "" {
 		log.Fatal("[ERROR] Cannot use --interval and --schedule together")
@@ -76,6 +266,13 @@ func main() {
 		log.Fatal("[ERROR] --interval must be at least 60 seconds (or 0 for a single run)")
 	}
 
+	if *webhookSecret != "" && *listenWebhook == "" {
+		log.Fatal("[ERROR] --webhook-secret requires --listen-webhook")
+	}
+	if len(mutableTags.values) > 0 && !*onlyMutable {
+		log.Fatal("[ERROR] --mutable-tags requires --only-mutable-tags")
+	}
+
 	// Validate the schedule up front so a typo fails fast, before any Docker
 	// connection or leftover cleanup happens.
 	var targetTime time.Time
@@ -87,11 +284,149 @@ func main() {
 		}
 	}
 
+	// Validate the interval schedule. Times no window covers fall back to
+	// --interval, so without one the windows must cover the whole day.
+	var windows []intervalWindow
+	if *intervalSched != "" {
+		if *schedule != "" {
+			log.Fatal("[ERROR] Cannot use --interval-schedule and --schedule together")
+		}
+		var err error
+		windows, err = parseIntervalSchedule(*intervalSched)
+		if err != nil {
+			log.Fatalf("[ERROR] Invalid interval schedule: %v", err)
+		}
+		if *interval == 0 && !coversDay(windows) {
+			log.Fatal("[ERROR] --interval-schedule must cover the whole day unless --interval is set as a fallback")
+		}
+	}
+
+	maxSize, err := parseSize(*maxImageSize)
+	if err != nil {
+		log.Fatalf("[ERROR] Invalid --max-image-size: %v", err)
+	}
+	minFree, err := parseSize(*minFreeDisk)
+	if err != nil {
+		log.Fatalf("[ERROR] Invalid --min-free-disk: %v", err)
+	}
+	if *parallelPulls < 0 {
+		log.Fatal("[ERROR] --max-parallel-pulls must not be negative")
+	}
+	if *parallelPulls > 1 && minFree > 0 {
+		log.Fatal("[ERROR] --max-parallel-pulls cannot be combined with --min-free-disk, which checks the free space before each pull")
+	}
+
+	if *groupBy != "service" && *groupBy != "none" {
+		log.Fatalf("[ERROR] Invalid --group-by %q: must be service or none", *groupBy)
+	}
+
+	if err := docker.SetOldNameTemplate(*oldNameTmpl); err != nil {
+		log.Fatalf("[ERROR] --old-name-template: %v", err)
+	}
+	if *keepImages < 0 {
+		log.Fatal("[ERROR] --keep-images must not be negative")
+	}
+	if *keepImages > 0 && *cleanup {
+		log.Fatal("[ERROR] --keep-images and --cleanup cannot be combined: pick a retention window or immediate removal")
+	}
+	// A single run without a state file forgets the deployment history on
+	// exit, so the retention window could never span more than one image.
+	if *keepImages > 0 && *stateFile == "" && *listenWebhook == "" && *schedule == "" && *intervalSched == "" && *interval <= 0 {
+		log.Fatal("[ERROR] --keep-images needs --state-file in single-run mode, where the deployment history is kept between runs")
+	}
+	if *maxLoad < 0 {
+		log.Fatal("[ERROR] --max-load must not be negative")
+	}
+	if *leftoverGrace < 0 {
+		log.Fatal("[ERROR] --leftover-grace must not be negative")
+	}
+	if *maxFailures < 0 {
+		log.Fatal("[ERROR] --max-consecutive-failures must not be negative")
+	}
+	if *selfStop < 0 {
+		log.Fatal("[ERROR] --self-stop-timeout must not be negative")
+	}
+	if *selfMaxTries < 0 {
+		log.Fatal("[ERROR] --self-update-max-attempts must not be negative")
+	}
+	// A prefetch is a pull-only run that also records what it pulled.
+	if *prefetch {
+		if *stateFile == "" {
+			log.Fatal("[ERROR] --prefetch requires --state-file, where the staged images are recorded for the next regular run")
+		}
+		*pullOnly = true
+	}
+	if *pullOnly && *noStart {
+		log.Fatal("[ERROR] --pull-only and --no-start cannot be combined: pull-only never recreates containers")
+	}
+	if *stopTimeout < 0 {
+		log.Fatal("[ERROR] --stop-timeout must not be negative")
+	}
+	docker.SetStopTimeout(*stopTimeout)
+	if *inspectConc < 1 {
+		log.Fatal("[ERROR] --inspect-concurrency must be at least 1")
+	}
+	docker.SetInspectConcurrency(*inspectConc)
+	if *digestLen < 0 {
+		log.Fatal("[ERROR] --digest-display-length must not be negative")
+	}
+	updater.SetDigestLength(*digestLen)
+	if err := docker.SetMissingNetworkPolicy(*missingNetwork); err != nil {
+		log.Fatalf("[ERROR] --on-missing-network: %v", err)
+	}
+	docker.SetVersion(version)
+	if *ecrAuth {
+		if err := setupECR(*ecrRegion); err != nil {
+			log.Fatalf("[ERROR] --ecr-auth: %v", err)
+		}
+	} else if *ecrRegion != "" {
+		log.Fatal("[ERROR] --ecr-region requires --ecr-auth")
+	}
+	if *interactive && *pullOnly {
+		log.Fatal("[ERROR] --interactive and --pull-only cannot be combined: pull-only never recreates anything to confirm")
+	}
+	if *lockWait && *lockFile == "" {
+		log.Fatal("[ERROR] --lock-wait requires --lock-file")
+	}
+	// Maintenance mode is a dry run that reports through the notifiers.
+	if *maintenance {
+		*dryRun = true
+	}
+	if *planOut != "" && !*dryRun {
+		log.Fatal("[ERROR] --plan-out requires --dry-run")
+	}
+	if *applyPlan != "" && (*dryRun || *pullOnly || *interactive) {
+		log.Fatal("[ERROR] --apply-plan cannot be combined with --dry-run, --pull-only or --interactive")
+	}
+	if *promote != "" && (*stateFile == "" || *pullOnly || *applyPlan != "") {
+		log.Fatal("[ERROR] --promote needs --state-file and cannot be combined with --pull-only or --apply-plan")
+	}
+	if (*planOut != "" || *applyPlan != "" || *inventoryOut != "" || *promote != "") && (*interval > 0 || *schedule != "" || *intervalSched != "" || *listenWebhook != "") {
+		log.Fatal("[ERROR] --plan-out, --apply-plan, --inventory-out and --promote only work in single-run mode")
+	}
+	var plan updater.Plan
+	if *applyPlan != "" {
+		plan, err = updater.ReadPlan(*applyPlan)
+		if err != nil {
+			log.Fatalf("[ERROR] Failed to read plan: %v", err)
+		}
+	}
+	if *interactive && (*interval > 0 || *schedule != "" || *intervalSched != "" || *listenWebhook != "") {
+		log.Fatal("[ERROR] --interactive only works in single-run mode")
+	}
+
+	// Test notifications need neither Docker nor a run, so handle them first.
+	if *testNotify {
+		os.Exit(runTestNotify())
+	}
+
 	log.Printf("[INFO] Repull %s starting...", version)
 
-	// Set DOCKER_HOST if provided via flag
-	if *dockerHost != "" {
-		os.Setenv("DOCKER_HOST", *dockerHost)
+	if *doctor {
+		os.Exit(runDoctor())
+	}
+	if *enable != "" {
+		os.Exit(runEnable(*enable))
 	}
 
 	// Create Docker client
@@ -106,7 +441,7 @@ func main() {
 	// Remove containers left behind by a previous self-update.
 	if !*dryRun {
 		cleanupCtx, cleanupCancel := context.WithTimeout(context.Background(), 30*time.Second)
-		removed, cleanupErr := docker.CleanupSelfUpdateLeftovers(cleanupCtx, cli)
+		removed, cleanupErr := docker.CleanupSelfUpdateLeftovers(cleanupCtx, cli, *leftoverGrace)
 		cleanupCancel()
 		if cleanupErr != nil {
 			log.Printf("[WARN] Failed to clean up self-update leftovers: %v", cleanupErr)
@@ -123,32 +458,438 @@ func main() {
 	if notifier != nil {
 		log.Println("[INFO] Discord notifications enabled")
 	}
+	projectWebhooks, err := parseProjectWebhooks(*projectHooks)
+	if err != nil {
+		log.Fatalf("[ERROR] Invalid --project-webhook: %v", err)
+	}
+	projectNotifiers := make(map[string]*notify.Notifier, len(projectWebhooks))
+	for project, url := range projectWebhooks {
+		n, err := notify.NewDiscordNotifier(url)
+		if err != nil {
+			log.Fatalf("[ERROR] --project-webhook %s: %v", project, err)
+		}
+		projectNotifiers[project] = n
+	}
+	if len(projectNotifiers) > 0 {
+		log.Printf("[INFO] Discord notifications routed per project for %d project(s)", len(projectNotifiers))
+	}
+	discordSeverities, err := notify.ParseSeverities(*discordSev)
+	if err != nil {
+		log.Fatalf("[ERROR] Invalid --discord-severity: %v", err)
+	}
+	if discordSeverities != nil {
+		notifier.SetSeverities(discordSeverities)
+		for _, n := range projectNotifiers {
+			n.SetSeverities(discordSeverities)
+		}
+		log.Printf("[INFO] Discord notifications limited to severity: %s", *discordSev)
+	}
+	templates, err := loadTemplates()
+	if err != nil {
+		log.Fatalf("[ERROR] Invalid notification template: %v", err)
+	}
+	if templates != nil {
+		notifier.SetTemplates(templates)
+		for _, n := range projectNotifiers {
+			n.SetTemplates(templates)
+		}
+		log.Println("[INFO] Custom notification templates loaded")
+	}
+	fileNotifier, err := notify.NewFileNotifier(*notifyFile)
+	if err != nil {
+		log.Fatalf("[ERROR] --notify-file: %v", err)
+	}
+	fileSeverities, err := notify.ParseSeverities(*notifyFileSev)
+	if err != nil {
+		log.Fatalf("[ERROR] Invalid --notify-file-severity: %v", err)
+	}
+	fileNotifier.SetSeverities(fileSeverities)
+	if fileNotifier != nil {
+		notifier = notifier.WithFile(fileNotifier)
+		for project, n := range projectNotifiers {
+			projectNotifiers[project] = n.WithFile(fileNotifier)
+		}
+		log.Printf("[INFO] File notifications enabled (%s)", *notifyFile)
+	}
+	emailNotifier, err := notify.NewEmailNotifier(*smtpHost, *smtpPort, *smtpUser, *smtpPass, *smtpFrom, smtpTo.values)
+	if err != nil {
+		log.Fatalf("[ERROR] Invalid email settings (--smtp-*): %v", err)
+	}
+	emailSeverities, err := notify.ParseSeverities(*emailSev)
+	if err != nil {
+		log.Fatalf("[ERROR] Invalid --email-severity: %v", err)
+	}
+	if emailNotifier != nil {
+		email := notify.Filter(emailNotifier, emailSeverities)
+		notifier = notifier.WithSender(email)
+		for project, n := range projectNotifiers {
+			projectNotifiers[project] = n.WithSender(email)
+		}
+		log.Printf("[INFO] Email notifications enabled (%s)", *smtpHost)
+	}
+	ntfyNotifier, err := notify.NewNtfyNotifier(*ntfyURL, *ntfyTopic, *ntfyToken)
+	if err != nil {
+		log.Fatalf("[ERROR] Invalid ntfy settings (--ntfy-*): %v", err)
+	}
+	ntfySeverities, err := notify.ParseSeverities(*ntfySev)
+	if err != nil {
+		log.Fatalf("[ERROR] Invalid --ntfy-severity: %v", err)
+	}
+	if ntfyNotifier != nil {
+		ntfy := notify.Filter(ntfyNotifier, ntfySeverities)
+		notifier = notifier.WithSender(ntfy)
+		for project, n := range projectNotifiers {
+			projectNotifiers[project] = n.WithSender(ntfy)
+		}
+		log.Printf("[INFO] ntfy notifications enabled (topic %s)", *ntfyTopic)
+	}
+	if *notifyDebounce > 0 {
+		notifier.SetDebounce(*notifyDebounce)
+		for _, n := range projectNotifiers {
+			n.SetDebounce(*notifyDebounce)
+		}
+		log.Printf("[INFO] Update notifications debounced per group (quiet period: %s)", *notifyDebounce)
+	}
+	if *notifySummary {
+		if *notifyDebounce > 0 {
+			log.Fatal("[ERROR] --notify-summary cannot be combined with --notify-debounce")
+		}
+		// The summary has a fixed layout; custom templates would silently
+		// stop applying to the messages they were written for.
+		if *tmplUpdate != "" || *tmplError != "" || *tmplSummary != "" {
+			log.Fatal("[ERROR] --notify-summary cannot be combined with --template-update, --template-error or --template-summary")
+		}
+		notifier.SetRunSummary(true)
+		for _, n := range projectNotifiers {
+			n.SetRunSummary(true)
+		}
+		log.Println("[INFO] Discord notifications summarized per run")
+	}
+
+	// Create Uptime Kuma reporter
+	kuma, err = notify.NewKuma(*kumaURL)
+	if err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+	if kuma != nil {
+		log.Println("[INFO] Uptime Kuma push monitor enabled")
+	}
+
+	// Unlike the status server, profiling is useful in single-run mode too:
+	// a slow one-off run is exactly what it is for.
+	if *pprofAddr != "" {
+		servePprof(*pprofAddr)
+		log.Printf("[INFO] Serving pprof profiles on %s/debug/pprof/ (do not expose this publicly)", *pprofAddr)
+	}
+
+	// The status server only makes sense for a process that keeps running.
+	if *statusAddr != "" {
+		if *listenWebhook == "" && *schedule == "" && *intervalSched == "" && *interval <= 0 {
+			log.Println("[WARN] --status-addr is ignored in single-run mode")
+		} else {
+			statusTracker = status.NewTracker()
+			serveStatus(*statusAddr, statusTracker)
+			log.Printf("[INFO] Serving run status on %s/status", *statusAddr)
+		}
+	}
+
+	// Load persisted state. A corrupt file is fatal rather than silently
+	// reset, which would lift every io.repull.max-frequency throttle.
+	st, err := state.Load(*stateFile)
+	if err != nil {
+		log.Fatalf("[ERROR] Failed to load state file: %v", err)
+	}
+
+	var broadcaster *events.Broadcaster
+	if *eventSocket != "" {
+		broadcaster, err = events.Listen(*eventSocket)
+		if err != nil {
+			log.Fatalf("[ERROR] Failed to open event socket: %v", err)
+		}
+		defer broadcaster.Close()
+		log.Printf("[INFO] Streaming events on %s", *eventSocket)
+	}
+
+	projectReporters := make(map[string]notify.Reporter, len(projectNotifiers))
+	for project, n := range projectNotifiers {
+		projectReporters[project] = n
+	}
+	opts := updater.Options{
+		DryRun:               *dryRun,
+		Cleanup:              *cleanup,
+		Notifier:             notifier,
+		ProjectNotifiers:     projectReporters,
+		State:                st,
+		Events:               broadcaster,
+		Status:               statusTracker,
+		RestartLoopThreshold: *restartLoop,
+		MinContainerAge:      *minAge,
+		PullOnly:             *pullOnly,
+		Prefetch:             *prefetch,
+		SkipMissingImages:    *skipMissing,
+		NoStart:              *noStart,
+		OnePerRun:            *onePerRun,
+		SelfStopTimeout:      *selfStop,
+		MaxLoad:              *maxLoad,
+		KeepImages:           *keepImages,
+		SummarizeUnchanged:   *summarize,
+		Debug:                *debug,
+	}
+	// A dry run uses it to estimate download sizes.
+	if maxSize > 0 || *checkBase || *dryRun {
+		opts.Registry = registry.NewClient()
+	}
+	if maxSize > 0 {
+		opts.MaxImageSize = maxSize
+		log.Printf("[INFO] Skipping images larger than %s", *maxImageSize)
+	}
+	if minFree > 0 {
+		opts.MinFreeDisk = minFree
+		log.Printf("[INFO] Skipping pulls while less than %s is free on the Docker data root", *minFreeDisk)
+	}
+	if *parallelPulls > 1 {
+		opts.MaxParallelPulls = *parallelPulls
+		log.Printf("[INFO] Pulling up to %d images at once; groups are still updated one at a time", *parallelPulls)
+	}
+	if *maxFailures > 0 {
+		opts.MaxConsecutiveFailures = *maxFailures
+		log.Printf("[INFO] Halting a run after %d consecutive group failures", *maxFailures)
+	}
+	opts.SelfUpdateMaxAttempts = *selfMaxTries
+	if *checkBase {
+		opts.CheckBaseImages = true
+		log.Println("[INFO] Checking images for changed base images")
+	}
 
-	if *dryRun {
+	if *promote != "" {
+		opts.Promote = *promote
+		opts.Groups = map[string]bool{*promote: true}
+		log.Printf("[INFO] Promoting the canary of %s", *promote)
+	}
+
+	if *applyPlan != "" {
+		opts = plan.Apply(opts)
+		log.Printf("[INFO] Applying plan %s (%d group(s), made %s)", *applyPlan, len(plan.Groups), plan.Created.Format(time.RFC3339))
+	}
+
+	if *maintenance {
+		opts.NotifyAvailable = true
+		log.Println("[INFO] Running in MAINTENANCE mode - no changes will be made, available updates are sent as notifications")
+	} else if *dryRun {
 		log.Println("[INFO] Running in DRY-RUN mode - no changes will be made")
 	}
+	if *pullOnly {
+		log.Println("[INFO] Running in PULL-ONLY mode - images are pulled, containers are not recreated")
+	}
+	if *prefetch {
+		log.Println("[INFO] Prefetching - pulled images are staged for the next regular run")
+	}
+	if *noStart {
+		log.Println("[INFO] --no-start enabled - recreated containers are left stopped")
+	}
 	if *cleanup {
 		log.Println("[INFO] Cleanup enabled - replaced images will be removed after updates")
 	}
 
 	// Run based on mode
-	if *schedule != "" {
+	if *listenWebhook != "" {
+		if *webhookSecret == "" {
+			log.Println("[WARN] --listen-webhook has no --webhook-secret: anyone who can reach it can trigger checks")
+		}
+		// A poller alongside the listener is a safety net for missed
+		// webhooks; its checks go through the listener's runner.
+		var poll func(ctx context.Context, check func())
+		initial := true
+		if *schedule != "" {
+			log.Printf("[INFO] Running in webhook mode (listening on %s), with a daily check at %s", *listenWebhook, *schedule)
+			poll = func(ctx context.Context, check func()) {
+				scheduleLoop(ctx, realClock{}, targetTime, check)
+			}
+		} else if len(windows) > 0 || *interval > 0 {
+			log.Printf("[INFO] Running in webhook mode (listening on %s), with polling as in loop mode", *listenWebhook)
+			initial = false // the loop makes the initial check
+			fallback := time.Duration(*interval) * time.Second
+			poll = func(ctx context.Context, check func()) {
+				if waitInitialDelay(ctx, realClock{}, *initialDelay) {
+					intervalLoop(ctx, realClock{}, windows, fallback, !*noRunOnStart, check)
+				}
+			}
+		} else {
+			log.Printf("[INFO] Running in webhook mode (listening on %s)", *listenWebhook)
+		}
+		runWebhookListener(cli, opts, *listenWebhook, *webhookSecret, initial, poll)
+	} else if *schedule != "" {
 		log.Printf("[INFO] Running in schedule mode (daily at %s)", *schedule)
-		runSchedule(cli, notifier, targetTime)
+		runSchedule(cli, opts, targetTime)
+	} else if len(windows) > 0 {
+		log.Printf("[INFO] Running in loop mode (interval schedule: %s)", *intervalSched)
+		runLoop(cli, opts, windows)
 	} else if *interval > 0 {
 		log.Printf("[INFO] Running in loop mode (interval: %d seconds)", *interval)
-		runLoop(cli, notifier)
+		runLoop(cli, opts, nil)
 	} else {
 		log.Println("[INFO] Running in single-run mode")
-		if err := runOnce(cli, notifier); err != nil {
+		err := runOnce(cli, opts)
+		// Nothing would be left to deliver held notifications after exit.
+		// Ctrl-C during the flush aborts the sends still in flight.
+		flushCtx, stop := shutdownContext()
+		notifier.SetContext(flushCtx)
+		notifier.Flush()
+		for _, n := range projectNotifiers {
+			n.SetContext(flushCtx)
+			n.Flush()
+		}
+		stop()
+		if err != nil {
 			log.Fatalf("[ERROR] Update failed: %v", err)
 		}
 		log.Println("[INFO] Update complete")
 	}
 }
 
-// runOnce performs a single update check and execution.
-func runOnce(cli *client.Client, notifier *notify.Notifier) error {
+// loadTemplates loads the --template-update, --template-error and
+// --template-summary files. It returns nil when none is set, so the
+// notifiers keep their defaults.
+func loadTemplates() (*notify.Templates, error) {
+	if *tmplUpdate == "" && *tmplError == "" && *tmplSummary == "" {
+		return nil, nil
+	}
+	return notify.LoadTemplates(*tmplUpdate, *tmplError, *tmplSummary)
+}
+
+// runTestNotify sends sample notifications through every configured backend
+// and reports the outcome per backend on stdout. Returns the process exit
+// code: non-zero if no backend is configured or any backend failed.
+func runTestNotify() int {
+	notifier, err := notify.NewDiscordNotifier(*discordWebhook)
+	if err != nil {
+		fmt.Printf("Discord: FAILED (%v)\n", err)
+		return 1
+	}
+	templates, err := loadTemplates()
+	if err != nil {
+		fmt.Printf("Templates: FAILED (%v)\n", err)
+		return 1
+	}
+	notifier.SetTemplates(templates)
+	fileNotifier, err := notify.NewFileNotifier(*notifyFile)
+	if err != nil {
+		fmt.Printf("File: FAILED (%v)\n", err)
+		return 1
+	}
+	emailNotifier, err := notify.NewEmailNotifier(*smtpHost, *smtpPort, *smtpUser, *smtpPass, *smtpFrom, smtpTo.values)
+	if err != nil {
+		fmt.Printf("Email: FAILED (%v)\n", err)
+		return 1
+	}
+	ntfyNotifier, err := notify.NewNtfyNotifier(*ntfyURL, *ntfyTopic, *ntfyToken)
+	if err != nil {
+		fmt.Printf("ntfy: FAILED (%v)\n", err)
+		return 1
+	}
+	kumaPusher, err := notify.NewKuma(*kumaURL)
+	if err != nil {
+		fmt.Printf("Uptime Kuma: FAILED (%v)\n", err)
+		return 1
+	}
+	projectWebhooks, err := parseProjectWebhooks(*projectHooks)
+	if err != nil {
+		fmt.Printf("Project webhooks: FAILED (%v)\n", err)
+		return 1
+	}
+	if notifier == nil && fileNotifier == nil && emailNotifier == nil && ntfyNotifier == nil && kumaPusher == nil && len(projectWebhooks) == 0 {
+		fmt.Println("No notification backend configured (set --discord-webhook, --project-webhook, --notify-file, --smtp-host, --ntfy-topic or --kuma-url)")
+		return 1
+	}
+
+	code := 0
+	if notifier != nil {
+		if err := notifier.Test(); err != nil {
+			fmt.Printf("Discord: FAILED (%v)\n", err)
+			code = 1
+		} else {
+			fmt.Println("Discord: OK (sent sample update and error notification)")
+		}
+	}
+	if fileNotifier != nil {
+		if err := fileNotifier.Test(); err != nil {
+			fmt.Printf("File: FAILED (%v)\n", err)
+			code = 1
+		} else {
+			fmt.Printf("File: OK (appended sample update and error to %s)\n", *notifyFile)
+		}
+	}
+	if emailNotifier != nil {
+		if err := emailNotifier.Test(); err != nil {
+			fmt.Printf("Email: FAILED (%v)\n", err)
+			code = 1
+		} else {
+			fmt.Printf("Email: OK (sent a test email to %s)\n", strings.Join(smtpTo.values, ", "))
+		}
+	}
+	if ntfyNotifier != nil {
+		if err := ntfyNotifier.Test(); err != nil {
+			fmt.Printf("ntfy: FAILED (%v)\n", err)
+			code = 1
+		} else {
+			fmt.Printf("ntfy: OK (published a test notification to %s)\n", *ntfyTopic)
+		}
+	}
+	for _, project := range slices.Sorted(maps.Keys(projectWebhooks)) {
+		n, err := notify.NewDiscordNotifier(projectWebhooks[project])
+		if err == nil {
+			n.SetTemplates(templates)
+			err = n.Test()
+		}
+		if err != nil {
+			fmt.Printf("Discord (project %s): FAILED (%v)\n", project, err)
+			code = 1
+		} else {
+			fmt.Printf("Discord (project %s): OK (sent sample update and error notification)\n", project)
+		}
+	}
+	if kumaPusher != nil {
+		if err := kumaPusher.Test(); err != nil {
+			fmt.Printf("Uptime Kuma: FAILED (%v)\n", err)
+			code = 1
+		} else {
+			fmt.Println("Uptime Kuma: OK (pushed a test status=up)")
+		}
+	}
+	return code
+}
+
+// kuma reports the outcome of every run; nil when --kuma-url is not set.
+var kuma *notify.Kuma
+
+// runOnce performs a single update check and execution, then reports the
+// outcome to the Uptime Kuma push monitor and the status snapshot, if
+// configured.
+func runOnce(cli *client.Client, opts updater.Options) error {
+	// A run that never started is not reported to Uptime Kuma: the process
+	// holding the lock reports its own.
+	if *lockFile != "" {
+		release, err := acquireLock(*lockFile, *lockWait)
+		if err != nil {
+			return err
+		}
+		defer release()
+	}
+
+	statusTracker.RunStarted()
+	checked, err := checkAndUpdate(cli, opts)
+	statusTracker.RunFinished(err)
+	if err != nil {
+		kuma.Push(false, fmt.Sprintf("run failed: %v", err))
+	} else {
+		kuma.Push(true, fmt.Sprintf("checked %d group(s)", checked))
+	}
+	return err
+}
+
+// checkAndUpdate lists, filters and groups the opted-in containers and
+// updates them. Returns the number of groups checked.
+func checkAndUpdate(cli *client.Client, opts updater.Options) (int, error) {
 	// Listing and inspecting containers is fast; a short deadline prevents a
 	// stalled Docker daemon from blocking the loop indefinitely. The update
 	// work itself is bounded per group inside UpdateGroups, so one slow group
@@ -156,85 +897,192 @@ func runOnce(cli *client.Client, notifier *notify.Notifier) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
 	defer cancel()
 
-	// List running containers
-	containers, err := docker.ListRunningContainers(ctx, cli)
-	if err != nil {
-		return err
+	// Reread the channel file every run, so editing it starts a rollout.
+	// A broken file fails the run rather than silently dropping the pins.
+	if *channelFile != "" {
+		channels, err := updater.ReadChannels(*channelFile)
+		if err != nil {
+			return 0, fmt.Errorf("reading --channel-file: %w", err)
+		}
+		opts.Channels = channels
 	}
 
-	log.Printf("[INFO] Found %d running container(s)", len(containers))
-
-	// Filter opted-in containers
-	optedIn := updater.FilterOptedInContainers(containers)
+	// List the running opted-in containers. The daemon filters on the
+	// label, so the others are never inspected.
+	optedIn, err := docker.ListRunningContainers(ctx, cli, updater.EnableLabel+"=true")
+	if err != nil {
+		return 0, err
+	}
 	log.Printf("[INFO] Found %d opted-in container(s) (label: %s=true)", len(optedIn), updater.EnableLabel)
+	if len(networks.values) > 0 {
+		optedIn = updater.FilterByNetwork(optedIn, networks.values)
+		log.Printf("[INFO] %d of them attached to network(s) %s", len(optedIn), strings.Join(networks.values, ", "))
+	}
 
 	if len(optedIn) == 0 {
 		log.Println("[INFO] No containers opted in for auto-update")
-		return nil
+		return 0, nil
 	}
 
-	// Group by compose service
-	groups := updater.GroupByComposeService(optedIn)
-	log.Printf("[INFO] Grouped into %d service(s)", len(groups))
+	// Group by compose service, unless grouping is disabled
+	var groups map[string][]container.InspectResponse
+	if *groupBy == "none" {
+		groups = updater.GroupIndividually(optedIn)
+		log.Printf("[INFO] Grouping disabled: %d container(s) updated independently", len(groups))
+	} else {
+		groups = updater.GroupByComposeService(optedIn)
+		log.Printf("[INFO] Grouped into %d service(s)", len(groups))
+	}
+	groups = updater.ExcludeImages(groups, excludeImages.values)
+	if *onlyMutable {
+		groups = updater.OnlyMutableTags(groups, mutableTags.values)
+	}
+
+	if *inventoryOut != "" {
+		inventory := updater.Inventory(groups)
+		if err := updater.WriteInventory(*inventoryOut, inventory); err != nil {
+			return len(groups), err
+		}
+		log.Printf("[INFO] Wrote inventory of %d container(s) to %s", len(inventory), *inventoryOut)
+		return len(groups), nil
+	}
+
+	// Ask before recreating when a person is at the terminal. Without a TTY
+	// (cron, CI, a pipe) there is nobody to answer, so run as usual.
+	if *interactive && !*assumeYes && !opts.DryRun && stdinIsTerminal() {
+		return len(groups), runInteractive(context.Background(), cli, groups, opts)
+	}
+
+	if *planOut != "" {
+		return len(groups), runPlanOut(context.Background(), cli, groups, opts, *planOut)
+	}
 
 	// Update groups. Deliberately not bound to the listing deadline above —
 	// UpdateGroups applies its own per-group timeout.
-	return updater.UpdateGroups(context.Background(), cli, groups, *dryRun, *cleanup, notifier)
+	return len(groups), updater.UpdateGroups(context.Background(), cli, groups, opts)
 }
 
-// runLoop runs the update check in a loop at the specified interval.
-func runLoop(cli *client.Client, notifier *notify.Notifier) {
-	ticker := time.NewTicker(time.Duration(*interval) * time.Second)
-	defer ticker.Stop()
+// runLoop runs the update check in a loop at the specified interval. With
+// interval-schedule windows, the interval is recomputed after every check
+// from the window active at that time.
+func runLoop(cli *client.Client, opts updater.Options, windows []intervalWindow) {
+	ctx, stop := shutdownContext()
+	waited := waitInitialDelay(ctx, realClock{}, *initialDelay)
+	stop()
+	if !waited {
+		log.Println("[INFO] Shutting down")
+		return
+	}
+
+	fallback := time.Duration(*interval) * time.Second
+	intervalLoop(context.Background(), realClock{}, windows, fallback, !*noRunOnStart, func() {
+		if err := runOnce(cli, opts); err != nil {
+			log.Printf("[ERROR] Update failed: %v", err)
+		}
+	})
+}
+
+// intervalLoop calls check at once (unless runOnStart is false) and then
+// every interval until ctx is done. Like a time.Ticker, it keeps the start
+// times on a fixed grid: a check that overruns one or more ticks is
+// followed by a single immediate check, not one per missed tick. When the interval-schedule window changes, the
+// grid restarts from the end of that check.
+func intervalLoop(ctx context.Context, clk clock, windows []intervalWindow, fallback time.Duration, runOnStart bool, check func()) {
+	current := loopInterval(windows, fallback, clk.Now())
+	next := clk.Now().Add(current)
 
 	// Run immediately on start
-	log.Println("[INFO] Running initial check...")
-	if err := runOnce(cli, notifier); err != nil {
-		log.Printf("[ERROR] Update failed: %v", err)
+	if runOnStart {
+		log.Println("[INFO] Running initial check...")
+		check()
+	} else {
+		log.Printf("[INFO] Skipping the check at startup, first check in %s (--no-run-on-start)", current)
 	}
 
 	// Then run on interval
-	for range ticker.C {
-		log.Printf("[INFO] Running scheduled check (interval: %d seconds)...", *interval)
-		if err := runOnce(cli, notifier); err != nil {
-			log.Printf("[ERROR] Update failed: %v", err)
+	for ctx.Err() == nil {
+		statusTracker.SetNextRun(next)
+		if wait := next.Sub(clk.Now()); wait > 0 {
+			select {
+			case <-clk.After(wait):
+			case <-ctx.Done():
+				return
+			}
+		}
+		for now := clk.Now(); !next.After(now); {
+			next = next.Add(current)
+		}
+
+		log.Printf("[INFO] Running scheduled check (interval: %s)...", current)
+		check()
+		if d := loopInterval(windows, fallback, clk.Now()); d != current {
+			log.Printf("[INFO] Interval changed: %s -> %s", current, d)
+			current = d
+			next = clk.Now().Add(current)
 		}
 		log.Println("[INFO] Check complete, waiting for next interval...")
 	}
 }
 
 // runSchedule runs the update check daily at targetTime's wall-clock time.
-func runSchedule(cli *client.Client, notifier *notify.Notifier, targetTime time.Time) {
-	for {
+func runSchedule(cli *client.Client, opts updater.Options, targetTime time.Time) {
+	scheduleLoop(context.Background(), realClock{}, targetTime, func() {
+		if err := runOnce(cli, opts); err != nil {
+			log.Printf("[ERROR] Update failed: %v", err)
+		}
+	})
+}
+
+// scheduleLoop calls check daily at targetTime's wall-clock time until ctx
+// is done.
+func scheduleLoop(ctx context.Context, clk clock, targetTime time.Time, check func()) {
+	for ctx.Err() == nil {
 		// Calculate time until next occurrence
-		next := nextOccurrence(targetTime, time.Now())
+		next := nextOccurrence(targetTime, clk.Now())
+		statusTracker.SetNextRun(next)
 
-		log.Printf("[INFO] Next run scheduled at %s (in %s)", next.Format("2006-01-02 15:04:05"), time.Until(next).Round(time.Second))
+		log.Printf("[INFO] Next run scheduled at %s (in %s)", next.Format("2006-01-02 15:04:05"), next.Sub(clk.Now()).Round(time.Second))
 
 		// Sleep in short chunks and re-check the wall clock. time.Sleep uses
 		// the monotonic clock, so a single long sleep overshoots the target
 		// when the machine suspends or the clock is adjusted; chunked sleeping
 		// keeps the run within a minute of the scheduled wall-clock time.
 		for {
-			remaining := time.Until(next)
+			remaining := next.Sub(clk.Now())
 			if remaining <= 0 {
 				break
 			}
 			if remaining > time.Minute {
 				remaining = time.Minute
 			}
-			time.Sleep(remaining)
+			clk.Sleep(remaining)
+			if ctx.Err() != nil {
+				return
+			}
 		}
 
-		// Run update
+		// Run update. The next slot is the run's deadline, but only for
+		// reporting: cutting a run short mid-recreate would do more harm
+		// than a late slot. A slot the run overran is skipped, not queued.
 		log.Printf("[INFO] Running scheduled check...")
-		if err := runOnce(cli, notifier); err != nil {
-			log.Printf("[ERROR] Update failed: %v", err)
+		check()
+		for _, slot := range missedSlots(targetTime, next, clk.Now()) {
+			log.Printf("[WARN] Check was still running at the %s slot, skipping that run", slot.Format("2006-01-02 15:04"))
 		}
 		log.Println("[INFO] Check complete")
 	}
 }
 
+// missedSlots returns the occurrences of target's wall-clock time a run
+// that started at started and finished at finished ran through.
+func missedSlots(target, started, finished time.Time) []time.Time {
+	var missed []time.Time
+	for slot := nextOccurrence(target, started); !slot.After(finished); slot = nextOccurrence(target, slot) {
+		missed = append(missed, slot)
+	}
+	return missed
+}
+
 // parseScheduleTime parses "HH:MM" format
 func parseScheduleTime(schedule string) (time.Time, error) {
 	parts := strings.Split(schedule, ":")
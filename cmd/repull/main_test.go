@@ -1,6 +1,8 @@
 package main
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -138,3 +140,69 @@ func TestNextOccurrence(t *testing.T) {
 		})
 	}
 }
+
+// TestMissedSlots simulates runs around a daily 23:00 schedule: only a run
+// still going when the next 23:00 comes around misses a slot.
+func TestMissedSlots(t *testing.T) {
+	target := time.Date(2026, time.June, 11, 23, 0, 0, 0, time.UTC)
+	started := time.Date(2026, time.June, 11, 23, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		finished time.Time
+		want     []time.Time
+	}{
+		{"short run", started.Add(10 * time.Minute), nil},
+		{"ends just before the next slot", started.Add(24*time.Hour - time.Second), nil},
+		{"spans the next slot", started.Add(24*time.Hour + 30*time.Minute), []time.Time{started.AddDate(0, 0, 1)}},
+		{"spans two slots", started.Add(49 * time.Hour), []time.Time{started.AddDate(0, 0, 1), started.AddDate(0, 0, 2)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := missedSlots(target, started, tt.finished)
+			if len(got) != len(tt.want) {
+				t.Fatalf("missedSlots() = %v, want %v", got, tt.want)
+			}
+			for i := range tt.want {
+				if !got[i].Equal(tt.want[i]) {
+					t.Errorf("missedSlots()[%d] = %s, want %s", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSecretValue(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "webhook")
+	if err := os.WriteFile(path, []byte("  https://discord.com/api/webhooks/1/abc\n\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		value   string
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{name: "neither set", want: ""},
+		{name: "direct value", value: "direct", want: "direct"},
+		{name: "file is trimmed", path: path, want: "https://discord.com/api/webhooks/1/abc"},
+		{name: "both set is an error", value: "direct", path: path, wantErr: true},
+		{name: "missing file", path: filepath.Join(dir, "missing"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := secretValue("discord-webhook", tt.value, tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("secretValue() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("secretValue() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/fanuelsen/repull/internal/updater"
+)
+
+// runPlanOut runs a dry run and writes the updates it would make to path as
+// a plan file, for a later --apply-plan run to execute.
+func runPlanOut(ctx context.Context, cli *client.Client, groups map[string][]container.InspectResponse, opts updater.Options, path string) error {
+	plan := updater.Plan{Version: updater.PlanVersion, Created: time.Now().UTC()}
+	opts.Planned = func(groupKey, imageName, latestID string, outdated []container.InspectResponse) {
+		g := updater.PlanGroup{Group: groupKey, Image: imageName, ImageID: latestID}
+		for _, c := range outdated {
+			g.Containers = append(g.Containers, strings.TrimPrefix(c.Name, "/"))
+			g.ContainerIDs = append(g.ContainerIDs, c.ID)
+		}
+		plan.Groups = append(plan.Groups, g)
+	}
+	if err := updater.UpdateGroups(ctx, cli, groups, opts); err != nil {
+		return err
+	}
+
+	sort.Slice(plan.Groups, func(i, j int) bool { return plan.Groups[i].Group < plan.Groups[j].Group })
+	if err := updater.WritePlan(path, plan); err != nil {
+		return err
+	}
+	log.Printf("[INFO] Wrote plan with %d group(s) to %s", len(plan.Groups), path)
+	return nil
+}
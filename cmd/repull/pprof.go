@@ -0,0 +1,32 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/http/pprof"
+	"time"
+)
+
+// servePprof serves the net/http/pprof endpoints under /debug/pprof/ at
+// addr, in the background, for as long as the process runs. They get a mux
+// of their own rather than http.DefaultServeMux, so nothing else ever
+// exposes them by accident.
+func servePprof(addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("[ERROR] pprof server: %v", err)
+		}
+	}()
+}
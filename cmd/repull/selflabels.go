@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/fanuelsen/repull/internal/docker"
+	"github.com/fanuelsen/repull/internal/sanitize"
+	"github.com/fanuelsen/repull/internal/updater"
+)
+
+// selfConfigLabels maps labels on repull's own container to the flags they
+// configure.
+var selfConfigLabels = []struct {
+	label, flag string
+}{
+	{"io.repull.interval", "interval"},
+	{"io.repull.schedule", "schedule"},
+	{"io.repull.interval-schedule", "interval-schedule"},
+}
+
+// modeFlags are the flags that choose how repull runs, and the environment
+// variable behind each. The self-config labels all choose a mode too, so
+// they are taken as a group: an operator who picked a mode on the command
+// line or in the environment gets no mode from the labels, which could
+// otherwise conflict with it.
+var modeFlags = []struct {
+	flag, env string
+}{
+	{"interval", "REPULL_INTERVAL"},
+	{"schedule", "REPULL_SCHEDULE"},
+	{"interval-schedule", "REPULL_INTERVAL_SCHEDULE"},
+	{"listen-webhook", "REPULL_LISTEN_WEBHOOK"},
+}
+
+// labelConfig returns the flag values to take from labels: none if a run
+// mode is already given on the command line (set) or in the environment.
+// Flags and env always win, so the labels are only ever a default.
+func labelConfig(labels map[string]string, set map[string]bool, getenv func(string) string) map[string]string {
+	for _, m := range modeFlags {
+		if set[m.flag] || getenv(m.env) != "" {
+			return nil
+		}
+	}
+	values := make(map[string]string)
+	for _, l := range selfConfigLabels {
+		if v, ok := labels[l.label]; ok {
+			values[l.flag] = v
+		}
+	}
+	return values
+}
+
+// applySelfLabels reads configuration labels off the container repull runs
+// in, as the lowest-precedence source below flags and environment. A host
+// binary, or a daemon that cannot be reached yet, simply has no labels;
+// the real connection later reports any Docker problem.
+func applySelfLabels() {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		return
+	}
+	cli, err := docker.NewClient()
+	if err != nil {
+		return
+	}
+	defer cli.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	// Inside a container the hostname is the short container ID (or the
+	// container name when set to it), either of which inspect accepts.
+	inspect, err := cli.ContainerInspect(ctx, hostname)
+	if err != nil {
+		return
+	}
+	self, ok := updater.FindSelf([]container.InspectResponse{inspect})
+	if !ok || self.Config == nil {
+		return
+	}
+
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	for name, value := range labelConfig(self.Config.Labels, set, os.Getenv) {
+		if err := flag.Set(name, value); err != nil {
+			log.Fatalf("[ERROR] Invalid label for --%s on repull's container: %v", name, err)
+		}
+		log.Printf("[INFO] Using --%s=%s from repull's container label", name, sanitize.String(value))
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import "testing"
+
+func TestLabelConfig(t *testing.T) {
+	labels := map[string]string{
+		"io.repull.interval": "3600",
+		"io.repull.schedule": "03:00",
+		"io.repull.enable":   "true",
+	}
+
+	tests := []struct {
+		name string
+		set  map[string]bool
+		env  map[string]string
+		want map[string]string
+	}{
+		{name: "labels only", want: map[string]string{"interval": "3600", "schedule": "03:00"}},
+		{name: "flag wins", set: map[string]bool{"interval": true}},
+		{name: "env wins", env: map[string]string{"REPULL_SCHEDULE": "04:00"}},
+		{name: "webhook flag wins", set: map[string]bool{"listen-webhook": true}},
+		{name: "unrelated flag", set: map[string]bool{"cleanup": true}, want: map[string]string{"interval": "3600", "schedule": "03:00"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := labelConfig(labels, tt.set, func(k string) string { return tt.env[k] })
+			if len(got) != len(tt.want) {
+				t.Fatalf("labelConfig() = %v, want %v", got, tt.want)
+			}
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Errorf("labelConfig()[%q] = %q, want %q", k, got[k], v)
+				}
+			}
+		})
+	}
+}
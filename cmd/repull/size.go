@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// sizeUnits maps the suffixes --max-image-size accepts to their byte values.
+// KB/MB/GB/TB are decimal, as registries and `docker images` report sizes;
+// the KiB-style suffixes are binary.
+var sizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	// Longest suffixes first so "MiB" is not read as "B".
+	{"KIB", 1 << 10}, {"MIB", 1 << 20}, {"GIB", 1 << 30}, {"TIB", 1 << 40},
+	{"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9}, {"TB", 1e12},
+	{"B", 1},
+}
+
+// parseSize parses a size such as "2GB", "500MB" or "1.5GiB" into bytes. A
+// bare number is bytes; an empty string is 0 (no limit).
+func parseSize(s string) (int64, error) {
+	v := strings.ToUpper(strings.TrimSpace(s))
+	if v == "" {
+		return 0, nil
+	}
+	mult := int64(1)
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(v, u.suffix) {
+			mult = u.bytes
+			v = strings.TrimSpace(strings.TrimSuffix(v, u.suffix))
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(v, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q (use e.g. 500MB or 2GB)", s)
+	}
+	return int64(n * float64(mult)), nil
+}
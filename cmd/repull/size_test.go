@@ -0,0 +1,34 @@
+package main
+
+import "testing"
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{in: "", want: 0},
+		{in: "1024", want: 1024},
+		{in: "2GB", want: 2_000_000_000},
+		{in: "2gb", want: 2_000_000_000},
+		{in: "500 MB", want: 500_000_000},
+		{in: "1.5GiB", want: 1_610_612_736},
+		{in: "10KiB", want: 10_240},
+		{in: "100B", want: 100},
+		{in: "big", wantErr: true},
+		{in: "-1GB", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseSize(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseSize(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseSize(%q) = %d, want %d", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/fanuelsen/repull/internal/status"
+)
+
+// statusTracker keeps the run snapshot served by --status-addr; nil when
+// the flag is not set.
+var statusTracker *status.Tracker
+
+// serveStatus serves t's snapshot as JSON on GET /status at addr, in the
+// background, for as long as the process runs.
+func serveStatus(addr string, t *status.Tracker) {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           t.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("[ERROR] Status server: %v", err)
+		}
+	}()
+}
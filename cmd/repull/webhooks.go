@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// parseProjectWebhooks parses --project-webhook, a comma-separated list of
+// project=url pairs such as "myapp=https://...,other=https://...".
+func parseProjectWebhooks(s string) (map[string]string, error) {
+	webhooks := make(map[string]string)
+	if strings.TrimSpace(s) == "" {
+		return webhooks, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		project, url, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		project, url = strings.TrimSpace(project), strings.TrimSpace(url)
+		if !ok || project == "" || url == "" {
+			return nil, fmt.Errorf("%q: want project=url", pair)
+		}
+		if _, dup := webhooks[project]; dup {
+			return nil, fmt.Errorf("project %q listed twice", project)
+		}
+		webhooks[project] = url
+	}
+	return webhooks, nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import "testing"
+
+func TestParseProjectWebhooks(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    map[string]string
+		wantErr bool
+	}{
+		{name: "empty", in: "", want: map[string]string{}},
+		{name: "one", in: "myapp=https://discord.com/api/webhooks/1/a", want: map[string]string{"myapp": "https://discord.com/api/webhooks/1/a"}},
+		{
+			name: "two with spaces",
+			in:   "myapp=https://discord.com/api/webhooks/1/a, other = https://discord.com/api/webhooks/2/b",
+			want: map[string]string{"myapp": "https://discord.com/api/webhooks/1/a", "other": "https://discord.com/api/webhooks/2/b"},
+		},
+		{name: "missing url", in: "myapp=", wantErr: true},
+		{name: "missing project", in: "=https://discord.com/api/webhooks/1/a", wantErr: true},
+		{name: "no separator", in: "myapp", wantErr: true},
+		{name: "duplicate", in: "a=https://x,a=https://y", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseProjectWebhooks(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseProjectWebhooks(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("parseProjectWebhooks(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Errorf("parseProjectWebhooks(%q)[%q] = %q, want %q", tt.in, k, got[k], v)
+				}
+			}
+		})
+	}
+}
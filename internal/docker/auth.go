@@ -12,6 +12,7 @@ import (
 
 	"github.com/distribution/reference"
 	"github.com/docker/docker/api/types/registry"
+	"github.com/fanuelsen/repull/internal/sanitize"
 )
 
 // dockerConfig models the subset of Docker's config.json we need.
@@ -28,9 +29,17 @@ type dockerConfigAuth struct {
 	IdentityToken string `json:"identitytoken"`
 }
 
-// credHelperWarn makes sure the unsupported-credential-helper warning is
-// logged once instead of on every pull of every cycle.
-var credHelperWarn sync.Once
+// credentialSource, if set, is asked for a registry's credentials before
+// config.json. See SetCredentialSource.
+var credentialSource func(domain string) (registry.AuthConfig, bool)
+
+// SetCredentialSource installs fn as a source of registry credentials that
+// takes precedence over config.json, e.g. for registries whose tokens expire
+// and must be fetched fresh (ECR). fn returns ok=false to fall back to
+// config.json. Call it once at startup, before any pull.
+func SetCredentialSource(fn func(domain string) (auth registry.AuthConfig, ok bool)) {
+	credentialSource = fn
+}
 
 // plaintextWarn makes sure the unencrypted-transport warning is logged once.
 var plaintextWarn sync.Once
@@ -59,35 +68,66 @@ func warnIfPlaintextTransport() {
 // them along with each pull request. Repull therefore reads the same file —
 // $DOCKER_CONFIG/config.json, falling back to ~/.docker/config.json.
 //
-// Only inline base64 "auth" (or username/password) entries are supported.
-// Credential helpers (credsStore/credHelpers) would require the docker/cli
-// dependency and are deliberately not supported.
+// A credential helper configured for the registry (credHelpers, or the
+// credsStore default) is asked first, as the Docker CLI does; see
+// helperCredentials. Inline base64 "auth" (or username/password) entries
+// are the fallback when the helper has nothing or fails.
 func RegistryAuthFor(imageName string) string {
-	domain, err := registryDomain(imageName)
+	auth, ok := CredentialsFor(imageName)
+	if !ok {
+		return ""
+	}
+
+	encoded, err := registry.EncodeAuthConfig(auth)
 	if err != nil {
+		log.Printf("[WARN] Failed to encode registry credentials for %s: %v", auth.ServerAddress, err)
 		return ""
 	}
 
+	warnIfPlaintextTransport()
+	return encoded
+}
+
+// CredentialsFor returns the credentials for the registry hosting imageName
+// — from the credential source if one is set and has them, otherwise from
+// config.json (see RegistryAuthFor for the lookup rules) — or ok=false if
+// none are configured.
+func CredentialsFor(imageName string) (auth registry.AuthConfig, ok bool) {
+	domain, err := registryDomain(imageName)
+	if err != nil {
+		return registry.AuthConfig{}, false
+	}
+
+	if credentialSource != nil {
+		if auth, ok := credentialSource(domain); ok {
+			return auth, true
+		}
+	}
+
 	cfg, err := loadDockerConfig()
 	if err != nil {
 		// A missing config file is normal when no registry needs auth.
 		if !os.IsNotExist(err) {
 			log.Printf("[WARN] Failed to read Docker config: %v", err)
 		}
-		return ""
+		return registry.AuthConfig{}, false
+	}
+
+	if helper := credentialHelper(cfg, domain); helper != "" {
+		auth, ok, err := helperCredentials(helper, domain)
+		if err != nil {
+			log.Printf("[WARN] Credential helper for %s failed: %s", domain, sanitize.String(err.Error()))
+		} else if ok {
+			return auth, true
+		}
 	}
 
 	entry, ok := lookupAuth(cfg, domain)
 	if !ok {
-		if cfg.CredsStore != "" || cfg.CredHelpers[domain] != "" {
-			credHelperWarn.Do(func() {
-				log.Printf("[WARN] Docker config uses a credential helper, which repull does not support; pulls will be unauthenticated unless config.json contains inline auths")
-			})
-		}
-		return ""
+		return registry.AuthConfig{}, false
 	}
 
-	auth := registry.AuthConfig{
+	auth = registry.AuthConfig{
 		ServerAddress: domain,
 		Username:      entry.Username,
 		Password:      entry.Password,
@@ -104,17 +144,9 @@ func RegistryAuthFor(imageName string) string {
 	}
 
 	if auth.Username == "" && auth.IdentityToken == "" {
-		return ""
-	}
-
-	encoded, err := registry.EncodeAuthConfig(auth)
-	if err != nil {
-		log.Printf("[WARN] Failed to encode registry credentials for %s: %v", domain, err)
-		return ""
+		return registry.AuthConfig{}, false
 	}
-
-	warnIfPlaintextTransport()
-	return encoded
+	return auth, true
 }
 
 // registryDomain extracts the registry host from an image reference.
@@ -156,14 +188,7 @@ func loadDockerConfig() (*dockerConfig, error) {
 // credentials are stored under a legacy key, and other registries may be
 // keyed with or without a scheme prefix.
 func lookupAuth(cfg *dockerConfig, domain string) (dockerConfigAuth, bool) {
-	var keys []string
-	if domain == "docker.io" {
-		keys = []string{"https://index.docker.io/v1/", "index.docker.io", "docker.io"}
-	} else {
-		keys = []string{domain, "https://" + domain, "http://" + domain}
-	}
-
-	for _, key := range keys {
+	for _, key := range authKeys(domain) {
 		if entry, ok := cfg.Auths[key]; ok {
 			return entry, true
 		}
@@ -171,6 +196,15 @@ func lookupAuth(cfg *dockerConfig, domain string) (dockerConfigAuth, bool) {
 	return dockerConfigAuth{}, false
 }
 
+// authKeys returns the config.json keys a registry domain's entries may be
+// stored under, in lookup order.
+func authKeys(domain string) []string {
+	if domain == "docker.io" {
+		return []string{"https://index.docker.io/v1/", "index.docker.io", "docker.io"}
+	}
+	return []string{domain, "https://" + domain, "http://" + domain}
+}
+
 // decodeAuthField decodes a base64 "auth" entry into username and password.
 // Tolerates missing padding, which older Docker versions wrote.
 func decodeAuthField(auth string) (string, string, bool) {
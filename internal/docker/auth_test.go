@@ -140,6 +140,7 @@ func TestRegistryAuthFor(t *testing.T) {
 	t.Run("empty auth entry", func(t *testing.T) {
 		// Credential-helper setups leave empty entries in auths.
 		writeDockerConfig(t, `{"auths": {"ghcr.io": {}}, "credsStore": "desktop"}`)
+		t.Setenv("PATH", t.TempDir()) // no docker-credential-desktop
 
 		if got := RegistryAuthFor("ghcr.io/x/y"); got != "" {
 			t.Errorf("expected no credentials from empty entry, got %q", got)
@@ -152,3 +153,95 @@ func TestRegistryAuthFor(t *testing.T) {
 		}
 	})
 }
+
+func TestCredentialsForSourceTakesPrecedence(t *testing.T) {
+	writeDockerConfig(t, `{"auths":{"123456789012.dkr.ecr.eu-west-1.amazonaws.com":{"auth":"`+
+		base64.StdEncoding.EncodeToString([]byte("AWS:stale"))+`"},"ghcr.io":{"auth":"`+
+		base64.StdEncoding.EncodeToString([]byte("user:pass"))+`"}}}`)
+	SetCredentialSource(func(domain string) (registry.AuthConfig, bool) {
+		if domain != "123456789012.dkr.ecr.eu-west-1.amazonaws.com" {
+			return registry.AuthConfig{}, false
+		}
+		return registry.AuthConfig{ServerAddress: domain, Username: "AWS", Password: "fresh"}, true
+	})
+	t.Cleanup(func() { SetCredentialSource(nil) })
+
+	auth, ok := CredentialsFor("123456789012.dkr.ecr.eu-west-1.amazonaws.com/app:latest")
+	if !ok || auth.Password != "fresh" {
+		t.Errorf("ECR credentials = %+v, %v; want the fresh token from the source", auth, ok)
+	}
+	auth, ok = CredentialsFor("ghcr.io/team/app:latest")
+	if !ok || auth.Password != "pass" {
+		t.Errorf("ghcr.io credentials = %+v, %v; want config.json fallback", auth, ok)
+	}
+}
+
+// installCredHelpers puts fake docker-credential-<name> binaries on PATH.
+// Each answers `get` by running its shell snippet, which sees the server URL
+// in $server.
+func installCredHelpers(t *testing.T, helpers map[string]string) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, script := range helpers {
+		content := "#!/bin/sh\n[ \"$1\" = get ] || exit 1\nread -r server\n" + script + "\n"
+		if err := os.WriteFile(filepath.Join(dir, "docker-credential-"+name), []byte(content), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", dir)
+}
+
+func TestCredentialsForHelpers(t *testing.T) {
+	writeDockerConfig(t, `{
+		"auths": {"ghcr.io": {"auth": "`+base64.StdEncoding.EncodeToString([]byte("inline:pass"))+`"}},
+		"credsStore": "store",
+		"credHelpers": {"gcr.io": "gcloud", "ghcr.io": "broken"}
+	}`)
+	installCredHelpers(t, map[string]string{
+		"gcloud": `echo '{"ServerURL":"'$server'","Username":"oauth2accesstoken","Secret":"ya29.fresh"}'`,
+		"store": `case $server in
+https://index.docker.io/v1/) echo '{"ServerURL":"'$server'","Username":"<token>","Secret":"identity"}' ;;
+*) echo "credentials not found in native keychain"; exit 1 ;;
+esac`,
+		"broken": `echo "helper exploded" >&2; exit 1`,
+	})
+
+	tests := []struct {
+		name   string
+		image  string
+		want   registry.AuthConfig
+		wantOK bool
+	}{
+		{
+			name:   "credHelpers entry for the registry",
+			image:  "gcr.io/project/app:latest",
+			want:   registry.AuthConfig{ServerAddress: "gcr.io", Username: "oauth2accesstoken", Password: "ya29.fresh"},
+			wantOK: true,
+		},
+		{
+			name:   "credsStore default with an identity token",
+			image:  "nginx:latest",
+			want:   registry.AuthConfig{ServerAddress: "docker.io", IdentityToken: "identity"},
+			wantOK: true,
+		},
+		{
+			name:  "credsStore has nothing stored",
+			image: "quay.io/team/app:latest",
+		},
+		{
+			name:   "failing helper falls back to inline auths",
+			image:  "ghcr.io/team/app:latest",
+			want:   registry.AuthConfig{ServerAddress: "ghcr.io", Username: "inline", Password: "pass"},
+			wantOK: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := CredentialsFor(tt.image)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("CredentialsFor(%q) = %+v, %v; want %+v, %v", tt.image, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
@@ -2,15 +2,31 @@ package docker
 
 import (
 	"context"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/docker/docker/client"
+	"github.com/fanuelsen/repull/internal/useragent"
 )
 
+// defaultSocket is where the Docker client looks without DOCKER_HOST.
+const defaultSocket = "/var/run/docker.sock"
+
 // NewClient creates a new Docker API client using environment variables.
-// Respects DOCKER_HOST for remote Docker daemons.
+// Respects DOCKER_HOST for remote Docker daemons; without it, a rootless
+// daemon's socket is found as well (see discoverHost). Requests carry
+// repull's User-Agent (see useragent), e.g. for a socket proxy's logs.
 func NewClient() (*client.Client, error) {
-	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	opts := []client.Opt{client.FromEnv, client.WithAPIVersionNegotiation(), client.WithUserAgent(useragent.Get())}
+	if os.Getenv("DOCKER_HOST") == "" {
+		host := discoverHost(os.Getenv, socketReachable)
+		log.Printf("[INFO] Using Docker socket %s", host)
+		opts = append(opts, client.WithHost(host))
+	}
+	cli, err := client.NewClientWithOpts(opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -26,3 +42,30 @@ func NewClient() (*client.Client, error) {
 
 	return cli, nil
 }
+
+// discoverHost returns the Docker host to use when DOCKER_HOST is not set:
+// the default socket if it is reachable, else a rootless daemon's socket
+// at $XDG_RUNTIME_DIR/docker.sock. With neither reachable it falls back to
+// the default socket, so the error names the usual location.
+func discoverHost(getenv func(string) string, reachable func(path string) bool) string {
+	candidates := []string{defaultSocket}
+	if dir := getenv("XDG_RUNTIME_DIR"); dir != "" {
+		candidates = append(candidates, filepath.Join(dir, "docker.sock"))
+	}
+	for _, path := range candidates {
+		if reachable(path) {
+			return "unix://" + path
+		}
+	}
+	return "unix://" + defaultSocket
+}
+
+// socketReachable reports whether a Unix socket accepts connections.
+func socketReachable(path string) bool {
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
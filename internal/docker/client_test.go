@@ -0,0 +1,76 @@
+package docker
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+)
+
+func TestDiscoverHost(t *testing.T) {
+	tests := []struct {
+		name      string
+		xdg       string
+		reachable map[string]bool
+		want      string
+	}{
+		{
+			name:      "default socket wins",
+			xdg:       "/run/user/1000",
+			reachable: map[string]bool{"/var/run/docker.sock": true, "/run/user/1000/docker.sock": true},
+			want:      "unix:///var/run/docker.sock",
+		},
+		{
+			name:      "rootless socket when the default is unreachable",
+			xdg:       "/run/user/1000",
+			reachable: map[string]bool{"/run/user/1000/docker.sock": true},
+			want:      "unix:///run/user/1000/docker.sock",
+		},
+		{
+			name:      "no XDG_RUNTIME_DIR",
+			reachable: map[string]bool{"/run/user/1000/docker.sock": true},
+			want:      "unix:///var/run/docker.sock",
+		},
+		{
+			name: "nothing reachable falls back to the default",
+			xdg:  "/run/user/1000",
+			want: "unix:///var/run/docker.sock",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var probed []string
+			getenv := func(name string) string {
+				if name == "XDG_RUNTIME_DIR" {
+					return tt.xdg
+				}
+				return ""
+			}
+			reachable := func(path string) bool {
+				probed = append(probed, path)
+				return tt.reachable[path]
+			}
+			if got := discoverHost(getenv, reachable); got != tt.want {
+				t.Errorf("discoverHost() = %q, want %q (probed %v)", got, tt.want, probed)
+			}
+			if len(probed) == 0 || probed[0] != defaultSocket {
+				t.Errorf("probed %v, want the default socket first", probed)
+			}
+		})
+	}
+}
+
+func TestSocketReachable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "docker.sock")
+	if socketReachable(path) {
+		t.Error("socketReachable() = true for a missing socket")
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skipf("cannot listen on a Unix socket: %v", err)
+	}
+	defer ln.Close()
+	if !socketReachable(path) {
+		t.Error("socketReachable() = false for a listening socket")
+	}
+}
@@ -2,15 +2,19 @@ package docker
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"slices"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/mount"
 	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/client"
 	"github.com/docker/go-connections/nat"
@@ -45,10 +49,13 @@ func ShortID(id string) string {
 //
 // The recreated parameter contains a mapping of old container IDs to new IDs
 // for containers that were recreated in the current update cycle.
-func resolveNetworkMode(ctx context.Context, cli *client.Client, mode container.NetworkMode, recreated RecreatedContainers) container.NetworkMode {
+//
+// If the reference cannot be resolved, mode is returned unchanged together
+// with an error saying why.
+func resolveNetworkMode(ctx context.Context, cli *client.Client, mode container.NetworkMode, recreated RecreatedContainers) (container.NetworkMode, error) {
 	modeStr := string(mode)
 	if !strings.HasPrefix(modeStr, "container:") {
-		return mode
+		return mode, nil
 	}
 
 	// Extract the container reference (could be ID or name)
@@ -57,12 +64,12 @@ func resolveNetworkMode(ctx context.Context, cli *client.Client, mode container.
 	// First, check if this references a container we just recreated
 	if recreated != nil {
 		if newID, ok := recreated[ref]; ok {
-			return container.NetworkMode("container:" + newID)
+			return container.NetworkMode("container:" + newID), nil
 		}
 		// Also check partial ID matches (Docker often uses short IDs)
 		for oldID, newID := range recreated {
 			if strings.HasPrefix(oldID, ref) || strings.HasPrefix(ref, ShortID(oldID)) {
-				return container.NetworkMode("container:" + newID)
+				return container.NetworkMode("container:" + newID), nil
 			}
 		}
 	}
@@ -71,16 +78,15 @@ func resolveNetworkMode(ctx context.Context, cli *client.Client, mode container.
 	inspect, err := cli.ContainerInspect(ctx, ref)
 	if err == nil {
 		// Container exists, use its current ID
-		return container.NetworkMode("container:" + inspect.ID)
+		return container.NetworkMode("container:" + inspect.ID), nil
 	}
 
 	// Container not found by that reference - it might be a stale ID
 	// Try to find a container by searching all containers for a matching name
 	// Docker names have a leading slash, so we check both with and without
-	containers, err := cli.ContainerList(ctx, container.ListOptions{All: true})
-	if err != nil {
-		// Can't list containers, return original mode and let it fail later
-		return mode
+	containers, listErr := cli.ContainerList(ctx, container.ListOptions{All: true})
+	if listErr != nil {
+		return mode, fmt.Errorf("failed to list containers: %w", listErr)
 	}
 
 	for _, c := range containers {
@@ -88,13 +94,12 @@ func resolveNetworkMode(ctx context.Context, cli *client.Client, mode container.
 			// Docker container names have a leading slash
 			cleanName := strings.TrimPrefix(name, "/")
 			if cleanName == ref || name == ref {
-				return container.NetworkMode("container:" + c.ID)
+				return container.NetworkMode("container:" + c.ID), nil
 			}
 		}
 	}
 
-	// Couldn't resolve, return original mode
-	return mode
+	return mode, err
 }
 
 // FindNetworkDependents returns all running containers whose network_mode
@@ -138,7 +143,8 @@ func FindNetworkDependents(ctx context.Context, cli *client.Client, containerID
 // does it on the next startup.
 //
 // A leftover is identified by the rename pattern self-update produces
-// ("<name>-old-<its own short ID>"), not by the io.repull.app label alone.
+// ("<name>-old-<its own short ID>", or whatever --old-name-template makes of
+// the short ID), not by the io.repull.app label alone.
 // Docker merges image labels into container labels, so trusting the label
 // would let any third-party image carrying io.repull.app=true get unrelated
 // containers force-removed here — including, if it sorted newer, repull
@@ -146,8 +152,12 @@ func FindNetworkDependents(ctx context.Context, cli *client.Client, containerID
 // label cannot forge. The label filter remains as a cheap server-side
 // pre-filter only.
 //
+// Only leftovers that have exited at least grace ago are removed. A restart
+// right after a self-update can otherwise catch the old instance while it is
+// still shutting down; a later startup removes it.
+//
 // Returns the names of containers that were removed.
-func CleanupSelfUpdateLeftovers(ctx context.Context, cli *client.Client) ([]string, error) {
+func CleanupSelfUpdateLeftovers(ctx context.Context, cli *client.Client, grace time.Duration) ([]string, error) {
 	filter := filters.NewArgs()
 	filter.Add("label", "io.repull.app=true")
 
@@ -175,6 +185,18 @@ func CleanupSelfUpdateLeftovers(ctx context.Context, cli *client.Client) ([]stri
 		if !isSelfUpdateLeftover(name, c.ID) {
 			continue
 		}
+		if c.State != container.StateExited {
+			log.Printf("[INFO] Leaving self-update leftover %s: it is %s, not exited", name, c.State)
+			continue
+		}
+		inspect, err := cli.ContainerInspect(ctx, c.ID)
+		if err != nil {
+			continue
+		}
+		if !exitedBefore(inspect, time.Now().Add(-grace)) {
+			log.Printf("[INFO] Leaving self-update leftover %s: it exited less than %s ago", name, grace)
+			continue
+		}
 		if err := cli.ContainerRemove(ctx, c.ID, container.RemoveOptions{Force: true}); err != nil {
 			continue
 		}
@@ -183,20 +205,73 @@ func CleanupSelfUpdateLeftovers(ctx context.Context, cli *client.Client) ([]stri
 	return removed, nil
 }
 
+// exitedBefore reports whether c has exited, and did so before cutoff. An
+// unknown exit time counts as recent.
+func exitedBefore(c container.InspectResponse, cutoff time.Time) bool {
+	if c.ContainerJSONBase == nil || c.State == nil || c.State.Status != container.StateExited {
+		return false
+	}
+	finished, err := time.Parse(time.RFC3339Nano, c.State.FinishedAt)
+	if err != nil || finished.IsZero() {
+		return false
+	}
+	return finished.Before(cutoff)
+}
+
 // isSelfUpdateLeftover reports whether a container is the remnant of a
 // previous self-update: its name ends in the "-old-<short ID>" suffix that
 // updateRepullInstance appends on rename, where the short ID is the
 // container's own. A name that is only the suffix (empty original name)
-// does not count.
+// does not count. With a custom --old-name-template the short ID may sit
+// anywhere in the name, so it only has to contain it.
 func isSelfUpdateLeftover(name, id string) bool {
+	if oldNameCustom {
+		short := ShortID(id)
+		return len(name) > len(short) && strings.Contains(name, short)
+	}
 	suffix := "-old-" + ShortID(id)
 	return len(name) > len(suffix) && strings.HasSuffix(name, suffix)
 }
 
-// ListRunningContainers returns all currently running containers.
-func ListRunningContainers(ctx context.Context, cli *client.Client) ([]container.InspectResponse, error) {
+// ContainerNames returns the names, without the leading slash, of all
+// containers on the host, running or not.
+func ContainerNames(ctx context.Context, cli *client.Client) (map[string]bool, error) {
+	containers, err := cli.ContainerList(ctx, container.ListOptions{All: true})
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]bool, len(containers))
+	for _, c := range containers {
+		for _, name := range c.Names {
+			names[strings.TrimPrefix(name, "/")] = true
+		}
+	}
+	return names, nil
+}
+
+// DefaultInspectConcurrency is how many containers ListRunningContainers
+// inspects at once, unless SetInspectConcurrency says otherwise.
+const DefaultInspectConcurrency = 4
+
+var inspectConcurrency = DefaultInspectConcurrency
+
+// SetInspectConcurrency sets how many containers ListRunningContainers
+// inspects at once (--inspect-concurrency); n < 1 means one at a time.
+func SetInspectConcurrency(n int) {
+	inspectConcurrency = max(n, 1)
+}
+
+// ListRunningContainers returns the currently running containers, in the
+// order the daemon lists them. Given labels (key or key=value), only the
+// containers carrying all of them are listed, so no inspect is spent on the
+// rest. The containers are inspected concurrently, up to the limit set with
+// SetInspectConcurrency.
+func ListRunningContainers(ctx context.Context, cli *client.Client, labels ...string) ([]container.InspectResponse, error) {
 	filter := filters.NewArgs()
 	filter.Add("status", "running")
+	for _, label := range labels {
+		filter.Add("label", label)
+	}
 
 	containers, err := cli.ContainerList(ctx, container.ListOptions{
 		Filters: filter,
@@ -207,17 +282,121 @@ func ListRunningContainers(ctx context.Context, cli *client.Client) ([]container
 
 	// Get full container details. A container can exit between the list and
 	// the inspect calls — skip it instead of failing the whole update cycle.
+	inspected := make([]*container.InspectResponse, len(containers))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, inspectConcurrency)
+	for i, c := range containers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			inspect, err := cli.ContainerInspect(ctx, c.ID)
+			if err != nil {
+				log.Printf("[WARN] Skipping container %s: inspect failed: %v", ShortID(c.ID), err)
+				return
+			}
+			if err := checkInspect(inspect); err != nil {
+				log.Printf("[WARN] Skipping container %s: %v", ShortID(c.ID), err)
+				return
+			}
+			inspected[i] = &inspect
+		}()
+	}
+	wg.Wait()
+
 	var detailed []container.InspectResponse
-	for _, c := range containers {
-		inspect, err := cli.ContainerInspect(ctx, c.ID)
-		if err != nil {
-			log.Printf("[WARN] Skipping container %s: inspect failed: %v", ShortID(c.ID), err)
+	for _, inspect := range inspected {
+		if inspect != nil {
+			detailed = append(detailed, *inspect)
+		}
+	}
+	return detailed, nil
+}
+
+// checkInspect reports an inspect response that lacks the parts recreation
+// reads. The daemon can return one for a container caught mid-removal or in
+// an odd state; using it would panic halfway through a recreate and leave
+// the container stopped.
+func checkInspect(c container.InspectResponse) error {
+	switch {
+	case c.ContainerJSONBase == nil:
+		return errors.New("incomplete inspect response: no container details")
+	case c.HostConfig == nil:
+		return fmt.Errorf("incomplete inspect response for %s: no host config", ShortID(c.ID))
+	case c.Config == nil:
+		return fmt.Errorf("incomplete inspect response for %s: no config", ShortID(c.ID))
+	}
+	return nil
+}
+
+// ResetLabel lists container config fields that recreation should not carry
+// over, e.g. io.repull.reset=env,cmd. The new container then gets the image's
+// defaults for those fields — useful to drop a stale override.
+const ResetLabel = "io.repull.reset"
+
+// resetFieldNames is the set of field names ResetLabel accepts. Each maps to a
+// container.Config field that Docker fills from the image when left empty.
+var resetFieldNames = map[string]bool{
+	"env":         true,
+	"cmd":         true,
+	"entrypoint":  true,
+	"workdir":     true,
+	"user":        true,
+	"healthcheck": true,
+	"stopsignal":  true,
+}
+
+// ResetFields parses the io.repull.reset label of a container into the set of
+// fields to reset. Returns an error naming the first unsupported field, so a
+// typo fails the update before anything is stopped instead of being ignored.
+func ResetFields(c container.InspectResponse) (map[string]bool, error) {
+	if c.Config == nil || c.Config.Labels[ResetLabel] == "" {
+		return nil, nil
+	}
+	fields := make(map[string]bool)
+	for _, name := range strings.Split(c.Config.Labels[ResetLabel], ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
 			continue
 		}
-		detailed = append(detailed, inspect)
+		if !resetFieldNames[name] {
+			return nil, fmt.Errorf("invalid %s field %q (supported: env, cmd, entrypoint, workdir, user, healthcheck, stopsignal)", ResetLabel, name)
+		}
+		fields[name] = true
 	}
+	return fields, nil
+}
 
-	return detailed, nil
+// applyReset clears the fields named in reset so Docker falls back to the
+// image's defaults for them.
+func applyReset(config *container.Config, reset map[string]bool) {
+	if reset["env"] {
+		config.Env = nil
+	}
+	if reset["cmd"] {
+		config.Cmd = nil
+	}
+	if reset["entrypoint"] {
+		config.Entrypoint = nil
+	}
+	// ArgsEscaped describes the command being replaced by the image's.
+	if reset["cmd"] || reset["entrypoint"] {
+		config.ArgsEscaped = false
+	}
+	if reset["workdir"] {
+		config.WorkingDir = ""
+	}
+	if reset["user"] {
+		config.User = ""
+	}
+	if reset["healthcheck"] {
+		config.Healthcheck = nil
+	}
+	if reset["stopsignal"] {
+		config.StopSignal = ""
+	}
 }
 
 // containerConfigs holds the configs needed to create a new container.
@@ -229,11 +408,19 @@ type containerConfigs struct {
 	additionalNetworks []string
 	// endpoints holds the sanitized endpoint settings for every network, keyed by network name.
 	endpoints map[string]*network.EndpointSettings
+	// networkSpecs holds the networks' settings captured for
+	// MissingNetworkRecreate (see captureNetworks).
+	networkSpecs map[string]network.Inspect
 }
 
 // sanitizeEndpoint copies the parts of an endpoint's settings that represent
-// user configuration (static IPs, aliases, links, driver options) and drops
-// runtime state (endpoint ID, assigned IP and MAC addresses, DNS names).
+// user configuration (static IPv4 and IPv6 addresses, aliases, links, driver
+// options) and drops runtime state (endpoint ID, assigned IP and MAC
+// addresses, DNS names).
+//
+// Static addresses live in IPAMConfig; IPAddress and GlobalIPv6Address only
+// report what the daemon assigned, which for a static address is the same
+// value again and for a dynamic one must not be pinned.
 // Reusing runtime fields on a new container would pin stale values — for
 // example the old container's auto-assigned MAC address, or a DNS alias
 // pointing at the old container's short ID.
@@ -243,7 +430,7 @@ func sanitizeEndpoint(old *network.EndpointSettings, oldContainerID string) *net
 	}
 
 	ep := &network.EndpointSettings{
-		IPAMConfig: old.IPAMConfig,
+		IPAMConfig: copyIPAMConfig(old.IPAMConfig),
 		Links:      old.Links,
 		DriverOpts: old.DriverOpts,
 		GwPriority: old.GwPriority,
@@ -261,6 +448,60 @@ func sanitizeEndpoint(old *network.EndpointSettings, oldContainerID string) *net
 	return ep
 }
 
+// anonymousVolumeBinds returns binds that reattach the old container's
+// anonymous volumes — those from Config.Volumes or an image VOLUME that no
+// bind or mount names — at their original paths, the way Compose does on
+// recreate. Without them the new container would get fresh, empty volumes
+// and the data would be left behind in volumes nothing uses.
+//
+// Containers using VolumesFrom are left alone: volumes inherited that way
+// also show up as mounts, and binding them again would collide.
+func anonymousVolumeBinds(old container.InspectResponse) []string {
+	if old.HostConfig == nil || len(old.HostConfig.VolumesFrom) > 0 {
+		return nil
+	}
+
+	covered := make(map[string]bool)
+	for _, b := range old.HostConfig.Binds {
+		parts := strings.Split(b, ":")
+		if len(parts) >= 2 {
+			covered[parts[1]] = true
+		} else {
+			covered[parts[0]] = true
+		}
+	}
+	for _, m := range old.HostConfig.Mounts {
+		covered[m.Target] = true
+	}
+
+	var binds []string
+	for _, m := range old.Mounts {
+		if m.Type != mount.TypeVolume || m.Name == "" || covered[m.Destination] {
+			continue
+		}
+		bind := m.Name + ":" + m.Destination
+		if !m.RW {
+			bind += ":ro"
+		}
+		binds = append(binds, bind)
+	}
+	return binds
+}
+
+// copyIPAMConfig returns a copy of an endpoint's static address
+// configuration, so the new container's settings do not alias the old
+// inspect response.
+func copyIPAMConfig(old *network.EndpointIPAMConfig) *network.EndpointIPAMConfig {
+	if old == nil {
+		return nil
+	}
+	return &network.EndpointIPAMConfig{
+		IPv4Address:  old.IPv4Address,
+		IPv6Address:  old.IPv6Address,
+		LinkLocalIPs: slices.Clone(old.LinkLocalIPs),
+	}
+}
+
 // recreatePortConfig computes the exposed ports, published-port bindings, and
 // publish-all flag for a recreated container.
 //
@@ -292,7 +533,8 @@ func recreatePortConfig(cfg *container.Config, host *container.HostConfig) (nat.
 // buildContainerConfigs extracts the container, host, and network configs from
 // an existing container's inspect response. This is used by both RecreateContainer
 // and CreateAndStartContainer to avoid duplicating the config-building logic.
-func buildContainerConfigs(ctx context.Context, cli *client.Client, old container.InspectResponse, recreated RecreatedContainers) containerConfigs {
+// Fields named in reset (see ResetFields) are left for the image to fill in.
+func buildContainerConfigs(ctx context.Context, cli *client.Client, old container.InspectResponse, recreated RecreatedContainers, reset map[string]bool) containerConfigs {
 	// Inspect responses always include Config and HostConfig in practice;
 	// guard once here so a partial response can't panic the update.
 	oldConfig := old.Config
@@ -311,6 +553,8 @@ func buildContainerConfigs(ctx context.Context, cli *client.Client, old containe
 
 	exposedPorts, portBindings, publishAllPorts := recreatePortConfig(oldConfig, oldHost)
 
+	// Every label carries over, com.docker.compose.* included, so the
+	// replacement stays in its compose project and repull group.
 	config := &container.Config{
 		Image:        oldConfig.Image,
 		Cmd:          oldConfig.Cmd,
@@ -331,17 +575,25 @@ func buildContainerConfigs(ctx context.Context, cli *client.Client, old containe
 		AttachStdout: oldConfig.AttachStdout,
 		AttachStderr: oldConfig.AttachStderr,
 		Domainname:   oldConfig.Domainname,
+		// Windows containers run shell-form commands with Shell, and
+		// ArgsEscaped says Cmd is already escaped for their command line.
+		Shell:       oldConfig.Shell,
+		ArgsEscaped: oldConfig.ArgsEscaped,
 	}
 
 	if canSetHostname {
 		config.Hostname = oldConfig.Hostname
 	}
 
+	applyReset(config, reset)
+
 	// Resolve network mode in case it references a container that was recreated
-	networkMode := resolveNetworkMode(ctx, cli, oldHost.NetworkMode, recreated)
+	// RecreateContainer has already resolved it once; an error here would
+	// only surface again as a create failure.
+	networkMode, _ := resolveNetworkMode(ctx, cli, oldHost.NetworkMode, recreated)
 
 	hostConfig := &container.HostConfig{
-		Binds:           oldHost.Binds,
+		Binds:           append(slices.Clone(oldHost.Binds), anonymousVolumeBinds(old)...),
 		Mounts:          oldHost.Mounts,
 		VolumesFrom:     oldHost.VolumesFrom,
 		VolumeDriver:    oldHost.VolumeDriver,
@@ -411,19 +663,26 @@ func buildContainerConfigs(ctx context.Context, cli *client.Client, old containe
 	}
 }
 
+// RecreateOptions tunes RecreateContainer.
+type RecreateOptions struct {
+	// NoStart leaves the replacement created but stopped (--no-start), e.g.
+	// to inspect it before starting it by hand. Self-updates always start
+	// the replacement: nothing else would.
+	NoStart bool
+}
+
 // createAndConnectNetworks creates a container, connects it to additional networks,
-// and starts it. On any failure the partially-created container is removed.
-// Returns the new container ID.
-func createAndConnectNetworks(ctx context.Context, cli *client.Client, cc containerConfigs, name string) (string, error) {
-	resp, err := cli.ContainerCreate(ctx, cc.config, cc.hostConfig, cc.networkConfig, nil, name)
+// and starts it unless start is false. On any failure the partially-created
+// container is removed. Returns the new container ID.
+func createAndConnectNetworks(ctx context.Context, cli *client.Client, cc containerConfigs, name string, start bool) (string, error) {
+	resp, err := createContainer(ctx, cli, cc, name)
 	if err != nil {
 		return "", fmt.Errorf("failed to create container: %w", err)
 	}
 
 	// Connect to additional networks before starting
 	for _, netName := range cc.additionalNetworks {
-		endpointConfig := cc.endpoints[netName]
-		if err := cli.NetworkConnect(ctx, netName, resp.ID, endpointConfig); err != nil {
+		if err := connectNetwork(ctx, cli, cc, netName, resp.ID, name); err != nil {
 			rbCtx, cancel := RollbackContext(ctx)
 			defer cancel()
 			cli.ContainerRemove(rbCtx, resp.ID, container.RemoveOptions{Force: true})
@@ -431,6 +690,10 @@ func createAndConnectNetworks(ctx context.Context, cli *client.Client, cc contai
 		}
 	}
 
+	if !start {
+		return resp.ID, nil
+	}
+
 	// Start the new container
 	if err := cli.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
 		rbCtx, cancel := RollbackContext(ctx)
@@ -449,61 +712,173 @@ func createAndConnectNetworks(ctx context.Context, cli *client.Client, cc contai
 //
 // Uses a rename-based approach to avoid data loss: the old container is stopped
 // and renamed (not removed) before creating the new one. If creation fails, the
-// old container is renamed back and restarted as a rollback.
+// old container is renamed back and restarted as a rollback. The same happens
+// when the new container fails its io.repull.verify-cmd probe.
+// With opts.NoStart the new container is created but left stopped, and the
+// probe is skipped.
 //
 // The recreated parameter contains a mapping of old container IDs to new IDs
 // for containers that were recreated earlier in the current update cycle.
-// This is used to resolve stale network_mode references.
-func RecreateContainer(ctx context.Context, cli *client.Client, oldContainer container.InspectResponse, recreated RecreatedContainers) (string, error) {
+// This is used to resolve stale network_mode references; one that cannot be
+// resolved fails with a *NetworkResolveError before the container is
+// touched. Failures after that are returned as a *RecreateError.
+func RecreateContainer(ctx context.Context, cli *client.Client, oldContainer container.InspectResponse, recreated RecreatedContainers, opts RecreateOptions) (string, error) {
+	p, err := RecreateContainerPending(ctx, cli, oldContainer, recreated, opts)
+	if err != nil {
+		return "", err
+	}
+	p.Commit(ctx, cli)
+	return p.NewID, nil
+}
+
+// PendingRecreate is a recreate whose old container is kept, stopped and
+// renamed, until Commit removes it or Revert brings it back. It lets several
+// containers be recreated as one unit, e.g. an app and its sidecars.
+type PendingRecreate struct {
+	// NewID is the ID of the replacement container.
+	NewID   string
+	oldID   string
+	oldName string
+}
+
+// Commit removes the old container (best-effort). Uses a rollback context
+// so the removal still happens if ctx has expired.
+func (p *PendingRecreate) Commit(ctx context.Context, cli *client.Client) {
+	rmCtx, cancel := RollbackContext(ctx)
+	defer cancel()
+	cli.ContainerRemove(rmCtx, p.oldID, container.RemoveOptions{})
+}
+
+// Revert removes the replacement and renames the old container back and
+// starts it again, reporting whether the old container runs again.
+func (p *PendingRecreate) Revert(ctx context.Context, cli *client.Client) bool {
+	rbCtx, cancel := RollbackContext(ctx)
+	defer cancel()
+	cli.ContainerRemove(rbCtx, p.NewID, container.RemoveOptions{Force: true})
+	return restoreOld(rbCtx, cli, p.oldID, p.oldName)
+}
+
+// RecreateContainerPending is RecreateContainer without the final removal
+// of the old container: the caller commits or reverts the returned
+// recreate. Failures are rolled back and returned like RecreateContainer's.
+func RecreateContainerPending(ctx context.Context, cli *client.Client, oldContainer container.InspectResponse, recreated RecreatedContainers, opts RecreateOptions) (*PendingRecreate, error) {
+	if err := checkInspect(oldContainer); err != nil {
+		return nil, err
+	}
 	oldID := oldContainer.ID
 	oldName := oldContainer.Name
 
-	// Stop the old container. A nil timeout lets Docker use the container's
-	// own StopTimeout (compose stop_grace_period) or the daemon default of
+	// Validate the labels before touching the container.
+	reset, err := ResetFields(oldContainer)
+	if err != nil {
+		return nil, err
+	}
+	verifyCmd, verifyTimeout, err := verifySpec(oldContainer)
+	if err != nil {
+		return nil, err
+	}
+	ready, readyTimeout, err := readySpec(oldContainer)
+	if err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimPrefix(oldName, "/")
+	tempName, err := OldName(oldContainer, name, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	// The replacement could not be created with a network_mode pointing
+	// nowhere, so check it while the old container still runs untouched.
+	if _, err := resolveNetworkMode(ctx, cli, oldContainer.HostConfig.NetworkMode, recreated); err != nil {
+		return nil, &NetworkResolveError{
+			Container: name,
+			Ref:       strings.TrimPrefix(string(oldContainer.HostConfig.NetworkMode), "container:"),
+			Err:       err,
+		}
+	}
+
+	// Capture the networks while they still exist, in case one is gone by
+	// the time the replacement joins it.
+	networkSpecs := captureNetworks(ctx, cli, oldContainer)
+
+	// Stop the old container. Unless io.repull.stop-timeout/-signal say
+	// otherwise, a nil timeout lets Docker use the container's own
+	// StopTimeout (compose stop_grace_period) or the daemon default of
 	// 10s — a hardcoded value here would cut short containers that declare
 	// they need longer to shut down cleanly (e.g. databases).
-	if err := cli.ContainerStop(ctx, oldID, container.StopOptions{}); err != nil {
-		return "", fmt.Errorf("failed to stop container %s: %w", oldID, err)
+	if err := cli.ContainerStop(ctx, oldID, stopOptions(oldContainer)); err != nil {
+		return nil, &RecreateError{Container: name, Err: fmt.Errorf("failed to stop container %s: %w", oldID, err)}
 	}
 
 	// Rename old container to free up the name for the new one.
 	// If creation fails we can rename it back and restart as rollback.
-	tempName := oldName + "-old-" + ShortID(oldID)
 	if err := cli.ContainerRename(ctx, oldID, tempName); err != nil {
 		// Rename failed — try to restart the old container and bail
 		rbCtx, cancel := RollbackContext(ctx)
 		defer cancel()
-		cli.ContainerStart(rbCtx, oldID, container.StartOptions{})
-		return "", fmt.Errorf("failed to rename container %s: %w", oldID, err)
+		startErr := cli.ContainerStart(rbCtx, oldID, container.StartOptions{})
+		return nil, &RecreateError{Container: name, RolledBack: startErr == nil, Err: fmt.Errorf("failed to rename container %s: %w", oldID, err)}
 	}
 
-	cc := buildContainerConfigs(ctx, cli, oldContainer, recreated)
+	cc := buildContainerConfigs(ctx, cli, oldContainer, recreated, reset)
+	cc.networkSpecs = networkSpecs
+	stampUpdate(cc.config, oldContainer, time.Now())
 
-	newID, err := createAndConnectNetworks(ctx, cli, cc, oldName)
+	newID, err := createAndConnectNetworks(ctx, cli, cc, oldName, !opts.NoStart)
 	if err != nil {
 		// Rollback: rename old container back and restart it
 		rbCtx, cancel := RollbackContext(ctx)
 		defer cancel()
-		cli.ContainerRename(rbCtx, oldID, oldName)
-		cli.ContainerStart(rbCtx, oldID, container.StartOptions{})
-		return "", err
+		return nil, &RecreateError{Container: name, RolledBack: restoreOld(rbCtx, cli, oldID, oldName), Err: err}
 	}
 
-	// New container is running — clean up old one (best-effort). Uses the
-	// rollback context so the removal still happens if ctx has expired.
-	rmCtx, cancel := RollbackContext(ctx)
-	defer cancel()
-	cli.ContainerRemove(rmCtx, oldID, container.RemoveOptions{})
+	// Wait for io.repull.ready and run the io.repull.verify-cmd probe while
+	// the old container still exists, so a failure can be rolled back like
+	// a failed create. A container left stopped by --no-start has nothing
+	// to probe.
+	if !opts.NoStart {
+		var err error
+		if ready != nil {
+			err = waitReady(ctx, cli, newID, *ready, readyTimeout)
+		}
+		if err == nil && verifyCmd != "" {
+			err = verifyContainer(ctx, cli, newID, verifyCmd, verifyTimeout)
+		}
+		if err != nil {
+			rbCtx, cancel := RollbackContext(ctx)
+			defer cancel()
+			cli.ContainerRemove(rbCtx, newID, container.RemoveOptions{Force: true})
+			return nil, &RecreateError{Container: name, RolledBack: restoreOld(rbCtx, cli, oldID, oldName), Err: fmt.Errorf("rolled back: %w", err)}
+		}
+	}
+
+	return &PendingRecreate{NewID: newID, oldID: oldID, oldName: oldName}, nil
+}
 
-	return newID, nil
+// restoreOld renames the old container back to oldName and starts it again,
+// reporting whether both succeeded.
+func restoreOld(ctx context.Context, cli *client.Client, oldID, oldName string) bool {
+	renameErr := cli.ContainerRename(ctx, oldID, oldName)
+	startErr := cli.ContainerStart(ctx, oldID, container.StartOptions{})
+	return renameErr == nil && startErr == nil
 }
 
 // CreateAndStartContainer creates and starts a new container based on an existing container's config.
 // Used for self-update where we can't stop the old container before creating the new one.
 // The newName parameter specifies the name for the new container.
 func CreateAndStartContainer(ctx context.Context, cli *client.Client, oldContainer container.InspectResponse, newName string) error {
-	cc := buildContainerConfigs(ctx, cli, oldContainer, nil)
+	if err := checkInspect(oldContainer); err != nil {
+		return err
+	}
+	reset, err := ResetFields(oldContainer)
+	if err != nil {
+		return err
+	}
+
+	cc := buildContainerConfigs(ctx, cli, oldContainer, nil, reset)
+	stampUpdate(cc.config, oldContainer, time.Now())
 
-	_, err := createAndConnectNetworks(ctx, cli, cc, newName)
+	_, err = createAndConnectNetworks(ctx, cli, cc, newName, true)
 	return err
 }
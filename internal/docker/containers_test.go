@@ -1,13 +1,56 @@
 package docker
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/mount"
 	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
 	"github.com/docker/go-connections/nat"
 )
 
+// fakeDaemon serves handler as a Docker daemon and returns a client for it,
+// with the "METHOD /path?query" of every request (API version stripped) in
+// the order they arrived. The handler sees the unmodified request.
+func fakeDaemon(t *testing.T, handler http.HandlerFunc) (*client.Client, *[]string) {
+	t.Helper()
+	var mu sync.Mutex
+	var calls []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call := r.Method + " " + r.URL.Path
+		if i := strings.Index(r.URL.Path[1:], "/"); strings.HasPrefix(r.URL.Path, "/v") && i > 0 {
+			call = r.Method + " " + r.URL.Path[i+1:]
+		}
+		if r.URL.RawQuery != "" {
+			call += "?" + r.URL.RawQuery
+		}
+		mu.Lock()
+		calls = append(calls, call)
+		mu.Unlock()
+		handler(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	cli, err := client.NewClientWithOpts(client.WithHost("tcp://"+strings.TrimPrefix(srv.URL, "http://")), client.WithVersion("1.47"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cli.Close() })
+	return cli, &calls
+}
+
 // TestRecreatePortConfigDropsPortsForContainerNetns verifies that a container
 // sharing another container's network namespace (network_mode: container:/
 // service:) gets no exposed/published ports, even though its inspect response
@@ -96,6 +139,175 @@ func TestIsSelfUpdateLeftover(t *testing.T) {
 	}
 }
 
+// TestCleanupSelfUpdateLeftoversGrace verifies that only leftovers which
+// exited longer than the grace period ago are removed: one that exited a
+// moment ago, or is still running, may be an old instance shutting down.
+func TestCleanupSelfUpdateLeftoversGrace(t *testing.T) {
+	const (
+		oldID    = "aaaaaaaaaaaa0000000000000000000000000000000000000000000000000000"
+		recentID = "bbbbbbbbbbbb0000000000000000000000000000000000000000000000000000"
+		runID    = "cccccccccccc0000000000000000000000000000000000000000000000000000"
+	)
+	finished := map[string]time.Time{
+		oldID:    time.Now().Add(-time.Hour),
+		recentID: time.Now().Add(-30 * time.Second),
+	}
+
+	var mu sync.Mutex
+	var removed []string
+	cli, _ := fakeDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/containers/json"):
+			json.NewEncoder(w).Encode([]container.Summary{
+				{ID: oldID, Names: []string{"/repull-old-aaaaaaaaaaaa"}, State: container.StateExited},
+				{ID: recentID, Names: []string{"/repull-old-bbbbbbbbbbbb"}, State: container.StateExited},
+				{ID: runID, Names: []string{"/repull-old-cccccccccccc"}, State: container.StateRunning},
+			})
+		case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/json"):
+			id := strings.TrimSuffix(r.URL.Path[strings.Index(r.URL.Path, "/containers/")+len("/containers/"):], "/json")
+			json.NewEncoder(w).Encode(container.InspectResponse{ContainerJSONBase: &container.ContainerJSONBase{
+				ID:    id,
+				State: &container.State{Status: container.StateExited, FinishedAt: finished[id].Format(time.RFC3339Nano)},
+			}})
+		case r.Method == http.MethodDelete:
+			mu.Lock()
+			removed = append(removed, r.URL.Path)
+			mu.Unlock()
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	got, err := CleanupSelfUpdateLeftovers(t.Context(), cli, 5*time.Minute)
+	if err != nil {
+		t.Fatalf("CleanupSelfUpdateLeftovers() error = %v", err)
+	}
+	if len(got) != 1 || got[0] != "repull-old-aaaaaaaaaaaa" {
+		t.Errorf("removed %v, want only repull-old-aaaaaaaaaaaa", got)
+	}
+	if len(removed) != 1 || !strings.HasSuffix(removed[0], "/containers/"+oldID) {
+		t.Errorf("DELETE calls = %v, want only %s", removed, oldID)
+	}
+}
+
+// TestListRunningContainersConcurrency verifies the inspects run in
+// parallel but never more than the configured number at once, and that the
+// result keeps the list order and skips a container that fails to inspect.
+func TestListRunningContainersConcurrency(t *testing.T) {
+	const limit = 3
+	SetInspectConcurrency(limit)
+	t.Cleanup(func() { SetInspectConcurrency(DefaultInspectConcurrency) })
+
+	var ids []string
+	for i := range 12 {
+		ids = append(ids, fmt.Sprintf("c%02d", i))
+	}
+
+	var mu sync.Mutex
+	inFlight, peak := 0, 0
+	cli, _ := fakeDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasSuffix(r.URL.Path, "/containers/json") {
+			var list []container.Summary
+			for _, id := range ids {
+				list = append(list, container.Summary{ID: id})
+			}
+			json.NewEncoder(w).Encode(list)
+			return
+		}
+		mu.Lock()
+		inFlight++
+		peak = max(peak, inFlight)
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+
+		id := strings.TrimSuffix(r.URL.Path[strings.Index(r.URL.Path, "/containers/")+len("/containers/"):], "/json")
+		if id == "c05" {
+			http.Error(w, `{"message":"No such container"}`, http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(container.InspectResponse{
+			ContainerJSONBase: &container.ContainerJSONBase{ID: id, HostConfig: &container.HostConfig{}},
+			Config:            &container.Config{},
+		})
+	})
+
+	got, err := ListRunningContainers(t.Context(), cli)
+	if err != nil {
+		t.Fatalf("ListRunningContainers() error = %v", err)
+	}
+	var gotIDs []string
+	for _, c := range got {
+		gotIDs = append(gotIDs, c.ID)
+	}
+	want := slices.Delete(slices.Clone(ids), 5, 6)
+	if !reflect.DeepEqual(gotIDs, want) {
+		t.Errorf("containers = %v, want %v", gotIDs, want)
+	}
+	if peak > limit || peak < 2 {
+		t.Errorf("peak concurrent inspects = %d, want 2..%d", peak, limit)
+	}
+}
+
+// TestListRunningContainersLabelFilter verifies the label filter goes to
+// the daemon with the list call, so containers without the label are never
+// inspected.
+func TestListRunningContainersLabelFilter(t *testing.T) {
+	all := []container.Summary{
+		{ID: "web", Labels: map[string]string{"io.repull.enable": "true"}},
+		{ID: "db", Labels: map[string]string{}},
+		{ID: "cache", Labels: map[string]string{"io.repull.enable": "false"}},
+	}
+	var mu sync.Mutex
+	var inspected []string
+	cli, _ := fakeDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasSuffix(r.URL.Path, "/containers/json") {
+			args, err := filters.FromJSON(r.URL.Query().Get("filters"))
+			if err != nil {
+				t.Errorf("bad filters: %v", err)
+			}
+			var list []container.Summary
+			for _, c := range all {
+				if args.MatchKVList("label", c.Labels) {
+					list = append(list, c)
+				}
+			}
+			json.NewEncoder(w).Encode(list)
+			return
+		}
+		id := strings.TrimSuffix(r.URL.Path[strings.Index(r.URL.Path, "/containers/")+len("/containers/"):], "/json")
+		mu.Lock()
+		inspected = append(inspected, id)
+		mu.Unlock()
+		json.NewEncoder(w).Encode(container.InspectResponse{
+			ContainerJSONBase: &container.ContainerJSONBase{ID: id, HostConfig: &container.HostConfig{}},
+			Config:            &container.Config{},
+		})
+	})
+
+	got, err := ListRunningContainers(t.Context(), cli, "io.repull.enable=true")
+	if err != nil {
+		t.Fatalf("ListRunningContainers() error = %v", err)
+	}
+	if len(got) != 1 || got[0].ID != "web" {
+		t.Errorf("ListRunningContainers() returned %d container(s), want web only", len(got))
+	}
+	if !reflect.DeepEqual(inspected, []string{"web"}) {
+		t.Errorf("inspected %v, want web only", inspected)
+	}
+
+	inspected = nil
+	if got, _ := ListRunningContainers(t.Context(), cli); len(got) != 3 || len(inspected) != 3 {
+		t.Errorf("without labels: listed %d, inspected %d, want all 3", len(got), len(inspected))
+	}
+}
+
 func TestSanitizeEndpoint(t *testing.T) {
 	oldContainerID := "abcdef123456789012345678901234567890"
 	oldShort := ShortID(oldContainerID)
@@ -147,3 +359,426 @@ func TestSanitizeEndpoint(t *testing.T) {
 		}
 	})
 }
+
+func TestResetFields(t *testing.T) {
+	withLabel := func(v string) container.InspectResponse {
+		return container.InspectResponse{Config: &container.Config{Labels: map[string]string{ResetLabel: v}}}
+	}
+
+	tests := []struct {
+		name    string
+		c       container.InspectResponse
+		want    []string
+		wantErr bool
+	}{
+		{name: "no label", c: container.InspectResponse{Config: &container.Config{}}},
+		{name: "nil config", c: container.InspectResponse{}},
+		{name: "env and cmd", c: withLabel("env,cmd"), want: []string{"env", "cmd"}},
+		{name: "spaces and case", c: withLabel(" Env , CMD "), want: []string{"env", "cmd"}},
+		{name: "unsupported field", c: withLabel("env,ports"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResetFields(tt.c)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ResetFields() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ResetFields() = %v, want %v", got, tt.want)
+			}
+			for _, f := range tt.want {
+				if !got[f] {
+					t.Errorf("ResetFields() missing %q: %v", f, got)
+				}
+			}
+		})
+	}
+}
+
+// TestBuildContainerConfigsResetEnv verifies that io.repull.reset=env leaves
+// Env unset on the new container, so Docker falls back to the image's
+// environment instead of carrying the old override over.
+func TestBuildContainerConfigsResetEnv(t *testing.T) {
+	old := container.InspectResponse{
+		ContainerJSONBase: &container.ContainerJSONBase{
+			ID:         "abcdef123456789012345678901234567890",
+			HostConfig: &container.HostConfig{NetworkMode: "bridge"},
+		},
+		Config: &container.Config{
+			Image:  "app:latest",
+			Env:    []string{"STALE=1", "PATH=/usr/bin"},
+			Cmd:    []string{"serve"},
+			Labels: map[string]string{ResetLabel: "env"},
+		},
+	}
+
+	reset, err := ResetFields(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cc := buildContainerConfigs(t.Context(), nil, old, nil, reset)
+
+	if cc.config.Env != nil {
+		t.Errorf("Env = %v, want nil (image default)", cc.config.Env)
+	}
+	if len(cc.config.Cmd) != 1 || cc.config.Cmd[0] != "serve" {
+		t.Errorf("Cmd = %v, want [serve] (not reset)", cc.config.Cmd)
+	}
+}
+
+// TestBuildContainerConfigsKeepsTTY verifies that a container created with
+// `docker run -it` keeps its terminal and stdin settings on recreate.
+func TestBuildContainerConfigsKeepsTTY(t *testing.T) {
+	old := container.InspectResponse{
+		ContainerJSONBase: &container.ContainerJSONBase{
+			ID:         "abcdef123456789012345678901234567890",
+			HostConfig: &container.HostConfig{NetworkMode: "bridge"},
+		},
+		Config: &container.Config{
+			Image:        "alpine:latest",
+			Cmd:          []string{"sh"},
+			Tty:          true,
+			OpenStdin:    true,
+			StdinOnce:    true,
+			AttachStdin:  true,
+			AttachStdout: true,
+			AttachStderr: true,
+		},
+	}
+
+	cc := buildContainerConfigs(t.Context(), nil, old, nil, nil)
+
+	got := cc.config
+	if !got.Tty || !got.OpenStdin || !got.StdinOnce || !got.AttachStdin || !got.AttachStdout || !got.AttachStderr {
+		t.Errorf("Tty/OpenStdin/StdinOnce/AttachStdin/AttachStdout/AttachStderr = %v/%v/%v/%v/%v/%v, want all true",
+			got.Tty, got.OpenStdin, got.StdinOnce, got.AttachStdin, got.AttachStdout, got.AttachStderr)
+	}
+}
+
+// TestBuildContainerConfigsKeepsHardening verifies that custom masked and
+// read-only paths and OCI annotations survive recreation: dropping them
+// would silently weaken a hardened container.
+func TestBuildContainerConfigsKeepsHardening(t *testing.T) {
+	masked := []string{"/proc/kcore", "/proc/keys", "/sys/firmware", "/srv/secrets"}
+	readonly := []string{"/proc/sys", "/proc/sysrq-trigger", "/etc/app"}
+	annotations := map[string]string{"io.kubernetes.cri-o.userns-mode": "auto", "org.example.policy": "strict"}
+	old := container.InspectResponse{
+		ContainerJSONBase: &container.ContainerJSONBase{
+			ID: "abcdef123456789012345678901234567890",
+			HostConfig: &container.HostConfig{
+				NetworkMode:   "bridge",
+				MaskedPaths:   masked,
+				ReadonlyPaths: readonly,
+				Annotations:   annotations,
+			},
+		},
+		Config: &container.Config{Image: "alpine:latest"},
+	}
+
+	cc := buildContainerConfigs(t.Context(), nil, old, nil, nil)
+
+	got := cc.hostConfig
+	if !reflect.DeepEqual(got.MaskedPaths, masked) {
+		t.Errorf("MaskedPaths = %v, want %v", got.MaskedPaths, masked)
+	}
+	if !reflect.DeepEqual(got.ReadonlyPaths, readonly) {
+		t.Errorf("ReadonlyPaths = %v, want %v", got.ReadonlyPaths, readonly)
+	}
+	if !reflect.DeepEqual(got.Annotations, annotations) {
+		t.Errorf("Annotations = %v, want %v", got.Annotations, annotations)
+	}
+}
+
+// TestBuildContainerConfigsKeepsWindowsShell verifies a Windows container's
+// Shell and ArgsEscaped carry over, and that ArgsEscaped is dropped along
+// with a command reset to the image's.
+func TestBuildContainerConfigsKeepsWindowsShell(t *testing.T) {
+	shell := []string{"powershell", "-Command", "$ErrorActionPreference = 'Stop';"}
+	tests := []struct {
+		name        string
+		reset       map[string]bool
+		wantEscaped bool
+	}{
+		{name: "no reset", wantEscaped: true},
+		{name: "cmd reset", reset: map[string]bool{"cmd": true}, wantEscaped: false},
+		{name: "entrypoint reset", reset: map[string]bool{"entrypoint": true}, wantEscaped: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			old := container.InspectResponse{
+				ContainerJSONBase: &container.ContainerJSONBase{
+					ID:         "abcdef123456789012345678901234567890",
+					HostConfig: &container.HostConfig{NetworkMode: "nat"},
+				},
+				Config: &container.Config{
+					Image:       "mcr.microsoft.com/windows/servercore:ltsc2022",
+					Cmd:         []string{`cmd /S /C "C:\app\start.cmd"`},
+					Shell:       shell,
+					ArgsEscaped: true,
+				},
+			}
+
+			cc := buildContainerConfigs(t.Context(), nil, old, nil, tt.reset)
+
+			if !reflect.DeepEqual([]string(cc.config.Shell), shell) {
+				t.Errorf("Shell = %v, want %v", cc.config.Shell, shell)
+			}
+			if cc.config.ArgsEscaped != tt.wantEscaped {
+				t.Errorf("ArgsEscaped = %v, want %v", cc.config.ArgsEscaped, tt.wantEscaped)
+			}
+		})
+	}
+}
+
+// TestRecreateRejectsIncompleteInspect verifies that a partially populated
+// inspect response aborts before any Docker call is made (cli is nil, so a
+// call would panic) instead of stopping the container and then panicking.
+func TestRecreateRejectsIncompleteInspect(t *testing.T) {
+	base := func() *container.ContainerJSONBase {
+		return &container.ContainerJSONBase{ID: "abcdef123456789012345678901234567890", Name: "/web", HostConfig: &container.HostConfig{}}
+	}
+	noHost := base()
+	noHost.HostConfig = nil
+
+	tests := []struct {
+		name string
+		c    container.InspectResponse
+	}{
+		{name: "nil base", c: container.InspectResponse{Config: &container.Config{}}},
+		{name: "nil host config", c: container.InspectResponse{ContainerJSONBase: noHost, Config: &container.Config{}}},
+		{name: "nil config", c: container.InspectResponse{ContainerJSONBase: base()}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := RecreateContainer(t.Context(), nil, tt.c, nil, RecreateOptions{}); err == nil {
+				t.Error("RecreateContainer() error = nil, want incomplete inspect error")
+			}
+			if err := CreateAndStartContainer(t.Context(), nil, tt.c, "web"); err == nil {
+				t.Error("CreateAndStartContainer() error = nil, want incomplete inspect error")
+			}
+		})
+	}
+}
+
+// TestRecreateContainerNoStart verifies that with NoStart the replacement
+// is created but ContainerStart is never called.
+func TestRecreateContainerNoStart(t *testing.T) {
+	cli, calls := fakeDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/containers/create") {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"Id":"newcontainer"}`))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	old := container.InspectResponse{
+		ContainerJSONBase: &container.ContainerJSONBase{
+			ID:         "abcdef123456789012345678901234567890",
+			Name:       "/web",
+			HostConfig: &container.HostConfig{NetworkMode: "bridge"},
+		},
+		Config: &container.Config{Image: "nginx:latest"},
+	}
+
+	newID, err := RecreateContainer(t.Context(), cli, old, nil, RecreateOptions{NoStart: true})
+	if err != nil {
+		t.Fatalf("RecreateContainer() error = %v", err)
+	}
+	if newID != "newcontainer" {
+		t.Errorf("newID = %q, want newcontainer", newID)
+	}
+	created := false
+	for _, c := range *calls {
+		if strings.HasPrefix(c, "POST /containers/create") {
+			created = true
+		}
+		if strings.HasSuffix(c, "/start") {
+			t.Errorf("ContainerStart called under --no-start: %v", *calls)
+		}
+	}
+	if !created {
+		t.Errorf("container not created: %v", *calls)
+	}
+}
+
+// TestRecreateContainerRollsBackOnCreateFailure verifies the fail-safe path:
+// the old container is only stopped and renamed before the create, so when
+// the new image cannot be created the old container gets its name back and
+// is started again, and nothing is removed.
+func TestRecreateContainerRollsBackOnCreateFailure(t *testing.T) {
+	cli, calls := fakeDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/containers/create") {
+			http.Error(w, `{"message":"no such device"}`, http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	id := "abcdef123456789012345678901234567890"
+	old := container.InspectResponse{
+		ContainerJSONBase: &container.ContainerJSONBase{ID: id, Name: "/web", HostConfig: &container.HostConfig{NetworkMode: "bridge"}},
+		Config:            &container.Config{Image: "nginx:latest"},
+	}
+
+	_, err := RecreateContainer(t.Context(), cli, old, nil, RecreateOptions{})
+	var recreateErr *RecreateError
+	if !errors.As(err, &recreateErr) {
+		t.Fatalf("RecreateContainer() error = %v, want *RecreateError", err)
+	}
+	if recreateErr.Container != "web" || !recreateErr.RolledBack {
+		t.Errorf("RecreateError = {Container: %q, RolledBack: %v}, want {web, true}", recreateErr.Container, recreateErr.RolledBack)
+	}
+
+	want := []string{
+		"POST /containers/" + id + "/stop",
+		"POST /containers/" + id + "/rename?name=web-old-abcdef123456",
+		"POST /containers/create?name=%2Fweb",
+		"POST /containers/" + id + "/rename?name=%2Fweb",
+		"POST /containers/" + id + "/start",
+	}
+	if !slices.Equal(*calls, want) {
+		t.Errorf("calls = %v, want %v", *calls, want)
+	}
+}
+
+// TestRecreateContainerUnresolvableNetworkMode verifies that a container
+// whose network_mode names a container that no longer exists fails with a
+// *NetworkResolveError before it is stopped.
+func TestRecreateContainerUnresolvableNetworkMode(t *testing.T) {
+	cli, calls := fakeDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/containers/json"):
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`[{"Id":"other","Names":["/other"]}]`))
+		case strings.HasSuffix(r.URL.Path, "/json"):
+			http.Error(w, `{"message":"No such container: vpn"}`, http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+
+	old := container.InspectResponse{
+		ContainerJSONBase: &container.ContainerJSONBase{ID: "abcdef123456789012345678901234567890", Name: "/app", HostConfig: &container.HostConfig{NetworkMode: "container:vpn"}},
+		Config:            &container.Config{Image: "app:latest"},
+	}
+
+	_, err := RecreateContainer(t.Context(), cli, old, nil, RecreateOptions{})
+	var netErr *NetworkResolveError
+	if !errors.As(err, &netErr) {
+		t.Fatalf("RecreateContainer() error = %v, want *NetworkResolveError", err)
+	}
+	if netErr.Container != "app" || netErr.Ref != "vpn" {
+		t.Errorf("NetworkResolveError = {Container: %q, Ref: %q}, want {app, vpn}", netErr.Container, netErr.Ref)
+	}
+	for _, c := range *calls {
+		if strings.HasPrefix(c, "POST ") {
+			t.Errorf("unexpected call %q: the container must be left untouched", c)
+		}
+	}
+}
+
+// TestBuildContainerConfigsKeepsStaticIPv6 verifies that a dual-stack
+// container keeps its static IPv4 and IPv6 addresses on every network,
+// including those connected after creation, while the daemon-assigned
+// addresses are not copied.
+func TestBuildContainerConfigsKeepsStaticIPv6(t *testing.T) {
+	ipam := func(v4, v6 string) *network.EndpointIPAMConfig {
+		return &network.EndpointIPAMConfig{IPv4Address: v4, IPv6Address: v6}
+	}
+	old := container.InspectResponse{
+		ContainerJSONBase: &container.ContainerJSONBase{
+			ID:         "abcdef123456789012345678901234567890",
+			HostConfig: &container.HostConfig{NetworkMode: "frontend"},
+		},
+		Config: &container.Config{Image: "app:latest"},
+		NetworkSettings: &container.NetworkSettings{Networks: map[string]*network.EndpointSettings{
+			"frontend": {IPAMConfig: ipam("172.20.0.5", "fd00:20::5"), IPAddress: "172.20.0.5", GlobalIPv6Address: "fd00:20::5"},
+			"backend":  {IPAMConfig: ipam("", "fd00:30::7"), GlobalIPv6Address: "fd00:30::7"},
+		}},
+	}
+
+	cc := buildContainerConfigs(t.Context(), nil, old, nil, nil)
+
+	want := map[string]string{"frontend": "fd00:20::5", "backend": "fd00:30::7"}
+	for name, v6 := range want {
+		ep := cc.endpoints[name]
+		if ep == nil || ep.IPAMConfig == nil || ep.IPAMConfig.IPv6Address != v6 {
+			t.Errorf("%s: IPAMConfig = %+v, want IPv6Address %s", name, ep, v6)
+			continue
+		}
+		if ep.GlobalIPv6Address != "" || ep.IPAddress != "" {
+			t.Errorf("%s: runtime addresses copied: %+v", name, ep)
+		}
+		if ep.IPAMConfig == old.NetworkSettings.Networks[name].IPAMConfig {
+			t.Errorf("%s: IPAMConfig aliases the old container's", name)
+		}
+	}
+	if cc.endpoints["frontend"].IPAMConfig.IPv4Address != "172.20.0.5" {
+		t.Errorf("frontend IPv4 lost: %+v", cc.endpoints["frontend"].IPAMConfig)
+	}
+}
+
+// TestAnonymousVolumeBinds verifies that anonymous volumes are reattached by
+// name on recreate instead of being replaced with fresh, empty ones, while
+// explicitly configured binds and mounts are left as they are.
+func TestAnonymousVolumeBinds(t *testing.T) {
+	anon := "3f1c9e0a7b2d4c6e8f0a1b2c3d4e5f60718293a4b5c6d7e8f90a1b2c3d4e5f6"
+	base := func(host *container.HostConfig) container.InspectResponse {
+		return container.InspectResponse{
+			ContainerJSONBase: &container.ContainerJSONBase{HostConfig: host},
+			Mounts: []container.MountPoint{
+				{Type: mount.TypeVolume, Name: anon, Destination: "/var/lib/postgresql/data", RW: true},
+				{Type: mount.TypeVolume, Name: "app_config", Destination: "/config", RW: true},
+				{Type: mount.TypeBind, Source: "/srv/logs", Destination: "/logs", RW: true},
+				{Type: mount.TypeVolume, Name: "cache", Destination: "/cache", RW: false},
+			},
+		}
+	}
+
+	tests := []struct {
+		name string
+		host *container.HostConfig
+		want []string
+	}{
+		{
+			name: "anonymous volumes rebound",
+			host: &container.HostConfig{
+				Binds:  []string{"app_config:/config", "/srv/logs:/logs"},
+				Mounts: nil,
+			},
+			want: []string{anon + ":/var/lib/postgresql/data", "cache:/cache:ro"},
+		},
+		{
+			name: "mounts count as configured",
+			host: &container.HostConfig{
+				Binds:  []string{"/srv/logs:/logs"},
+				Mounts: []mount.Mount{{Type: mount.TypeVolume, Source: "app_config", Target: "/config"}, {Type: mount.TypeVolume, Target: "/cache"}},
+			},
+			want: []string{anon + ":/var/lib/postgresql/data"},
+		},
+		{
+			name: "volumes-from left alone",
+			host: &container.HostConfig{VolumesFrom: []string{"data"}},
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := anonymousVolumeBinds(base(tt.host))
+			if len(got) != len(tt.want) {
+				t.Fatalf("anonymousVolumeBinds() = %v, want %v", got, tt.want)
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("anonymousVolumeBinds()[%d] = %q, want %q", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
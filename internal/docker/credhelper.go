@@ -0,0 +1,91 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/registry"
+)
+
+// credHelperTimeout bounds a single credential helper call. Helpers such as
+// ecr-login or gcloud may make a network request of their own.
+const credHelperTimeout = 30 * time.Second
+
+// errCredentialsNotFound is what helpers print when they have nothing stored
+// for a server, which is not an error for our purposes.
+const errCredentialsNotFound = "credentials not found in native keychain"
+
+// credentialHelper returns the name of the credential helper config.json
+// assigns to domain: its credHelpers entry, else the credsStore default.
+// Returns "" when none is configured.
+func credentialHelper(cfg *dockerConfig, domain string) string {
+	for _, key := range authKeys(domain) {
+		if helper := cfg.CredHelpers[key]; helper != "" {
+			return helper
+		}
+	}
+	return cfg.CredsStore
+}
+
+// helperServerURL is the server address a helper stores domain's
+// credentials under; `docker login` uses the legacy index URL for Docker Hub.
+func helperServerURL(domain string) string {
+	if domain == "docker.io" {
+		return "https://index.docker.io/v1/"
+	}
+	return domain
+}
+
+// helperCredentials asks docker-credential-<helper> for domain's credentials
+// using the credential helper protocol: `get` with the server URL on stdin,
+// answered with {"ServerURL","Username","Secret"} JSON on stdout. A Username
+// of "<token>" marks Secret as an identity token. Returns ok=false if the
+// helper has nothing stored for domain.
+func helperCredentials(helper, domain string) (auth registry.AuthConfig, ok bool, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), credHelperTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "docker-credential-"+helper, "get")
+	cmd.Stdin = strings.NewReader(helperServerURL(domain))
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		// Helpers report errors on stdout; some use stderr.
+		msg := strings.TrimSpace(stdout.String() + " " + stderr.String())
+		if strings.Contains(msg, errCredentialsNotFound) {
+			return registry.AuthConfig{}, false, nil
+		}
+		var exitErr *exec.ExitError
+		if msg != "" && errors.As(err, &exitErr) {
+			return registry.AuthConfig{}, false, fmt.Errorf("docker-credential-%s: %s", helper, truncateOutput(msg))
+		}
+		return registry.AuthConfig{}, false, fmt.Errorf("docker-credential-%s: %w", helper, err)
+	}
+
+	var resp struct {
+		Username string
+		Secret   string
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		return registry.AuthConfig{}, false, fmt.Errorf("docker-credential-%s: decoding output: %w", helper, err)
+	}
+	if resp.Secret == "" {
+		return registry.AuthConfig{}, false, nil
+	}
+
+	auth = registry.AuthConfig{ServerAddress: domain}
+	if resp.Username == "<token>" {
+		auth.IdentityToken = resp.Secret
+	} else {
+		auth.Username = resp.Username
+		auth.Password = resp.Secret
+	}
+	return auth, true, nil
+}
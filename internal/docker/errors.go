@@ -0,0 +1,38 @@
+package docker
+
+// PullError is returned by PullImage when an image cannot be pulled. Its
+// message is that of Err.
+type PullError struct {
+	Image string
+	Err   error
+}
+
+func (e *PullError) Error() string { return e.Err.Error() }
+func (e *PullError) Unwrap() error { return e.Err }
+
+// RecreateError is returned by RecreateContainer when a container could not
+// be replaced. RolledBack reports whether the old container was restored
+// (renamed back and started again); when false it may be left stopped. Its
+// message is that of Err, which names the step that failed.
+type RecreateError struct {
+	Container  string
+	RolledBack bool
+	Err        error
+}
+
+func (e *RecreateError) Error() string { return e.Err.Error() }
+func (e *RecreateError) Unwrap() error { return e.Err }
+
+// NetworkResolveError is returned by RecreateContainer, before the container
+// is touched, when its network_mode: container:<Ref> names a container that
+// no longer exists: the replacement could not be created.
+type NetworkResolveError struct {
+	Container string
+	Ref       string
+	Err       error
+}
+
+func (e *NetworkResolveError) Error() string {
+	return "cannot resolve network_mode container:" + e.Ref + " of " + e.Container + ": " + e.Err.Error()
+}
+func (e *NetworkResolveError) Unwrap() error { return e.Err }
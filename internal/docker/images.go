@@ -3,7 +3,11 @@ package docker
 import (
 	"context"
 	"io"
+	"strings"
 
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/distribution/reference"
+	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/image"
 	"github.com/docker/docker/client"
 )
@@ -11,19 +15,38 @@ import (
 // PullImage pulls the latest version of an image from the registry.
 // Credentials for private registries are read from Docker's config.json
 // (see RegistryAuthFor); public images work without any configuration.
+// Failures are returned as a *PullError.
 func PullImage(ctx context.Context, cli *client.Client, imageName string) error {
 	opts := image.PullOptions{
 		RegistryAuth: RegistryAuthFor(imageName),
 	}
 	reader, err := cli.ImagePull(ctx, imageName, opts)
 	if err != nil {
-		return err
+		return &PullError{Image: imageName, Err: err}
 	}
 	defer reader.Close()
 
 	// Consume the output to ensure pull completes
-	_, err = io.Copy(io.Discard, reader)
-	return err
+	if _, err := io.Copy(io.Discard, reader); err != nil {
+		return &PullError{Image: imageName, Err: err}
+	}
+	return nil
+}
+
+// IsImageNotFound reports whether a pull failed because the tag (or the
+// whole repository) no longer exists upstream, as opposed to a transient
+// failure such as a timeout or an unreachable registry. The daemon reports
+// a missing manifest as a NotFound error; the text check covers registries
+// and daemon versions that surface it as a plain "manifest unknown".
+func IsImageNotFound(err error) bool {
+	if err == nil {
+		return false
+	}
+	if cerrdefs.IsNotFound(err) {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "manifest unknown") || strings.Contains(msg, "name unknown")
 }
 
 // GetImageID returns the image ID (sha256:...) that the given image name
@@ -31,6 +54,12 @@ func PullImage(ctx context.Context, cli *client.Client, imageName string) error
 // (which holds the ID of the image the container was created from) tells us
 // whether the container is running the latest local image — regardless of
 // who pulled it or when.
+//
+// RepoDigests is deliberately not used. With the containerd image store it
+// can be empty even for registry images, and its entries differ from the
+// classic store's; the image ID, by contrast, is what the container's Image
+// field records under either store (the image index digest with containerd),
+// so the comparison stays stable regardless of the snapshotter.
 func GetImageID(ctx context.Context, cli *client.Client, imageName string) (string, error) {
 	inspect, err := cli.ImageInspect(ctx, imageName)
 	if err != nil {
@@ -47,3 +76,110 @@ func RemoveImage(ctx context.Context, cli *client.Client, imageID string) error
 	_, err := cli.ImageRemove(ctx, imageID, image.RemoveOptions{})
 	return err
 }
+
+// IsDigestPinned reports whether imageName references an image by digest
+// (e.g. nginx@sha256:...). Pulling such a reference always yields the same
+// image, so there is never anything to update.
+func IsDigestPinned(imageName string) bool {
+	named, err := reference.ParseNormalizedNamed(imageName)
+	if err != nil {
+		return false
+	}
+	_, ok := named.(reference.Canonical)
+	return ok
+}
+
+// PreviousTag is the tag under which the image a container ran before an
+// update is kept, e.g. nginx:repull-previous, for manual rollbacks.
+const PreviousTag = "repull-previous"
+
+// RepoName returns the fully qualified repository of imageName without tag
+// or digest, e.g. docker.io/library/nginx for nginx:1.27.
+func RepoName(imageName string) (string, error) {
+	named, err := reference.ParseNormalizedNamed(imageName)
+	if err != nil {
+		return "", err
+	}
+	return reference.TrimNamed(named).String(), nil
+}
+
+// ImagesInUse returns the IDs of the images used by any container, running
+// or not.
+func ImagesInUse(ctx context.Context, cli *client.Client) (map[string]bool, error) {
+	containers, err := cli.ContainerList(ctx, container.ListOptions{All: true})
+	if err != nil {
+		return nil, err
+	}
+	inUse := make(map[string]bool, len(containers))
+	for _, c := range containers {
+		inUse[c.ImageID] = true
+	}
+	return inUse, nil
+}
+
+// LocalImageIDs returns the IDs of all images present on the host.
+func LocalImageIDs(ctx context.Context, cli *client.Client) (map[string]bool, error) {
+	images, err := cli.ImageList(ctx, image.ListOptions{All: true})
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[string]bool, len(images))
+	for _, img := range images {
+		ids[img.ID] = true
+	}
+	return ids, nil
+}
+
+// LocalLayers returns the DiffIDs of the layers of all images present on
+// the host: a pull only downloads the layers of an image not among them.
+func LocalLayers(ctx context.Context, cli *client.Client) (map[string]bool, error) {
+	images, err := cli.ImageList(ctx, image.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	layers := make(map[string]bool)
+	for _, img := range images {
+		inspect, err := cli.ImageInspect(ctx, img.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, l := range inspect.RootFS.Layers {
+			layers[l] = true
+		}
+	}
+	return layers, nil
+}
+
+// PreviousRef returns the <repo>:repull-previous reference for imageName.
+func PreviousRef(imageName string) (string, error) {
+	named, err := reference.ParseNormalizedNamed(imageName)
+	if err != nil {
+		return "", err
+	}
+	tagged, err := reference.WithTag(reference.TrimNamed(named), PreviousTag)
+	if err != nil {
+		return "", err
+	}
+	return tagged.String(), nil
+}
+
+// TagPrevious tags currentID as <repo>:repull-previous so the image survives
+// the pull moving the floating tag away from it — `docker image prune` and
+// repull's own cleanup leave tagged images alone. A tag can only point at one
+// image, so each repo keeps exactly one previous image; the ID the tag pointed
+// at before (now untagged) is returned as stale so the caller can remove it,
+// or "" if there was none.
+func TagPrevious(ctx context.Context, cli *client.Client, imageName, currentID string) (stale string, err error) {
+	ref, err := PreviousRef(imageName)
+	if err != nil {
+		return "", err
+	}
+	// A missing tag (first update of this repo) is not an error.
+	if inspect, err := cli.ImageInspect(ctx, ref); err == nil && inspect.ID != currentID {
+		stale = inspect.ID
+	}
+	if err := cli.ImageTag(ctx, currentID, ref); err != nil {
+		return "", err
+	}
+	return stale, nil
+}
@@ -0,0 +1,100 @@
+package docker
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	cerrdefs "github.com/containerd/errdefs"
+)
+
+func TestIsDigestPinned(t *testing.T) {
+	digest := "sha256:4b1d4ef4b8f0a9e0d3d9a7c3c6e2e9f0b4e6c5d1a3f2b7c8d9e0a1b2c3d4e5f6"
+
+	tests := []struct {
+		image string
+		want  bool
+	}{
+		{"nginx", false},
+		{"nginx:1.27", false},
+		{"ghcr.io/fanuelsen/repull:latest", false},
+		{"nginx@" + digest, true},
+		{"nginx:1.27@" + digest, true},
+		{"registry.example.com:5000/team/app@" + digest, true},
+		{"not a valid reference", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			if got := IsDigestPinned(tt.image); got != tt.want {
+				t.Errorf("IsDigestPinned(%q) = %v, want %v", tt.image, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPreviousRef(t *testing.T) {
+	tests := []struct {
+		image string
+		want  string
+	}{
+		{"nginx", "docker.io/library/nginx:repull-previous"},
+		{"nginx:1.27", "docker.io/library/nginx:repull-previous"},
+		{"ghcr.io/fanuelsen/repull:latest", "ghcr.io/fanuelsen/repull:repull-previous"},
+		{"nginx@sha256:4b1d4ef4b8f0a9e0d3d9a7c3c6e2e9f0b4e6c5d1a3f2b7c8d9e0a1b2c3d4e5f6", "docker.io/library/nginx:repull-previous"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			got, err := PreviousRef(tt.image)
+			if err != nil {
+				t.Fatalf("PreviousRef(%q) error = %v", tt.image, err)
+			}
+			if got != tt.want {
+				t.Errorf("PreviousRef(%q) = %q, want %q", tt.image, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsImageNotFound(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "nil", err: nil, want: false},
+		{name: "typed not found", err: fmt.Errorf("pull: %w", cerrdefs.ErrNotFound), want: true},
+		{name: "manifest unknown", err: errors.New("Error response from daemon: manifest for app:gone not found: manifest unknown: manifest unknown"), want: true},
+		{name: "repository gone", err: errors.New("name unknown: repository name not known to registry"), want: true},
+		{name: "timeout", err: errors.New("Get \"https://registry-1.docker.io/v2/\": net/http: request canceled while waiting for connection"), want: false},
+		{name: "unauthorized", err: errors.New("unauthorized: authentication required"), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsImageNotFound(tt.err); got != tt.want {
+				t.Errorf("IsImageNotFound(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPullImageReturnsPullError(t *testing.T) {
+	cli, _ := fakeDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message":"manifest for app:gone not found: manifest unknown"}`, http.StatusNotFound)
+	})
+
+	err := PullImage(t.Context(), cli, "app:gone")
+	var pullErr *PullError
+	if !errors.As(err, &pullErr) {
+		t.Fatalf("PullImage() error = %v, want *PullError", err)
+	}
+	if pullErr.Image != "app:gone" {
+		t.Errorf("PullError.Image = %q, want app:gone", pullErr.Image)
+	}
+	if !IsImageNotFound(err) {
+		t.Errorf("IsImageNotFound(%v) = false, want true through the wrapper", err)
+	}
+}
@@ -0,0 +1,43 @@
+package docker
+
+import (
+	"maps"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// Labels repull sets on every container it creates, recording the update
+// for `docker inspect`. Each update overwrites them, so they describe the
+// latest one only. Grouping reads the compose labels alone, so these never
+// move a container to another group.
+const (
+	// UpdatedAtLabel is the time of the update, in RFC 3339 UTC.
+	UpdatedAtLabel = "io.repull.updated-at"
+	// PreviousDigestLabel is the ID of the image the replaced container ran.
+	PreviousDigestLabel = "io.repull.previous-digest"
+	// UpdatedByVersionLabel is the version of repull that made the update.
+	UpdatedByVersionLabel = "io.repull.updated-by-version"
+)
+
+// repullVersion is the value of UpdatedByVersionLabel; see SetVersion.
+var repullVersion = "dev"
+
+// SetVersion sets the repull version recorded in UpdatedByVersionLabel.
+func SetVersion(v string) {
+	repullVersion = v
+}
+
+// stampUpdate adds the update metadata labels to config, the config of the
+// replacement for old. The labels map is copied first: it is shared with
+// the inspect response of the old container.
+func stampUpdate(config *container.Config, old container.InspectResponse, now time.Time) {
+	labels := maps.Clone(config.Labels)
+	if labels == nil {
+		labels = make(map[string]string, 3)
+	}
+	labels[UpdatedAtLabel] = now.UTC().Format(time.RFC3339)
+	labels[PreviousDigestLabel] = old.Image
+	labels[UpdatedByVersionLabel] = repullVersion
+	config.Labels = labels
+}
@@ -0,0 +1,73 @@
+package docker
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// TestRecreateContainerSetsUpdateMetadata verifies that the replacement
+// carries the update metadata labels next to the labels it inherits, and
+// that the old container's labels are left alone.
+func TestRecreateContainerSetsUpdateMetadata(t *testing.T) {
+	SetVersion("v1.2.3")
+	t.Cleanup(func() { SetVersion("dev") })
+
+	var created container.Config
+	cli, _ := fakeDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/containers/create") {
+			if err := json.NewDecoder(r.Body).Decode(&created); err != nil {
+				t.Errorf("decoding create body: %v", err)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"Id":"newcontainer"}`))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	labels := map[string]string{
+		"com.docker.compose.project": "myapp",
+		"com.docker.compose.service": "web",
+		"io.repull.enable":           "true",
+		PreviousDigestLabel:          "sha256:older",
+	}
+	old := container.InspectResponse{
+		ContainerJSONBase: &container.ContainerJSONBase{
+			ID:         "abcdef123456789012345678901234567890",
+			Name:       "/myapp-web-1",
+			Image:      "sha256:old",
+			HostConfig: &container.HostConfig{NetworkMode: "bridge"},
+		},
+		Config: &container.Config{Image: "nginx:latest", Labels: labels},
+	}
+
+	before := time.Now().UTC().Truncate(time.Second)
+	if _, err := RecreateContainer(t.Context(), cli, old, nil, RecreateOptions{}); err != nil {
+		t.Fatalf("RecreateContainer() error = %v", err)
+	}
+
+	got := created.Labels
+	if got[PreviousDigestLabel] != "sha256:old" {
+		t.Errorf("%s = %q, want sha256:old", PreviousDigestLabel, got[PreviousDigestLabel])
+	}
+	if got[UpdatedByVersionLabel] != "v1.2.3" {
+		t.Errorf("%s = %q, want v1.2.3", UpdatedByVersionLabel, got[UpdatedByVersionLabel])
+	}
+	if at, err := time.Parse(time.RFC3339, got[UpdatedAtLabel]); err != nil || at.Before(before) {
+		t.Errorf("%s = %q, want an RFC 3339 time not before %s", UpdatedAtLabel, got[UpdatedAtLabel], before.Format(time.RFC3339))
+	}
+	for _, k := range []string{"com.docker.compose.project", "com.docker.compose.service", "io.repull.enable"} {
+		if got[k] != labels[k] {
+			t.Errorf("label %s = %q, want %q carried over", k, got[k], labels[k])
+		}
+	}
+	if labels[PreviousDigestLabel] != "sha256:older" || labels[UpdatedAtLabel] != "" {
+		t.Errorf("old container's labels were modified: %v", labels)
+	}
+}
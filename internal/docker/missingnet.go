@@ -0,0 +1,144 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+	"github.com/fanuelsen/repull/internal/sanitize"
+)
+
+// What to do when a network the old container was on no longer exists by
+// the time its replacement joins it, e.g. after a compose project was
+// partly taken down (--on-missing-network).
+const (
+	// MissingNetworkFail fails the recreate, which rolls back to the old
+	// container.
+	MissingNetworkFail = "fail"
+	// MissingNetworkSkip leaves the replacement off the missing network.
+	// It only applies to networks joined after creation: the network a
+	// container is created on is part of its network mode.
+	MissingNetworkSkip = "skip"
+	// MissingNetworkRecreate creates the network again with the driver,
+	// options, IPAM config and labels it had when the recreate started.
+	MissingNetworkRecreate = "recreate"
+)
+
+// missingNetworkPolicy is set with SetMissingNetworkPolicy.
+var missingNetworkPolicy = MissingNetworkFail
+
+// SetMissingNetworkPolicy sets what RecreateContainer does about a network
+// that went missing: MissingNetworkFail (the default), MissingNetworkSkip
+// or MissingNetworkRecreate.
+func SetMissingNetworkPolicy(policy string) error {
+	switch policy {
+	case MissingNetworkFail, MissingNetworkSkip, MissingNetworkRecreate:
+		missingNetworkPolicy = policy
+		return nil
+	default:
+		return fmt.Errorf("invalid policy %q: must be %s, %s or %s", policy, MissingNetworkFail, MissingNetworkSkip, MissingNetworkRecreate)
+	}
+}
+
+// captureNetworks inspects the user-defined networks old is connected to,
+// for MissingNetworkRecreate: once a network is gone, nothing is left to
+// learn its settings from. A network that cannot be inspected is left out
+// and cannot be recreated. Other policies need nothing and get nil.
+func captureNetworks(ctx context.Context, cli *client.Client, old container.InspectResponse) map[string]network.Inspect {
+	if missingNetworkPolicy != MissingNetworkRecreate || old.NetworkSettings == nil {
+		return nil
+	}
+	specs := make(map[string]network.Inspect)
+	for name := range old.NetworkSettings.Networks {
+		if name == network.NetworkBridge || name == network.NetworkHost || name == network.NetworkNone {
+			continue
+		}
+		spec, err := cli.NetworkInspect(ctx, name, network.InspectOptions{})
+		if err != nil {
+			log.Printf("[WARN] Failed to inspect network %s, it cannot be recreated if it goes missing: %v", sanitize.String(name), err)
+			continue
+		}
+		specs[name] = spec
+	}
+	return specs
+}
+
+// createContainer creates the container from cc. Under
+// MissingNetworkRecreate, a create that fails because the network it is
+// created on went missing is retried once the network is recreated; the
+// create error itself does not say which resource was not found.
+func createContainer(ctx context.Context, cli *client.Client, cc containerConfigs, name string) (container.CreateResponse, error) {
+	resp, err := cli.ContainerCreate(ctx, cc.config, cc.hostConfig, cc.networkConfig, nil, name)
+	if err == nil || missingNetworkPolicy != MissingNetworkRecreate {
+		return resp, err
+	}
+	for netName := range cc.networkConfig.EndpointsConfig {
+		if !networkMissing(ctx, cli, netName) {
+			continue
+		}
+		if recErr := recreateNetwork(ctx, cli, netName, cc.networkSpecs); recErr != nil {
+			return resp, fmt.Errorf("%w (network %s is missing and recreating it failed: %v)", err, sanitize.String(netName), recErr)
+		}
+		return cli.ContainerCreate(ctx, cc.config, cc.hostConfig, cc.networkConfig, nil, name)
+	}
+	return resp, err
+}
+
+// connectNetwork connects the new container id to an additional network,
+// applying the missing network policy. The container was just created, so
+// "not found" means the network is gone.
+func connectNetwork(ctx context.Context, cli *client.Client, cc containerConfigs, netName, id, name string) error {
+	err := cli.NetworkConnect(ctx, netName, id, cc.endpoints[netName])
+	if err == nil || !cerrdefs.IsNotFound(err) {
+		return err
+	}
+	switch missingNetworkPolicy {
+	case MissingNetworkSkip:
+		log.Printf("[WARN] Network %s no longer exists, leaving %s off it (--on-missing-network %s)", sanitize.String(netName), sanitize.String(strings.TrimPrefix(name, "/")), MissingNetworkSkip)
+		return nil
+	case MissingNetworkRecreate:
+		if recErr := recreateNetwork(ctx, cli, netName, cc.networkSpecs); recErr != nil {
+			return fmt.Errorf("%w (recreating the network failed: %v)", err, recErr)
+		}
+		return cli.NetworkConnect(ctx, netName, id, cc.endpoints[netName])
+	}
+	return err
+}
+
+// networkMissing reports whether the named network does not exist.
+func networkMissing(ctx context.Context, cli *client.Client, name string) bool {
+	_, err := cli.NetworkInspect(ctx, name, network.InspectOptions{})
+	return cerrdefs.IsNotFound(err)
+}
+
+// recreateNetwork creates the named network again from its captured spec.
+func recreateNetwork(ctx context.Context, cli *client.Client, name string, specs map[string]network.Inspect) error {
+	spec, ok := specs[name]
+	if !ok {
+		return fmt.Errorf("its settings were not captured")
+	}
+	// EnableIPv4 is left to the daemon default: daemons before API 1.47 do
+	// not report it, and a false read from them would turn IPv4 off.
+	enableIPv6 := spec.EnableIPv6
+	ipam := spec.IPAM
+	_, err := cli.NetworkCreate(ctx, name, network.CreateOptions{
+		Driver:     spec.Driver,
+		Scope:      spec.Scope,
+		EnableIPv6: &enableIPv6,
+		IPAM:       &ipam,
+		Internal:   spec.Internal,
+		Attachable: spec.Attachable,
+		Options:    spec.Options,
+		Labels:     spec.Labels,
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("[WARN] Network %s no longer existed and was recreated (driver %s, --on-missing-network %s)", sanitize.String(name), sanitize.String(spec.Driver), MissingNetworkRecreate)
+	return nil
+}
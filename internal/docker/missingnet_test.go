@@ -0,0 +1,125 @@
+package docker
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+)
+
+// TestRecreateContainerMissingNetwork verifies each --on-missing-network
+// policy when an additional network was removed while the container was
+// being recreated.
+func TestRecreateContainerMissingNetwork(t *testing.T) {
+	tests := []struct {
+		policy      string
+		wantErr     bool
+		wantCreated bool // the network was recreated
+	}{
+		{policy: MissingNetworkFail, wantErr: true},
+		{policy: MissingNetworkSkip},
+		{policy: MissingNetworkRecreate, wantCreated: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			if err := SetMissingNetworkPolicy(tt.policy); err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { SetMissingNetworkPolicy(MissingNetworkFail) })
+
+			// app_public sorts after app_default, so it is joined after
+			// creation rather than at create time.
+			backend := network.Inspect{
+				Name:    "app_public",
+				Driver:  "bridge",
+				Options: map[string]string{"com.docker.network.bridge.enable_icc": "false"},
+				Labels:  map[string]string{"com.docker.compose.project": "app"},
+			}
+			var mu sync.Mutex
+			stopped, connected := false, false
+			var created *network.CreateRequest
+			cli, _ := fakeDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				w.Header().Set("Content-Type", "application/json")
+				path := r.URL.Path
+				switch {
+				case strings.HasSuffix(path, "/stop"):
+					stopped = true
+					w.WriteHeader(http.StatusNoContent)
+				case r.Method == http.MethodGet && strings.HasSuffix(path, "/networks/app_public"):
+					// The network disappears once the container is stopped.
+					if stopped && created == nil {
+						http.Error(w, `{"message":"network app_public not found"}`, http.StatusNotFound)
+						return
+					}
+					json.NewEncoder(w).Encode(backend)
+				case r.Method == http.MethodGet && strings.Contains(path, "/networks/"):
+					json.NewEncoder(w).Encode(network.Inspect{Name: "app_default", Driver: "bridge"})
+				case strings.HasSuffix(path, "/networks/create"):
+					created = &network.CreateRequest{}
+					json.NewDecoder(r.Body).Decode(created)
+					w.WriteHeader(http.StatusCreated)
+					w.Write([]byte(`{"Id":"newnetwork"}`))
+				case strings.HasSuffix(path, "/networks/app_public/connect"):
+					if created == nil {
+						http.Error(w, `{"message":"network app_public not found"}`, http.StatusNotFound)
+						return
+					}
+					connected = true
+					w.WriteHeader(http.StatusOK)
+				case strings.HasSuffix(path, "/containers/create"):
+					w.WriteHeader(http.StatusCreated)
+					w.Write([]byte(`{"Id":"newcontainer"}`))
+				default:
+					w.WriteHeader(http.StatusNoContent)
+				}
+			})
+
+			old := container.InspectResponse{
+				ContainerJSONBase: &container.ContainerJSONBase{
+					ID:         "abcdef123456789012345678901234567890",
+					Name:       "/app-web-1",
+					HostConfig: &container.HostConfig{NetworkMode: "app_default"},
+				},
+				Config: &container.Config{Image: "nginx:latest"},
+				NetworkSettings: &container.NetworkSettings{Networks: map[string]*network.EndpointSettings{
+					"app_default": {},
+					"app_public":  {Aliases: []string{"web"}},
+				}},
+			}
+
+			_, err := RecreateContainer(t.Context(), cli, old, nil, RecreateOptions{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RecreateContainer() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if (created != nil) != tt.wantCreated {
+				t.Fatalf("network recreated = %v, want %v", created != nil, tt.wantCreated)
+			}
+			if created == nil {
+				return
+			}
+			if created.Name != "app_public" || created.Driver != "bridge" ||
+				created.Options["com.docker.network.bridge.enable_icc"] != "false" ||
+				created.Labels["com.docker.compose.project"] != "app" {
+				t.Errorf("network created as %+v, want the captured app_public settings", created)
+			}
+			if !connected {
+				t.Error("container not connected to the recreated network")
+			}
+		})
+	}
+}
+
+func TestSetMissingNetworkPolicyRejectsUnknown(t *testing.T) {
+	if err := SetMissingNetworkPolicy("ignore"); err == nil {
+		t.Error("SetMissingNetworkPolicy(ignore) error = nil, want an error")
+	}
+}
@@ -0,0 +1,83 @@
+package docker
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// DefaultOldNameTemplate is the name an old container gets while its
+// replacement is created: "<name>-old-<short ID>".
+const DefaultOldNameTemplate = "{{.Name}}-old-{{.ShortID}}"
+
+// OldNameData is what an --old-name-template can refer to.
+type OldNameData struct {
+	// Name is the container's current name, without the leading slash.
+	Name string
+	// ShortID is the container's 12-character ID.
+	ShortID string
+	// Digest is the short hex ID of the image the container runs.
+	Digest string
+	// Timestamp is the rename time in UTC, e.g. 20260611-120000.
+	Timestamp string
+}
+
+// validContainerName matches the names Docker accepts.
+var validContainerName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]+$`)
+
+var (
+	oldNameTemplate = template.Must(template.New("old-name").Option("missingkey=error").Parse(DefaultOldNameTemplate))
+	oldNameCustom   bool
+)
+
+// SetOldNameTemplate replaces the template used to rename old containers.
+// The template must render a valid container name that contains both
+// {{.Name}} and {{.ShortID}}: a renamed container is only ever recognized as
+// a self-update leftover by its own ID in a name longer than that ID (see
+// isSelfUpdateLeftover), and the ID also keeps two renames of the same name
+// apart.
+func SetOldNameTemplate(text string) error {
+	tmpl, err := template.New("old-name").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return fmt.Errorf("invalid old name template: %w", err)
+	}
+
+	sample := OldNameData{Name: "sample-name", ShortID: "0123456789ab", Digest: "ba9876543210", Timestamp: "20060102-150405"}
+	name, err := renderOldName(tmpl, sample)
+	if err != nil {
+		return fmt.Errorf("invalid old name template: %w", err)
+	}
+	if !strings.Contains(name, sample.Name) || !strings.Contains(name, sample.ShortID) {
+		return fmt.Errorf("invalid old name template %q: must include {{.Name}} and {{.ShortID}}", text)
+	}
+
+	oldNameTemplate = tmpl
+	oldNameCustom = text != DefaultOldNameTemplate
+	return nil
+}
+
+// OldName returns the temporary name for c while it is being replaced.
+func OldName(c container.InspectResponse, name string, now time.Time) (string, error) {
+	return renderOldName(oldNameTemplate, OldNameData{
+		Name:      name,
+		ShortID:   ShortID(c.ID),
+		Digest:    ShortID(strings.TrimPrefix(c.Image, "sha256:")),
+		Timestamp: now.UTC().Format("20060102-150405"),
+	})
+}
+
+func renderOldName(tmpl *template.Template, data OldNameData) (string, error) {
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	name := b.String()
+	if !validContainerName.MatchString(name) {
+		return "", fmt.Errorf("%q is not a valid container name", name)
+	}
+	return name, nil
+}
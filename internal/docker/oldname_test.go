@@ -0,0 +1,62 @@
+package docker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+func TestSetOldNameTemplate(t *testing.T) {
+	t.Cleanup(func() { SetOldNameTemplate(DefaultOldNameTemplate) })
+
+	tests := []struct {
+		name    string
+		text    string
+		wantErr bool
+	}{
+		{name: "default", text: DefaultOldNameTemplate},
+		{name: "custom", text: "{{.Name}}-bak-{{.ShortID}}"},
+		{name: "all fields", text: "{{.Name}}.{{.Digest}}.{{.Timestamp}}.{{.ShortID}}"},
+		{name: "missing short ID", text: "{{.Name}}-bak-{{.Timestamp}}", wantErr: true},
+		{name: "missing name", text: "bak-{{.ShortID}}", wantErr: true},
+		{name: "short ID alone", text: "{{.ShortID}}", wantErr: true},
+		{name: "unknown field", text: "{{.Name}}-{{.Tag}}-{{.ShortID}}", wantErr: true},
+		{name: "parse error", text: "{{.Name", wantErr: true},
+		{name: "invalid container name", text: "{{.Name}}/{{.ShortID}}", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := SetOldNameTemplate(tt.text); (err != nil) != tt.wantErr {
+				t.Errorf("SetOldNameTemplate(%q) error = %v, wantErr %v", tt.text, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOldName(t *testing.T) {
+	t.Cleanup(func() { SetOldNameTemplate(DefaultOldNameTemplate) })
+
+	c := container.InspectResponse{ContainerJSONBase: &container.ContainerJSONBase{
+		ID:    "abcdef123456789012345678901234567890",
+		Image: "sha256:0011223344556677889900",
+	}}
+	now := time.Date(2026, time.June, 11, 12, 0, 0, 0, time.UTC)
+
+	got, err := OldName(c, "web", now)
+	if err != nil || got != "web-old-abcdef123456" {
+		t.Errorf("OldName() = %q, %v; want web-old-abcdef123456", got, err)
+	}
+
+	if err := SetOldNameTemplate("{{.Name}}-bak-{{.Timestamp}}-{{.Digest}}-{{.ShortID}}"); err != nil {
+		t.Fatal(err)
+	}
+	got, err = OldName(c, "web", now)
+	if want := "web-bak-20260611-120000-001122334455-abcdef123456"; err != nil || got != want {
+		t.Errorf("OldName() = %q, %v; want %q", got, err, want)
+	}
+	if !isSelfUpdateLeftover(got, c.ID) {
+		t.Errorf("isSelfUpdateLeftover(%q) = false with a custom template", got)
+	}
+}
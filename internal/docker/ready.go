@@ -0,0 +1,181 @@
+package docker
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// ReadyLabel declares when a recreated container counts as ready:
+// io.repull.ready=log:"Server started" waits for a log line containing the
+// text, io.repull.ready=tcp:8080 for the port to accept connections. Like
+// io.repull.verify-cmd it is bounded by io.repull.verify-timeout, and a
+// container that never gets ready is rolled back.
+const ReadyLabel = "io.repull.ready"
+
+// readyPollInterval is the pause between TCP connection attempts.
+const readyPollInterval = time.Second
+
+// readyCheck is a parsed ReadyLabel.
+type readyCheck struct {
+	// logLine is the text to wait for in the logs, for log: checks.
+	logLine string
+	// port is the TCP port to probe, for tcp: checks.
+	port int
+}
+
+func (r readyCheck) String() string {
+	if r.port != 0 {
+		return "tcp:" + strconv.Itoa(r.port)
+	}
+	return "log:" + strconv.Quote(r.logLine)
+}
+
+// readySpec reads the readiness label of a container. A nil check means
+// none is configured.
+func readySpec(c container.InspectResponse) (*readyCheck, time.Duration, error) {
+	if c.Config == nil {
+		return nil, 0, nil
+	}
+	v := strings.TrimSpace(c.Config.Labels[ReadyLabel])
+	if v == "" {
+		return nil, 0, nil
+	}
+
+	var check readyCheck
+	kind, arg, _ := strings.Cut(v, ":")
+	switch kind {
+	case "log":
+		if unquoted, err := strconv.Unquote(arg); err == nil {
+			arg = unquoted
+		}
+		if arg == "" {
+			return nil, 0, fmt.Errorf("invalid %s %q: log: needs the text to wait for", ReadyLabel, v)
+		}
+		check.logLine = arg
+	case "tcp":
+		port, err := strconv.Atoi(arg)
+		if err != nil || port < 1 || port > 65535 {
+			return nil, 0, fmt.Errorf("invalid %s %q: tcp: needs a port number", ReadyLabel, v)
+		}
+		check.port = port
+	default:
+		return nil, 0, fmt.Errorf("invalid %s %q: must be log:\"<text>\" or tcp:<port>", ReadyLabel, v)
+	}
+
+	timeout, err := verifyTimeout(c)
+	if err != nil {
+		return nil, 0, err
+	}
+	return &check, timeout, nil
+}
+
+// waitReady waits until the container passes check or timeout elapses.
+func waitReady(ctx context.Context, cli *client.Client, containerID string, check readyCheck, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	inspect, err := cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return fmt.Errorf("readiness check %s: %w", check, err)
+	}
+
+	if check.port != 0 {
+		addr, err := containerAddr(inspect, check.port)
+		if err != nil {
+			return fmt.Errorf("readiness check %s: %w", check, err)
+		}
+		if err := waitForTCP(ctx, addr); err != nil {
+			return fmt.Errorf("not ready within %s: %s: %w", timeout, check, err)
+		}
+		return nil
+	}
+
+	logs, err := cli.ContainerLogs(ctx, containerID, container.LogsOptions{ShowStdout: true, ShowStderr: true, Follow: true})
+	if err != nil {
+		return fmt.Errorf("readiness check %s: %w", check, err)
+	}
+	defer logs.Close()
+
+	// Without a TTY the stream is multiplexed (see stdcopy).
+	var r io.Reader = logs
+	if inspect.Config == nil || !inspect.Config.Tty {
+		pr, pw := io.Pipe()
+		go func() {
+			_, err := stdcopy.StdCopy(pw, pw, logs)
+			pw.CloseWithError(err)
+		}()
+		defer pr.Close()
+		r = pr
+	}
+	if err := waitForLogLine(ctx, r, check.logLine); err != nil {
+		return fmt.Errorf("not ready within %s: %s: %w", timeout, check, err)
+	}
+	return nil
+}
+
+// waitForLogLine reads r until a line contains text. Cancelling ctx must
+// also unblock reads from r, as closing a followed log stream does.
+func waitForLogLine(ctx context.Context, r io.Reader, text string) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if strings.Contains(scanner.Text(), text) {
+			return nil
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	// A followed log only ends when the container stops.
+	return errors.New("container stopped before logging it")
+}
+
+// waitForTCP dials addr until a connection succeeds or ctx is done.
+func waitForTCP(ctx context.Context, addr string) error {
+	var d net.Dialer
+	for {
+		dialCtx, cancel := context.WithTimeout(ctx, readyPollInterval)
+		conn, err := d.DialContext(dialCtx, "tcp", addr)
+		cancel()
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s: %w", addr, err)
+		case <-time.After(readyPollInterval):
+		}
+	}
+}
+
+// containerAddr returns the address port of the container is reachable on
+// from repull: its IP on one of its networks, or localhost with host
+// networking. repull must be able to route to that network.
+func containerAddr(c container.InspectResponse, port int) (string, error) {
+	if c.HostConfig != nil && c.HostConfig.NetworkMode.IsHost() {
+		return net.JoinHostPort("127.0.0.1", strconv.Itoa(port)), nil
+	}
+	if c.NetworkSettings != nil {
+		for _, ep := range c.NetworkSettings.Networks {
+			if ep != nil && ep.IPAddress != "" {
+				return net.JoinHostPort(ep.IPAddress, strconv.Itoa(port)), nil
+			}
+		}
+	}
+	return "", errors.New("container has no IP address to probe")
+}
@@ -0,0 +1,139 @@
+package docker
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+func TestReadySpec(t *testing.T) {
+	withLabels := func(labels map[string]string) container.InspectResponse {
+		return container.InspectResponse{Config: &container.Config{Labels: labels}}
+	}
+
+	tests := []struct {
+		name        string
+		labels      map[string]string
+		want        *readyCheck
+		wantTimeout time.Duration
+		wantErr     bool
+	}{
+		{name: "no label"},
+		{name: "quoted log line", labels: map[string]string{ReadyLabel: `log:"Server started"`}, want: &readyCheck{logLine: "Server started"}, wantTimeout: defaultVerifyTimeout},
+		{name: "bare log line", labels: map[string]string{ReadyLabel: "log:listening on :80"}, want: &readyCheck{logLine: "listening on :80"}, wantTimeout: defaultVerifyTimeout},
+		{name: "tcp port", labels: map[string]string{ReadyLabel: "tcp:8080", VerifyTimeoutLabel: "2m"}, want: &readyCheck{port: 8080}, wantTimeout: 2 * time.Minute},
+		{name: "empty log line", labels: map[string]string{ReadyLabel: `log:""`}, wantErr: true},
+		{name: "bad port", labels: map[string]string{ReadyLabel: "tcp:http"}, wantErr: true},
+		{name: "port out of range", labels: map[string]string{ReadyLabel: "tcp:70000"}, wantErr: true},
+		{name: "unknown kind", labels: map[string]string{ReadyLabel: "http:/health"}, wantErr: true},
+		{name: "invalid timeout", labels: map[string]string{ReadyLabel: "tcp:80", VerifyTimeoutLabel: "soon"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, timeout, err := readySpec(withLabels(tt.labels))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readySpec() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) || timeout != tt.wantTimeout {
+				t.Errorf("readySpec() = %v, %s; want %v, %s", got, timeout, tt.want, tt.wantTimeout)
+			}
+		})
+	}
+}
+
+func TestWaitForLogLine(t *testing.T) {
+	logs := "starting\nloading config\nServer started on :8080\n"
+	if err := waitForLogLine(t.Context(), strings.NewReader(logs), "Server started"); err != nil {
+		t.Errorf("waitForLogLine() error = %v, want the line found", err)
+	}
+	if err := waitForLogLine(t.Context(), strings.NewReader("starting\npanic: boom\n"), "Server started"); err == nil {
+		t.Error("waitForLogLine() = nil for a log that ended without the line")
+	}
+
+	// A followed log that never prints the line is cut off by the deadline.
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	go func() {
+		pw.Write([]byte("starting\n"))
+		<-ctx.Done()
+		pr.CloseWithError(ctx.Err())
+	}()
+	if err := waitForLogLine(ctx, pr, "Server started"); err != context.DeadlineExceeded {
+		t.Errorf("waitForLogLine() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestWaitForTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	if err := waitForTCP(t.Context(), addr); err != nil {
+		t.Errorf("waitForTCP() on a listening port error = %v", err)
+	}
+	ln.Close()
+
+	ctx, cancel := context.WithTimeout(t.Context(), 100*time.Millisecond)
+	defer cancel()
+	if err := waitForTCP(ctx, addr); err == nil {
+		t.Error("waitForTCP() on a closed port = nil, want an error")
+	}
+}
+
+func TestContainerAddr(t *testing.T) {
+	bridged := container.InspectResponse{
+		ContainerJSONBase: &container.ContainerJSONBase{HostConfig: &container.HostConfig{NetworkMode: "bridge"}},
+		NetworkSettings: &container.NetworkSettings{Networks: map[string]*network.EndpointSettings{
+			"bridge": {IPAddress: "172.17.0.5"},
+		}},
+	}
+	if got, err := containerAddr(bridged, 8080); err != nil || got != "172.17.0.5:8080" {
+		t.Errorf("containerAddr(bridge) = %q, %v; want 172.17.0.5:8080", got, err)
+	}
+
+	host := container.InspectResponse{ContainerJSONBase: &container.ContainerJSONBase{HostConfig: &container.HostConfig{NetworkMode: "host"}}}
+	if got, err := containerAddr(host, 8080); err != nil || got != "127.0.0.1:8080" {
+		t.Errorf("containerAddr(host) = %q, %v; want 127.0.0.1:8080", got, err)
+	}
+
+	shared := container.InspectResponse{ContainerJSONBase: &container.ContainerJSONBase{HostConfig: &container.HostConfig{NetworkMode: "container:vpn"}}}
+	if _, err := containerAddr(shared, 8080); err == nil {
+		t.Error("containerAddr(container:vpn) = nil error, want no address")
+	}
+}
+
+// TestWaitReadyLog verifies the log check against a fake daemon serving a
+// multiplexed (non-TTY) log stream.
+func TestWaitReadyLog(t *testing.T) {
+	cli, _ := fakeDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/logs"):
+			stdout := stdcopy.NewStdWriter(w, stdcopy.Stdout)
+			stderr := stdcopy.NewStdWriter(w, stdcopy.Stderr)
+			stderr.Write([]byte("warming up\n"))
+			stdout.Write([]byte("Server started\n"))
+		case strings.HasSuffix(r.URL.Path, "/json"):
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"Id":"new","Config":{"Tty":false}}`))
+		}
+	})
+
+	if err := waitReady(t.Context(), cli, "new", readyCheck{logLine: "Server started"}, 5*time.Second); err != nil {
+		t.Errorf("waitReady() error = %v", err)
+	}
+	if err := waitReady(t.Context(), cli, "new", readyCheck{logLine: "never printed"}, 5*time.Second); err == nil {
+		t.Error("waitReady() = nil for a line the container never logged")
+	}
+}
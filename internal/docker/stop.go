@@ -0,0 +1,111 @@
+package docker
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/fanuelsen/repull/internal/sanitize"
+)
+
+const (
+	// StopTimeoutLabel overrides how long the old container gets to shut
+	// down on recreate before it is killed, e.g. io.repull.stop-timeout=60s.
+	StopTimeoutLabel = "io.repull.stop-timeout"
+	// StopSignalLabel overrides the signal sent to stop the old container on
+	// recreate, e.g. io.repull.stop-signal=SIGQUIT.
+	StopSignalLabel = "io.repull.stop-signal"
+)
+
+// stopSignals are the signal names io.repull.stop-signal accepts, without
+// the SIG prefix. Numeric signals (1-64) are accepted as well.
+var stopSignals = map[string]bool{
+	"HUP": true, "INT": true, "QUIT": true, "KILL": true, "USR1": true,
+	"USR2": true, "TERM": true, "STOP": true, "PWR": true, "WINCH": true,
+}
+
+// defaultStopTimeout is the grace period, in seconds, for containers that
+// declare none of their own; nil leaves it to the daemon (10s).
+var defaultStopTimeout *int
+
+// SetStopTimeout sets the grace period stopping a container on recreate
+// gets when neither io.repull.stop-timeout nor the container's own
+// StopTimeout says otherwise (--stop-timeout); 0 restores the daemon
+// default.
+func SetStopTimeout(d time.Duration) {
+	defaultStopTimeout = nil
+	if d > 0 {
+		secs := stopSeconds(d)
+		defaultStopTimeout = &secs
+	}
+}
+
+// stopSeconds converts d to the whole seconds the Docker API takes,
+// rounding up so a sub-second grace period is not turned into an
+// immediate kill.
+func stopSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
+
+// stopOptions returns the options for stopping c during a recreate. The
+// timeout is, in order of precedence, io.repull.stop-timeout, the
+// container's own StopTimeout (compose stop_grace_period), the
+// --stop-timeout default, or else the daemon's 10s. The container's own
+// value and the daemon default are Docker's to apply, so the timeout is
+// left nil for them; likewise the signal, unless io.repull.stop-signal is
+// set. An invalid label is logged and ignored, so a typo falls back to the
+// default stop behavior instead of blocking the update.
+func stopOptions(c container.InspectResponse) container.StopOptions {
+	var opts container.StopOptions
+	if c.Config == nil {
+		return opts
+	}
+	name := sanitize.String(strings.TrimPrefix(c.Name, "/"))
+
+	if v := c.Config.Labels[StopTimeoutLabel]; v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			log.Printf("[WARN] Ignoring %s=%q on %s: must be a duration such as 60s", StopTimeoutLabel, sanitize.String(v), name)
+		} else {
+			secs := stopSeconds(d)
+			opts.Timeout = &secs
+		}
+	}
+	if opts.Timeout == nil && c.Config.StopTimeout == nil {
+		opts.Timeout = defaultStopTimeout
+	}
+
+	if v := c.Config.Labels[StopSignalLabel]; v != "" {
+		if sig, ok := normalizeSignal(v); ok {
+			opts.Signal = sig
+		} else {
+			log.Printf("[WARN] Ignoring %s=%q on %s: not a known signal", StopSignalLabel, sanitize.String(v), name)
+		}
+	}
+
+	return opts
+}
+
+// RestartContainer restarts c in place, stopping it the way a recreate
+// would (see stopOptions). The container keeps the image it was created
+// from.
+func RestartContainer(ctx context.Context, cli *client.Client, c container.InspectResponse) error {
+	return cli.ContainerRestart(ctx, c.ID, stopOptions(c))
+}
+
+// normalizeSignal validates a signal given as a name (TERM, SIGTERM) or
+// number and returns it in the form the Docker API expects.
+func normalizeSignal(v string) (string, bool) {
+	if n, err := strconv.Atoi(v); err == nil {
+		return v, n >= 1 && n <= 64
+	}
+	name := strings.TrimPrefix(strings.ToUpper(v), "SIG")
+	if !stopSignals[name] {
+		return "", false
+	}
+	return "SIG" + name, true
+}
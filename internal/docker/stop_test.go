@@ -0,0 +1,95 @@
+package docker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+func TestStopOptions(t *testing.T) {
+	withLabels := func(labels map[string]string) container.InspectResponse {
+		return container.InspectResponse{
+			ContainerJSONBase: &container.ContainerJSONBase{Name: "/db"},
+			Config:            &container.Config{Labels: labels},
+		}
+	}
+	intPtr := func(n int) *int { return &n }
+
+	tests := []struct {
+		name        string
+		c           container.InspectResponse
+		wantTimeout *int
+		wantSignal  string
+	}{
+		{name: "no labels keeps defaults", c: withLabels(nil)},
+		{name: "timeout", c: withLabels(map[string]string{StopTimeoutLabel: "90s"}), wantTimeout: intPtr(90)},
+		{name: "timeout in minutes", c: withLabels(map[string]string{StopTimeoutLabel: "2m"}), wantTimeout: intPtr(120)},
+		{name: "signal with prefix", c: withLabels(map[string]string{StopSignalLabel: "SIGQUIT"}), wantSignal: "SIGQUIT"},
+		{name: "signal without prefix", c: withLabels(map[string]string{StopSignalLabel: "int"}), wantSignal: "SIGINT"},
+		{name: "numeric signal", c: withLabels(map[string]string{StopSignalLabel: "15"}), wantSignal: "15"},
+		{
+			name:        "both together",
+			c:           withLabels(map[string]string{StopTimeoutLabel: "30s", StopSignalLabel: "SIGTERM"}),
+			wantTimeout: intPtr(30),
+			wantSignal:  "SIGTERM",
+		},
+		{name: "invalid timeout falls back", c: withLabels(map[string]string{StopTimeoutLabel: "forever"})},
+		{name: "negative timeout falls back", c: withLabels(map[string]string{StopTimeoutLabel: "-5s"})},
+		{name: "invalid signal falls back", c: withLabels(map[string]string{StopSignalLabel: "SIGNOPE"})},
+		{name: "out of range signal falls back", c: withLabels(map[string]string{StopSignalLabel: "99"})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := stopOptions(tt.c)
+			if (got.Timeout == nil) != (tt.wantTimeout == nil) || (got.Timeout != nil && *got.Timeout != *tt.wantTimeout) {
+				t.Errorf("Timeout = %v, want %v", got.Timeout, tt.wantTimeout)
+			}
+			if got.Signal != tt.wantSignal {
+				t.Errorf("Signal = %q, want %q", got.Signal, tt.wantSignal)
+			}
+		})
+	}
+}
+
+// TestStopTimeoutPrecedence verifies the label beats the container's own
+// StopTimeout, which beats --stop-timeout, which beats the daemon default.
+func TestStopTimeoutPrecedence(t *testing.T) {
+	intPtr := func(n int) *int { return &n }
+	c := func(label string, own *int) container.InspectResponse {
+		labels := map[string]string{}
+		if label != "" {
+			labels[StopTimeoutLabel] = label
+		}
+		return container.InspectResponse{
+			ContainerJSONBase: &container.ContainerJSONBase{Name: "/db"},
+			Config:            &container.Config{Labels: labels, StopTimeout: own},
+		}
+	}
+
+	tests := []struct {
+		name        string
+		global      time.Duration
+		c           container.InspectResponse
+		wantTimeout *int
+	}{
+		{name: "label beats everything", global: 20 * time.Second, c: c("90s", intPtr(30)), wantTimeout: intPtr(90)},
+		{name: "container's own left to Docker", global: 20 * time.Second, c: c("", intPtr(30))},
+		{name: "global default", global: 20 * time.Second, c: c("", nil), wantTimeout: intPtr(20)},
+		{name: "daemon default", c: c("", nil)},
+		{name: "invalid label falls back to global", global: 20 * time.Second, c: c("forever", nil), wantTimeout: intPtr(20)},
+		{name: "sub-second global rounds up", global: 500 * time.Millisecond, c: c("", nil), wantTimeout: intPtr(1)},
+		{name: "sub-second label rounds up", c: c("1500ms", nil), wantTimeout: intPtr(2)},
+	}
+	t.Cleanup(func() { SetStopTimeout(0) })
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetStopTimeout(tt.global)
+			got := stopOptions(tt.c).Timeout
+			if (got == nil) != (tt.wantTimeout == nil) || (got != nil && *got != *tt.wantTimeout) {
+				t.Errorf("Timeout = %v, want %v", got, tt.wantTimeout)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+const (
+	// VerifyCmdLabel is a shell command run inside the recreated container
+	// (via docker exec) to confirm the update worked, e.g.
+	// io.repull.verify-cmd=curl -f http://localhost:8080/health. It is retried
+	// until it exits 0 or VerifyTimeoutLabel elapses; on failure the old
+	// container is restored.
+	VerifyCmdLabel = "io.repull.verify-cmd"
+	// VerifyTimeoutLabel overrides how long the verify command may keep
+	// failing, or the container may take to get ready (see ReadyLabel),
+	// before the update is rolled back (default 60s).
+	VerifyTimeoutLabel = "io.repull.verify-timeout"
+)
+
+// defaultVerifyTimeout is how long a verify command may keep failing when no
+// io.repull.verify-timeout is set. Apps usually need a few seconds to start
+// listening, so a single attempt right after start would fail spuriously.
+const defaultVerifyTimeout = 60 * time.Second
+
+// verifyRetryInterval is the pause between verify attempts.
+const verifyRetryInterval = 2 * time.Second
+
+// maxVerifyOutput caps how much command output ends up in logs and errors.
+const maxVerifyOutput = 500
+
+// verifySpec reads the verify labels of a container. An empty cmd means no
+// verification is configured.
+func verifySpec(c container.InspectResponse) (cmd string, timeout time.Duration, err error) {
+	if c.Config == nil {
+		return "", 0, nil
+	}
+	cmd = strings.TrimSpace(c.Config.Labels[VerifyCmdLabel])
+	if cmd == "" {
+		return "", 0, nil
+	}
+	timeout, err = verifyTimeout(c)
+	if err != nil {
+		return "", 0, err
+	}
+	return cmd, timeout, nil
+}
+
+// verifyTimeout reads VerifyTimeoutLabel, defaulting to
+// defaultVerifyTimeout.
+func verifyTimeout(c container.InspectResponse) (time.Duration, error) {
+	v := c.Config.Labels[VerifyTimeoutLabel]
+	if v == "" {
+		return defaultVerifyTimeout, nil
+	}
+	timeout, err := time.ParseDuration(v)
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("invalid %s %q: must be a positive duration such as 90s", VerifyTimeoutLabel, v)
+	}
+	return timeout, nil
+}
+
+// verifyContainer runs cmd in the container until it succeeds or timeout
+// elapses. Each attempt is bound by the same deadline, so a command that
+// hangs cannot outlast timeout. The error of the last attempt includes the
+// command's output.
+func verifyContainer(ctx context.Context, cli *client.Client, containerID, cmd string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		attemptCtx, cancel := context.WithDeadline(ctx, deadline)
+		exitCode, output, err := execInContainer(attemptCtx, cli, containerID, cmd)
+		cancel()
+		if err == nil && exitCode == 0 {
+			return nil
+		}
+		if time.Now().Add(verifyRetryInterval).After(deadline) {
+			if err != nil && ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+				return fmt.Errorf("verify command did not finish within %s", timeout)
+			}
+			if err != nil {
+				return fmt.Errorf("verify command failed: %w", err)
+			}
+			return fmt.Errorf("verify command exited %d after %s: %s", exitCode, timeout, truncateOutput(output))
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("verify command: %w", ctx.Err())
+		case <-time.After(verifyRetryInterval):
+		}
+	}
+}
+
+// execInContainer runs cmd through sh -c in the container and returns its
+// exit code and combined stdout/stderr.
+func execInContainer(ctx context.Context, cli *client.Client, containerID, cmd string) (int, string, error) {
+	exec, err := cli.ContainerExecCreate(ctx, containerID, container.ExecOptions{
+		Cmd:          []string{"sh", "-c", cmd},
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return 0, "", err
+	}
+
+	resp, err := cli.ContainerExecAttach(ctx, exec.ID, container.ExecAttachOptions{})
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Close()
+	// Reading the attached stream does not watch ctx; closing it does.
+	stop := context.AfterFunc(ctx, resp.Close)
+	defer stop()
+
+	var out bytes.Buffer
+	if _, err := stdcopy.StdCopy(&out, &out, resp.Reader); err != nil {
+		if ctx.Err() != nil {
+			return 0, out.String(), ctx.Err()
+		}
+		return 0, "", err
+	}
+
+	// The stream closes as the process exits; the exit code can lag behind.
+	for {
+		inspect, err := cli.ContainerExecInspect(ctx, exec.ID)
+		if err != nil {
+			return 0, out.String(), err
+		}
+		if !inspect.Running {
+			return inspect.ExitCode, out.String(), nil
+		}
+		select {
+		case <-ctx.Done():
+			return 0, out.String(), ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// truncateOutput trims command output for logs and notifications.
+func truncateOutput(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) > maxVerifyOutput {
+		return s[:maxVerifyOutput] + "..."
+	}
+	return s
+}
@@ -0,0 +1,105 @@
+package docker
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+func TestVerifySpec(t *testing.T) {
+	withLabels := func(labels map[string]string) container.InspectResponse {
+		return container.InspectResponse{Config: &container.Config{Labels: labels}}
+	}
+
+	tests := []struct {
+		name        string
+		c           container.InspectResponse
+		wantCmd     string
+		wantTimeout time.Duration
+		wantErr     bool
+	}{
+		{name: "nil config", c: container.InspectResponse{}},
+		{name: "no label", c: withLabels(nil)},
+		{
+			name:        "default timeout",
+			c:           withLabels(map[string]string{VerifyCmdLabel: "curl -f http://localhost:8080/health"}),
+			wantCmd:     "curl -f http://localhost:8080/health",
+			wantTimeout: defaultVerifyTimeout,
+		},
+		{
+			name:        "custom timeout",
+			c:           withLabels(map[string]string{VerifyCmdLabel: "true", VerifyTimeoutLabel: "90s"}),
+			wantCmd:     "true",
+			wantTimeout: 90 * time.Second,
+		},
+		{
+			name:    "invalid timeout",
+			c:       withLabels(map[string]string{VerifyCmdLabel: "true", VerifyTimeoutLabel: "soon"}),
+			wantErr: true,
+		},
+		{
+			name: "timeout without command is ignored",
+			c:    withLabels(map[string]string{VerifyTimeoutLabel: "soon"}),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd, timeout, err := verifySpec(tt.c)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("verifySpec() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if cmd != tt.wantCmd || timeout != tt.wantTimeout {
+				t.Errorf("verifySpec() = %q, %s; want %q, %s", cmd, timeout, tt.wantCmd, tt.wantTimeout)
+			}
+		})
+	}
+}
+
+func TestTruncateOutput(t *testing.T) {
+	long := strings.Repeat("x", maxVerifyOutput+10)
+	if got := truncateOutput(long); len(got) != maxVerifyOutput+3 || !strings.HasSuffix(got, "...") {
+		t.Errorf("truncateOutput() length = %d, want %d with ... suffix", len(got), maxVerifyOutput+3)
+	}
+	if got := truncateOutput("  ok\n"); got != "ok" {
+		t.Errorf("truncateOutput() = %q, want %q", got, "ok")
+	}
+}
+
+// TestVerifyContainerHangingExec verifies a verify command that never
+// finishes is cut off at the verify timeout rather than waited on.
+func TestVerifyContainerHangingExec(t *testing.T) {
+	done := make(chan struct{})
+	cli, _ := fakeDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/exec"):
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"Id":"exec1"}`))
+		case strings.HasSuffix(r.URL.Path, "/start"):
+			// Attach, then never send output or end the stream.
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			conn.Write([]byte("HTTP/1.1 101 UPGRADED\r\nContent-Type: application/vnd.docker.raw-stream\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n\r\n"))
+			<-done
+		default:
+			w.Write([]byte(`{"Running":true}`))
+		}
+	})
+	t.Cleanup(func() { close(done) })
+
+	start := time.Now()
+	err := verifyContainer(t.Context(), cli, "c1", "sleep infinity", 200*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "did not finish within 200ms") {
+		t.Errorf("verifyContainer() error = %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("verifyContainer() took %v, want it bounded by the 200ms timeout", elapsed)
+	}
+}
@@ -0,0 +1,200 @@
+// Package ecr fetches short-lived pull credentials for Amazon ECR
+// registries. ECR has no long-lived registry passwords: a token from the
+// GetAuthorizationToken API is valid for 12 hours, and config.json entries
+// written by `aws ecr get-login-password | docker login` go stale long before
+// an unattended repull is restarted. The API is called directly with a
+// SigV4-signed request, which avoids pulling in the AWS SDK.
+package ecr
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fanuelsen/repull/internal/useragent"
+)
+
+// hostPattern matches private ECR registry hosts, e.g.
+// 123456789012.dkr.ecr.eu-west-1.amazonaws.com, including the FIPS and
+// China-partition variants.
+var hostPattern = regexp.MustCompile(`^[0-9]{12}\.dkr\.ecr(-fips)?\.([a-z0-9-]+)\.amazonaws\.com(\.cn)?$`)
+
+// refreshBefore is how long before expiry a cached token is replaced, so a
+// pull never starts with a token about to lapse.
+const refreshBefore = 15 * time.Minute
+
+// RegionFor reports whether host is an ECR registry and, if so, the region
+// in its hostname.
+func RegionFor(host string) (region string, ok bool) {
+	m := hostPattern.FindStringSubmatch(host)
+	if m == nil {
+		return "", false
+	}
+	return m[2], true
+}
+
+// apiHost returns the host of the ECR API serving the registry at host in
+// region: the FIPS endpoint for a -fips registry, and the China partition's
+// for an .amazonaws.com.cn one.
+func apiHost(host, region string) string {
+	m := hostPattern.FindStringSubmatch(host)
+	if m == nil {
+		return ""
+	}
+	if m[1] != "" {
+		return "ecr-fips." + region + ".amazonaws.com" + m[3]
+	}
+	return "api.ecr." + region + ".amazonaws.com" + m[3]
+}
+
+// Credentials are AWS access keys used to sign API requests.
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// CredentialsFromEnv reads the standard AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and optional AWS_SESSION_TOKEN variables.
+func CredentialsFromEnv() (Credentials, error) {
+	creds := Credentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return Credentials{}, errors.New("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set")
+	}
+	return creds, nil
+}
+
+type token struct {
+	username string
+	password string
+	expires  time.Time
+}
+
+// Provider hands out ECR registry credentials, caching one token per API
+// endpoint until shortly before it expires.
+type Provider struct {
+	creds Credentials
+	// region, if set, is used for every registry instead of the region in
+	// its hostname.
+	region string
+	http   *http.Client
+	// endpoint returns the API URL for an API host (see apiHost);
+	// overridden in tests.
+	endpoint func(api string) string
+	now      func() time.Time
+
+	mu     sync.Mutex
+	tokens map[string]token
+}
+
+// NewProvider returns a Provider signing with creds. A non-empty region
+// overrides the region taken from each registry's hostname.
+func NewProvider(creds Credentials, region string) *Provider {
+	return &Provider{
+		creds:  creds,
+		region: region,
+		http:   &http.Client{Timeout: 30 * time.Second, Transport: useragent.Transport{}},
+		endpoint: func(api string) string {
+			return "https://" + api + "/"
+		},
+		now:    time.Now,
+		tokens: make(map[string]token),
+	}
+}
+
+// Auth returns the username and password for the registry at host, or
+// ok=false if host is not an ECR registry. A token is fetched only on first
+// use and when the cached one is about to expire.
+func (p *Provider) Auth(ctx context.Context, host string) (username, password string, ok bool, err error) {
+	region, ok := RegionFor(host)
+	if !ok {
+		return "", "", false, nil
+	}
+	if p.region != "" {
+		region = p.region
+	}
+	api := apiHost(host, region)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if t, cached := p.tokens[api]; cached && p.now().Add(refreshBefore).Before(t.expires) {
+		return t.username, t.password, true, nil
+	}
+	t, err := p.fetch(ctx, api, region)
+	if err != nil {
+		return "", "", true, err
+	}
+	p.tokens[api] = t
+	return t.username, t.password, true, nil
+}
+
+// fetch calls GetAuthorizationToken on the API host api in region.
+func (p *Provider) fetch(ctx context.Context, api, region string) (token, error) {
+	body := []byte("{}")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint(api), strings.NewReader(string(body)))
+	if err != nil {
+		return token{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "AmazonEC2ContainerRegistry_V20150921.GetAuthorizationToken")
+	sign(req, body, p.creds, region, "ecr", p.now())
+
+	resp, err := p.http.Do(req)
+	if err != nil {
+		return token{}, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return token{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		_ = json.Unmarshal(data, &apiErr)
+		return token{}, fmt.Errorf("GetAuthorizationToken: %s: %s %s", resp.Status, apiErr.Type, apiErr.Message)
+	}
+
+	var out struct {
+		AuthorizationData []struct {
+			AuthorizationToken string  `json:"authorizationToken"`
+			ExpiresAt          float64 `json:"expiresAt"`
+		} `json:"authorizationData"`
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return token{}, fmt.Errorf("GetAuthorizationToken: %w", err)
+	}
+	if len(out.AuthorizationData) == 0 {
+		return token{}, errors.New("GetAuthorizationToken: no authorization data in response")
+	}
+
+	auth := out.AuthorizationData[0]
+	decoded, err := base64.StdEncoding.DecodeString(auth.AuthorizationToken)
+	if err != nil {
+		return token{}, fmt.Errorf("GetAuthorizationToken: decoding token: %w", err)
+	}
+	user, pass, ok := strings.Cut(string(decoded), ":")
+	if !ok {
+		return token{}, errors.New("GetAuthorizationToken: malformed token")
+	}
+	sec, frac := math.Modf(auth.ExpiresAt)
+	return token{username: user, password: pass, expires: time.Unix(int64(sec), int64(frac*1e9))}, nil
+}
@@ -0,0 +1,156 @@
+package ecr
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegionFor(t *testing.T) {
+	tests := []struct {
+		host   string
+		region string
+		ok     bool
+	}{
+		{"123456789012.dkr.ecr.eu-west-1.amazonaws.com", "eu-west-1", true},
+		{"123456789012.dkr.ecr.us-gov-west-1.amazonaws.com", "us-gov-west-1", true},
+		{"123456789012.dkr.ecr-fips.us-east-1.amazonaws.com", "us-east-1", true},
+		{"123456789012.dkr.ecr.cn-north-1.amazonaws.com.cn", "cn-north-1", true},
+		{"public.ecr.aws", "", false},
+		{"docker.io", "", false},
+		{"ghcr.io", "", false},
+		{"12345.dkr.ecr.eu-west-1.amazonaws.com", "", false},
+		{"123456789012.dkr.ecr.eu-west-1.amazonaws.com.evil.example", "", false},
+		{"123456789012.dkr.ecr.eu-west-1.amazonaws.com:443", "", false},
+	}
+	for _, tt := range tests {
+		region, ok := RegionFor(tt.host)
+		if region != tt.region || ok != tt.ok {
+			t.Errorf("RegionFor(%q) = %q, %v; want %q, %v", tt.host, region, ok, tt.region, tt.ok)
+		}
+	}
+}
+
+// TestAPIHost verifies that each registry is served by the API endpoint of
+// its own partition: FIPS registries by the FIPS endpoint and China
+// registries by the .amazonaws.com.cn one.
+func TestAPIHost(t *testing.T) {
+	tests := []struct {
+		name, host, want string
+	}{
+		{"standard", "123456789012.dkr.ecr.eu-west-1.amazonaws.com", "api.ecr.eu-west-1.amazonaws.com"},
+		{"fips", "123456789012.dkr.ecr-fips.us-east-1.amazonaws.com", "ecr-fips.us-east-1.amazonaws.com"},
+		{"china", "123456789012.dkr.ecr.cn-north-1.amazonaws.com.cn", "api.ecr.cn-north-1.amazonaws.com.cn"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			region, _ := RegionFor(tt.host)
+			if got := apiHost(tt.host, region); got != tt.want {
+				t.Errorf("apiHost(%q) = %q, want %q", tt.host, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSign checks the signer against the GET ListUsers example from the AWS
+// Signature Version 4 documentation.
+func TestSign(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", nil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	creds := Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+
+	sign(req, nil, creds, "us-east-1", "iam", now)
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, " +
+		"SignedHeaders=content-type;host;x-amz-date, " +
+		"Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("Authorization =\n  %s\nwant\n  %s", got, want)
+	}
+}
+
+func TestProviderAuth(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	calls := 0
+	var gotRegion string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if target := r.Header.Get("X-Amz-Target"); !strings.HasSuffix(target, ".GetAuthorizationToken") {
+			t.Errorf("X-Amz-Target = %q", target)
+		}
+		auth := r.Header.Get("Authorization")
+		if !strings.Contains(auth, "Credential=AKID/20260101/") {
+			t.Errorf("Authorization = %q", auth)
+		}
+		gotRegion = strings.Split(auth, "/")[2]
+		tok := base64.StdEncoding.EncodeToString([]byte("AWS:secret-password"))
+		expires := now.Add(12 * time.Hour).Unix()
+		w.Write([]byte(`{"authorizationData":[{"authorizationToken":"` + tok + `","expiresAt":` + strconv.FormatInt(expires, 10) + `}]}`))
+	}))
+	defer srv.Close()
+
+	var gotAPI []string
+	p := NewProvider(Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}, "")
+	p.endpoint = func(api string) string {
+		gotAPI = append(gotAPI, api)
+		return srv.URL + "/"
+	}
+	p.now = func() time.Time { return now }
+
+	user, pass, ok, err := p.Auth(context.Background(), "docker.io")
+	if ok || err != nil || calls != 0 {
+		t.Fatalf("Auth(docker.io) = %q, %q, %v, %v; want no ECR lookup", user, pass, ok, err)
+	}
+
+	host := "123456789012.dkr.ecr.eu-west-1.amazonaws.com"
+	user, pass, ok, err = p.Auth(context.Background(), host)
+	if err != nil || !ok || user != "AWS" || pass != "secret-password" {
+		t.Fatalf("Auth() = %q, %q, %v, %v", user, pass, ok, err)
+	}
+	if gotRegion != "eu-west-1" {
+		t.Errorf("signed for region %q, want eu-west-1", gotRegion)
+	}
+
+	// A cached token is reused until it nears expiry.
+	p.now = func() time.Time { return now.Add(11 * time.Hour) }
+	p.Auth(context.Background(), host)
+	if calls != 1 {
+		t.Errorf("API called %d times, want 1 (cached)", calls)
+	}
+	p.now = func() time.Time { return now.Add(11*time.Hour + 50*time.Minute) }
+	p.Auth(context.Background(), host)
+	if calls != 2 {
+		t.Errorf("API called %d times, want 2 (refreshed)", calls)
+	}
+
+	// A China registry in the same account gets a token from its own
+	// partition's endpoint.
+	if _, _, _, err := p.Auth(context.Background(), "123456789012.dkr.ecr.cn-north-1.amazonaws.com.cn"); err != nil {
+		t.Fatalf("Auth(China) error = %v", err)
+	}
+	if calls != 3 || gotAPI[len(gotAPI)-1] != "api.ecr.cn-north-1.amazonaws.com.cn" {
+		t.Errorf("API hosts = %v, want the China endpoint last", gotAPI)
+	}
+}
+
+func TestProviderAuthError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"__type":"UnrecognizedClientException","message":"The security token included in the request is invalid."}`))
+	}))
+	defer srv.Close()
+
+	p := NewProvider(Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}, "us-east-1")
+	p.endpoint = func(string) string { return srv.URL + "/" }
+
+	_, _, ok, err := p.Auth(context.Background(), "123456789012.dkr.ecr.eu-west-1.amazonaws.com")
+	if !ok || err == nil || !strings.Contains(err.Error(), "UnrecognizedClientException") {
+		t.Errorf("Auth() ok=%v err=%v; want ok with an API error", ok, err)
+	}
+}
@@ -0,0 +1,140 @@
+// Package events streams JSON events about update runs to local clients over
+// a Unix domain socket. Each event is one JSON object per line, so a client
+// can simply tail the socket (e.g. socat - UNIX-CONNECT:/run/repull.sock).
+package events
+
+import (
+	"encoding/json"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Event types.
+const (
+	RunStart = "run_start"
+	Group    = "group"
+	RunEnd   = "run_end"
+)
+
+// Event is one entry of the stream. Fields that do not apply to an event
+// type are omitted.
+type Event struct {
+	Time   time.Time `json:"time"`
+	Type   string    `json:"type"`
+	Group  string    `json:"group,omitempty"`
+	Image  string    `json:"image,omitempty"`
+	Result string    `json:"result,omitempty"`
+	Error  string    `json:"error,omitempty"`
+	Groups int       `json:"groups,omitempty"`
+	Failed int       `json:"failed,omitempty"`
+}
+
+// clientBuffer is how many events a slow client may lag behind before
+// further events are dropped for it.
+const clientBuffer = 64
+
+// Broadcaster fans events out to every connected client. Events are never
+// queued for clients that are not connected, and a client that stops reading
+// loses events rather than blocking the update cycle. A nil *Broadcaster
+// discards everything, so callers need no nil checks.
+type Broadcaster struct {
+	listener net.Listener
+	path     string
+
+	mu      sync.Mutex
+	clients map[chan []byte]struct{}
+}
+
+// Listen creates the Unix socket at path and starts accepting clients. A
+// stale socket left by a previous run is removed first. The socket is only
+// accessible to the owner and group.
+func Listen(path string) (*Broadcaster, error) {
+	if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0o660); err != nil {
+		l.Close()
+		return nil, err
+	}
+
+	b := &Broadcaster{listener: l, path: path, clients: make(map[chan []byte]struct{})}
+	go b.accept()
+	return b, nil
+}
+
+func (b *Broadcaster) accept() {
+	for {
+		conn, err := b.listener.Accept()
+		if err != nil {
+			// Closed by Close.
+			return
+		}
+		ch := make(chan []byte, clientBuffer)
+		b.mu.Lock()
+		b.clients[ch] = struct{}{}
+		b.mu.Unlock()
+		go b.serve(conn, ch)
+	}
+}
+
+// serve writes events to one client until it disconnects.
+func (b *Broadcaster) serve(conn net.Conn, ch chan []byte) {
+	defer func() {
+		b.mu.Lock()
+		delete(b.clients, ch)
+		b.mu.Unlock()
+		conn.Close()
+	}()
+	for line := range ch {
+		if _, err := conn.Write(line); err != nil {
+			return
+		}
+	}
+}
+
+// Emit sends an event to every connected client, stamping the time if unset.
+func (b *Broadcaster) Emit(e Event) {
+	if b == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	// Marshalling a struct of strings, ints and a time cannot fail.
+	data, _ := json.Marshal(e)
+	data = append(data, '\n')
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.clients {
+		select {
+		case ch <- data:
+		default:
+			log.Printf("[WARN] Event client too slow, dropping %s event", e.Type)
+		}
+	}
+}
+
+// Close stops accepting clients, disconnects the connected ones and removes
+// the socket file.
+func (b *Broadcaster) Close() error {
+	if b == nil {
+		return nil
+	}
+	err := b.listener.Close()
+	b.mu.Lock()
+	for ch := range b.clients {
+		close(ch)
+		delete(b.clients, ch)
+	}
+	b.mu.Unlock()
+	os.Remove(b.path)
+	return err
+}
@@ -0,0 +1,69 @@
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBroadcasterDeliversToClient(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "repull.sock")
+	b, err := Listen(path)
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	defer b.Close()
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// Wait for the broadcaster to register the client.
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		b.mu.Lock()
+		n := len(b.clients)
+		b.mu.Unlock()
+		if n == 1 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	b.Emit(Event{Type: Group, Group: "app:web", Result: "updated"})
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil {
+		t.Fatalf("reading event: %v", err)
+	}
+	var got Event
+	if err := json.Unmarshal(line, &got); err != nil {
+		t.Fatalf("event is not JSON: %q", line)
+	}
+	if got.Type != Group || got.Group != "app:web" || got.Result != "updated" || got.Time.IsZero() {
+		t.Errorf("event = %+v, want group app:web updated with a timestamp", got)
+	}
+}
+
+func TestBroadcasterWithoutClients(t *testing.T) {
+	b, err := Listen(filepath.Join(t.TempDir(), "repull.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	// Must neither block nor panic.
+	b.Emit(Event{Type: RunStart})
+
+	var nilB *Broadcaster
+	nilB.Emit(Event{Type: RunStart})
+	if err := nilB.Close(); err != nil {
+		t.Errorf("nil Close() error = %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+package notify
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// discordMaxLen is Discord's limit for the content of one message, in
+// characters.
+const discordMaxLen = 2000
+
+// chunkSuffixLen is the room reserved in each chunk for the " (i/n)" counter.
+const chunkSuffixLen = 12
+
+// chunkMessage splits content into messages of at most limit characters,
+// numbered " (1/3)" etc. when more than one is needed. It splits at line
+// breaks where possible and only cuts inside a line that alone exceeds the
+// limit. Content that fits is returned unchanged.
+func chunkMessage(content string, limit int) []string {
+	if utf8.RuneCountInString(content) <= limit {
+		return []string{content}
+	}
+
+	max := limit - chunkSuffixLen
+	var chunks []string
+	var cur strings.Builder
+	curLen := 0
+	flush := func() {
+		if curLen > 0 {
+			chunks = append(chunks, cur.String())
+			cur.Reset()
+			curLen = 0
+		}
+	}
+
+	for _, line := range strings.SplitAfter(content, "\n") {
+		for utf8.RuneCountInString(line) > max {
+			flush()
+			runes := []rune(line)
+			chunks = append(chunks, string(runes[:max]))
+			line = string(runes[max:])
+		}
+		n := utf8.RuneCountInString(line)
+		if curLen+n > max {
+			flush()
+		}
+		cur.WriteString(line)
+		curLen += n
+	}
+	flush()
+
+	for i := range chunks {
+		chunks[i] = strings.TrimRight(chunks[i], "\n") + fmt.Sprintf(" (%d/%d)", i+1, len(chunks))
+	}
+	return chunks
+}
@@ -0,0 +1,55 @@
+package notify
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestChunkMessageFits(t *testing.T) {
+	got := chunkMessage("short message", discordMaxLen)
+	if len(got) != 1 || got[0] != "short message" {
+		t.Errorf("chunkMessage() = %q, want the message unchanged", got)
+	}
+}
+
+// TestChunkMessageSplitsSummary verifies a large run summary is split at line
+// boundaries into numbered messages that each fit Discord's limit.
+func TestChunkMessageSplitsSummary(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("Update summary\n")
+	for i := range 150 {
+		fmt.Fprintf(&b, "✅ project:service-%03d sha256:0123456789 → sha256:abcdef0123\n", i)
+	}
+	summary := b.String()
+
+	got := chunkMessage(summary, discordMaxLen)
+	if len(got) < 2 {
+		t.Fatalf("chunkMessage() returned %d chunk(s), want several", len(got))
+	}
+	for i, c := range got {
+		if n := utf8.RuneCountInString(c); n > discordMaxLen {
+			t.Errorf("chunk %d has %d characters, over the %d limit", i+1, n, discordMaxLen)
+		}
+		if want := fmt.Sprintf("(%d/%d)", i+1, len(got)); !strings.HasSuffix(c, want) {
+			t.Errorf("chunk %d does not end with %q", i+1, want)
+		}
+	}
+	if !strings.Contains(got[0], "service-000") || !strings.Contains(got[len(got)-1], "service-149") {
+		t.Error("chunks lost the first or last summary line")
+	}
+}
+
+func TestChunkMessageCutsOverlongLine(t *testing.T) {
+	line := strings.Repeat("x", 3*discordMaxLen)
+	got := chunkMessage(line, discordMaxLen)
+	if len(got) < 3 {
+		t.Fatalf("chunkMessage() returned %d chunk(s), want at least 3", len(got))
+	}
+	for i, c := range got {
+		if n := utf8.RuneCountInString(c); n > discordMaxLen {
+			t.Errorf("chunk %d has %d characters, over the limit", i+1, n)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package notify
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// debouncer coalesces update notifications per group: an update starts a
+// quiet-period timer, further updates of the same group within the period
+// restart it, and when it finally fires a single message reports the net
+// change from the first old digest to the latest new one.
+type debouncer struct {
+	mu      sync.Mutex
+	window  time.Duration
+	send    func(content string)
+	summary func(SummaryData) string
+	pending map[string]*pendingUpdate
+}
+
+// pendingUpdate is the coalesced state of one group's updates.
+type pendingUpdate struct {
+	send       func(content string)
+	image      string
+	oldDigest  string
+	newDigest  string
+	notes      string
+	dependents []string
+	count      int
+	timer      *time.Timer
+}
+
+// newDebouncer returns a debouncer sending through send. summary renders
+// the coalesced messages; nil renders the default summary template.
+func newDebouncer(window time.Duration, send func(content string), summary func(SummaryData) string) *debouncer {
+	if summary == nil {
+		summary = func(d SummaryData) string { return (*Templates)(nil).Summary(d, plainEscape) }
+	}
+	return &debouncer{
+		window:  window,
+		send:    send,
+		summary: summary,
+		pending: make(map[string]*pendingUpdate),
+	}
+}
+
+// add records an update and (re)starts the group's quiet-period timer.
+func (d *debouncer) add(service, image, oldDigest, newDigest, notes string, dependents ...string) {
+	d.addVia(d.send, service, image, oldDigest, newDigest, notes, dependents...)
+}
+
+// addVia is add with the coalesced message going out through send instead
+// of the debouncer's own, e.g. a notifier carrying a routing key.
+// The notes of the latest update are the ones sent; the dependents of all
+// of them are.
+func (d *debouncer) addVia(send func(content string), service, image, oldDigest, newDigest, notes string, dependents ...string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if p, ok := d.pending[service]; ok {
+		p.send = send
+		p.image = image
+		p.newDigest = newDigest
+		p.notes = notes
+		for _, dep := range dependents {
+			if !slices.Contains(p.dependents, dep) {
+				p.dependents = append(p.dependents, dep)
+			}
+		}
+		p.count++
+		p.timer.Reset(d.window)
+		return
+	}
+
+	p := &pendingUpdate{send: send, image: image, oldDigest: oldDigest, newDigest: newDigest, notes: notes, dependents: dependents, count: 1}
+	p.timer = time.AfterFunc(d.window, func() { d.fire(service) })
+	d.pending[service] = p
+}
+
+// fire sends the coalesced notification for a group.
+func (d *debouncer) fire(service string) {
+	d.mu.Lock()
+	p, ok := d.pending[service]
+	delete(d.pending, service)
+	d.mu.Unlock()
+
+	if ok {
+		p.send(d.summary(p.data(service)))
+	}
+}
+
+// flush sends every pending notification immediately, e.g. before the
+// process exits.
+func (d *debouncer) flush() {
+	d.mu.Lock()
+	pending := d.pending
+	d.pending = make(map[string]*pendingUpdate)
+	d.mu.Unlock()
+
+	for service, p := range pending {
+		p.timer.Stop()
+		p.send(d.summary(p.data(service)))
+	}
+}
+
+func (p *pendingUpdate) data(service string) SummaryData {
+	return SummaryData{Service: service, Image: p.image, OldDigest: p.oldDigest, NewDigest: p.newDigest, Notes: p.notes, Dependents: p.dependents, Count: p.count}
+}
@@ -0,0 +1,78 @@
+package notify
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder collects sent messages for inspection.
+type recorder struct {
+	mu   sync.Mutex
+	sent []string
+}
+
+func (r *recorder) send(content string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sent = append(r.sent, content)
+}
+
+func (r *recorder) messages() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.sent...)
+}
+
+func TestDebouncerCoalescesNetChange(t *testing.T) {
+	r := &recorder{}
+	d := newDebouncer(time.Hour, r.send, nil)
+
+	d.add("app:web", "app:latest", "sha256:aaa", "sha256:bbb", "")
+	d.add("app:web", "app:latest", "sha256:bbb", "sha256:ccc", "")
+	d.add("app:db", "db:16", "sha256:111", "sha256:222", "")
+
+	if got := r.messages(); len(got) != 0 {
+		t.Fatalf("sent %d message(s) before the quiet period ended: %v", len(got), got)
+	}
+
+	d.flush()
+	got := r.messages()
+	if len(got) != 2 {
+		t.Fatalf("sent %d message(s), want 2 (one per group): %v", len(got), got)
+	}
+	var web string
+	for _, m := range got {
+		if strings.Contains(m, "app:web") {
+			web = m
+		}
+	}
+	if !strings.Contains(web, "sha256:aaa → sha256:ccc") {
+		t.Errorf("web message = %q, want net change sha256:aaa → sha256:ccc", web)
+	}
+	if !strings.Contains(web, "2 updates") {
+		t.Errorf("web message = %q, want update count", web)
+	}
+}
+
+func TestDebouncerFiresAfterQuietPeriod(t *testing.T) {
+	r := &recorder{}
+	d := newDebouncer(10*time.Millisecond, r.send, nil)
+
+	d.add("app:web", "app:latest", "sha256:aaa", "sha256:bbb", "")
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(r.messages()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := r.messages(); len(got) != 1 {
+		t.Fatalf("sent %d message(s) after the quiet period, want 1", len(got))
+	}
+
+	// Nothing pending anymore: a flush must not resend.
+	d.flush()
+	if got := r.messages(); len(got) != 1 {
+		t.Errorf("flush resent a delivered notification: %v", got)
+	}
+}
@@ -2,6 +2,7 @@ package notify
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -10,15 +11,44 @@ import (
 	"time"
 
 	"github.com/fanuelsen/repull/internal/sanitize"
+	"github.com/fanuelsen/repull/internal/useragent"
 )
 
-// httpClient is used for all Discord webhook requests.
+// httpClient is used for all notification requests.
 // A 10s timeout prevents a hung Discord connection from stalling the update loop.
-var httpClient = &http.Client{Timeout: 10 * time.Second}
+var httpClient = &http.Client{Timeout: 10 * time.Second, Transport: useragent.Transport{}}
 
-// Notifier sends notifications to Discord via webhook
+// retryDelays are the waits before each retry of a failed webhook post or
+// ntfy publish.
+// Connection errors and 5xx responses are retried; 4xx responses are not,
+// as sending the same message again cannot fix them.
+var retryDelays = []time.Duration{1 * time.Second, 2 * time.Second, 4 * time.Second}
+
+// postBudget bounds one webhook post or ntfy publish including its
+// retries, so an outage delays the update loop by at most this much per
+// message.
+const postBudget = 30 * time.Second
+
+// Notifier sends notifications to Discord via webhook, to a FileNotifier
+// if one is attached with WithFile, and updates and errors to the senders
+// attached with WithSender.
 type Notifier struct {
 	webhookURL string
+	debounce   *debouncer
+	file       *FileNotifier
+	senders    MultiNotifier
+	ctx        context.Context
+	// key is sent with every notification for a downstream gateway to
+	// route on (see WithKey).
+	key string
+	// severities filters what goes to the webhook (see SetSeverities).
+	severities Severities
+	// templates renders update, error and summary messages (see
+	// SetTemplates); nil renders the defaults.
+	templates *Templates
+	// batch holds a run's updates and errors for one summary message (see
+	// SetRunSummary); nil sends them as they happen.
+	batch *runSummary
 }
 
 // NewDiscordNotifier creates a new Discord notifier.
@@ -48,19 +78,250 @@ type allowedMentions struct {
 	Parse []string `json:"parse"`
 }
 
+// WithFile attaches f, so every notification is also appended to its file,
+// and returns the notifier. On a nil notifier (Discord disabled) it returns a
+// new one that only writes the file. A nil f changes nothing.
+func (n *Notifier) WithFile(f *FileNotifier) *Notifier {
+	if f == nil {
+		return n
+	}
+	if n == nil {
+		n = &Notifier{}
+	}
+	n.file = f
+	return n
+}
+
+// WithSender attaches s, so every update and error is also sent to it, and
+// returns the notifier. On a nil notifier (Discord disabled) it returns a
+// new one that only sends to s. A nil s changes nothing.
+func (n *Notifier) WithSender(s Sender) *Notifier {
+	if s == nil {
+		return n
+	}
+	if n == nil {
+		n = &Notifier{}
+	}
+	n.senders = append(n.senders, s)
+	return n
+}
+
+// WithKey returns a copy of the notifier that tags every notification with
+// a routing key: as an X-Repull-Key header on webhook requests and as the
+// "key" field of file events. Discord's payload format is fixed, so for
+// Discord the key only reaches a gateway or proxy in front of it. Debounced
+// updates go out through the copy that added them last. An empty key, or a
+// nil notifier, returns n unchanged.
+func (n *Notifier) WithKey(key string) Reporter {
+	if n == nil || key == "" {
+		return n
+	}
+	c := *n
+	c.key = key
+	c.file = n.file.withKey(key)
+	return &c
+}
+
+// SetContext makes every request the notifier sends from now on carry ctx,
+// so cancelling ctx (e.g. on shutdown) aborts notifications in flight
+// instead of leaving them to run into the HTTP timeout. The attached
+// senders that take a context get it too. Without it requests use
+// context.Background.
+func (n *Notifier) SetContext(ctx context.Context) {
+	if n == nil {
+		return
+	}
+	n.ctx = ctx
+	n.senders.SetContext(ctx)
+}
+
+// requestContext returns the context set with SetContext.
+func (n *Notifier) requestContext() context.Context {
+	if n.ctx == nil {
+		return context.Background()
+	}
+	return n.ctx
+}
+
+// SetSeverities restricts the webhook to notifications of the given
+// severities; a nil set (the default) sends everything. An attached
+// FileNotifier keeps its own filter.
+func (n *Notifier) SetSeverities(set Severities) {
+	if n == nil {
+		return
+	}
+	n.severities = set
+}
+
+// sendAs sends content to the webhook if the notifier takes severity s.
+func (n *Notifier) sendAs(s Severity, content string) {
+	if !n.severities.allows(s) {
+		return
+	}
+	n.send(content)
+}
+
+// SetTemplates renders update, error and debounced summary messages with
+// t instead of the defaults, escaping values for Discord Markdown where the
+// templates ask for it. A nil t restores the defaults.
+func (n *Notifier) SetTemplates(t *Templates) {
+	if n == nil {
+		return
+	}
+	n.templates = t
+}
+
+// summary renders a debounced update message.
+func (n *Notifier) summary(d SummaryData) string {
+	return n.templates.Summary(d, discordEscape)
+}
+
+// SetDebounce coalesces update notifications per group: they are held until
+// no further update of the group arrives for window, then sent as a single
+// message. Error notifications are never delayed. A zero window disables
+// debouncing.
+func (n *Notifier) SetDebounce(window time.Duration) {
+	if n == nil {
+		return
+	}
+	if window <= 0 {
+		n.debounce = nil
+		return
+	}
+	n.debounce = newDebouncer(window, n.send, n.summary)
+}
+
+// Flush sends any debounced or batched update notifications immediately.
+// Call it before the process exits so held notifications are not lost.
+func (n *Notifier) Flush() {
+	if n == nil {
+		return
+	}
+	n.EndRun()
+	if n.debounce != nil {
+		n.debounce.flush()
+	}
+}
+
+// EndRun marks the end of an update run: the run summary (see
+// SetRunSummary) and senders that batch a run's notifications, such as
+// EmailNotifier, send them now. Updates debounced for the webhook stay
+// held; their quiet period may span runs.
+func (n *Notifier) EndRun() {
+	if n == nil {
+		return
+	}
+	if n.batch != nil {
+		n.sendRunSummary()
+	}
+	if err := n.senders.Flush(); err != nil {
+		log.Printf("[WARN] Update notification failed: %v", err)
+	}
+}
+
 // SendUpdate sends a notification about a successful container update.
 // The digest strings are included as-is; callers truncate them for display.
-// Failures are logged, not returned: a broken webhook should never affect
-// the update cycle itself.
-func (n *Notifier) SendUpdate(service, image, oldDigest, newDigest string) {
+// notes, if not empty, is context on the new image such as its release
+// notes, appended to the message. dependents are the containers recreated
+// along with the service, reported in the same message rather than on
+// their own. Failures are logged, not returned: a broken webhook should
+// never affect the update cycle itself.
+func (n *Notifier) SendUpdate(service, image, oldDigest, newDigest, notes string, dependents ...string) {
+	if n == nil {
+		return
+	}
+
+	// The file gets every update as it happens; debouncing is for people.
+	n.file.SendUpdate(service, image, oldDigest, newDigest, notes, dependents...)
+	n.forwardUpdate([]Severity{SeverityUpdate}, service, image, oldDigest, newDigest)
+	if !n.severities.allows(SeverityUpdate) {
+		return
+	}
+	if n.batch != nil {
+		n.batch.add(n.key, UpdateResult{Service: service, Image: image, OldDigest: oldDigest, NewDigest: newDigest, Dependents: dependents})
+		return
+	}
+	if n.debounce != nil {
+		n.debounce.addVia(n.send, service, image, oldDigest, newDigest, notes, dependents...)
+		return
+	}
+
+	n.send(n.templates.Update(UpdateData{Service: service, Image: image, OldDigest: oldDigest, NewDigest: newDigest, Notes: notes, Dependents: dependents}, discordEscape))
+}
+
+// forwardUpdate sends an update of severities ss to the attached senders
+// that take one of them (see Filter), logging failures.
+func (n *Notifier) forwardUpdate(ss []Severity, service, image, oldDigest, newDigest string) {
+	if err := n.senders.sendUpdate(ss, service, image, oldDigest, newDigest); err != nil {
+		log.Printf("[WARN] Update notification failed: %v", err)
+	}
+}
+
+// forwardError sends an error of severities ss to the attached senders
+// that take one of them, logging failures. Senders only know updates and
+// errors, so other notifications that need attention, such as a halted
+// run, reach them as errors.
+func (n *Notifier) forwardError(ss []Severity, service, errorMsg string) {
+	if err := n.senders.sendError(ss, service, errorMsg); err != nil {
+		log.Printf("[WARN] Error notification failed: %v", err)
+	}
+}
+
+// SendAvailable sends a notification that a service has an update repull
+// would apply but, in --maintenance-mode, does not. It reads differently
+// from SendUpdate so nobody mistakes it for a real change. Like SendUpdate,
+// failures are logged, not returned.
+func (n *Notifier) SendAvailable(service, image, oldDigest, newDigest string) {
+	if n == nil {
+		return
+	}
+
+	n.file.SendAvailable(service, image, oldDigest, newDigest)
+	n.sendAs(SeverityUpdate, fmt.Sprintf("🔔 Update available for %s (not applied, maintenance mode)\nImage: %s\n%s → %s",
+		service, image, oldDigest, newDigest))
+}
+
+// SendPulled sends a notification that --pull-only pulled a new image for a
+// service without recreating its containers. Like SendUpdate, failures are
+// logged, not returned.
+func (n *Notifier) SendPulled(service, image, oldDigest, newDigest string) {
 	if n == nil {
 		return
 	}
 
-	n.send(fmt.Sprintf("✅ Updated %s\nImage: %s\n%s → %s",
+	n.file.SendPulled(service, image, oldDigest, newDigest)
+	n.sendAs(SeverityUpdate, fmt.Sprintf("📦 New image pulled (not recreated) for %s\nImage: %s\n%s → %s",
 		service, image, oldDigest, newDigest))
 }
 
+// SendCanary sends a notification that one container of a service, the
+// canary, now runs the new image and the rest wait for `repull --promote`.
+// Like SendUpdate, failures are logged, not returned.
+func (n *Notifier) SendCanary(service, container, image, oldDigest, newDigest string) {
+	if n == nil {
+		return
+	}
+
+	n.file.SendCanary(service, container, image, oldDigest, newDigest)
+	n.forwardUpdate([]Severity{SeverityUpdate}, service+" (canary "+container+")", image, oldDigest, newDigest)
+	n.sendAs(SeverityUpdate, fmt.Sprintf("🐤 Canary deployed for %s: %s\nImage: %s\n%s → %s\nRun `repull --promote %s` to roll out the rest",
+		service, container, image, oldDigest, newDigest, service))
+}
+
+// SendStaleBase sends a notification that a service's image was built on a
+// base image that has since changed, so the image needs a rebuild. Like
+// SendUpdate, failures are logged, not returned.
+func (n *Notifier) SendStaleBase(service, image, base, oldDigest, newDigest string) {
+	if n == nil {
+		return
+	}
+
+	n.file.SendStaleBase(service, image, base, oldDigest, newDigest)
+	n.forwardError([]Severity{SeverityUpdate}, service, fmt.Sprintf("base image %s of %s changed (%s -> %s), the image needs a rebuild", base, image, oldDigest, newDigest))
+	n.sendAs(SeverityUpdate, fmt.Sprintf("⚠️ Base image changed for %s\nImage: %s is built on %s\n%s → %s\nThe image needs a rebuild to pick it up",
+		service, image, base, oldDigest, newDigest))
+}
+
 // SendError sends a notification about an update failure.
 // Error messages are truncated to avoid leaking sensitive data (e.g. registry
 // credentials that may appear in Docker API error strings) to Discord.
@@ -76,27 +337,200 @@ func (n *Notifier) SendError(service, errorMsg string) {
 		errorMsg = errorMsg[:maxLen] + "..."
 	}
 
-	n.send(fmt.Sprintf("❌ Failed to update %s\nError: %s", service, errorMsg))
+	n.file.SendError(service, errorMsg)
+	n.forwardError([]Severity{SeverityError}, service, errorMsg)
+	if n.batch != nil && n.severities.allows(SeverityError) {
+		n.batch.add(n.key, UpdateResult{Service: service, Error: errorMsg})
+		return
+	}
+	n.sendAs(SeverityError, n.templates.Error(ErrorData{Service: service, Error: errorMsg}, discordEscape))
+}
+
+// SelfUpdateStage is the stage of a self-update SendSelfUpdate reports.
+type SelfUpdateStage string
+
+const (
+	// SelfUpdateStarting is sent before the old container is renamed, so
+	// there is a trace even if the process dies halfway.
+	SelfUpdateStarting SelfUpdateStage = "starting"
+	// SelfUpdateDone is sent once the new container runs, before the old
+	// one (this process) is stopped.
+	SelfUpdateDone SelfUpdateStage = "done"
+	// SelfUpdateFailed is sent when the new container could not take over
+	// and the old one was kept.
+	SelfUpdateFailed SelfUpdateStage = "failed"
+)
+
+// SendSelfUpdate sends a notification about repull replacing its own
+// container. These messages matter more than others: a failed self-update
+// can leave nothing running to report later, and on success the sender is
+// about to be stopped. So they are never debounced, and they carry the
+// self-update severity (see SeveritySelfUpdate). detail is the error of a
+// failed stage, or the new image's notes once done (see SendUpdate). Like
+// SendUpdate, failures are logged, not returned.
+func (n *Notifier) SendSelfUpdate(stage SelfUpdateStage, service, image, oldDigest, newDigest, detail string) {
+	if n == nil {
+		return
+	}
+
+	n.file.SendSelfUpdate(stage, service, image, oldDigest, newDigest, detail)
+	switch stage {
+	case SelfUpdateDone:
+		n.forwardUpdate([]Severity{SeveritySelfUpdate, SeverityUpdate}, service, image, oldDigest, newDigest)
+	case SelfUpdateFailed:
+		n.forwardError([]Severity{SeveritySelfUpdate, SeverityError}, service, "self-update failed, the old instance keeps running: "+detail)
+	}
+	var content string
+	also := SeverityUpdate
+	switch stage {
+	case SelfUpdateStarting:
+		content = fmt.Sprintf("🔄 Self-update of %s starting\nImage: %s\n%s → %s", service, image, oldDigest, newDigest)
+	case SelfUpdateDone:
+		content = fmt.Sprintf("✅ Self-update of %s done, stopping the old instance\nImage: %s\n%s → %s", service, image, oldDigest, newDigest)
+		if detail != "" {
+			content += "\n" + detail
+		}
+	default:
+		content = fmt.Sprintf("❌ Self-update of %s failed, the old instance keeps running\nImage: %s\nError: %s", service, image, detail)
+		also = SeverityError
+	}
+	if !n.severities.allows(SeveritySelfUpdate, also) {
+		return
+	}
+	n.send(content)
+}
+
+// SendHalt sends a notification that repull halted a run because too many
+// groups failed in a row (--max-consecutive-failures). Unlike the other
+// notifications it pings the channel with @here: services may be down and
+// someone needs to look now. reason is repull's own text, never container
+// or image names, so the mention cannot be triggered from outside. Like
+// SendUpdate, failures are logged, not returned.
+func (n *Notifier) SendHalt(reason string) {
+	if n == nil {
+		return
+	}
+
+	n.file.SendHalt(reason)
+	n.forwardError([]Severity{SeverityError}, "repull", "circuit breaker open, halting run: "+reason)
+	if n.webhookURL == "" || !n.severities.allows(SeverityError) {
+		return
+	}
+	if err := n.postMentions("🚨 @here Circuit breaker open, halting run\n"+reason, []string{"everyone"}); err != nil {
+		log.Printf("[WARN] Discord notification failed: %v", err)
+	}
+}
+
+// Test sends a sample update and a sample error notification, rendered with
+// the notifier's templates, so a webhook can be verified without waiting
+// for a real update. Unlike SendUpdate and SendError it returns the first
+// failure instead of logging it.
+func (n *Notifier) Test() error {
+	update := UpdateData{Service: "repull:test", Image: "example/image:latest", OldDigest: "sha256:0000000000", NewDigest: "sha256:1111111111"}
+	if err := n.post(n.templates.Update(update, discordEscape)); err != nil {
+		return fmt.Errorf("sample update: %w", err)
+	}
+	if err := n.post(n.templates.Error(ErrorData{Service: "repull:test", Error: "this is a test notification"}, discordEscape)); err != nil {
+		return fmt.Errorf("sample error: %w", err)
+	}
+	return nil
+}
+
+// Check verifies the webhook exists and its token is valid without posting a
+// message: Discord answers a GET on a webhook URL with the webhook's details.
+func (n *Notifier) Check() error {
+	req, err := http.NewRequestWithContext(n.requestContext(), http.MethodGet, n.webhookURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
 }
 
 // send performs the HTTP POST to the Discord webhook, logging any failure.
+// Content over Discord's message limit is split into numbered messages
+// rather than rejected by Discord. A notifier without a webhook (see
+// WithFile) sends nothing.
+func (n *Notifier) send(content string) {
+	if n.webhookURL == "" {
+		return
+	}
+	for _, chunk := range chunkMessage(content, discordMaxLen) {
+		if err := n.post(chunk); err != nil {
+			log.Printf("[WARN] Discord notification failed: %v", err)
+			return
+		}
+	}
+}
+
+// post performs the HTTP POST to the Discord webhook.
 // Content is sanitized here at the sink so no caller can forget it — error
 // text in particular can echo registry-controlled response bodies.
-func (n *Notifier) send(content string) {
+func (n *Notifier) post(content string) error {
+	return n.postMentions(content, []string{})
+}
+
+// postMentions is post with the mention types (e.g. "everyone") Discord
+// may resolve in content; post allows none. Transient failures are
+// retried after each of retryDelays, within postBudget.
+func (n *Notifier) postMentions(content string, mentions []string) error {
 	// Marshalling a struct of strings and a string slice cannot fail.
 	data, _ := json.Marshal(webhookMessage{
 		Content:         sanitize.String(content),
-		AllowedMentions: allowedMentions{Parse: []string{}},
+		AllowedMentions: allowedMentions{Parse: mentions},
 	})
 
-	resp, err := httpClient.Post(n.webhookURL, "application/json", bytes.NewBuffer(data))
+	return withRetries(n.requestContext(), func(ctx context.Context) (bool, error) {
+		return n.postOnce(ctx, data)
+	})
+}
+
+// withRetries calls try until it succeeds or reports a failure not worth
+// retrying, waiting retryDelays between attempts, all within postBudget
+// of ctx.
+func withRetries(ctx context.Context, try func(ctx context.Context) (retry bool, err error)) error {
+	ctx, cancel := context.WithTimeout(ctx, postBudget)
+	defer cancel()
+	for attempt := 0; ; attempt++ {
+		retry, err := try(ctx)
+		if err == nil || !retry || attempt == len(retryDelays) {
+			return err
+		}
+		select {
+		case <-time.After(retryDelays[attempt]):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+// postOnce makes a single POST of data to the webhook. retry reports
+// whether a failure is worth retrying: a connection error or a 5xx.
+func (n *Notifier) postOnce(ctx context.Context, data []byte) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.webhookURL, bytes.NewReader(data))
 	if err != nil {
-		log.Printf("[WARN] Discord notification failed: %v", err)
-		return
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if n.key != "" {
+		req.Header.Set("X-Repull-Key", sanitize.String(n.key))
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		log.Printf("[WARN] Discord notification failed: webhook returned status %d", resp.StatusCode)
+		return resp.StatusCode >= 500, fmt.Errorf("webhook returned status %d", resp.StatusCode)
 	}
+	return false, nil
 }
@@ -0,0 +1,225 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewDiscordNotifier(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		wantNil bool
+		wantErr bool
+	}{
+		{name: "empty disables", url: "", wantNil: true},
+		{name: "discord.com", url: "https://discord.com/api/webhooks/1/abc"},
+		{name: "discordapp.com", url: "https://discordapp.com/api/webhooks/1/abc"},
+		{name: "plain http rejected", url: "http://discord.com/api/webhooks/1/abc", wantNil: true, wantErr: true},
+		{name: "other host rejected", url: "https://example.com/api/webhooks/1/abc", wantNil: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := NewDiscordNotifier(tt.url)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewDiscordNotifier(%q) error = %v, wantErr %v", tt.url, err, tt.wantErr)
+			}
+			if (n == nil) != tt.wantNil {
+				t.Errorf("NewDiscordNotifier(%q) = %v, wantNil %v", tt.url, n, tt.wantNil)
+			}
+		})
+	}
+}
+
+func TestNotifierTest(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	n := &Notifier{webhookURL: srv.URL}
+	if err := n.Test(); err != nil {
+		t.Fatalf("Test() error = %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("webhook called %d times, want 2 (update and error)", calls)
+	}
+}
+
+func TestSendUpdateIncludesNotes(t *testing.T) {
+	var got webhookMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	n := &Notifier{webhookURL: srv.URL}
+	n.SendUpdate("app:web", "nginx:latest", "sha256:aaaa", "sha256:bbbb", "Changelog: https://acme.example/changes")
+
+	if !strings.Contains(got.Content, "Updated app:web") || !strings.HasSuffix(got.Content, "Changelog: https://acme.example/changes") {
+		t.Errorf("content = %q, want the update followed by its notes", got.Content)
+	}
+}
+
+// TestSendHaltPings verifies the circuit breaker alert may ping @here while
+// other notifications keep mentions disabled.
+func TestSendHaltPings(t *testing.T) {
+	var got []webhookMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var m webhookMessage
+		json.NewDecoder(r.Body).Decode(&m)
+		got = append(got, m)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	n := &Notifier{webhookURL: srv.URL}
+	n.SendError("app:@everyone", "boom")
+	n.SendHalt("3 groups failed in a row")
+
+	if len(got) != 2 {
+		t.Fatalf("webhook called %d times, want 2", len(got))
+	}
+	if len(got[0].AllowedMentions.Parse) != 0 {
+		t.Errorf("error notification allows mentions %v, want none", got[0].AllowedMentions.Parse)
+	}
+	if !strings.Contains(got[1].Content, "@here") || len(got[1].AllowedMentions.Parse) != 1 || got[1].AllowedMentions.Parse[0] != "everyone" {
+		t.Errorf("halt notification = %+v, want an allowed @here ping", got[1])
+	}
+}
+
+func TestNotifierTestReportsFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	n := &Notifier{webhookURL: srv.URL}
+	if err := n.Test(); err == nil {
+		t.Fatal("Test() error = nil, want failure for status 401")
+	}
+}
+
+func TestNotifierCheck(t *testing.T) {
+	var posted bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			posted = true
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	n := &Notifier{webhookURL: srv.URL}
+	if err := n.Check(); err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if posted {
+		t.Error("Check() sent a non-GET request; it must not post a message")
+	}
+}
+
+func TestNotifierCancelledContextAbortsSend(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Hang like an unresponsive webhook until the test ends.
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	n := &Notifier{webhookURL: srv.URL}
+	n.SetContext(ctx)
+
+	done := make(chan error, 1)
+	go func() { done <- n.post("hello") }()
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("post() error = %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("post() did not return after its context was cancelled")
+	}
+}
+
+func TestNotifierWithKeySetsHeader(t *testing.T) {
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get("X-Repull-Key"))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	n := &Notifier{webhookURL: srv.URL}
+	n.WithKey("team-platform").SendError("app:web", "boom")
+	n.SendError("app:db", "boom")
+
+	if len(got) != 2 || got[0] != "team-platform" || got[1] != "" {
+		t.Errorf("X-Repull-Key headers = %q, want [team-platform \"\"] (the original stays unkeyed)", got)
+	}
+}
+
+// TestNotifierRetries verifies a post is retried on 5xx responses until
+// it is delivered or the retries run out, but never on 4xx.
+func TestNotifierRetries(t *testing.T) {
+	saved := retryDelays
+	retryDelays = []time.Duration{time.Millisecond, 2 * time.Millisecond, 4 * time.Millisecond}
+	t.Cleanup(func() { retryDelays = saved })
+
+	tests := []struct {
+		name      string
+		statuses  []int // response per attempt; the last one repeats
+		wantCalls int
+		wantErr   bool
+	}{
+		{name: "fails twice then succeeds", statuses: []int{http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusNoContent}, wantCalls: 3},
+		{name: "client error is not retried", statuses: []int{http.StatusBadRequest}, wantCalls: 1, wantErr: true},
+		{name: "gives up after three retries", statuses: []int{http.StatusInternalServerError}, wantCalls: 4, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var delivered []string
+			calls := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				status := tt.statuses[min(calls, len(tt.statuses)-1)]
+				calls++
+				if status < 300 {
+					var m webhookMessage
+					json.NewDecoder(r.Body).Decode(&m)
+					delivered = append(delivered, m.Content)
+				}
+				w.WriteHeader(status)
+			}))
+			defer srv.Close()
+
+			n := &Notifier{webhookURL: srv.URL}
+			err := n.post("hello")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("post() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("webhook called %d times, want %d", calls, tt.wantCalls)
+			}
+			if !tt.wantErr && (len(delivered) != 1 || delivered[0] != "hello") {
+				t.Errorf("delivered = %q, want the message once", delivered)
+			}
+		})
+	}
+}
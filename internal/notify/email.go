@@ -0,0 +1,251 @@
+package notify
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fanuelsen/repull/internal/sanitize"
+)
+
+// smtpTimeout bounds a whole email delivery, from connecting to QUIT, so an
+// unresponsive mail server cannot stall the update loop.
+const smtpTimeout = 30 * time.Second
+
+// EmailNotifier sends notifications by email, for environments without a
+// chat app. It is a Sender: errors go out as they happen, updates are held
+// and sent as one digest per run (see Flush).
+type EmailNotifier struct {
+	host     string
+	port     int
+	username string
+	password string
+	from     string
+	to       []string
+	ctx      context.Context
+
+	mu      sync.Mutex
+	pending []UpdateData
+}
+
+// NewEmailNotifier creates a notifier sending through the SMTP server at
+// host:port. Port 465 uses implicit TLS; any other port upgrades with
+// STARTTLS when the server offers it. With a username it authenticates
+// with PLAIN or LOGIN, whichever the server offers, and only over TLS (or
+// to localhost). Returns nil if host is empty (disables it).
+func NewEmailNotifier(host string, port int, username, password, from string, to []string) (*EmailNotifier, error) {
+	if host == "" {
+		return nil, nil
+	}
+	if port <= 0 || port > 65535 {
+		return nil, fmt.Errorf("invalid SMTP port %d", port)
+	}
+	if from == "" {
+		return nil, errors.New("a sender address is required")
+	}
+	if len(to) == 0 {
+		return nil, errors.New("at least one recipient is required")
+	}
+	for _, addr := range append([]string{from}, to...) {
+		if strings.ContainsAny(addr, "\r\n") {
+			return nil, fmt.Errorf("invalid email address %q", addr)
+		}
+	}
+	return &EmailNotifier{host: host, port: port, username: username, password: password, from: from, to: to}, nil
+}
+
+// SetContext makes every email from now on carry ctx, as
+// Notifier.SetContext does for Discord: cancelling it aborts a delivery
+// in flight.
+func (e *EmailNotifier) SetContext(ctx context.Context) {
+	if e == nil {
+		return
+	}
+	e.ctx = ctx
+}
+
+// SendUpdate holds the update for the run's digest email.
+func (e *EmailNotifier) SendUpdate(service, image, oldDigest, newDigest string) error {
+	if e == nil {
+		return nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.pending = append(e.pending, UpdateData{Service: service, Image: image, OldDigest: oldDigest, NewDigest: newDigest})
+	return nil
+}
+
+// SendError sends an email about an update failure right away.
+func (e *EmailNotifier) SendError(service, errorMsg string) error {
+	if e == nil {
+		return nil
+	}
+	return e.send("repull: update failed for "+service, fmt.Sprintf("Update failed for %s\n\nError: %s\n", service, errorMsg))
+}
+
+// Flush sends the updates held since the last flush as one digest email,
+// if there are any.
+func (e *EmailNotifier) Flush() error {
+	if e == nil {
+		return nil
+	}
+	e.mu.Lock()
+	updates := e.pending
+	e.pending = nil
+	e.mu.Unlock()
+	if len(updates) == 0 {
+		return nil
+	}
+
+	subject := "repull: " + updates[0].Service + " updated"
+	if len(updates) > 1 {
+		subject = fmt.Sprintf("repull: %d services updated", len(updates))
+	}
+	var body strings.Builder
+	for _, u := range updates {
+		fmt.Fprintf(&body, "%s\nImage: %s\n%s -> %s\n\n", u.Service, u.Image, u.OldDigest, u.NewDigest)
+	}
+	return e.send(subject, body.String())
+}
+
+// Test sends a sample email, for --test-notify.
+func (e *EmailNotifier) Test() error {
+	return e.send("repull: test notification", "This is a test notification from repull.\n")
+}
+
+// send delivers one plain-text email to every recipient.
+func (e *EmailNotifier) send(subject, body string) error {
+	ctx := e.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(ctx, smtpTimeout)
+	defer cancel()
+
+	addr := net.JoinHostPort(e.host, strconv.Itoa(e.port))
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	var conn net.Conn
+	var err error
+	if e.port == 465 {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: e.host}}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("connecting to %s: %w", addr, err)
+	}
+	// net/smtp takes no context, so the deadline and any cancellation are
+	// applied to the connection underneath it.
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	c, err := smtp.NewClient(conn, e.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: e.host}); err != nil {
+			return fmt.Errorf("STARTTLS: %w", err)
+		}
+	}
+	if e.username != "" {
+		if err := c.Auth(e.auth(c)); err != nil {
+			return fmt.Errorf("authenticating: %w", err)
+		}
+	}
+	if err := c.Mail(e.from); err != nil {
+		return err
+	}
+	for _, to := range e.to {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(e.message(subject, body, time.Now())); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// auth picks PLAIN if the server offers it, LOGIN otherwise.
+func (e *EmailNotifier) auth(c *smtp.Client) smtp.Auth {
+	_, mechs := c.Extension("AUTH")
+	if slices.Contains(strings.Fields(mechs), "LOGIN") && !slices.Contains(strings.Fields(mechs), "PLAIN") {
+		return &loginAuth{username: e.username, password: e.password, host: e.host}
+	}
+	return smtp.PlainAuth("", e.username, e.password, e.host)
+}
+
+// message formats the email. Values are sanitized line by line: a line
+// break smuggled into the subject would start new headers.
+func (e *EmailNotifier) message(subject, body string, now time.Time) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", e.from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(e.to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", sanitize.String(subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	for line := range strings.Lines(body) {
+		b.WriteString(sanitize.String(strings.TrimSuffix(line, "\n")))
+		b.WriteString("\r\n")
+	}
+	return []byte(b.String())
+}
+
+// loginAuth implements the LOGIN mechanism, which net/smtp lacks but some
+// servers (e.g. older Exchange) offer instead of PLAIN. Like PlainAuth it
+// refuses to send the password unencrypted, except to localhost.
+type loginAuth struct {
+	username, password, host string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, errors.New("unencrypted connection")
+	}
+	if server.Name != a.host {
+		return "", nil, errors.New("wrong host name")
+	}
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	switch strings.ToLower(strings.TrimSuffix(string(fromServer), ":")) {
+	case "username":
+		return []byte(a.username), nil
+	case "password":
+		return []byte(a.password), nil
+	}
+	return nil, fmt.Errorf("unexpected LOGIN challenge %q", fromServer)
+}
+
+// isLocalhost reports whether host is the loopback host, as net/smtp's
+// PlainAuth decides it.
+func isLocalhost(host string) bool {
+	return host == "localhost" || host == "127.0.0.1" || host == "::1"
+}
@@ -0,0 +1,242 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"net"
+	"net/smtp"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// smtpServer is a minimal SMTP server recording the messages it receives
+// and the credentials clients authenticate with.
+type smtpServer struct {
+	mu       sync.Mutex
+	messages []string
+	logins   []string // "mechanism user:password"
+}
+
+// startSMTP serves SMTP on a local port offering the given AUTH mechanisms
+// and returns the server and its port.
+func startSMTP(t *testing.T, mechs string) (*smtpServer, int) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	s := &smtpServer{}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn, mechs)
+		}
+	}()
+	return s, ln.Addr().(*net.TCPAddr).Port
+}
+
+// received returns the messages received so far.
+func (s *smtpServer) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.messages)
+}
+
+func (s *smtpServer) serve(conn net.Conn, mechs string) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+	readLine := func() string {
+		line, _ := r.ReadString('\n')
+		return strings.TrimRight(line, "\r\n")
+	}
+	decode := func(s string) string {
+		b, _ := base64.StdEncoding.DecodeString(s)
+		return string(b)
+	}
+
+	reply("220 localhost ESMTP")
+	for {
+		line := readLine()
+		cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch {
+		case cmd == "EHLO":
+			reply("250-localhost")
+			reply("250 AUTH " + mechs)
+		case strings.HasPrefix(line, "AUTH PLAIN "):
+			parts := strings.Split(decode(strings.TrimPrefix(line, "AUTH PLAIN ")), "\x00")
+			s.mu.Lock()
+			s.logins = append(s.logins, "PLAIN "+parts[1]+":"+parts[2])
+			s.mu.Unlock()
+			reply("235 ok")
+		case line == "AUTH LOGIN":
+			reply("334 " + base64.StdEncoding.EncodeToString([]byte("Username:")))
+			user := decode(readLine())
+			reply("334 " + base64.StdEncoding.EncodeToString([]byte("Password:")))
+			pass := decode(readLine())
+			s.mu.Lock()
+			s.logins = append(s.logins, "LOGIN "+user+":"+pass)
+			s.mu.Unlock()
+			reply("235 ok")
+		case cmd == "DATA":
+			reply("354 go ahead")
+			var msg strings.Builder
+			for l := readLine(); l != "."; l = readLine() {
+				msg.WriteString(l + "\n")
+			}
+			s.mu.Lock()
+			s.messages = append(s.messages, msg.String())
+			s.mu.Unlock()
+			reply("250 queued")
+		case cmd == "QUIT":
+			reply("221 bye")
+			return
+		case cmd == "":
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+func TestNewEmailNotifier(t *testing.T) {
+	tests := []struct {
+		name    string
+		host    string
+		port    int
+		from    string
+		to      []string
+		wantNil bool
+		wantErr bool
+	}{
+		{name: "empty host disables", host: "", wantNil: true},
+		{name: "valid", host: "smtp.example.com", port: 587, from: "repull@example.com", to: []string{"ops@example.com"}},
+		{name: "no sender", host: "smtp.example.com", port: 587, to: []string{"ops@example.com"}, wantNil: true, wantErr: true},
+		{name: "no recipients", host: "smtp.example.com", port: 587, from: "repull@example.com", wantNil: true, wantErr: true},
+		{name: "bad port", host: "smtp.example.com", port: 0, from: "repull@example.com", to: []string{"ops@example.com"}, wantNil: true, wantErr: true},
+		{name: "header injection", host: "smtp.example.com", port: 587, from: "repull@example.com\r\nBcc: x@evil.test", to: []string{"ops@example.com"}, wantNil: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := NewEmailNotifier(tt.host, tt.port, "", "", tt.from, tt.to)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewEmailNotifier() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if (e == nil) != tt.wantNil {
+				t.Errorf("NewEmailNotifier() = %v, wantNil %v", e, tt.wantNil)
+			}
+		})
+	}
+}
+
+// TestEmailDigest verifies the updates of a run go out as one email on
+// Flush, while errors are sent right away.
+func TestEmailDigest(t *testing.T) {
+	srv, port := startSMTP(t, "PLAIN LOGIN")
+	e, err := NewEmailNotifier("127.0.0.1", port, "repull", "s3cret", "repull@example.com", []string{"ops@example.com", "dev@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := (*Notifier)(nil).WithSender(e)
+
+	n.SendUpdate("app:web", "nginx:latest", "sha256:aaaa", "sha256:bbbb", "")
+	n.SendUpdate("app:api", "api:latest", "sha256:cccc", "sha256:dddd", "")
+	n.SendError("app:db", "pull failed")
+	if got := srv.received(); len(got) != 1 || !strings.Contains(got[0], "Subject: repull: update failed for app:db") {
+		t.Fatalf("messages before the end of the run = %q, want the error only", got)
+	}
+
+	n.EndRun()
+	got := srv.received()
+	if len(got) != 2 {
+		t.Fatalf("got %d message(s), want the error and one digest", len(got))
+	}
+	digest := got[1]
+	for _, want := range []string{"Subject: repull: 2 services updated", "To: ops@example.com, dev@example.com", "app:web", "sha256:aaaa -> sha256:bbbb", "api:latest"} {
+		if !strings.Contains(digest, want) {
+			t.Errorf("digest lacks %q:\n%s", want, digest)
+		}
+	}
+	n.SendError("app:api\r\nBcc: x@evil.test", "pull failed")
+	if header, _, _ := strings.Cut(srv.received()[2], "\n\n"); strings.Contains(header, "\nBcc:") {
+		t.Errorf("error email has an injected header:\n%s", header)
+	}
+	if len(srv.logins) != 3 || srv.logins[0] != "PLAIN repull:s3cret" {
+		t.Errorf("logins = %q, want PLAIN repull:s3cret per email", srv.logins)
+	}
+
+	n.EndRun()
+	if got := srv.received(); len(got) != 3 {
+		t.Errorf("a run without updates sent %d more message(s)", len(got)-3)
+	}
+}
+
+func TestEmailLoginAuth(t *testing.T) {
+	srv, port := startSMTP(t, "LOGIN")
+	e, err := NewEmailNotifier("127.0.0.1", port, "repull", "s3cret", "repull@example.com", []string{"ops@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Test(); err != nil {
+		t.Fatalf("Test() error = %v", err)
+	}
+	if len(srv.logins) != 1 || srv.logins[0] != "LOGIN repull:s3cret" {
+		t.Errorf("logins = %q, want LOGIN repull:s3cret", srv.logins)
+	}
+}
+
+// TestLoginAuthRefusesPlaintext verifies LOGIN, like PLAIN, never sends
+// credentials to a remote server without TLS.
+func TestLoginAuthRefusesPlaintext(t *testing.T) {
+	a := &loginAuth{username: "repull", password: "s3cret", host: "mail.example.com"}
+	if _, _, err := a.Start(&smtp.ServerInfo{Name: "mail.example.com"}); err == nil {
+		t.Error("Start() without TLS succeeded, want an error")
+	}
+	if _, _, err := a.Start(&smtp.ServerInfo{Name: "mail.example.com", TLS: true}); err != nil {
+		t.Errorf("Start() over TLS error = %v", err)
+	}
+}
+
+// TestEmailContext verifies cancelling the context set with SetContext
+// aborts a delivery to a server that never answers.
+func TestEmailContext(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			// Never send the greeting.
+			t.Cleanup(func() { conn.Close() })
+		}
+	}()
+
+	e, err := NewEmailNotifier("127.0.0.1", ln.Addr().(*net.TCPAddr).Port, "", "", "repull@example.com", []string{"ops@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+	e.SetContext(ctx)
+
+	start := time.Now()
+	if err := e.SendError("app:web", "pull failed"); err == nil {
+		t.Error("SendError() succeeded against a silent server")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("SendError() took %v, want it aborted with the context", elapsed)
+	}
+}
@@ -0,0 +1,187 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/fanuelsen/repull/internal/sanitize"
+)
+
+// FileNotifier appends notifications as JSON lines to a local file, for log
+// shippers such as promtail or fluentd to pick up. It has the same Send
+// methods as Notifier and is usually attached to one with WithFile.
+type FileNotifier struct {
+	// mu is shared with the copies withKey makes, which write the same file.
+	mu   *sync.Mutex
+	path string
+	key  string
+	// severities filters what is written (see SetSeverities).
+	severities Severities
+}
+
+// FileEvent is one line of a FileNotifier's file.
+type FileEvent struct {
+	Time      time.Time `json:"time"`
+	Event     string    `json:"event"` // update, available, pulled, canary, stale-base, error, halt, self-update-start, self-update or self-update-failed
+	Service   string    `json:"service"`
+	Key       string    `json:"key,omitempty"`
+	Container string    `json:"container,omitempty"`
+	Image     string    `json:"image,omitempty"`
+	BaseImage string    `json:"base_image,omitempty"`
+	OldDigest string    `json:"old_digest,omitempty"`
+	NewDigest string    `json:"new_digest,omitempty"`
+	Notes     string    `json:"notes,omitempty"`
+	// Dependents are the containers recreated along with the service
+	// (update events only).
+	Dependents []string `json:"dependents,omitempty"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// NewFileNotifier creates a notifier appending to path, creating the file if
+// needed. Returns nil if path is empty (disables it), and an error if the
+// file cannot be opened for appending.
+func NewFileNotifier(path string) (*FileNotifier, error) {
+	if path == "" {
+		return nil, nil
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+	return &FileNotifier{mu: &sync.Mutex{}, path: path}, nil
+}
+
+// withKey returns a copy of f that records key with every event (see
+// Notifier.WithKey).
+func (f *FileNotifier) withKey(key string) *FileNotifier {
+	if f == nil {
+		return nil
+	}
+	c := *f
+	c.key = key
+	return &c
+}
+
+// SetSeverities restricts the file to events of the given severities; a
+// nil set (the default) writes everything. Copies made by withKey later
+// inherit the filter.
+func (f *FileNotifier) SetSeverities(set Severities) {
+	if f == nil {
+		return
+	}
+	f.severities = set
+}
+
+// SendUpdate records a successful container update.
+func (f *FileNotifier) SendUpdate(service, image, oldDigest, newDigest, notes string, dependents ...string) {
+	f.write(FileEvent{Event: "update", Service: service, Image: image, OldDigest: oldDigest, NewDigest: newDigest, Notes: notes, Dependents: dependents})
+}
+
+// SendAvailable records an update --maintenance-mode did not apply.
+func (f *FileNotifier) SendAvailable(service, image, oldDigest, newDigest string) {
+	f.write(FileEvent{Event: "available", Service: service, Image: image, OldDigest: oldDigest, NewDigest: newDigest})
+}
+
+// SendPulled records that --pull-only pulled a new image.
+func (f *FileNotifier) SendPulled(service, image, oldDigest, newDigest string) {
+	f.write(FileEvent{Event: "pulled", Service: service, Image: image, OldDigest: oldDigest, NewDigest: newDigest})
+}
+
+// SendCanary records a canary deployment awaiting promotion.
+func (f *FileNotifier) SendCanary(service, container, image, oldDigest, newDigest string) {
+	f.write(FileEvent{Event: "canary", Service: service, Container: container, Image: image, OldDigest: oldDigest, NewDigest: newDigest})
+}
+
+// SendStaleBase records that an image was built on an outdated base image.
+func (f *FileNotifier) SendStaleBase(service, image, base, oldDigest, newDigest string) {
+	f.write(FileEvent{Event: "stale-base", Service: service, Image: image, BaseImage: base, OldDigest: oldDigest, NewDigest: newDigest})
+}
+
+// SendError records an update failure.
+func (f *FileNotifier) SendError(service, errorMsg string) {
+	f.write(FileEvent{Event: "error", Service: service, Error: errorMsg})
+}
+
+// SendHalt records that the circuit breaker halted a run.
+func (f *FileNotifier) SendHalt(reason string) {
+	f.write(FileEvent{Event: "halt", Service: "repull", Error: reason})
+}
+
+// SendSelfUpdate records a stage of repull replacing its own container, as
+// a self-update-start, self-update or self-update-failed event.
+func (f *FileNotifier) SendSelfUpdate(stage SelfUpdateStage, service, image, oldDigest, newDigest, detail string) {
+	e := FileEvent{Event: "self-update", Service: service, Image: image, OldDigest: oldDigest, NewDigest: newDigest}
+	switch stage {
+	case SelfUpdateStarting:
+		e.Event = "self-update-start"
+	case SelfUpdateDone:
+		e.Notes = detail
+	case SelfUpdateFailed:
+		e.Event = "self-update-failed"
+		e.Error = detail
+	}
+	f.write(e)
+}
+
+// Test appends a sample update and a sample error event. Unlike the Send
+// methods it returns the first failure instead of logging it.
+func (f *FileNotifier) Test() error {
+	if err := f.append(FileEvent{Event: "update", Service: "repull:test", Image: "example/image:latest", OldDigest: "sha256:0000000000", NewDigest: "sha256:1111111111"}); err != nil {
+		return fmt.Errorf("sample update: %w", err)
+	}
+	if err := f.append(FileEvent{Event: "error", Service: "repull:test", Error: "this is a test notification"}); err != nil {
+		return fmt.Errorf("sample error: %w", err)
+	}
+	return nil
+}
+
+// write appends e, logging any failure: like a broken webhook, an
+// unwritable file should never affect the update cycle itself.
+func (f *FileNotifier) write(e FileEvent) {
+	if f == nil || !f.severities.allows(eventSeverities(e.Event)...) {
+		return
+	}
+	if err := f.append(e); err != nil {
+		log.Printf("[WARN] File notification failed: %v", err)
+	}
+}
+
+// append writes e as a single line. The file is reopened for every event so
+// a shipper that rotates it by renaming is picked up, and each line goes out
+// in one O_APPEND write so lines never interleave. Strings are sanitized
+// here at the sink, as in Notifier.post.
+func (f *FileNotifier) append(e FileEvent) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	if e.Key == "" {
+		e.Key = f.key
+	}
+	e.Service = sanitize.String(e.Service)
+	e.Container = sanitize.String(e.Container)
+	e.Image = sanitize.String(e.Image)
+	e.BaseImage = sanitize.String(e.BaseImage)
+	e.Error = sanitize.String(e.Error)
+	e.Key = sanitize.String(e.Key)
+
+	// Marshalling a struct of strings and a time cannot fail.
+	line, _ := json.Marshal(e)
+	line = append(line, '\n')
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(line); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
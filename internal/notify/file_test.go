@@ -0,0 +1,113 @@
+package notify
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// readEvents parses every line of a FileNotifier's file.
+func readEvents(t *testing.T, path string) []FileEvent {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var events []FileEvent
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var e FileEvent
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("line %q is not valid JSON: %v", scanner.Text(), err)
+		}
+		events = append(events, e)
+	}
+	return events
+}
+
+func TestFileNotifierAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	if err := os.WriteFile(path, []byte(`{"event":"existing"}`+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := NewFileNotifier(path)
+	if err != nil {
+		t.Fatalf("NewFileNotifier() error = %v", err)
+	}
+	f.SendUpdate("app:web", "nginx:latest", "sha256:aaaa", "sha256:bbbb", "")
+	f.SendError("app:db", "pull failed\x1b[31m")
+
+	events := readEvents(t, path)
+	if len(events) != 3 {
+		t.Fatalf("got %d lines, want 3 (existing content kept): %+v", len(events), events)
+	}
+	update := events[1]
+	if update.Event != "update" || update.Service != "app:web" || update.Image != "nginx:latest" ||
+		update.OldDigest != "sha256:aaaa" || update.NewDigest != "sha256:bbbb" || update.Time.IsZero() {
+		t.Errorf("update event = %+v", update)
+	}
+	errEvent := events[2]
+	if errEvent.Event != "error" || errEvent.Service != "app:db" || errEvent.Error == "" {
+		t.Errorf("error event = %+v", errEvent)
+	}
+	if errEvent.Error == "pull failed\x1b[31m" {
+		t.Error("control characters were not sanitized")
+	}
+}
+
+func TestFileNotifierConcurrentWrites(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	f, err := NewFileNotifier(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const writers, perWriter = 8, 50
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perWriter; j++ {
+				f.SendUpdate("app:web", "nginx:latest", "sha256:aaaa", "sha256:bbbb", "")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := len(readEvents(t, path)); n != writers*perWriter {
+		t.Errorf("got %d lines, want %d", n, writers*perWriter)
+	}
+}
+
+func TestNilFileNotifier(t *testing.T) {
+	f, err := NewFileNotifier("")
+	if f != nil || err != nil {
+		t.Fatalf("NewFileNotifier(\"\") = %v, %v; want nil, nil", f, err)
+	}
+	f.SendUpdate("app:web", "nginx:latest", "a", "b", "")
+	f.SendError("app:web", "boom")
+}
+
+func TestNotifierWithFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	f, _ := NewFileNotifier(path)
+
+	// Without Discord, WithFile still yields a notifier that reaches the file.
+	var n *Notifier
+	n = n.WithFile(f)
+	n.SendUpdate("app:web", "nginx:latest", "sha256:aaaa", "sha256:bbbb", "")
+	n.SendPulled("app:api", "api:latest", "sha256:cccc", "sha256:dddd")
+	n.SendError("app:db", "boom")
+
+	events := readEvents(t, path)
+	if len(events) != 3 || events[0].Event != "update" || events[1].Event != "pulled" || events[2].Event != "error" {
+		t.Errorf("events = %+v, want update, pulled, error", events)
+	}
+}
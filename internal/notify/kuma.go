@@ -0,0 +1,103 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/fanuelsen/repull/internal/sanitize"
+)
+
+// Kuma reports run health to an Uptime Kuma push monitor.
+type Kuma struct {
+	pushURL *url.URL
+	ctx     context.Context
+}
+
+// NewKuma creates an Uptime Kuma push reporter for a push URL such as
+// https://kuma.example.com/api/push/<token>. Returns nil if pushURL is empty
+// (disables it). Any status/msg/ping query parameters in the URL are
+// replaced on each push.
+func NewKuma(pushURL string) (*Kuma, error) {
+	if pushURL == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(pushURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" || !strings.Contains(u.Path, "/api/push/") {
+		return nil, fmt.Errorf("invalid Uptime Kuma push URL: must look like https://<host>/api/push/<token>")
+	}
+	return &Kuma{pushURL: u}, nil
+}
+
+// SetContext makes every push from now on carry ctx, as
+// Notifier.SetContext does for Discord.
+func (k *Kuma) SetContext(ctx context.Context) {
+	if k == nil {
+		return
+	}
+	k.ctx = ctx
+}
+
+// Push reports the outcome of a run: status=up when ok, status=down
+// otherwise, with msg as a short summary. Failures, including non-2xx
+// responses, are logged, not returned: a monitoring outage should never
+// affect the update cycle itself.
+func (k *Kuma) Push(ok bool, msg string) {
+	if k == nil {
+		return
+	}
+	if err := k.push(ok, msg); err != nil {
+		log.Printf("[WARN] Uptime Kuma push failed: %v", err)
+	}
+}
+
+// Test pushes a sample status=up report, for --test-notify.
+func (k *Kuma) Test() error {
+	return k.push(true, "repull test notification")
+}
+
+// push makes a single push. The error never includes the push URL's
+// token.
+func (k *Kuma) push(ok bool, msg string) error {
+	const maxLen = 200
+	msg = sanitize.String(msg)
+	if runes := []rune(msg); len(runes) > maxLen {
+		msg = string(runes[:maxLen]) + "..."
+	}
+
+	status := "down"
+	if ok {
+		status = "up"
+	}
+
+	u := *k.pushURL
+	q := u.Query()
+	q.Set("status", status)
+	q.Set("msg", msg)
+	q.Set("ping", "")
+	u.RawQuery = q.Encode()
+
+	ctx := k.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		// The error text includes the URL, and with it the push token.
+		return errors.New(strings.ReplaceAll(err.Error(), u.String(), u.Host))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package notify
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestNewKuma(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		wantNil bool
+		wantErr bool
+	}{
+		{name: "empty disables", url: "", wantNil: true},
+		{name: "push URL", url: "https://kuma.example.com/api/push/abc123"},
+		{name: "push URL with query", url: "https://kuma.example.com/api/push/abc123?status=up&msg=OK&ping="},
+		{name: "plain http on a LAN", url: "http://10.0.0.5:3001/api/push/abc123"},
+		{name: "not a push URL", url: "https://kuma.example.com/dashboard", wantNil: true, wantErr: true},
+		{name: "no scheme", url: "kuma.example.com/api/push/abc123", wantNil: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k, err := NewKuma(tt.url)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewKuma(%q) error = %v, wantErr %v", tt.url, err, tt.wantErr)
+			}
+			if (k == nil) != tt.wantNil {
+				t.Errorf("NewKuma(%q) = %v, wantNil %v", tt.url, k, tt.wantNil)
+			}
+		})
+	}
+}
+
+func TestKumaPush(t *testing.T) {
+	var status, msg string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status = r.URL.Query().Get("status")
+		msg = r.URL.Query().Get("msg")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	k, err := NewKuma(srv.URL + "/api/push/abc123?status=up&msg=OK")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	k.Push(true, "checked 3 group(s)")
+	if status != "up" || msg != "checked 3 group(s)" {
+		t.Errorf("pushed status=%q msg=%q, want up and the summary", status, msg)
+	}
+
+	k.Push(false, "1 group(s) failed")
+	if status != "down" || msg != "1 group(s) failed" {
+		t.Errorf("pushed status=%q msg=%q, want down and the summary", status, msg)
+	}
+}
+
+// TestKumaPushTruncatesRunes verifies a long summary is cut on a rune
+// boundary, so a multi-byte character is never split into invalid UTF-8.
+func TestKumaPushTruncatesRunes(t *testing.T) {
+	var msg string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		msg = r.URL.Query().Get("msg")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	k, err := NewKuma(srv.URL + "/api/push/abc123")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	k.Push(true, "a"+strings.Repeat("é", 300))
+	if want := "a" + strings.Repeat("é", 199) + "..."; msg != want || !utf8.ValidString(msg) {
+		t.Errorf("pushed msg=%q, want %q", msg, want)
+	}
+}
+
+// TestKumaTest verifies Test returns a failed push, unlike Push, without
+// the push token in the error.
+func TestKumaTest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	k, err := NewKuma(srv.URL + "/api/push/secret-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := k.Test(); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("Test() error = %v, want status 503", err)
+	}
+
+	srv.Close()
+	if err := k.Test(); err == nil || strings.Contains(err.Error(), "secret-token") {
+		t.Errorf("Test() error = %v, want a connection error without the token", err)
+	}
+}
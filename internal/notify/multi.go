@@ -0,0 +1,134 @@
+package notify
+
+import (
+	"context"
+	"errors"
+)
+
+// Sender is a notification backend beyond the Discord webhook and the file,
+// e.g. Slack or email, attached to a Notifier with WithSender. Unlike the
+// Notifier, which logs failures, a Sender returns them. The Notifier maps
+// its other notifications onto these two: canaries and finished
+// self-updates are updates; a halted run, a failed self-update and a
+// changed base image are errors. Implementations
+// with a nil-pointer receiver should ignore everything, like Notifier does.
+type Sender interface {
+	SendUpdate(service, image, oldDigest, newDigest string) error
+	SendError(service, errorMsg string) error
+}
+
+// flusher is implemented by senders that hold notifications back, such as
+// EmailNotifier batching a run's updates into one digest.
+type flusher interface {
+	Flush() error
+}
+
+// contextSetter is implemented by senders whose requests can carry a
+// context, such as NtfyNotifier, so cancelling it aborts them (see
+// Notifier.SetContext).
+type contextSetter interface {
+	SetContext(ctx context.Context)
+}
+
+// MultiNotifier is a Sender that fans out to several senders. Every sender
+// is called, whatever the others return; the errors are joined.
+type MultiNotifier []Sender
+
+// SendUpdate sends the update to every sender that takes updates.
+func (m MultiNotifier) SendUpdate(service, image, oldDigest, newDigest string) error {
+	return m.sendUpdate([]Severity{SeverityUpdate}, service, image, oldDigest, newDigest)
+}
+
+// SendError sends the error to every sender that takes errors.
+func (m MultiNotifier) SendError(service, errorMsg string) error {
+	return m.sendError([]Severity{SeverityError}, service, errorMsg)
+}
+
+// sendUpdate sends an update of severities ss to every sender that takes
+// one of them.
+func (m MultiNotifier) sendUpdate(ss []Severity, service, image, oldDigest, newDigest string) error {
+	return m.each(ss, func(s Sender) error { return s.SendUpdate(service, image, oldDigest, newDigest) })
+}
+
+// sendError sends an error of severities ss to every sender that takes one
+// of them.
+func (m MultiNotifier) sendError(ss []Severity, service, errorMsg string) error {
+	return m.each(ss, func(s Sender) error { return s.SendError(service, errorMsg) })
+}
+
+// each calls send for every sender that takes one of ss, unwrapping those
+// bound to a severity set with Filter. Every sender is called, whatever
+// the others return; the errors are joined.
+func (m MultiNotifier) each(ss []Severity, send func(Sender) error) error {
+	var errs []error
+	for _, s := range m {
+		if f, ok := s.(filtered); ok {
+			if !f.severities.allows(ss...) {
+				continue
+			}
+			s = f.Sender
+		}
+		if s == nil {
+			continue
+		}
+		if err := send(s); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Flush makes every sender that holds notifications back send them.
+func (m MultiNotifier) Flush() error {
+	var errs []error
+	for _, s := range m {
+		if f, ok := s.(flusher); ok {
+			if err := f.Flush(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// SetContext passes ctx to every sender that takes one.
+func (m MultiNotifier) SetContext(ctx context.Context) {
+	for _, s := range m {
+		if c, ok := s.(contextSetter); ok {
+			c.SetContext(ctx)
+		}
+	}
+}
+
+// filtered is a Sender bound to a severity set (see Filter).
+type filtered struct {
+	Sender
+	severities Severities
+}
+
+// Filter binds s to the severities in set, e.g. for --email-severity:
+// attached to a Notifier or in a MultiNotifier, it only receives
+// notifications of those severities, judged by what they are rather than
+// how the Sender hears of them (a stale base image is an update, though it
+// reaches senders as an error). A nil set returns s unchanged.
+func Filter(s Sender, set Severities) Sender {
+	if set == nil {
+		return s
+	}
+	return filtered{Sender: s, severities: set}
+}
+
+// Flush passes on to the wrapped sender, if it holds notifications back.
+func (f filtered) Flush() error {
+	if fl, ok := f.Sender.(flusher); ok {
+		return fl.Flush()
+	}
+	return nil
+}
+
+// SetContext passes on to the wrapped sender, if it takes a context.
+func (f filtered) SetContext(ctx context.Context) {
+	if c, ok := f.Sender.(contextSetter); ok {
+		c.SetContext(ctx)
+	}
+}
@@ -0,0 +1,113 @@
+package notify
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// recordingSender records what it is sent and fails with err, if set.
+type recordingSender struct {
+	sent []string
+	err  error
+}
+
+func (r *recordingSender) SendUpdate(service, image, oldDigest, newDigest string) error {
+	r.sent = append(r.sent, "update "+service)
+	return r.err
+}
+
+func (r *recordingSender) SendError(service, errorMsg string) error {
+	r.sent = append(r.sent, "error "+service)
+	return r.err
+}
+
+// TestMultiNotifierFailingBackend verifies a failing sender neither stops
+// the others nor hides its error.
+func TestMultiNotifierFailingBackend(t *testing.T) {
+	errDown := errors.New("smtp: connection refused")
+	first, broken, last := &recordingSender{}, &recordingSender{err: errDown}, &recordingSender{}
+	m := MultiNotifier{first, broken, nil, last}
+
+	if err := m.SendUpdate("app:web", "nginx:latest", "sha256:aaaa", "sha256:bbbb"); !errors.Is(err, errDown) {
+		t.Errorf("SendUpdate() error = %v, want %v", err, errDown)
+	}
+	if err := m.SendError("app:db", "pull failed"); !errors.Is(err, errDown) {
+		t.Errorf("SendError() error = %v, want %v", err, errDown)
+	}
+	for _, s := range []*recordingSender{first, broken, last} {
+		if len(s.sent) != 2 || s.sent[0] != "update app:web" || s.sent[1] != "error app:db" {
+			t.Errorf("sender got %q, want the update and the error", s.sent)
+		}
+	}
+
+	if err := (MultiNotifier{first, last}).SendUpdate("app:web", "nginx:latest", "sha256:aaaa", "sha256:bbbb"); err != nil {
+		t.Errorf("SendUpdate() without failures error = %v, want nil", err)
+	}
+}
+
+// TestNotifierWithSender verifies a notifier forwards updates and errors to
+// attached senders, including one created for senders alone.
+func TestNotifierWithSender(t *testing.T) {
+	s := &recordingSender{err: errors.New("down")}
+	var n *Notifier
+	n = n.WithSender(s)
+	n.SendUpdate("app:web", "nginx:latest", "sha256:aaaa", "sha256:bbbb", "")
+	n.SendError("app:db", "pull failed")
+
+	if len(s.sent) != 2 || s.sent[0] != "update app:web" || s.sent[1] != "error app:db" {
+		t.Errorf("sender got %q, want the update and the error", s.sent)
+	}
+
+	var none *Notifier
+	if none.WithSender(nil) != nil {
+		t.Error("WithSender(nil) on a nil notifier returned a notifier")
+	}
+}
+
+// TestNotifierForwardsToSenders verifies notifications beyond plain updates
+// and errors reach senders too, so a setup without Discord still hears of a
+// halted run or a failed self-update.
+func TestNotifierForwardsToSenders(t *testing.T) {
+	s := &recordingSender{}
+	n := (*Notifier)(nil).WithSender(s)
+
+	n.SendHalt("3 groups failed in a row")
+	n.SendSelfUpdate(SelfUpdateStarting, "repull:repull", "repull:latest", "sha256:aaaa", "sha256:bbbb", "")
+	n.SendSelfUpdate(SelfUpdateFailed, "repull:repull", "repull:latest", "sha256:aaaa", "sha256:bbbb", "could not start new container")
+	n.SendSelfUpdate(SelfUpdateDone, "repull:repull", "repull:latest", "sha256:aaaa", "sha256:bbbb", "")
+	n.SendCanary("app:web", "web-1", "nginx:latest", "sha256:aaaa", "sha256:bbbb")
+	n.SendStaleBase("app:api", "api:latest", "debian:12", "sha256:aaaa", "sha256:bbbb")
+
+	want := []string{"error repull", "error repull:repull", "update repull:repull", "update app:web (canary web-1)", "error app:api"}
+	if !reflect.DeepEqual(s.sent, want) {
+		t.Errorf("sender got %q, want %q", s.sent, want)
+	}
+}
+
+// TestFilteredSenders verifies senders bound to a severity set with Filter
+// only receive those severities: an update-only backend gets no errors and
+// an error-only one no updates, judged by what a notification is rather
+// than how it reaches senders.
+func TestFilteredSenders(t *testing.T) {
+	updates, errs, all := &recordingSender{}, &recordingSender{}, &recordingSender{}
+	n := (*Notifier)(nil).
+		WithSender(Filter(updates, Severities{SeverityUpdate: true})).
+		WithSender(Filter(errs, Severities{SeverityError: true})).
+		WithSender(Filter(all, nil))
+
+	n.SendUpdate("app:web", "nginx:latest", "sha256:aaaa", "sha256:bbbb", "")
+	n.SendError("app:db", "pull failed")
+	n.SendStaleBase("app:api", "api:latest", "alpine:3", "sha256:aaaa", "sha256:bbbb")
+	n.SendSelfUpdate(SelfUpdateFailed, "repull", "repull:latest", "sha256:aaaa", "sha256:bbbb", "boom")
+
+	if want := []string{"update app:web", "error app:api"}; !reflect.DeepEqual(updates.sent, want) {
+		t.Errorf("update-only sender got %q, want %q", updates.sent, want)
+	}
+	if want := []string{"error app:db", "error repull"}; !reflect.DeepEqual(errs.sent, want) {
+		t.Errorf("error-only sender got %q, want %q", errs.sent, want)
+	}
+	if len(all.sent) != 4 {
+		t.Errorf("unfiltered sender got %q, want all four", all.sent)
+	}
+}
@@ -0,0 +1,124 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/fanuelsen/repull/internal/sanitize"
+)
+
+// DefaultNtfyURL is the public ntfy server, used when no server is given.
+const DefaultNtfyURL = "https://ntfy.sh"
+
+// NtfyNotifier sends push notifications to an ntfy topic. It is a Sender:
+// every update and error is published as it happens, errors with a higher
+// priority so they stand out on the phone.
+type NtfyNotifier struct {
+	topicURL string
+	token    string
+	ctx      context.Context
+}
+
+// NewNtfyNotifier creates a notifier publishing to topic on the ntfy server
+// at serverURL (DefaultNtfyURL if empty). token, if set, is sent as a Bearer
+// token for access-protected topics. Returns nil if topic is empty
+// (disables it).
+func NewNtfyNotifier(serverURL, topic, token string) (*NtfyNotifier, error) {
+	if topic == "" {
+		return nil, nil
+	}
+	if serverURL == "" {
+		serverURL = DefaultNtfyURL
+	}
+	u, err := url.Parse(serverURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, fmt.Errorf("invalid ntfy server URL %q: must be http(s)://<host>", serverURL)
+	}
+	if strings.ContainsAny(topic, "/?#") || strings.TrimSpace(topic) != topic {
+		return nil, fmt.Errorf("invalid ntfy topic %q", topic)
+	}
+	return &NtfyNotifier{topicURL: strings.TrimSuffix(u.String(), "/") + "/" + url.PathEscape(topic), token: token}, nil
+}
+
+// SetContext makes every publish from now on carry ctx, as
+// Notifier.SetContext does for Discord.
+func (n *NtfyNotifier) SetContext(ctx context.Context) {
+	if n == nil {
+		return
+	}
+	n.ctx = ctx
+}
+
+// SendUpdate publishes an update notification at default priority.
+func (n *NtfyNotifier) SendUpdate(service, image, oldDigest, newDigest string) error {
+	if n == nil {
+		return nil
+	}
+	return n.publish("Updated "+service, "default", "white_check_mark",
+		fmt.Sprintf("Image: %s\n%s -> %s", image, oldDigest, newDigest))
+}
+
+// SendError publishes an update failure at high priority.
+func (n *NtfyNotifier) SendError(service, errorMsg string) error {
+	if n == nil {
+		return nil
+	}
+	return n.publish("Update failed for "+service, "high", "warning", "Error: "+errorMsg)
+}
+
+// Test publishes a sample notification, for --test-notify.
+func (n *NtfyNotifier) Test() error {
+	return n.publish("repull test notification", "default", "", "This is a test notification from repull.")
+}
+
+// publish POSTs message to the topic, retrying connection errors and 5xx
+// responses like a Discord post. Header values are sanitized: a line break
+// in a service name would otherwise start new headers.
+func (n *NtfyNotifier) publish(title, priority, tags, message string) error {
+	var body strings.Builder
+	for line := range strings.Lines(message) {
+		body.WriteString(sanitize.String(strings.TrimSuffix(line, "\n")))
+		body.WriteString("\n")
+	}
+	data := strings.TrimSuffix(body.String(), "\n")
+
+	ctx := n.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return withRetries(ctx, func(ctx context.Context) (bool, error) {
+		return n.publishOnce(ctx, title, priority, tags, data)
+	})
+}
+
+// publishOnce makes a single POST of body to the topic. retry reports
+// whether a failure is worth retrying: a connection error or a 5xx.
+func (n *NtfyNotifier) publishOnce(ctx context.Context, title, priority, tags, body string) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.topicURL, strings.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	req.Header.Set("Title", sanitize.String(title))
+	req.Header.Set("Priority", priority)
+	if tags != "" {
+		req.Header.Set("Tags", tags)
+	}
+	if n.token != "" {
+		req.Header.Set("Authorization", "Bearer "+n.token)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode >= 500, fmt.Errorf("ntfy returned status %d", resp.StatusCode)
+	}
+	return false, nil
+}
@@ -0,0 +1,165 @@
+package notify
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewNtfyNotifier(t *testing.T) {
+	tests := []struct {
+		name    string
+		server  string
+		topic   string
+		wantURL string
+		wantNil bool
+		wantErr bool
+	}{
+		{name: "empty topic disables", server: DefaultNtfyURL, topic: "", wantNil: true},
+		{name: "default server", server: "", topic: "repull", wantURL: "https://ntfy.sh/repull"},
+		{name: "self-hosted with trailing slash", server: "https://ntfy.example.com/", topic: "ops", wantURL: "https://ntfy.example.com/ops"},
+		{name: "bad scheme", server: "ftp://ntfy.example.com", topic: "ops", wantNil: true, wantErr: true},
+		{name: "topic with slash", server: DefaultNtfyURL, topic: "ops/../x", wantNil: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := NewNtfyNotifier(tt.server, tt.topic, "")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewNtfyNotifier() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if (n == nil) != tt.wantNil {
+				t.Fatalf("NewNtfyNotifier() = %v, wantNil %v", n, tt.wantNil)
+			}
+			if n != nil && n.topicURL != tt.wantURL {
+				t.Errorf("topic URL = %q, want %q", n.topicURL, tt.wantURL)
+			}
+		})
+	}
+}
+
+// TestNtfyPublish verifies updates and errors are posted to the topic with
+// a title, errors at a higher priority, and the token as a Bearer header.
+func TestNtfyPublish(t *testing.T) {
+	type post struct {
+		path, title, priority, auth, body string
+	}
+	var posts []post
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		posts = append(posts, post{r.URL.Path, r.Header.Get("Title"), r.Header.Get("Priority"), r.Header.Get("Authorization"), string(body)})
+	}))
+	defer srv.Close()
+
+	n, err := NewNtfyNotifier(srv.URL, "repull", "tk_secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	notifier := (*Notifier)(nil).WithSender(n)
+	notifier.SendUpdate("app:web", "nginx:latest", "sha256:aaaa", "sha256:bbbb", "")
+	notifier.SendError("app:db\r\nX-Injected: 1", "pull failed")
+
+	if len(posts) != 2 {
+		t.Fatalf("got %d post(s), want 2", len(posts))
+	}
+	update, failure := posts[0], posts[1]
+	if update.path != "/repull" || update.auth != "Bearer tk_secret" {
+		t.Errorf("update posted to %q with Authorization %q", update.path, update.auth)
+	}
+	if update.title != "Updated app:web" || update.priority != "default" || !strings.Contains(update.body, "sha256:aaaa -> sha256:bbbb") {
+		t.Errorf("update = %+v", update)
+	}
+	if !strings.HasPrefix(failure.title, "Update failed for app:db") || strings.ContainsAny(failure.title, "\r\n") || failure.priority != "high" {
+		t.Errorf("error = %+v, want a sanitized title at high priority", failure)
+	}
+	if failure.body != "Error: pull failed" {
+		t.Errorf("error body = %q", failure.body)
+	}
+}
+
+func TestNtfyStatusError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	n, err := NewNtfyNotifier(srv.URL, "repull", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Test(); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("Test() error = %v, want status 403", err)
+	}
+}
+
+// TestNtfyRetries verifies a publish is retried on a 5xx like a Discord
+// post, but not on a 4xx.
+func TestNtfyRetries(t *testing.T) {
+	saved := retryDelays
+	retryDelays = []time.Duration{time.Millisecond, 2 * time.Millisecond, 4 * time.Millisecond}
+	t.Cleanup(func() { retryDelays = saved })
+
+	tests := []struct {
+		name      string
+		statuses  []int // response per attempt; the last one repeats
+		wantCalls int
+		wantErr   bool
+	}{
+		{name: "fails twice then succeeds", statuses: []int{http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusOK}, wantCalls: 3},
+		{name: "client error is not retried", statuses: []int{http.StatusForbidden}, wantCalls: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.statuses[min(calls, len(tt.statuses)-1)])
+				calls++
+			}))
+			defer srv.Close()
+
+			n, err := NewNtfyNotifier(srv.URL, "repull", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := n.SendError("app:web", "pull failed"); (err != nil) != tt.wantErr {
+				t.Errorf("SendError() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("posted %d time(s), want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+// TestNtfyContext verifies the context set on the Notifier reaches the
+// ntfy sender, so cancelling it aborts a publish to a hung server.
+func TestNtfyContext(t *testing.T) {
+	hung := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-hung:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(hung)
+
+	n, err := NewNtfyNotifier(srv.URL, "repull", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+	(*Notifier)(nil).WithSender(n).SetContext(ctx)
+
+	start := time.Now()
+	if err := n.SendError("app:web", "pull failed"); err == nil {
+		t.Error("SendError() succeeded against a hung server")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("SendError() took %v, want it aborted with the context", elapsed)
+	}
+}
@@ -0,0 +1,22 @@
+package notify
+
+// Reporter is what an update cycle reports to: the updater takes one
+// rather than a *Notifier, so other backends and test fakes can stand in.
+// *Notifier implements it, and a nil *Notifier reports nothing.
+type Reporter interface {
+	SendUpdate(service, image, oldDigest, newDigest, notes string, dependents ...string)
+	SendAvailable(service, image, oldDigest, newDigest string)
+	SendPulled(service, image, oldDigest, newDigest string)
+	SendCanary(service, container, image, oldDigest, newDigest string)
+	SendStaleBase(service, image, base, oldDigest, newDigest string)
+	SendError(service, errorMsg string)
+	SendSelfUpdate(stage SelfUpdateStage, service, image, oldDigest, newDigest, detail string)
+	SendHalt(reason string)
+	// WithKey returns a Reporter that tags every notification with a
+	// routing key (see Notifier.WithKey).
+	WithKey(key string) Reporter
+	// EndRun marks the end of an update run, Flush the end of the
+	// process (see Notifier.EndRun and Notifier.Flush).
+	EndRun()
+	Flush()
+}
@@ -0,0 +1,74 @@
+package notify
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Severity classifies a notification so each backend can be bound to the
+// kinds it should receive, e.g. updates to a quiet channel and errors to
+// one people watch.
+type Severity string
+
+const (
+	// SeverityUpdate covers routine news: updates, available updates,
+	// pulls, canaries and stale base images.
+	SeverityUpdate Severity = "update"
+	// SeverityError covers failures and circuit breaker halts.
+	SeverityError Severity = "error"
+	// SeveritySelfUpdate covers repull replacing its own container. These
+	// events also reach backends taking updates (start, success) or errors
+	// (failure), so narrowing a backend never hides them.
+	SeveritySelfUpdate Severity = "self-update"
+)
+
+// Severities is the set of severities a backend receives. A nil set
+// receives everything, so a backend without a filter behaves as before.
+type Severities map[Severity]bool
+
+// ParseSeverities parses a comma-separated severity list such as
+// "update,error". An empty list returns nil (everything).
+func ParseSeverities(list string) (Severities, error) {
+	var set Severities
+	for _, part := range strings.Split(list, ",") {
+		s := Severity(strings.ToLower(strings.TrimSpace(part)))
+		if s == "" {
+			continue
+		}
+		if s != SeverityUpdate && s != SeverityError && s != SeveritySelfUpdate {
+			return nil, fmt.Errorf("unknown severity %q (want update, error or self-update)", s)
+		}
+		if set == nil {
+			set = make(Severities)
+		}
+		set[s] = true
+	}
+	return set, nil
+}
+
+// allows reports whether a backend with this set receives any of ss.
+func (set Severities) allows(ss ...Severity) bool {
+	if set == nil {
+		return true
+	}
+	for _, s := range ss {
+		if set[s] {
+			return true
+		}
+	}
+	return false
+}
+
+// eventSeverities maps a FileEvent's event name to its severities.
+func eventSeverities(event string) []Severity {
+	switch event {
+	case "error", "halt":
+		return []Severity{SeverityError}
+	case "self-update-failed":
+		return []Severity{SeveritySelfUpdate, SeverityError}
+	case "self-update-start", "self-update":
+		return []Severity{SeveritySelfUpdate, SeverityUpdate}
+	default:
+		return []Severity{SeverityUpdate}
+	}
+}
@@ -0,0 +1,131 @@
+package notify
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSeverities(t *testing.T) {
+	tests := []struct {
+		name    string
+		list    string
+		want    Severities
+		wantErr bool
+	}{
+		{name: "empty means all", list: "", want: nil},
+		{name: "single", list: "error", want: Severities{SeverityError: true}},
+		{name: "list with spaces and case", list: " Update , error ", want: Severities{SeverityUpdate: true, SeverityError: true}},
+		{name: "self-update", list: "self-update", want: Severities{SeveritySelfUpdate: true}},
+		{name: "unknown", list: "update,critical", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSeverities(tt.list)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSeverities(%q) error = %v, wantErr %v", tt.list, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseSeverities(%q) = %v, want %v", tt.list, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSeverityRouting verifies an update-only backend never receives
+// errors and an error-only backend never receives updates, across the
+// Discord and file backends of one notifier.
+func TestSeverityRouting(t *testing.T) {
+	var posted []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var m webhookMessage
+		json.NewDecoder(r.Body).Decode(&m)
+		posted = append(posted, m.Content)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	f, err := NewFileNotifier(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.SetSeverities(Severities{SeverityError: true})
+	n := (&Notifier{webhookURL: srv.URL}).WithFile(f)
+	n.SetSeverities(Severities{SeverityUpdate: true})
+
+	n.SendUpdate("app:web", "nginx:latest", "sha256:aaaa", "sha256:bbbb", "")
+	n.SendPulled("app:api", "api:latest", "sha256:aaaa", "sha256:bbbb")
+	n.SendStaleBase("app:web", "web:latest", "debian:12", "sha256:aaaa", "sha256:bbbb")
+	n.SendError("app:db", "pull failed")
+	n.SendHalt("3 groups failed in a row")
+
+	if len(posted) != 3 {
+		t.Fatalf("update-only webhook got %d messages, want 3: %q", len(posted), posted)
+	}
+	for _, content := range posted {
+		if strings.Contains(content, "Failed") || strings.Contains(content, "Circuit breaker") {
+			t.Errorf("update-only webhook received %q", content)
+		}
+	}
+
+	events := readEvents(t, path)
+	if len(events) != 2 || events[0].Event != "error" || events[1].Event != "halt" {
+		t.Errorf("error-only file got %+v, want the error and the halt only", events)
+	}
+}
+
+// TestSelfUpdateRouting verifies self-update events reach backends taking
+// the self-update severity, and also those taking updates (start, success)
+// or errors (failure).
+func TestSelfUpdateRouting(t *testing.T) {
+	stages := []SelfUpdateStage{SelfUpdateStarting, SelfUpdateDone, SelfUpdateFailed}
+	tests := []struct {
+		name string
+		set  Severities
+		want []string // file events received, in order
+	}{
+		{name: "self-update only", set: Severities{SeveritySelfUpdate: true}, want: []string{"self-update-start", "self-update", "self-update-failed"}},
+		{name: "updates only", set: Severities{SeverityUpdate: true}, want: []string{"self-update-start", "self-update"}},
+		{name: "errors only", set: Severities{SeverityError: true}, want: []string{"self-update-failed"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var posted []string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var m webhookMessage
+				json.NewDecoder(r.Body).Decode(&m)
+				posted = append(posted, m.Content)
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			defer srv.Close()
+
+			path := filepath.Join(t.TempDir(), "events.jsonl")
+			f, err := NewFileNotifier(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.SetSeverities(tt.set)
+			n := (&Notifier{webhookURL: srv.URL}).WithFile(f)
+			n.SetSeverities(tt.set)
+			for _, stage := range stages {
+				n.SendSelfUpdate(stage, "repull:repull", "repull:latest", "sha256:aaaa", "sha256:bbbb", "")
+			}
+
+			var got []string
+			for _, e := range readEvents(t, path) {
+				got = append(got, e.Event)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("file events = %v, want %v", got, tt.want)
+			}
+			if len(posted) != len(tt.want) {
+				t.Errorf("webhook got %d message(s), want %d: %q", len(posted), len(tt.want), posted)
+			}
+		})
+	}
+}
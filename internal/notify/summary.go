@@ -0,0 +1,111 @@
+package notify
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// UpdateResult is one entry of a run summary (see SendSummary): a service
+// that was updated or, with Error set, one whose update failed.
+type UpdateResult struct {
+	Service    string
+	Image      string
+	OldDigest  string
+	NewDigest  string
+	Dependents []string
+	Error      string
+}
+
+// runSummary holds the webhook's updates and errors of one run, per routing
+// key, until EndRun sends them (see SetRunSummary). Copies made by WithKey
+// share it.
+type runSummary struct {
+	mu      sync.Mutex
+	results map[string][]UpdateResult
+}
+
+func (s *runSummary) add(key string, r UpdateResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results[key] = append(s.results[key], r)
+}
+
+// take returns the held results and starts over.
+func (s *runSummary) take() map[string][]UpdateResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	results := s.results
+	s.results = make(map[string][]UpdateResult)
+	return results
+}
+
+// SetRunSummary switches the webhook from a message per update and error
+// to one summary per run, sent by EndRun (see SendSummary). Other
+// notifications, such as self-updates and the circuit breaker, still go out
+// as they happen, and the file and senders are not affected. Debounced
+// updates are summarized instead of debounced.
+func (n *Notifier) SetRunSummary(on bool) {
+	if n == nil {
+		return
+	}
+	n.batch = nil
+	if on {
+		n.batch = &runSummary{results: make(map[string][]UpdateResult)}
+	}
+}
+
+// SendSummary sends one message listing results, the updated services
+// first and the failures after them. Content over Discord's message limit
+// is split into numbered messages. The layout is fixed: custom templates
+// (see SetTemplates) do not apply. Like SendUpdate, failures are logged,
+// not returned.
+func (n *Notifier) SendSummary(results []UpdateResult) {
+	if n == nil || len(results) == 0 {
+		return
+	}
+
+	var updated, failed []UpdateResult
+	for _, r := range results {
+		if r.Error != "" {
+			failed = append(failed, r)
+		} else {
+			updated = append(updated, r)
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "📋 Run summary: %d updated, %d failed", len(updated), len(failed))
+	if len(updated) > 0 {
+		b.WriteString("\n\n✅ Updated")
+		for _, r := range updated {
+			fmt.Fprintf(&b, "\n• %s (%s): %s → %s", discordEscape(r.Service), discordEscape(r.Image), r.OldDigest, r.NewDigest)
+			if len(r.Dependents) > 0 {
+				fmt.Fprintf(&b, " and %d dependent container(s)", len(r.Dependents))
+			}
+		}
+	}
+	if len(failed) > 0 {
+		b.WriteString("\n\n❌ Failed")
+		for _, r := range failed {
+			fmt.Fprintf(&b, "\n• %s: %s", discordEscape(r.Service), discordEscape(r.Error))
+		}
+	}
+	n.send(b.String())
+}
+
+// sendRunSummary sends the summary held for each routing key, if any.
+func (n *Notifier) sendRunSummary() {
+	results := n.batch.take()
+	keys := make([]string, 0, len(results))
+	for key := range results {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		c := *n
+		c.key = key
+		c.SendSummary(results[key])
+	}
+}
@@ -0,0 +1,102 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// webhookRecorder serves a webhook recording the content and the
+// X-Repull-Key header of every message posted to it.
+func webhookRecorder(t *testing.T) (*Notifier, *[]string, *[]string) {
+	t.Helper()
+	var contents, keys []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var m webhookMessage
+		json.NewDecoder(r.Body).Decode(&m)
+		contents = append(contents, m.Content)
+		keys = append(keys, r.Header.Get("X-Repull-Key"))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(srv.Close)
+	return &Notifier{webhookURL: srv.URL}, &contents, &keys
+}
+
+// TestRunSummary verifies the updates and errors of a run are held until
+// EndRun and then sent as one message, successes before failures, one per
+// routing key.
+func TestRunSummary(t *testing.T) {
+	n, contents, keys := webhookRecorder(t)
+	n.SetRunSummary(true)
+
+	n.SendUpdate("app:web", "nginx:latest", "sha256:aaaa", "sha256:bbbb", "release notes", "app-worker-1")
+	n.SendError("app:db", "pull failed")
+	n.SendUpdate("app:api", "api:latest", "sha256:cccc", "sha256:dddd", "")
+	n.WithKey("team-platform").SendUpdate("infra:proxy", "traefik:v3", "sha256:eeee", "sha256:ffff", "")
+	if len(*contents) != 0 {
+		t.Fatalf("sent %d message(s) before the end of the run", len(*contents))
+	}
+
+	n.EndRun()
+	if len(*contents) != 2 {
+		t.Fatalf("got %d message(s), want one per key: %q", len(*contents), *contents)
+	}
+	summary := (*contents)[0]
+	if (*keys)[0] != "" || (*keys)[1] != "team-platform" || !strings.Contains((*contents)[1], "infra:proxy") {
+		t.Errorf("keys = %q, want the unkeyed summary, then team-platform's", *keys)
+	}
+	for _, want := range []string{"2 updated, 1 failed", "app:web (nginx:latest): sha256:aaaa → sha256:bbbb and 1 dependent container(s)", "app:api", "app:db: pull failed"} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("summary lacks %q:\n%s", want, summary)
+		}
+	}
+	if strings.Index(summary, "Failed") < strings.Index(summary, "app:api") {
+		t.Errorf("failures are not listed after the updates:\n%s", summary)
+	}
+	if strings.Contains(summary, "infra:proxy") || strings.Contains(summary, "release notes") {
+		t.Errorf("summary has another key's update or notes:\n%s", summary)
+	}
+
+	n.EndRun()
+	if len(*contents) != 2 {
+		t.Errorf("a run without updates sent %d more message(s)", len(*contents)-2)
+	}
+}
+
+// TestRunSummarySplits verifies a summary over Discord's limit goes out as
+// several numbered messages, none of them over the limit.
+// TestRunSummarySplits verifies a summary over Discord's limit is split into
+// messages numbered "(i/n)" that keep every entry.
+func TestRunSummarySplits(t *testing.T) {
+	n, contents, _ := webhookRecorder(t)
+	var results []UpdateResult
+	for i := range 40 {
+		results = append(results, UpdateResult{Service: fmt.Sprintf("project:service%02d", i), Image: "registry.example.com/team/image:latest", OldDigest: "sha256:0123456789ab", NewDigest: "sha256:ba9876543210"})
+	}
+	results = append(results, UpdateResult{Service: "project:db", Error: "pull failed"})
+	n.SendSummary(results)
+
+	if len(*contents) < 2 {
+		t.Fatalf("got %d message(s), want the summary split", len(*contents))
+	}
+	for i, c := range *contents {
+		if utf8.RuneCountInString(c) > discordMaxLen {
+			t.Errorf("message %d is %d characters long", i+1, utf8.RuneCountInString(c))
+		}
+		if !strings.HasSuffix(c, fmt.Sprintf("(%d/%d)", i+1, len(*contents))) {
+			t.Errorf("message %d is not numbered: %q", i+1, c[max(0, len(c)-20):])
+		}
+	}
+	last := len(*contents)
+	if !strings.HasSuffix((*contents)[0], fmt.Sprintf("(1/%d)", last)) || !strings.HasSuffix((*contents)[last-1], fmt.Sprintf("(%d/%d)", last, last)) {
+		t.Errorf("messages not numbered from 1 to %d", last)
+	}
+	joined := strings.Join(*contents, "\n")
+	if !strings.Contains(joined, "project:service39") || !strings.Contains(joined, "project:db: pull failed") {
+		t.Error("split summary lost entries")
+	}
+}
@@ -0,0 +1,186 @@
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// UpdateData is what an update template renders: one successful update.
+type UpdateData struct {
+	Service   string
+	Image     string
+	OldDigest string
+	NewDigest string
+	Notes     string
+	// Dependents are the containers recreated along with the service
+	// because they share its network namespace.
+	Dependents []string
+}
+
+// ErrorData is what an error template renders: one failed update.
+type ErrorData struct {
+	Service string
+	Error   string
+}
+
+// SummaryData is what a summary template renders: the updates of one group
+// batched by --notify-debounce, from the first old digest to the latest new
+// one. Count is the number of updates batched.
+type SummaryData struct {
+	Service    string
+	Image      string
+	OldDigest  string
+	NewDigest  string
+	Notes      string
+	Dependents []string
+	Count      int
+}
+
+// The built-in templates, matching the messages repull has always sent.
+const (
+	DefaultUpdateTemplate  = "✅ Updated {{.Service}}{{with .Dependents}} and {{len .}} dependent container(s){{end}}\nImage: {{.Image}}\n{{.OldDigest}} → {{.NewDigest}}{{if .Notes}}\n{{.Notes}}{{end}}"
+	DefaultErrorTemplate   = "❌ Failed to update {{.Service}}\nError: {{.Error}}"
+	DefaultSummaryTemplate = "✅ Updated {{.Service}}{{with .Dependents}} and {{len .}} dependent container(s){{end}}{{if gt .Count 1}} ({{.Count}} updates){{end}}\nImage: {{.Image}}\n{{.OldDigest}} → {{.NewDigest}}{{if .Notes}}\n{{.Notes}}{{end}}"
+)
+
+// Templates renders update, error and summary messages from Go templates
+// (text/template). Templates can call {{escape .Field}} to escape a value
+// for the backend rendering it, e.g. Markdown for Discord. A nil *Templates
+// renders the defaults.
+type Templates struct {
+	update, err, summary *template.Template
+}
+
+// defaultTemplates backs a nil *Templates.
+var defaultTemplates = mustTemplates(DefaultUpdateTemplate, DefaultErrorTemplate, DefaultSummaryTemplate)
+
+// LoadTemplates reads the update, error and summary templates from files.
+// An empty path keeps that event's default. Each template is rendered once
+// with sample data, so a reference to a field that does not exist fails
+// here rather than at the first notification.
+func LoadTemplates(updatePath, errorPath, summaryPath string) (*Templates, error) {
+	texts := []string{DefaultUpdateTemplate, DefaultErrorTemplate, DefaultSummaryTemplate}
+	for i, path := range []string{updatePath, errorPath, summaryPath} {
+		if path == "" {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		texts[i] = strings.TrimRight(string(data), "\n")
+	}
+	t, err := parseTemplates(texts[0], texts[1], texts[2])
+	if err != nil {
+		return nil, err
+	}
+	sample := UpdateData{Service: "app:web", Image: "nginx:latest", OldDigest: "sha256:0000000000", NewDigest: "sha256:1111111111", Notes: "notes", Dependents: []string{"app-sidecar-1"}}
+	if _, err := render(t.update, sample, plainEscape); err != nil {
+		return nil, fmt.Errorf("update template: %w", err)
+	}
+	if _, err := render(t.err, ErrorData{Service: "app:web", Error: "error"}, plainEscape); err != nil {
+		return nil, fmt.Errorf("error template: %w", err)
+	}
+	summary := SummaryData{Service: sample.Service, Image: sample.Image, OldDigest: sample.OldDigest, NewDigest: sample.NewDigest, Notes: sample.Notes, Dependents: sample.Dependents, Count: 2}
+	if _, err := render(t.summary, summary, plainEscape); err != nil {
+		return nil, fmt.Errorf("summary template: %w", err)
+	}
+	return t, nil
+}
+
+func parseTemplates(update, errText, summary string) (*Templates, error) {
+	parse := func(name, text string) (*template.Template, error) {
+		t, err := template.New(name).Funcs(template.FuncMap{"escape": plainEscape}).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("%s template: %w", name, err)
+		}
+		return t, nil
+	}
+	var t Templates
+	var err error
+	if t.update, err = parse("update", update); err != nil {
+		return nil, err
+	}
+	if t.err, err = parse("error", errText); err != nil {
+		return nil, err
+	}
+	if t.summary, err = parse("summary", summary); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func mustTemplates(update, errText, summary string) *Templates {
+	t, err := parseTemplates(update, errText, summary)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// Update renders an update message, escaping for the backend with escape.
+func (t *Templates) Update(d UpdateData, escape func(string) string) string {
+	return execute(t.orDefault().update, defaultTemplates.update, d, escape)
+}
+
+// Error renders an error message, escaping for the backend with escape.
+func (t *Templates) Error(d ErrorData, escape func(string) string) string {
+	return execute(t.orDefault().err, defaultTemplates.err, d, escape)
+}
+
+// Summary renders a batched update message, escaping for the backend with
+// escape.
+func (t *Templates) Summary(d SummaryData, escape func(string) string) string {
+	return execute(t.orDefault().summary, defaultTemplates.summary, d, escape)
+}
+
+func (t *Templates) orDefault() *Templates {
+	if t == nil {
+		return defaultTemplates
+	}
+	return t
+}
+
+// execute renders tmpl, falling back to def when it fails at render time:
+// a notification with the default wording beats none.
+func execute(tmpl, def *template.Template, data any, escape func(string) string) string {
+	out, err := render(tmpl, data, escape)
+	if err == nil {
+		return out
+	}
+	log.Printf("[WARN] Notification %s template failed, using the default: %v", tmpl.Name(), err)
+	out, _ = render(def, data, escape)
+	return out
+}
+
+// render executes tmpl with escape bound to {{escape}}.
+func render(tmpl *template.Template, data any, escape func(string) string) (string, error) {
+	clone, err := tmpl.Clone()
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := clone.Funcs(template.FuncMap{"escape": escape}).Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// plainEscape is the escape function of backends without markup.
+func plainEscape(s string) string {
+	return s
+}
+
+// discordMarkdown matches the characters Discord treats as Markdown.
+var discordMarkdown = strings.NewReplacer(
+	`\`, `\\`, "*", `\*`, "_", `\_`, "~", `\~`, "`", "\\`", "|", `\|`, ">", `\>`, "#", `\#`, "-", `\-`, "[", `\[`, "]", `\]`,
+)
+
+// discordEscape escapes Discord Markdown, so a value is shown as written.
+func discordEscape(s string) string {
+	return discordMarkdown.Replace(s)
+}
@@ -0,0 +1,112 @@
+package notify
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTemplatesRender(t *testing.T) {
+	update := UpdateData{Service: "app:web", Image: "nginx:latest", OldDigest: "sha256:aaaa", NewDigest: "sha256:bbbb"}
+	summary := SummaryData{Service: "app:web", Image: "nginx:latest", OldDigest: "sha256:aaaa", NewDigest: "sha256:cccc", Count: 3}
+	failure := ErrorData{Service: "app:web", Error: "pull access denied"}
+
+	dir := t.TempDir()
+	write := func(name, text string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	custom, err := LoadTemplates(
+		write("update.tmpl", "{{escape .Service}} is now on {{.NewDigest}}\n"),
+		write("error.tmpl", "{{.Service}} failed: {{.Error}}"),
+		write("summary.tmpl", "{{.Service}}: {{.Count}} updates, {{.OldDigest}} → {{.NewDigest}}"),
+	)
+	if err != nil {
+		t.Fatalf("LoadTemplates() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"default update", (*Templates)(nil).Update(update, plainEscape), "✅ Updated app:web\nImage: nginx:latest\nsha256:aaaa → sha256:bbbb"},
+		{"default update with notes", (*Templates)(nil).Update(UpdateData{Service: "app:web", Image: "nginx:latest", OldDigest: "sha256:aaaa", NewDigest: "sha256:bbbb", Notes: "Changelog: v2"}, plainEscape), "✅ Updated app:web\nImage: nginx:latest\nsha256:aaaa → sha256:bbbb\nChangelog: v2"},
+		{"default update with dependents", (*Templates)(nil).Update(UpdateData{Service: "app:web", Image: "nginx:latest", OldDigest: "sha256:aaaa", NewDigest: "sha256:bbbb", Dependents: []string{"vpn", "proxy"}}, plainEscape), "✅ Updated app:web and 2 dependent container(s)\nImage: nginx:latest\nsha256:aaaa → sha256:bbbb"},
+		{"default error", (*Templates)(nil).Error(failure, plainEscape), "❌ Failed to update app:web\nError: pull access denied"},
+		{"default summary", (*Templates)(nil).Summary(summary, plainEscape), "✅ Updated app:web (3 updates)\nImage: nginx:latest\nsha256:aaaa → sha256:cccc"},
+		{"default summary of one", (*Templates)(nil).Summary(SummaryData{Service: "app:web", Image: "nginx:latest", OldDigest: "sha256:aaaa", NewDigest: "sha256:bbbb", Count: 1}, plainEscape), "✅ Updated app:web\nImage: nginx:latest\nsha256:aaaa → sha256:bbbb"},
+		{"custom update", custom.Update(update, plainEscape), "app:web is now on sha256:bbbb"},
+		{"custom update escaped for Discord", custom.Update(UpdateData{Service: "my_app:*web*", NewDigest: "sha256:bbbb"}, discordEscape), `my\_app:\*web\* is now on sha256:bbbb`},
+		{"custom error", custom.Error(failure, plainEscape), "app:web failed: pull access denied"},
+		{"custom summary", custom.Summary(summary, plainEscape), "app:web: 3 updates, sha256:aaaa → sha256:cccc"},
+	}
+
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestLoadTemplatesRejectsBadTemplates(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name string
+		text string
+	}{
+		{"syntax error", "{{.Service"},
+		{"unknown field", "{{.Container}} updated"},
+		{"unknown function", "{{upper .Service}}"},
+	}
+
+	for _, tt := range tests {
+		path := filepath.Join(dir, "update.tmpl")
+		if err := os.WriteFile(path, []byte(tt.text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadTemplates(path, "", ""); err == nil {
+			t.Errorf("%s: LoadTemplates() error = nil, want an error", tt.name)
+		}
+	}
+	if _, err := LoadTemplates(filepath.Join(dir, "missing.tmpl"), "", ""); err == nil {
+		t.Error("missing file: LoadTemplates() error = nil, want an error")
+	}
+}
+
+func TestNotifierUsesTemplates(t *testing.T) {
+	var got webhookMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "error.tmpl")
+	if err := os.WriteFile(path, []byte("{{escape .Service}} is broken: {{.Error}}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	templates, err := LoadTemplates("", path, "")
+	if err != nil {
+		t.Fatalf("LoadTemplates() error = %v", err)
+	}
+
+	n := &Notifier{webhookURL: srv.URL}
+	n.SetTemplates(templates)
+	n.SendError("app:my_web", "exit 1")
+	if want := `app:my\_web is broken: exit 1`; got.Content != want {
+		t.Errorf("error content = %q, want %q", got.Content, want)
+	}
+	n.SendUpdate("app:web", "nginx:latest", "sha256:aaaa", "sha256:bbbb", "")
+	if !strings.HasPrefix(got.Content, "✅ Updated app:web") {
+		t.Errorf("update content = %q, want the default message", got.Content)
+	}
+}
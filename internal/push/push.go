@@ -0,0 +1,156 @@
+// Package push receives registry push webhooks, so a push can trigger an
+// update instead of waiting for the next poll. It understands the payloads
+// of Docker Hub and Harbor; see Parse.
+package push
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/distribution/reference"
+	"github.com/fanuelsen/repull/internal/sanitize"
+)
+
+// SecretHeader carries the shared secret of a webhook request.
+const SecretHeader = "X-Repull-Secret"
+
+// maxBody bounds the payload a request may send.
+const maxBody = 1 << 20
+
+// dockerHubPayload is the part of a Docker Hub push webhook repull reads.
+type dockerHubPayload struct {
+	PushData *struct {
+		Tag string `json:"tag"`
+	} `json:"push_data"`
+	Repository struct {
+		RepoName string `json:"repo_name"`
+	} `json:"repository"`
+}
+
+// harborPayload is the part of a Harbor (v2) push webhook repull reads.
+type harborPayload struct {
+	Type      string `json:"type"`
+	EventData *struct {
+		Resources []struct {
+			Tag         string `json:"tag"`
+			ResourceURL string `json:"resource_url"`
+		} `json:"resources"`
+	} `json:"event_data"`
+}
+
+// Parse returns the image references a push webhook payload announces,
+// normalized (e.g. "docker.io/library/nginx:latest"). Payloads that are not
+// push events are an error.
+func Parse(body []byte) ([]string, error) {
+	var hub dockerHubPayload
+	if err := json.Unmarshal(body, &hub); err != nil {
+		return nil, fmt.Errorf("decoding payload: %w", err)
+	}
+	if hub.PushData != nil {
+		ref, err := normalize(hub.Repository.RepoName + ":" + hub.PushData.Tag)
+		if err != nil {
+			return nil, err
+		}
+		return []string{ref}, nil
+	}
+
+	var harbor harborPayload
+	if err := json.Unmarshal(body, &harbor); err != nil {
+		return nil, fmt.Errorf("decoding payload: %w", err)
+	}
+	if harbor.EventData != nil {
+		// Harbor 2 sends PUSH_ARTIFACT; 1.x sent pushImage.
+		if harbor.Type != "PUSH_ARTIFACT" && harbor.Type != "pushImage" {
+			return nil, fmt.Errorf("not a push event: %q", harbor.Type)
+		}
+		var refs []string
+		for _, r := range harbor.EventData.Resources {
+			// A push by digest has no tag for containers to follow.
+			if r.Tag == "" {
+				continue
+			}
+			ref, err := normalize(r.ResourceURL)
+			if err != nil {
+				return nil, err
+			}
+			refs = append(refs, ref)
+		}
+		if len(refs) == 0 {
+			return nil, errors.New("push event names no tagged image")
+		}
+		return refs, nil
+	}
+
+	return nil, errors.New("unrecognized payload: expected a Docker Hub or Harbor push event")
+}
+
+// normalize parses an image reference from a payload into its canonical
+// form, defaulting the tag to latest.
+func normalize(image string) (string, error) {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return "", fmt.Errorf("invalid image reference %q: %w", image, err)
+	}
+	return reference.TagNameOnly(named).String(), nil
+}
+
+// Handler returns an HTTP handler that accepts push webhooks and calls
+// trigger with the pushed image references. trigger must not block: the
+// registry is answered only after it returns. With a non-empty secret,
+// requests must present it (see authorized).
+func Handler(secret string, trigger func(refs []string)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !authorized(r, secret) {
+			log.Printf("[WARN] Rejected webhook from %s: missing or wrong secret", sanitize.String(r.RemoteAddr))
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBody))
+		if err != nil {
+			http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		refs, err := Parse(body)
+		if err != nil {
+			log.Printf("[WARN] Ignoring webhook: %s", sanitize.String(err.Error()))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("[INFO] Webhook announced a push of %s", sanitize.String(strings.Join(refs, ", ")))
+		trigger(refs)
+		w.WriteHeader(http.StatusAccepted)
+	})
+}
+
+// authorized reports whether r presents secret: in the X-Repull-Secret
+// header, as the Authorization header (Harbor's "auth header" setting sends
+// it verbatim, with or without a Bearer prefix), or as the secret query
+// parameter, for registries like Docker Hub that cannot set headers.
+func authorized(r *http.Request, secret string) bool {
+	if secret == "" {
+		return true
+	}
+	candidates := []string{
+		r.Header.Get(SecretHeader),
+		r.Header.Get("Authorization"),
+		strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "),
+		r.URL.Query().Get("secret"),
+	}
+	for _, c := range candidates {
+		if c != "" && subtle.ConstantTimeCompare([]byte(c), []byte(secret)) == 1 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,109 @@
+package push
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const dockerHubPush = `{
+	"callback_url": "https://registry.hub.docker.com/u/team/app/hook/abc/",
+	"push_data": {"pushed_at": 1700000000, "pusher": "team", "tag": "1.2"},
+	"repository": {"name": "app", "namespace": "team", "repo_name": "team/app", "status": "Active"}
+}`
+
+const harborPush = `{
+	"type": "PUSH_ARTIFACT",
+	"occur_at": 1700000000,
+	"operator": "admin",
+	"event_data": {
+		"resources": [
+			{"digest": "sha256:1234", "tag": "latest", "resource_url": "harbor.example.com/library/app:latest"}
+		],
+		"repository": {"name": "app", "namespace": "library", "repo_full_name": "library/app", "repo_type": "private"}
+	}
+}`
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    []string
+		wantErr bool
+	}{
+		{"docker hub", dockerHubPush, []string{"docker.io/team/app:1.2"}, false},
+		{"harbor", harborPush, []string{"harbor.example.com/library/app:latest"}, false},
+		{"harbor 1.x", `{"type": "pushImage", "event_data": {"resources": [{"tag": "v1", "resource_url": "harbor.example.com/team/api:v1"}]}}`, []string{"harbor.example.com/team/api:v1"}, false},
+		{"harbor delete", `{"type": "DELETE_ARTIFACT", "event_data": {"resources": [{"tag": "v1", "resource_url": "harbor.example.com/team/api:v1"}]}}`, nil, true},
+		{"harbor push by digest", `{"type": "PUSH_ARTIFACT", "event_data": {"resources": [{"digest": "sha256:1234", "resource_url": "harbor.example.com/team/api@sha256:1234"}]}}`, nil, true},
+		{"unknown", `{"action": "published"}`, nil, true},
+		{"not json", `push`, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse([]byte(tt.body))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Parse() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandler(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		target      string
+		header      map[string]string
+		body        string
+		wantStatus  int
+		wantTrigger bool
+	}{
+		{"secret header", http.MethodPost, "/", map[string]string{SecretHeader: "s3cret"}, harborPush, http.StatusAccepted, true},
+		{"harbor auth header", http.MethodPost, "/", map[string]string{"Authorization": "s3cret"}, harborPush, http.StatusAccepted, true},
+		{"bearer auth header", http.MethodPost, "/", map[string]string{"Authorization": "Bearer s3cret"}, harborPush, http.StatusAccepted, true},
+		{"query secret", http.MethodPost, "/?secret=s3cret", nil, dockerHubPush, http.StatusAccepted, true},
+		{"wrong secret", http.MethodPost, "/", map[string]string{SecretHeader: "guess"}, harborPush, http.StatusUnauthorized, false},
+		{"no secret", http.MethodPost, "/", nil, harborPush, http.StatusUnauthorized, false},
+		{"bad payload", http.MethodPost, "/", map[string]string{SecretHeader: "s3cret"}, `{}`, http.StatusBadRequest, false},
+		{"get", http.MethodGet, "/", map[string]string{SecretHeader: "s3cret"}, "", http.StatusMethodNotAllowed, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var triggered []string
+			h := Handler("s3cret", func(refs []string) { triggered = refs })
+
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if (triggered != nil) != tt.wantTrigger {
+				t.Errorf("triggered = %v, want trigger %v", triggered, tt.wantTrigger)
+			}
+		})
+	}
+}
+
+func TestHandlerWithoutSecret(t *testing.T) {
+	var triggered []string
+	h := Handler("", func(refs []string) { triggered = refs })
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(dockerHubPush)))
+
+	if rec.Code != http.StatusAccepted || len(triggered) != 1 {
+		t.Errorf("status = %d, triggered = %v; want 202 and one image", rec.Code, triggered)
+	}
+}
@@ -0,0 +1,413 @@
+// Package registry queries image manifests straight from a registry, without
+// going through the Docker daemon. It is used for checks that must happen
+// before a pull, such as the size guard; the pull itself is still done by the
+// daemon.
+package registry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/distribution/reference"
+	registrytypes "github.com/docker/docker/api/types/registry"
+	"github.com/fanuelsen/repull/internal/useragent"
+)
+
+// Manifest media types accepted from the registry: single-platform manifests
+// and multi-platform indexes, in both Docker and OCI flavors.
+var acceptTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// Client fetches manifests from registries.
+type Client struct {
+	// HTTP is the client used for registry and token requests.
+	HTTP *http.Client
+	// Scheme is the registry URL scheme; "https" unless overridden in tests.
+	Scheme string
+}
+
+// NewClient returns a Client with a timeout suited to small manifest fetches.
+func NewClient() *Client {
+	return &Client{HTTP: &http.Client{Timeout: 30 * time.Second, Transport: useragent.Transport{}}, Scheme: "https"}
+}
+
+// Platform identifies the image for one platform in a multi-platform
+// index, e.g. linux/arm/v7.
+type Platform struct {
+	OS           string `json:"os"`
+	Architecture string `json:"architecture"`
+	// Variant tells ARM versions apart, e.g. "v7"; empty elsewhere.
+	Variant string `json:"variant,omitempty"`
+}
+
+func (p Platform) String() string {
+	s := p.OS + "/" + p.Architecture
+	if p.Variant != "" {
+		s += "/" + p.Variant
+	}
+	return s
+}
+
+type descriptor struct {
+	MediaType string    `json:"mediaType"`
+	Digest    string    `json:"digest"`
+	Size      int64     `json:"size"`
+	Platform  *Platform `json:"platform,omitempty"`
+}
+
+type manifest struct {
+	MediaType string       `json:"mediaType"`
+	Config    descriptor   `json:"config"`
+	Layers    []descriptor `json:"layers"`
+	Manifests []descriptor `json:"manifests"`
+
+	// digest identifies the manifest itself, from Docker-Content-Digest or
+	// computed from the body.
+	digest string
+}
+
+// ImageSize returns the total compressed size — config plus layers — of the
+// image imageName refers to for the given platform (e.g. linux/amd64).
+// That is what a pull downloads when none of its layers are present locally.
+// auth holds registry credentials; the zero value means anonymous access.
+func (c *Client) ImageSize(ctx context.Context, imageName string, platform Platform, auth registrytypes.AuthConfig) (int64, error) {
+	s, ref, err := c.newSession(imageName, auth)
+	if err != nil {
+		return 0, err
+	}
+	m, err := s.platformManifest(ctx, ref, platform, imageName)
+	if err != nil {
+		return 0, err
+	}
+
+	size := m.Config.Size
+	for _, l := range m.Layers {
+		size += l.Size
+	}
+	return size, nil
+}
+
+// Layer is one layer of an image as the registry stores it.
+type Layer struct {
+	// Digest and Size describe the compressed blob a pull downloads.
+	Digest string
+	Size   int64
+	// DiffID is the digest of the uncompressed layer, which is how the
+	// Docker daemon identifies layers it already has (RootFS.Layers).
+	DiffID string
+}
+
+// Layers returns the layers of the image imageName refers to for the given
+// platform, in order, with the config size a pull also downloads. Unlike
+// ImageSize it fetches the image config too, for the layers' DiffIDs.
+func (c *Client) Layers(ctx context.Context, imageName string, platform Platform, auth registrytypes.AuthConfig) (layers []Layer, configSize int64, err error) {
+	s, ref, err := c.newSession(imageName, auth)
+	if err != nil {
+		return nil, 0, err
+	}
+	m, err := s.platformManifest(ctx, ref, platform, imageName)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	body, err := s.blob(ctx, m.Config.Digest)
+	if err != nil {
+		return nil, 0, fmt.Errorf("fetching image config: %w", err)
+	}
+	var cfg struct {
+		RootFS struct {
+			DiffIDs []string `json:"diff_ids"`
+		} `json:"rootfs"`
+	}
+	if err := json.Unmarshal(body, &cfg); err != nil {
+		return nil, 0, fmt.Errorf("decoding image config: %w", err)
+	}
+	if len(cfg.RootFS.DiffIDs) != len(m.Layers) {
+		return nil, 0, fmt.Errorf("image config lists %d layers, manifest %d", len(cfg.RootFS.DiffIDs), len(m.Layers))
+	}
+
+	for i, l := range m.Layers {
+		layers = append(layers, Layer{Digest: l.Digest, Size: l.Size, DiffID: cfg.RootFS.DiffIDs[i]})
+	}
+	return layers, m.Config.Size, nil
+}
+
+// Digests returns the digest imageName currently resolves to in the
+// registry and, for a multi-platform image, the digests of its
+// per-platform manifests: a reference to the image may record either.
+func (c *Client) Digests(ctx context.Context, imageName string, auth registrytypes.AuthConfig) ([]string, error) {
+	s, ref, err := c.newSession(imageName, auth)
+	if err != nil {
+		return nil, err
+	}
+	m, err := s.manifest(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	digests := []string{m.digest}
+	for _, d := range m.Manifests {
+		digests = append(digests, d.Digest)
+	}
+	return digests, nil
+}
+
+// newSession parses imageName into a lookup session for its repository and
+// the tag or digest to fetch.
+func (c *Client) newSession(imageName string, auth registrytypes.AuthConfig) (*session, string, error) {
+	named, err := reference.ParseNormalizedNamed(imageName)
+	if err != nil {
+		return nil, "", err
+	}
+	named = reference.TagNameOnly(named)
+
+	ref := ""
+	switch r := named.(type) {
+	case reference.Digested:
+		ref = r.Digest().String()
+	case reference.Tagged:
+		ref = r.Tag()
+	}
+
+	host := reference.Domain(named)
+	if host == "docker.io" {
+		host = "registry-1.docker.io"
+	}
+	return &session{client: c, host: host, repo: reference.Path(named), auth: auth}, ref, nil
+}
+
+// platformManifest fetches the manifest for ref and, if it is a
+// multi-platform index, the manifest of the given platform within it. An
+// entry of the platform's variant wins; one without a variant, or any
+// variant when the platform names none, is taken otherwise. An index
+// offering only other variants, e.g. arm/v6 for an arm/v7 daemon, has no
+// image for it.
+func (s *session) platformManifest(ctx context.Context, ref string, platform Platform, imageName string) (*manifest, error) {
+	m, err := s.manifest(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if len(m.Manifests) == 0 {
+		return m, nil
+	}
+	fallback := ""
+	for _, d := range m.Manifests {
+		p := d.Platform
+		if p == nil || p.OS != platform.OS || p.Architecture != platform.Architecture {
+			continue
+		}
+		if p.Variant == platform.Variant {
+			return s.manifest(ctx, d.Digest)
+		}
+		if fallback == "" && (p.Variant == "" || platform.Variant == "") {
+			fallback = d.Digest
+		}
+	}
+	if fallback != "" {
+		return s.manifest(ctx, fallback)
+	}
+	return nil, fmt.Errorf("no %s image in %s", platform, imageName)
+}
+
+// session holds the per-repository state of a lookup, including a bearer
+// token once one has been obtained.
+type session struct {
+	client *Client
+	host   string
+	repo   string
+	auth   registrytypes.AuthConfig
+	token  string
+}
+
+// manifest fetches and decodes the manifest for ref (a tag or digest),
+// answering an authentication challenge once if the registry issues one.
+func (s *session) manifest(ctx context.Context, ref string) (*manifest, error) {
+	u := fmt.Sprintf("%s://%s/v2/%s/manifests/%s", s.client.Scheme, s.host, s.repo, ref)
+
+	resp, err := s.getAuthenticated(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("registry returned status %d for %s", resp.StatusCode, ref)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return nil, fmt.Errorf("reading manifest: %w", err)
+	}
+	var m manifest
+	if err := json.Unmarshal(body, &m); err != nil {
+		return nil, fmt.Errorf("decoding manifest: %w", err)
+	}
+	m.digest = resp.Header.Get("Docker-Content-Digest")
+	if m.digest == "" {
+		sum := sha256.Sum256(body)
+		m.digest = "sha256:" + hex.EncodeToString(sum[:])
+	}
+	return &m, nil
+}
+
+// blob fetches a small blob, such as an image config, by digest.
+func (s *session) blob(ctx context.Context, digest string) ([]byte, error) {
+	u := fmt.Sprintf("%s://%s/v2/%s/blobs/%s", s.client.Scheme, s.host, s.repo, digest)
+
+	resp, err := s.getAuthenticated(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("registry returned status %d for %s", resp.StatusCode, digest)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+}
+
+// getAuthenticated performs a GET, answering an authentication challenge
+// once if the registry issues one.
+func (s *session) getAuthenticated(ctx context.Context, u string) (*http.Response, error) {
+	resp, err := s.get(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized && s.token == "" {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		if err := s.authenticate(ctx, challenge); err != nil {
+			return nil, err
+		}
+		return s.get(ctx, u)
+	}
+	return resp, nil
+}
+
+func (s *session) get(ctx context.Context, u string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", strings.Join(acceptTypes, ", "))
+	switch {
+	case s.token != "":
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	case s.auth.Username != "":
+		req.SetBasicAuth(s.auth.Username, s.auth.Password)
+	}
+	return s.client.HTTP.Do(req)
+}
+
+// authenticate obtains a bearer token as described by a
+// `WWW-Authenticate: Bearer realm=...,service=...,scope=...` challenge.
+func (s *session) authenticate(ctx context.Context, challenge string) error {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return errors.New("registry requires authentication")
+	}
+	p := parseChallenge(params)
+	if p["realm"] == "" {
+		return errors.New("registry auth challenge has no realm")
+	}
+
+	q := url.Values{}
+	if p["service"] != "" {
+		q.Set("service", p["service"])
+	}
+	scope := p["scope"]
+	if scope == "" {
+		scope = "repository:" + s.repo + ":pull"
+	}
+	q.Set("scope", scope)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p["realm"]+"?"+q.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	if s.auth.Username != "" && s.trustsRealm(req.URL) {
+		req.SetBasicAuth(s.auth.Username, s.auth.Password)
+	}
+	resp, err := s.client.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("token endpoint returned status %d", resp.StatusCode)
+	}
+
+	var tok struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&tok); err != nil {
+		return fmt.Errorf("decoding token: %w", err)
+	}
+	s.token = tok.Token
+	if s.token == "" {
+		s.token = tok.AccessToken
+	}
+	if s.token == "" {
+		return errors.New("token endpoint returned no token")
+	}
+	return nil
+}
+
+// tokenHosts maps registries to the token service they send clients to on
+// another host, which is trusted with their credentials (see trustsRealm).
+var tokenHosts = map[string]string{
+	"registry-1.docker.io": "auth.docker.io",
+	"registry.gitlab.com":  "gitlab.com",
+}
+
+// trustsRealm reports whether the registry's credentials may be sent to the
+// token endpoint realm: one on the registry's own host or its token host in
+// tokenHosts, over HTTPS. The registry names the realm, so anything else
+// could hand the credentials to a host they were never meant for; the token
+// is then requested anonymously, which still works for public images.
+func (s *session) trustsRealm(realm *url.URL) bool {
+	if realm.Scheme != "https" && realm.Scheme != s.client.Scheme {
+		return false
+	}
+	return realm.Host == s.host || realm.Host == tokenHosts[s.host]
+}
+
+// parseChallenge parses the comma-separated key="value" parameters of a
+// WWW-Authenticate header.
+func parseChallenge(params string) map[string]string {
+	out := make(map[string]string)
+	for params != "" {
+		var part string
+		// Values are quoted and may contain commas (e.g. multiple scopes).
+		key, rest, ok := strings.Cut(params, "=")
+		if !ok {
+			break
+		}
+		key = strings.TrimSpace(key)
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				part, params = rest[1:], ""
+			} else {
+				part, params = rest[1:end+1], strings.TrimPrefix(rest[end+2:], ",")
+			}
+		} else {
+			part, params, _ = strings.Cut(rest, ",")
+		}
+		out[key] = part
+	}
+	return out
+}
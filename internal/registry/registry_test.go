@@ -0,0 +1,223 @@
+package registry
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	registrytypes "github.com/docker/docker/api/types/registry"
+)
+
+// stubRegistry serves a multi-platform index for team/app:latest whose
+// linux/amd64 manifest has a 1000-byte config and two layers of 4000 and
+// 5000 bytes, with diff IDs sha256:d1 and sha256:d2 in the config. Manifest requests require a bearer token from /token.
+func stubRegistry(t *testing.T) (*Client, string) {
+	t.Helper()
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			if r.URL.Query().Get("scope") != "repository:team/app:pull" {
+				http.Error(w, "bad scope", http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(`{"token":"t0ken"}`))
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer t0ken" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+srv.URL+`/token",service="stub",scope="repository:team/app:pull"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/v2/team/app/manifests/latest":
+			w.Write([]byte(`{
+				"mediaType": "application/vnd.oci.image.index.v1+json",
+				"manifests": [
+					{"digest": "sha256:arm", "platform": {"os": "linux", "architecture": "arm64"}},
+					{"digest": "sha256:amd", "platform": {"os": "linux", "architecture": "amd64"}}
+				]
+			}`))
+		case "/v2/team/app/manifests/sha256:amd":
+			w.Write([]byte(`{
+				"mediaType": "application/vnd.oci.image.manifest.v1+json",
+				"config": {"digest": "sha256:cfg", "size": 1000},
+				"layers": [{"digest": "sha256:l1", "size": 4000}, {"digest": "sha256:l2", "size": 5000}]
+			}`))
+		case "/v2/team/app/blobs/sha256:cfg":
+			w.Write([]byte(`{"rootfs": {"type": "layers", "diff_ids": ["sha256:d1", "sha256:d2"]}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	c := NewClient()
+	c.Scheme = "http"
+	return c, strings.TrimPrefix(srv.URL, "http://")
+}
+
+var linuxAMD64 = Platform{OS: "linux", Architecture: "amd64"}
+
+func TestImageSize(t *testing.T) {
+	c, host := stubRegistry(t)
+
+	got, err := c.ImageSize(t.Context(), host+"/team/app:latest", linuxAMD64, registrytypes.AuthConfig{})
+	if err != nil {
+		t.Fatalf("ImageSize() error = %v", err)
+	}
+	if got != 10000 {
+		t.Errorf("ImageSize() = %d, want 10000 (config + layers)", got)
+	}
+}
+
+func TestImageSizeMissingPlatform(t *testing.T) {
+	c, host := stubRegistry(t)
+
+	if _, err := c.ImageSize(t.Context(), host+"/team/app:latest", Platform{OS: "windows", Architecture: "amd64"}, registrytypes.AuthConfig{}); err == nil {
+		t.Error("ImageSize() error = nil, want error for a platform the index lacks")
+	}
+}
+
+func TestLayers(t *testing.T) {
+	c, host := stubRegistry(t)
+
+	layers, configSize, err := c.Layers(t.Context(), host+"/team/app:latest", linuxAMD64, registrytypes.AuthConfig{})
+	if err != nil {
+		t.Fatalf("Layers() error = %v", err)
+	}
+	want := []Layer{
+		{Digest: "sha256:l1", Size: 4000, DiffID: "sha256:d1"},
+		{Digest: "sha256:l2", Size: 5000, DiffID: "sha256:d2"},
+	}
+	if len(layers) != len(want) {
+		t.Fatalf("Layers() = %+v, want %+v", layers, want)
+	}
+	for i := range want {
+		if layers[i] != want[i] {
+			t.Errorf("Layers()[%d] = %+v, want %+v", i, layers[i], want[i])
+		}
+	}
+	if configSize != 1000 {
+		t.Errorf("Layers() config size = %d, want 1000", configSize)
+	}
+}
+
+// TestImageSizeVariant verifies the image of the platform's variant is
+// picked from an index listing several ARM versions, falling back to an
+// entry without a variant.
+func TestImageSizeVariant(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/team/app/manifests/latest" {
+			w.Write([]byte(`{
+				"mediaType": "application/vnd.oci.image.index.v1+json",
+				"manifests": [
+					{"digest": "sha256:6", "platform": {"os": "linux", "architecture": "arm", "variant": "v6"}},
+					{"digest": "sha256:7", "platform": {"os": "linux", "architecture": "arm", "variant": "v7"}},
+					{"digest": "sha256:8", "platform": {"os": "linux", "architecture": "arm64"}}
+				]
+			}`))
+			return
+		}
+		// Each platform's image has a config of the size of its digest.
+		size := strings.TrimPrefix(r.URL.Path, "/v2/team/app/manifests/sha256:")
+		w.Write([]byte(`{"mediaType": "application/vnd.oci.image.manifest.v1+json", "config": {"digest": "sha256:cfg", "size": ` + size + `}}`))
+	}))
+	t.Cleanup(srv.Close)
+	c := NewClient()
+	c.Scheme = "http"
+	image := strings.TrimPrefix(srv.URL, "http://") + "/team/app:latest"
+
+	tests := []struct {
+		arch, variant string
+		want          int64
+		wantErr       bool
+	}{
+		{arch: "arm", variant: "v7", want: 7},
+		{arch: "arm", variant: "v6", want: 6},
+		{arch: "arm", variant: "", want: 6},
+		{arch: "arm64", variant: "v8", want: 8},
+		{arch: "arm", variant: "v5", wantErr: true},
+	}
+	for _, tt := range tests {
+		platform := Platform{OS: "linux", Architecture: tt.arch, Variant: tt.variant}
+		got, err := c.ImageSize(t.Context(), image, platform, registrytypes.AuthConfig{})
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ImageSize(%s) = %d, %v; want %d, error %v", platform, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+// TestTokenCredentialsStayWithRegistry verifies the registry credentials are
+// sent to a token realm on the registry's own host, but not to a realm the
+// registry points at another host.
+func TestTokenCredentialsStayWithRegistry(t *testing.T) {
+	var gotAuth []string
+	token := func(w http.ResponseWriter, r *http.Request) {
+		sent := "none"
+		if _, _, ok := r.BasicAuth(); ok {
+			sent = "basic"
+		}
+		gotAuth = append(gotAuth, sent)
+		w.Write([]byte(`{"token":"t0ken"}`))
+	}
+	elsewhere := httptest.NewServer(http.HandlerFunc(token))
+	t.Cleanup(elsewhere.Close)
+
+	var srv *httptest.Server
+	realm := ""
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			token(w, r)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer t0ken" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+realm+`"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"mediaType": "application/vnd.oci.image.manifest.v1+json", "config": {"size": 1}}`))
+	}))
+	t.Cleanup(srv.Close)
+	c := NewClient()
+	c.Scheme = "http"
+	image := strings.TrimPrefix(srv.URL, "http://") + "/team/app:latest"
+	auth := registrytypes.AuthConfig{Username: "user", Password: "secret"}
+
+	for _, realm = range []string{srv.URL + "/token", elsewhere.URL + "/token"} {
+		if _, err := c.ImageSize(t.Context(), image, linuxAMD64, auth); err != nil {
+			t.Fatalf("ImageSize() with realm %s error = %v", realm, err)
+		}
+	}
+	if len(gotAuth) != 2 || gotAuth[0] != "basic" || gotAuth[1] != "none" {
+		t.Errorf("token requests authenticated with %v, want [basic none]", gotAuth)
+	}
+}
+
+func TestParseChallenge(t *testing.T) {
+	got := parseChallenge(`realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:a:pull,push"`)
+	want := map[string]string{
+		"realm":   "https://auth.docker.io/token",
+		"service": "registry.docker.io",
+		"scope":   "repository:a:pull,push",
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("parseChallenge()[%q] = %q, want %q", k, got[k], v)
+		}
+	}
+}
+
+func TestDigests(t *testing.T) {
+	c, host := stubRegistry(t)
+
+	got, err := c.Digests(t.Context(), host+"/team/app:latest", registrytypes.AuthConfig{})
+	if err != nil {
+		t.Fatalf("Digests() error = %v", err)
+	}
+	// The stub sends no Docker-Content-Digest, so the index digest is
+	// computed from the body; the platform digests come from the index.
+	if len(got) != 3 || !strings.HasPrefix(got[0], "sha256:") || got[1] != "sha256:arm" || got[2] != "sha256:amd" {
+		t.Errorf("Digests() = %v, want [<index digest> sha256:arm sha256:amd]", got)
+	}
+}
@@ -0,0 +1,404 @@
+// Package state persists what repull needs to remember between runs, such as
+// when each container was last recreated. Without a state file the state
+// lives in memory only and still carries over between runs of one process
+// (loop and schedule modes).
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// State is the persisted state. Containers are keyed by name: the ID changes
+// on every recreate, the name does not. Deployment history is keyed by image
+// repository (e.g. docker.io/library/nginx), newest first. Canaries are keyed
+// by group, staged images by image reference, failed self-updates by
+// container name.
+type State struct {
+	mu   sync.Mutex
+	path string
+
+	Recreated map[string]time.Time    `json:"recreated"`
+	Deployed  map[string][]Deployment `json:"deployed,omitempty"`
+	Canaries  map[string]Canary       `json:"canaries,omitempty"`
+	Staged    map[string]Staged       `json:"staged,omitempty"`
+	// SelfUpdates is a sentinel against retrying a broken repull image on
+	// every run (see SelfUpdate).
+	SelfUpdates map[string]SelfUpdate `json:"self_updates,omitempty"`
+	// RestartLoops holds the image ID each restart-looping container was
+	// reported on, so it is reported once rather than on every run.
+	RestartLoops map[string]string `json:"restart_loops,omitempty"`
+}
+
+// Canary is a group's container that runs a new image ahead of the rest,
+// which wait for promotion.
+type Canary struct {
+	Container string    `json:"container"`
+	ImageID   string    `json:"image_id"`
+	Time      time.Time `json:"time"`
+}
+
+// Staged is an image a --prefetch run pulled for containers still running
+// an older one, for a later run to recreate them from without pulling.
+type Staged struct {
+	ImageID string    `json:"image_id"`
+	Time    time.Time `json:"time"`
+}
+
+// SelfUpdate counts the failed attempts to move a repull instance to one
+// image. Notified records that the instance was then skipped and the user
+// told, so later skips stay quiet.
+type SelfUpdate struct {
+	ImageID  string    `json:"image_id"`
+	Attempts int       `json:"attempts"`
+	Time     time.Time `json:"time"`
+	Notified bool      `json:"notified,omitempty"`
+}
+
+// Deployment is an image repull has run containers from.
+type Deployment struct {
+	ImageID string    `json:"image_id"`
+	Time    time.Time `json:"time"`
+}
+
+// Load reads the state file at path. A missing file yields an empty state,
+// as on the very first run; an empty path yields an in-memory state that
+// Save never writes.
+func Load(path string) (*State, error) {
+	s := &State{path: path, Recreated: make(map[string]time.Time), Deployed: make(map[string][]Deployment), Canaries: make(map[string]Canary), Staged: make(map[string]Staged), SelfUpdates: make(map[string]SelfUpdate), RestartLoops: make(map[string]string)}
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if s.Recreated == nil {
+		s.Recreated = make(map[string]time.Time)
+	}
+	if s.Deployed == nil {
+		s.Deployed = make(map[string][]Deployment)
+	}
+	if s.Canaries == nil {
+		s.Canaries = make(map[string]Canary)
+	}
+	if s.Staged == nil {
+		s.Staged = make(map[string]Staged)
+	}
+	if s.SelfUpdates == nil {
+		s.SelfUpdates = make(map[string]SelfUpdate)
+	}
+	if s.RestartLoops == nil {
+		s.RestartLoops = make(map[string]string)
+	}
+	return s, nil
+}
+
+// Persistent reports whether the state is saved to a file, so it outlives
+// the process.
+func (s *State) Persistent() bool {
+	return s != nil && s.path != ""
+}
+
+// Save writes the state file. The file is written to a temporary file and
+// renamed into place, so a crash mid-write never leaves a truncated state
+// behind.
+func (s *State) Save() error {
+	if s == nil || s.path == "" {
+		return nil
+	}
+	s.mu.Lock()
+	data, err := json.MarshalIndent(s, "", "  ")
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".repull-state-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// LastRecreated returns when the named container was last recreated.
+func (s *State) LastRecreated(name string) (time.Time, bool) {
+	if s == nil {
+		return time.Time{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.Recreated[name]
+	return t, ok
+}
+
+// RecordRecreated records that the named container was recreated at t.
+func (s *State) RecordRecreated(name string, t time.Time) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Recreated[name] = t
+}
+
+// RecordDeployed records that containers of repo ran imageID as of t. An
+// image already in the history keeps the later of both times.
+func (s *State) RecordDeployed(repo, imageID string, t time.Time) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	history := s.Deployed[repo]
+	found := false
+	for i := range history {
+		if history[i].ImageID == imageID {
+			if t.After(history[i].Time) {
+				history[i].Time = t
+			}
+			found = true
+			break
+		}
+	}
+	if !found {
+		history = append(history, Deployment{ImageID: imageID, Time: t})
+	}
+	sort.SliceStable(history, func(i, j int) bool { return history[i].Time.After(history[j].Time) })
+	s.Deployed[repo] = history
+}
+
+// Deployments returns repo's deployment history, newest first.
+func (s *State) Deployments(repo string) []Deployment {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.Deployed[repo])
+}
+
+// ForgetDeployed drops imageID from repo's history, e.g. once the image has
+// been removed.
+func (s *State) ForgetDeployed(repo, imageID string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Deployed[repo] = slices.DeleteFunc(s.Deployed[repo], func(d Deployment) bool { return d.ImageID == imageID })
+	if len(s.Deployed[repo]) == 0 {
+		delete(s.Deployed, repo)
+	}
+}
+
+// Canary returns the group's pending canary, if any.
+func (s *State) Canary(group string) (Canary, bool) {
+	if s == nil {
+		return Canary{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.Canaries[group]
+	return c, ok
+}
+
+// RecordCanary records c as the group's pending canary, replacing any
+// earlier one.
+func (s *State) RecordCanary(group string, c Canary) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Canaries[group] = c
+}
+
+// ClearCanary forgets the group's canary, e.g. once it has been promoted.
+func (s *State) ClearCanary(group string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.Canaries, group)
+}
+
+// StagedImage returns the image staged for imageName, if any.
+func (s *State) StagedImage(imageName string) (Staged, bool) {
+	if s == nil {
+		return Staged{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.Staged[imageName]
+	return st, ok
+}
+
+// RecordStaged records st as staged for imageName, replacing any earlier
+// image.
+func (s *State) RecordStaged(imageName string, st Staged) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Staged[imageName] = st
+}
+
+// ClearStaged forgets the image staged for imageName, e.g. once its
+// containers have been recreated from it.
+func (s *State) ClearStaged(imageName string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.Staged, imageName)
+}
+
+// SelfUpdateAttempts returns the failed self-update attempts recorded for
+// the named container, if any.
+func (s *State) SelfUpdateAttempts(name string) (SelfUpdate, bool) {
+	if s == nil {
+		return SelfUpdate{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	su, ok := s.SelfUpdates[name]
+	return su, ok
+}
+
+// RecordSelfUpdate records su for the named container, replacing any
+// earlier entry.
+func (s *State) RecordSelfUpdate(name string, su SelfUpdate) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.SelfUpdates[name] = su
+}
+
+// ClearSelfUpdate forgets the failed self-update attempts of the named
+// container, e.g. once it has been updated.
+func (s *State) ClearSelfUpdate(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.SelfUpdates, name)
+}
+
+// RestartLoopReported reports whether the named container was already
+// reported as restart-looping on imageID.
+func (s *State) RestartLoopReported(name, imageID string) bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id, ok := s.RestartLoops[name]
+	return ok && id == imageID
+}
+
+// RecordRestartLoop records that the named container was reported as
+// restart-looping on imageID.
+func (s *State) RecordRestartLoop(name, imageID string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.RestartLoops[name] = imageID
+}
+
+// ClearRestartLoop forgets a restart loop of the named container, e.g. once
+// it runs steadily again, so a new loop is reported.
+func (s *State) ClearRestartLoop(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.RestartLoops, name)
+}
+
+// Compact drops entries for containers and images that no longer exist:
+// recreate times, canaries, self-update attempts and restart loops of
+// containers not in containers, and deployments and staged images of images
+// not in images. Without it, every container or image repull ever saw would
+// stay in the file. Returns the number of entries removed.
+func (s *State) Compact(containers, images map[string]bool) int {
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+	for name := range s.Recreated {
+		if !containers[name] {
+			delete(s.Recreated, name)
+			removed++
+		}
+	}
+	for group, c := range s.Canaries {
+		if !containers[c.Container] {
+			delete(s.Canaries, group)
+			removed++
+		}
+	}
+	for name := range s.SelfUpdates {
+		if !containers[name] {
+			delete(s.SelfUpdates, name)
+			removed++
+		}
+	}
+	for name := range s.RestartLoops {
+		if !containers[name] {
+			delete(s.RestartLoops, name)
+			removed++
+		}
+	}
+	for imageName, st := range s.Staged {
+		if !images[st.ImageID] {
+			delete(s.Staged, imageName)
+			removed++
+		}
+	}
+	for repo, history := range s.Deployed {
+		kept := slices.DeleteFunc(history, func(d Deployment) bool { return !images[d.ImageID] })
+		removed += len(history) - len(kept)
+		if len(kept) == 0 {
+			delete(s.Deployed, repo)
+		} else {
+			s.Deployed[repo] = kept
+		}
+	}
+	return removed
+}
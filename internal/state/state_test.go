@@ -0,0 +1,218 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadMissingFile(t *testing.T) {
+	s, err := Load(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if _, ok := s.LastRecreated("web"); ok {
+		t.Error("LastRecreated() found an entry in a fresh state")
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	when := time.Date(2026, time.June, 11, 10, 0, 0, 0, time.UTC)
+
+	s, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.RecordRecreated("web", when)
+	if err := s.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	got, ok := loaded.LastRecreated("web")
+	if !ok || !got.Equal(when) {
+		t.Errorf("LastRecreated(web) = %s, %v; want %s, true", got, ok, when)
+	}
+}
+
+func TestInMemoryState(t *testing.T) {
+	s, err := Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.RecordRecreated("web", time.Now())
+	if err := s.Save(); err != nil {
+		t.Errorf("Save() without a path error = %v, want nil", err)
+	}
+	if _, ok := s.LastRecreated("web"); !ok {
+		t.Error("LastRecreated(web) not found in in-memory state")
+	}
+}
+
+func TestLoadCorruptFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(path, []byte("{not json"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Error("Load() of a corrupt file error = nil, want error")
+	}
+}
+
+func TestRecordDeployed(t *testing.T) {
+	s, _ := Load("")
+	base := time.Date(2026, time.June, 11, 10, 0, 0, 0, time.UTC)
+
+	s.RecordDeployed("docker.io/library/nginx", "sha256:a", base)
+	s.RecordDeployed("docker.io/library/nginx", "sha256:c", base.Add(2*time.Hour))
+	s.RecordDeployed("docker.io/library/nginx", "sha256:b", base.Add(time.Hour))
+	// An older sighting of a known image does not move it back.
+	s.RecordDeployed("docker.io/library/nginx", "sha256:c", base)
+
+	var got []string
+	for _, d := range s.Deployments("docker.io/library/nginx") {
+		got = append(got, d.ImageID)
+	}
+	want := []string{"sha256:c", "sha256:b", "sha256:a"}
+	if len(got) != len(want) {
+		t.Fatalf("Deployments() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Deployments() = %v, want %v (newest first)", got, want)
+			break
+		}
+	}
+
+	s.ForgetDeployed("docker.io/library/nginx", "sha256:b")
+	if n := len(s.Deployments("docker.io/library/nginx")); n != 2 {
+		t.Errorf("after ForgetDeployed, %d deployments, want 2", n)
+	}
+}
+
+func TestNilStateDeployments(t *testing.T) {
+	var s *State
+	s.RecordDeployed("repo", "sha256:a", time.Now())
+	s.ForgetDeployed("repo", "sha256:a")
+	if d := s.Deployments("repo"); d != nil {
+		t.Errorf("Deployments() on nil state = %v, want nil", d)
+	}
+}
+
+func TestCanarySurvivesSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	when := time.Date(2026, time.June, 11, 10, 0, 0, 0, time.UTC)
+
+	s, _ := Load(path)
+	s.RecordCanary("app:web", Canary{Container: "app-web-1", ImageID: "sha256:new", Time: when})
+	if err := s.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, ok := loaded.Canary("app:web")
+	if !ok || c.Container != "app-web-1" || c.ImageID != "sha256:new" || !c.Time.Equal(when) {
+		t.Errorf("Canary(app:web) = %+v, %v", c, ok)
+	}
+
+	loaded.ClearCanary("app:web")
+	if _, ok := loaded.Canary("app:web"); ok {
+		t.Error("Canary(app:web) still found after ClearCanary")
+	}
+}
+
+func TestStagedSurvivesSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	when := time.Date(2026, time.June, 11, 3, 0, 0, 0, time.UTC)
+
+	s, _ := Load(path)
+	s.RecordStaged("nginx:latest", Staged{ImageID: "sha256:old", Time: when})
+	s.RecordStaged("nginx:latest", Staged{ImageID: "sha256:new", Time: when})
+	if err := s.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st, ok := loaded.StagedImage("nginx:latest")
+	if !ok || st.ImageID != "sha256:new" || !st.Time.Equal(when) {
+		t.Errorf("StagedImage(nginx:latest) = %+v, %v; want the later image", st, ok)
+	}
+
+	loaded.ClearStaged("nginx:latest")
+	if _, ok := loaded.StagedImage("nginx:latest"); ok {
+		t.Error("StagedImage(nginx:latest) still found after ClearStaged")
+	}
+
+	var none *State
+	if _, ok := none.StagedImage("nginx:latest"); ok {
+		t.Error("nil state reported a staged image")
+	}
+}
+
+func TestCompact(t *testing.T) {
+	s, _ := Load("")
+	now := time.Now()
+	s.RecordRecreated("web", now)
+	s.RecordRecreated("removed", now)
+	s.RecordCanary("app:web", Canary{Container: "web", ImageID: "sha256:a", Time: now})
+	s.RecordCanary("app:gone", Canary{Container: "gone-1", ImageID: "sha256:a", Time: now})
+	s.RecordDeployed("nginx", "sha256:a", now)
+	s.RecordDeployed("nginx", "sha256:pruned", now.Add(-time.Hour))
+	s.RecordDeployed("redis", "sha256:pruned-too", now)
+	s.RecordStaged("nginx:latest", Staged{ImageID: "sha256:a", Time: now})
+	s.RecordStaged("redis:latest", Staged{ImageID: "sha256:pruned", Time: now})
+	s.RecordSelfUpdate("web", SelfUpdate{ImageID: "sha256:b", Attempts: 1, Time: now})
+	s.RecordSelfUpdate("removed", SelfUpdate{ImageID: "sha256:b", Attempts: 1, Time: now})
+	s.RecordRestartLoop("web", "sha256:a")
+	s.RecordRestartLoop("removed", "sha256:a")
+
+	removed := s.Compact(map[string]bool{"web": true}, map[string]bool{"sha256:a": true})
+
+	if removed != 7 {
+		t.Errorf("Compact() removed %d entries, want 7", removed)
+	}
+	if !s.RestartLoopReported("web", "sha256:a") || s.RestartLoopReported("removed", "sha256:a") {
+		t.Error("restart loops not compacted to the existing containers")
+	}
+	if _, ok := s.SelfUpdateAttempts("web"); !ok {
+		t.Error("self-update attempts of an existing container were dropped")
+	}
+	if _, ok := s.SelfUpdateAttempts("removed"); ok {
+		t.Error("self-update attempts of a removed container were kept")
+	}
+	if _, ok := s.StagedImage("nginx:latest"); !ok {
+		t.Error("staged image that still exists was dropped")
+	}
+	if _, ok := s.StagedImage("redis:latest"); ok {
+		t.Error("staged image that no longer exists was kept")
+	}
+	if _, ok := s.LastRecreated("web"); !ok {
+		t.Error("recreate time of an existing container was dropped")
+	}
+	if _, ok := s.LastRecreated("removed"); ok {
+		t.Error("recreate time of a removed container was kept")
+	}
+	if _, ok := s.Canary("app:web"); !ok {
+		t.Error("canary of an existing container was dropped")
+	}
+	if _, ok := s.Canary("app:gone"); ok {
+		t.Error("canary of a removed container was kept")
+	}
+	if d := s.Deployments("nginx"); len(d) != 1 || d[0].ImageID != "sha256:a" {
+		t.Errorf("Deployments(nginx) = %v, want only sha256:a", d)
+	}
+	if _, ok := s.Deployed["redis"]; ok {
+		t.Error("repository without any remaining image was kept")
+	}
+}
@@ -0,0 +1,141 @@
+// Package status keeps an in-memory snapshot of repull's runs — when the
+// last one ran, how it went for every group, when the next is due — and
+// serves it as JSON (GET /status), e.g. for a homelab dashboard that should
+// not have to parse logs.
+package status
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Group is the status of one update group as of its last check.
+type Group struct {
+	// Image is the image reference the group runs.
+	Image string `json:"image"`
+	// Digest is the ID of the image the group runs after the check.
+	Digest string `json:"digest,omitempty"`
+	// LastAction is the result of the last check, e.g. "updated" or
+	// "up_to_date" (see updater.Result).
+	LastAction string    `json:"last_action"`
+	LastCheck  time.Time `json:"last_check"`
+	// LastError is the most recent error of the group and when it
+	// happened. It is kept after later successful checks, so a flapping
+	// group stays visible; compare LastErrorTime with LastCheck.
+	LastError     string    `json:"last_error,omitempty"`
+	LastErrorTime time.Time `json:"last_error_time,omitzero"`
+}
+
+// Snapshot is the status GET /status returns.
+type Snapshot struct {
+	// Running is true while a run is in progress.
+	Running bool `json:"running"`
+	// LastRun is when the last finished run started, and LastDuration how
+	// long it took, in seconds. LastRunError is its error, if any.
+	LastRun      time.Time `json:"last_run,omitzero"`
+	LastDuration float64   `json:"last_duration_seconds"`
+	LastRunError string    `json:"last_run_error,omitempty"`
+	// NextRun is when the next scheduled run is due; zero when runs are
+	// only triggered by webhooks.
+	NextRun time.Time `json:"next_run,omitzero"`
+	// Groups holds every group checked since startup, by group key.
+	Groups map[string]Group `json:"groups"`
+}
+
+// Tracker maintains the snapshot. Its methods are safe for concurrent use,
+// and a nil *Tracker ignores everything, so callers need no nil checks.
+type Tracker struct {
+	mu      sync.Mutex
+	snap    Snapshot
+	started time.Time
+}
+
+// NewTracker returns a tracker with an empty snapshot.
+func NewTracker() *Tracker {
+	return &Tracker{snap: Snapshot{Groups: make(map[string]Group)}}
+}
+
+// RunStarted marks a run as in progress.
+func (t *Tracker) RunStarted() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.started = time.Now()
+	t.snap.Running = true
+}
+
+// RunFinished records the end of the run RunStarted began; err is its
+// error, or nil.
+func (t *Tracker) RunFinished(err error) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.snap.Running = false
+	t.snap.LastRun = t.started
+	t.snap.LastDuration = time.Since(t.started).Seconds()
+	t.snap.LastRunError = ""
+	if err != nil {
+		t.snap.LastRunError = err.Error()
+	}
+}
+
+// GroupChecked records the outcome of checking one group. errMsg is the
+// group's error, or empty if it had none.
+func (t *Tracker) GroupChecked(groupKey, image, digest, action, errMsg string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	g := t.snap.Groups[groupKey]
+	g.Image = image
+	g.Digest = digest
+	g.LastAction = action
+	g.LastCheck = time.Now()
+	if errMsg != "" {
+		g.LastError = errMsg
+		g.LastErrorTime = g.LastCheck
+	}
+	t.snap.Groups[groupKey] = g
+}
+
+// SetNextRun records when the next scheduled run is due.
+func (t *Tracker) SetNextRun(next time.Time) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.snap.NextRun = next
+}
+
+// Snapshot returns a copy of the current status.
+func (t *Tracker) Snapshot() Snapshot {
+	if t == nil {
+		return Snapshot{}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	snap := t.snap
+	snap.Groups = make(map[string]Group, len(t.snap.Groups))
+	for k, g := range t.snap.Groups {
+		snap.Groups[k] = g
+	}
+	return snap
+}
+
+// Handler serves the snapshot as JSON on GET /status.
+func (t *Tracker) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(t.Snapshot())
+	})
+	return mux
+}
@@ -0,0 +1,95 @@
+package status
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTracker(t *testing.T) {
+	tr := NewTracker()
+	tr.RunStarted()
+	if !tr.Snapshot().Running {
+		t.Error("Running = false during a run")
+	}
+	tr.GroupChecked("app:web", "nginx:latest", "sha256:new", "updated", "")
+	tr.GroupChecked("app:db", "postgres:16", "sha256:db", "failed", "pull access denied")
+	tr.RunFinished(errors.New("1 group failed"))
+
+	// A later successful check keeps the last error for the dashboard.
+	tr.RunStarted()
+	tr.GroupChecked("app:db", "postgres:16", "sha256:db", "up_to_date", "")
+	tr.RunFinished(nil)
+	next := time.Now().Add(time.Hour)
+	tr.SetNextRun(next)
+
+	snap := tr.Snapshot()
+	if snap.Running || snap.LastRun.IsZero() || snap.LastRunError != "" || !snap.NextRun.Equal(next) {
+		t.Errorf("snapshot = %+v, want a finished run without error and the next run", snap)
+	}
+	web := snap.Groups["app:web"]
+	if web.Image != "nginx:latest" || web.Digest != "sha256:new" || web.LastAction != "updated" || web.LastError != "" {
+		t.Errorf("app:web = %+v", web)
+	}
+	db := snap.Groups["app:db"]
+	if db.LastAction != "up_to_date" || db.LastError != "pull access denied" || db.LastErrorTime.After(db.LastCheck) || db.LastErrorTime.IsZero() {
+		t.Errorf("app:db = %+v, want up_to_date with the earlier error kept", db)
+	}
+
+	// The snapshot is a copy.
+	snap.Groups["app:web"] = Group{}
+	if tr.Snapshot().Groups["app:web"].Image == "" {
+		t.Error("changing a snapshot changed the tracker")
+	}
+}
+
+func TestNilTracker(t *testing.T) {
+	var tr *Tracker
+	tr.RunStarted()
+	tr.GroupChecked("app:web", "nginx:latest", "sha256:new", "updated", "")
+	tr.RunFinished(nil)
+	tr.SetNextRun(time.Now())
+	if snap := tr.Snapshot(); snap.Groups != nil {
+		t.Errorf("nil tracker snapshot = %+v, want empty", snap)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	tr := NewTracker()
+	tr.GroupChecked("app:web", "nginx:latest", "sha256:new", "updated", "")
+	h := tr.Handler()
+
+	tests := []struct {
+		method, path string
+		wantStatus   int
+	}{
+		{http.MethodGet, "/status", http.StatusOK},
+		{http.MethodPost, "/status", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s %s = %d, want %d", tt.method, tt.path, rec.Code, tt.wantStatus)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+	var got map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("body = %q: %v", rec.Body, err)
+	}
+	if _, ok := got["next_run"]; ok {
+		t.Errorf("body = %s, want no next_run before one is scheduled", rec.Body)
+	}
+	groups, _ := got["groups"].(map[string]any)
+	web, _ := groups["app:web"].(map[string]any)
+	if web["digest"] != "sha256:new" || web["last_action"] != "updated" {
+		t.Errorf("body = %s, want app:web updated to sha256:new", rec.Body)
+	}
+}
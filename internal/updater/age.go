@@ -0,0 +1,43 @@
+package updater
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// containerAge returns how long c has been running, from State.StartedAt.
+// ok is false when the start time is missing or unparsable.
+func containerAge(c container.InspectResponse, now time.Time) (age time.Duration, ok bool) {
+	if c.ContainerJSONBase == nil || c.State == nil {
+		return 0, false
+	}
+	started, err := time.Parse(time.RFC3339Nano, c.State.StartedAt)
+	if err != nil || started.IsZero() {
+		return 0, false
+	}
+	return now.Sub(started), true
+}
+
+// deferYoung drops containers that have been running for less than minAge,
+// logging each: --min-container-age avoids churning containers that were only
+// just (re)started. A container with an unknown start time is kept. A minAge
+// of 0 keeps everything.
+func deferYoung(containers []container.InspectResponse, minAge time.Duration, now time.Time) []container.InspectResponse {
+	if minAge <= 0 {
+		return containers
+	}
+	var old []container.InspectResponse
+	for _, c := range containers {
+		age, ok := containerAge(c, now)
+		if ok && age < minAge {
+			log.Printf("[INFO] Deferring %s: running for %s, less than --min-container-age %s",
+				sanitize(strings.TrimPrefix(c.Name, "/")), age.Round(time.Second), minAge)
+			continue
+		}
+		old = append(old, c)
+	}
+	return old
+}
@@ -0,0 +1,59 @@
+package updater
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+func TestDeferYoung(t *testing.T) {
+	now := time.Date(2026, time.June, 11, 12, 0, 0, 0, time.UTC)
+	started := func(name string, ago time.Duration) container.InspectResponse {
+		return container.InspectResponse{
+			ContainerJSONBase: &container.ContainerJSONBase{
+				Name:  "/" + name,
+				State: &container.State{StartedAt: now.Add(-ago).Format(time.RFC3339Nano)},
+			},
+		}
+	}
+	unknown := container.InspectResponse{
+		ContainerJSONBase: &container.ContainerJSONBase{Name: "/unknown", State: &container.State{StartedAt: "0001-01-01T00:00:00Z"}},
+	}
+
+	containers := []container.InspectResponse{
+		started("fresh", time.Hour),
+		started("week-old", 8*24*time.Hour),
+		started("exactly", 168*time.Hour),
+		unknown,
+	}
+
+	tests := []struct {
+		name   string
+		minAge time.Duration
+		want   []string
+	}{
+		{name: "disabled", minAge: 0, want: []string{"/fresh", "/week-old", "/exactly", "/unknown"}},
+		{name: "one week", minAge: 168 * time.Hour, want: []string{"/week-old", "/exactly", "/unknown"}},
+		{name: "one minute", minAge: time.Minute, want: []string{"/fresh", "/week-old", "/exactly", "/unknown"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := deferYoung(containers, tt.minAge, now)
+			var names []string
+			for _, c := range got {
+				names = append(names, c.Name)
+			}
+			if len(names) != len(tt.want) {
+				t.Fatalf("deferYoung() = %v, want %v", names, tt.want)
+			}
+			for i := range names {
+				if names[i] != tt.want[i] {
+					t.Errorf("deferYoung() = %v, want %v", names, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
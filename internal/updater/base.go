@@ -0,0 +1,76 @@
+package updater
+
+import (
+	"context"
+	"log"
+	"slices"
+	"sync"
+
+	"github.com/docker/docker/client"
+	"github.com/fanuelsen/repull/internal/docker"
+)
+
+const (
+	// BaseNameLabel and BaseDigestLabel are the OCI annotations build tools
+	// set on an image to record the base image it was built FROM.
+	BaseNameLabel   = "org.opencontainers.image.base.name"
+	BaseDigestLabel = "org.opencontainers.image.base.digest"
+)
+
+// staleBaseWarned remembers which image/base combinations were already
+// reported, so a loop does not notify about the same stale image every run.
+var (
+	staleBaseMu     sync.Mutex
+	staleBaseWarned = make(map[string]bool)
+)
+
+// baseImageStale reports whether the base digest recorded in labels is no
+// longer among the digests the base image currently resolves to. Images
+// without both labels are never stale.
+func baseImageStale(labels map[string]string, current []string) bool {
+	name, recorded := labels[BaseNameLabel], labels[BaseDigestLabel]
+	if name == "" || recorded == "" || len(current) == 0 {
+		return false
+	}
+	return !slices.Contains(current, recorded)
+}
+
+// checkBaseImage warns, once per image, when imageID was built on a base
+// image that has moved on since (see BaseNameLabel). Pulling and recreating
+// cannot fix that — the registry still serves the same derived image — so
+// repull only reports that the image needs a rebuild. Lookup failures are
+// logged and otherwise ignored.
+func checkBaseImage(ctx context.Context, cli *client.Client, opts Options, groupKey, imageName, imageID string) {
+	inspect, err := cli.ImageInspect(ctx, imageID)
+	if err != nil || inspect.Config == nil {
+		return
+	}
+	labels := inspect.Config.Labels
+	base := labels[BaseNameLabel]
+	if base == "" || labels[BaseDigestLabel] == "" {
+		return
+	}
+
+	auth, _ := docker.CredentialsFor(base)
+	current, err := opts.Registry.Digests(ctx, base, auth)
+	if err != nil {
+		log.Printf("[WARN] Could not check base image %s of %s: %v", sanitize(base), sanitize(imageName), err)
+		return
+	}
+	if !baseImageStale(labels, current) {
+		return
+	}
+
+	key := imageID + " " + current[0]
+	staleBaseMu.Lock()
+	warned := staleBaseWarned[key]
+	staleBaseWarned[key] = true
+	staleBaseMu.Unlock()
+	if warned {
+		return
+	}
+
+	log.Printf("[WARN] %s: %s was built on %s@%s, which now resolves to %s; the image needs a rebuild",
+		sanitize(groupKey), sanitize(imageName), sanitize(base), truncateDigest(labels[BaseDigestLabel]), truncateDigest(current[0]))
+	opts.notifier().SendStaleBase(sanitize(groupKey), sanitize(imageName), sanitize(base), truncateDigest(labels[BaseDigestLabel]), truncateDigest(current[0]))
+}
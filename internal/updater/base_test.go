@@ -0,0 +1,32 @@
+package updater
+
+import "testing"
+
+func TestBaseImageStale(t *testing.T) {
+	labels := map[string]string{
+		BaseNameLabel:   "docker.io/library/alpine:3.20",
+		BaseDigestLabel: "sha256:index",
+	}
+
+	tests := []struct {
+		name    string
+		labels  map[string]string
+		current []string
+		want    bool
+	}{
+		{name: "unchanged index", labels: labels, current: []string{"sha256:index", "sha256:amd", "sha256:arm"}, want: false},
+		{name: "recorded platform manifest", labels: map[string]string{BaseNameLabel: "alpine:3.20", BaseDigestLabel: "sha256:amd"}, current: []string{"sha256:index", "sha256:amd"}, want: false},
+		{name: "base moved", labels: labels, current: []string{"sha256:newindex", "sha256:newamd"}, want: true},
+		{name: "no labels", labels: nil, current: []string{"sha256:newindex"}, want: false},
+		{name: "name only", labels: map[string]string{BaseNameLabel: "alpine:3.20"}, current: []string{"sha256:newindex"}, want: false},
+		{name: "nothing resolved", labels: labels, current: nil, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := baseImageStale(tt.labels, tt.current); got != tt.want {
+				t.Errorf("baseImageStale() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package updater
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/fanuelsen/repull/internal/state"
+)
+
+// CanaryLabel, set to "true", makes an update recreate a single container of
+// the group first; the rest keep the old image until `repull --promote`.
+const CanaryLabel = "io.repull.canary"
+
+// isCanaryGroup reports whether any container of the group opted into
+// canary rollouts.
+func isCanaryGroup(containers []container.InspectResponse) bool {
+	for _, c := range containers {
+		if c.Config != nil && c.Config.Labels[CanaryLabel] == "true" {
+			return true
+		}
+	}
+	return false
+}
+
+// canaryStep narrows outdated to what a canary group may recreate now.
+// Without a canary for latestID it picks one container — the previous canary
+// if it is outdated again — and reports canary=true. While that canary
+// awaits promotion it returns nothing. On promote it returns every outdated
+// container, but only if the canary runs latestID: an image pushed since was
+// never tried. Groups without the label, and single-container groups, which
+// have nothing to hold back, are returned unchanged.
+func canaryStep(groupKey string, containers, outdated []container.InspectResponse, latestID string, promote bool, st *state.State) (recreate []container.InspectResponse, canary bool, err error) {
+	if !isCanaryGroup(containers) {
+		return outdated, false, nil
+	}
+	rec, pending := st.Canary(groupKey)
+
+	if promote {
+		if !pending {
+			return nil, false, fmt.Errorf("no canary of %s is waiting to be promoted", sanitize(groupKey))
+		}
+		if rec.ImageID != latestID {
+			return nil, false, fmt.Errorf("canary %s runs %s, but the image now resolves to %s; not promoting an untried image (the next run deploys a new canary)",
+				sanitize(rec.Container), truncateDigest(rec.ImageID), truncateDigest(latestID))
+		}
+		return outdated, false, nil
+	}
+
+	if pending && rec.ImageID == latestID {
+		log.Printf("[INFO] Canary %s of %s runs %s; holding back %d container(s) until repull --promote %s",
+			sanitize(rec.Container), sanitize(groupKey), truncateDigest(latestID), len(outdated), sanitize(groupKey))
+		return nil, false, nil
+	}
+	if len(containers) < 2 {
+		return outdated, false, nil
+	}
+
+	pick := outdated[0]
+	if pending {
+		for _, c := range outdated {
+			if strings.TrimPrefix(c.Name, "/") == rec.Container {
+				pick = c
+				break
+			}
+		}
+	}
+	return []container.InspectResponse{pick}, true, nil
+}
@@ -0,0 +1,68 @@
+package updater
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/fanuelsen/repull/internal/state"
+)
+
+func TestCanaryStep(t *testing.T) {
+	replica := func(name string, labels map[string]string) container.InspectResponse {
+		return container.InspectResponse{
+			ContainerJSONBase: &container.ContainerJSONBase{Name: "/" + name, Image: "sha256:old"},
+			Config:            &container.Config{Image: "web:latest", Labels: labels},
+		}
+	}
+	canaryLabels := map[string]string{CanaryLabel: "true"}
+	group := []container.InspectResponse{replica("web-1", canaryLabels), replica("web-2", canaryLabels), replica("web-3", canaryLabels)}
+	plain := []container.InspectResponse{replica("web-1", nil), replica("web-2", nil)}
+
+	tests := []struct {
+		name       string
+		containers []container.InspectResponse
+		pending    *state.Canary
+		promote    bool
+		want       []string
+		wantCanary bool
+		wantErr    string
+	}{
+		{name: "no label", containers: plain, want: []string{"/web-1", "/web-2"}},
+		{name: "single container", containers: group[:1], want: []string{"/web-1"}},
+		{name: "new canary", containers: group, want: []string{"/web-1"}, wantCanary: true},
+		{name: "waiting", containers: group, pending: &state.Canary{Container: "web-1", ImageID: "sha256:new"}},
+		{name: "newer image reuses canary", containers: group, pending: &state.Canary{Container: "web-2", ImageID: "sha256:older"}, want: []string{"/web-2"}, wantCanary: true},
+		{name: "promote", containers: group, pending: &state.Canary{Container: "web-1", ImageID: "sha256:new"}, promote: true, want: []string{"/web-1", "/web-2", "/web-3"}},
+		{name: "promote without canary", containers: group, promote: true, wantErr: "no canary"},
+		{name: "promote untried image", containers: group, pending: &state.Canary{Container: "web-1", ImageID: "sha256:older"}, promote: true, wantErr: "untried"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st, _ := state.Load("")
+			if tt.pending != nil {
+				tt.pending.Time = time.Now()
+				st.RecordCanary("app:web", *tt.pending)
+			}
+			got, canary, err := canaryStep("app:web", tt.containers, tt.containers, "sha256:new", tt.promote, st)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("canaryStep() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("canaryStep() error = %v", err)
+			}
+			var names []string
+			for _, c := range got {
+				names = append(names, c.Name)
+			}
+			if strings.Join(names, ",") != strings.Join(tt.want, ",") || canary != tt.wantCanary {
+				t.Errorf("canaryStep() = %v, canary=%v; want %v, canary=%v", names, canary, tt.want, tt.wantCanary)
+			}
+		})
+	}
+}
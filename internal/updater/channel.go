@@ -0,0 +1,120 @@
+package updater
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/distribution/reference"
+	"github.com/docker/docker/api/types/container"
+)
+
+// Channels maps image repositories, fully qualified (e.g.
+// "docker.io/myapp/web"), to the tag their containers must run. Set through
+// --channel-file, it turns a tag change in a reviewed file into a rollout.
+type Channels map[string]string
+
+// ReadChannels reads a channel file: a flat YAML mapping of repository to
+// tag, one per line, such as
+//
+//	# approved releases
+//	myapp/web: 1.4.2
+//	ghcr.io/acme/api: "2.0"
+//
+// Repositories are written as for docker pull, without a tag.
+func ReadChannels(path string) (Channels, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	channels := make(Channels)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line, _, _ := strings.Cut(scanner.Text(), " #")
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		repo, tag, ok := strings.Cut(line, ": ")
+		if !ok {
+			return nil, fmt.Errorf("line %d: want \"repository: tag\"", n)
+		}
+		repo, tag = strings.TrimSpace(repo), unquote(strings.TrimSpace(tag))
+
+		named, err := reference.ParseNormalizedNamed(repo)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid repository %q: %w", n, repo, err)
+		}
+		if !reference.IsNameOnly(named) {
+			return nil, fmt.Errorf("line %d: repository %q must not carry a tag or digest", n, repo)
+		}
+		if _, err := reference.WithTag(named, tag); err != nil {
+			return nil, fmt.Errorf("line %d: invalid tag %q: %w", n, tag, err)
+		}
+		if _, dup := channels[named.Name()]; dup {
+			return nil, fmt.Errorf("line %d: repository %q listed twice", n, repo)
+		}
+		channels[named.Name()] = tag
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return channels, nil
+}
+
+// unquote strips one pair of matching YAML quotes.
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// image returns the reference imageName's repository is pinned to, e.g.
+// "myapp/web:1.4.2" for "myapp/web:1.4.1", or ok=false if the repository
+// has no channel. Tags and digests of imageName play no part.
+func (c Channels) image(imageName string) (string, bool) {
+	if len(c) == 0 {
+		return "", false
+	}
+	named, err := reference.ParseNormalizedNamed(imageName)
+	if err != nil {
+		return "", false
+	}
+	tag, ok := c[named.Name()]
+	if !ok {
+		return "", false
+	}
+	tagged, err := reference.WithTag(reference.TrimNamed(named), tag)
+	if err != nil {
+		return "", false
+	}
+	return reference.FamiliarString(tagged), true
+}
+
+// targetImage returns the image reference to pull for a group: the channel
+// tag if its repository has one, otherwise the tracked image (see
+// trackedImage).
+func targetImage(c container.InspectResponse, channels Channels) (imageName string, channeled, ok bool) {
+	if imageName, ok := channels.image(c.Config.Image); ok {
+		return imageName, true, true
+	}
+	imageName, ok = trackedImage(c)
+	return imageName, false, ok
+}
+
+// filterOffChannel returns the containers that are outdated (see
+// filterOutdatedContainers) or run the right image under another reference
+// than imageName, e.g. a tag that was re-pointed to the channel's release.
+func filterOffChannel(containers []container.InspectResponse, imageName, latestID string) []container.InspectResponse {
+	want := imageRefs(imageName)
+	var outdated []container.InspectResponse
+	for _, c := range containers {
+		if c.Image != latestID || !imageIn(c.Config.Image, want) {
+			outdated = append(outdated, c)
+		}
+	}
+	return outdated
+}
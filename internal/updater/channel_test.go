@@ -0,0 +1,124 @@
+package updater
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+func writeChannels(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "channels.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadChannels(t *testing.T) {
+	path := writeChannels(t, `# approved releases
+myapp/web: 1.4.2
+ghcr.io/acme/api: "2.0"   # pinned for the migration
+
+nginx: '1.27'
+`)
+	got, err := ReadChannels(path)
+	if err != nil {
+		t.Fatalf("ReadChannels() error = %v", err)
+	}
+	want := Channels{
+		"docker.io/myapp/web":     "1.4.2",
+		"ghcr.io/acme/api":        "2.0",
+		"docker.io/library/nginx": "1.27",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("ReadChannels() = %v, want %v", got, want)
+	}
+	for repo, tag := range want {
+		if got[repo] != tag {
+			t.Errorf("ReadChannels()[%q] = %q, want %q", repo, got[repo], tag)
+		}
+	}
+}
+
+func TestReadChannelsErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"no separator", "myapp/web 1.4.2\n"},
+		{"tagged repository", "myapp/web:1.4: 1.4.2\n"},
+		{"invalid tag", "myapp/web: not/a/tag\n"},
+		{"duplicate", "nginx: 1.27\ndocker.io/library/nginx: 1.26\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ReadChannels(writeChannels(t, tt.content)); err == nil {
+				t.Error("ReadChannels() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestTargetImage(t *testing.T) {
+	channels := Channels{"docker.io/myapp/web": "1.4.2"}
+	digest := "sha256:4b1d4ef4b8f0a9e0d3d9a7c3c6e2e9f0b4e6c5d1a3f2b7c8d9e0a1b2c3d4e5f6"
+
+	tests := []struct {
+		image         string
+		want          string
+		wantChanneled bool
+		wantOK        bool
+	}{
+		{"myapp/web:1.4.1", "myapp/web:1.4.2", true, true},
+		{"docker.io/myapp/web", "myapp/web:1.4.2", true, true},
+		{"myapp/web@" + digest, "myapp/web:1.4.2", true, true},
+		{"myapp/api:1.0", "myapp/api:1.0", false, true},
+		{"nginx@" + digest, "nginx@" + digest, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			c := container.InspectResponse{Config: &container.Config{Image: tt.image}}
+			got, channeled, ok := targetImage(c, channels)
+			if got != tt.want || channeled != tt.wantChanneled || ok != tt.wantOK {
+				t.Errorf("targetImage(%q) = %q, %v, %v; want %q, %v, %v", tt.image, got, channeled, ok, tt.want, tt.wantChanneled, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestFilterOffChannel(t *testing.T) {
+	running := func(name, image, id string) container.InspectResponse {
+		return container.InspectResponse{
+			ContainerJSONBase: &container.ContainerJSONBase{Name: "/" + name, Image: id},
+			Config:            &container.Config{Image: image},
+		}
+	}
+	containers := []container.InspectResponse{
+		running("current", "myapp/web:1.4.2", "sha256:new"),
+		running("qualified", "docker.io/myapp/web:1.4.2", "sha256:new"),
+		running("old-tag", "myapp/web:1.4.1", "sha256:old"),
+		// Same image ID, but still referencing the previous tag.
+		running("retagged", "myapp/web:1.4.1", "sha256:new"),
+		running("stale", "myapp/web:1.4.2", "sha256:old"),
+	}
+
+	got := filterOffChannel(containers, "myapp/web:1.4.2", "sha256:new")
+	var names []string
+	for _, c := range got {
+		names = append(names, c.Name)
+	}
+	want := []string{"/old-tag", "/retagged", "/stale"}
+	if len(names) != len(want) {
+		t.Fatalf("filterOffChannel() = %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Errorf("filterOffChannel()[%d] = %s, want %s", i, names[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package updater
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// TestUpdateGroupsCircuitBreaker verifies that the run halts once
+// MaxConsecutiveFailures groups failed in a row, leaving the later groups
+// untouched, and that a success in between resets the count.
+func TestUpdateGroupsCircuitBreaker(t *testing.T) {
+	outdated := func(id, image string) container.InspectResponse {
+		return container.InspectResponse{
+			ContainerJSONBase: &container.ContainerJSONBase{ID: id, Name: "/" + id, Image: "sha256:old", HostConfig: &container.HostConfig{}},
+			Config:            &container.Config{Image: image},
+		}
+	}
+
+	tests := []struct {
+		name        string
+		images      map[string]string // group key -> image; groups run in key order
+		wantPulls   int
+		wantTripped bool
+	}{
+		{
+			name:        "trips after the threshold",
+			images:      map[string]string{"a": "bad:1", "b": "bad:2", "c": "bad:3", "d": "bad:4"},
+			wantPulls:   2,
+			wantTripped: true,
+		},
+		{
+			name:      "success resets the count",
+			images:    map[string]string{"a": "bad:1", "b": "good:1", "c": "bad:2", "d": "good:2"},
+			wantPulls: 4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cli, calls := fakeDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				switch {
+				case strings.HasSuffix(r.URL.Path, "/images/create"):
+					if strings.HasSuffix(r.URL.Query().Get("fromImage"), "/bad") {
+						http.Error(w, `{"message":"registry unavailable"}`, http.StatusInternalServerError)
+						return
+					}
+					w.WriteHeader(http.StatusOK)
+				case strings.HasSuffix(r.URL.Path, "/json"):
+					// The pulled image is the one already running.
+					w.Write([]byte(`{"Id":"sha256:old"}`))
+				default:
+					w.WriteHeader(http.StatusOK)
+				}
+			})
+
+			groups := make(map[string][]container.InspectResponse)
+			for key, image := range tt.images {
+				groups[key] = []container.InspectResponse{outdated(key, image)}
+			}
+
+			err := UpdateGroups(t.Context(), cli, groups, Options{MaxConsecutiveFailures: 2})
+			if got := errors.Is(err, ErrCircuitOpen); got != tt.wantTripped {
+				t.Errorf("errors.Is(err, ErrCircuitOpen) = %v, want %v (err = %v)", got, tt.wantTripped, err)
+			}
+			pulls := 0
+			for _, c := range *calls {
+				if c == "POST /images/create" {
+					pulls++
+				}
+			}
+			if pulls != tt.wantPulls {
+				t.Errorf("pulled %d image(s), want %d: %v", pulls, tt.wantPulls, *calls)
+			}
+		})
+	}
+}
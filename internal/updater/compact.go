@@ -0,0 +1,34 @@
+package updater
+
+import (
+	"context"
+	"log"
+
+	"github.com/docker/docker/client"
+	"github.com/fanuelsen/repull/internal/docker"
+	"github.com/fanuelsen/repull/internal/state"
+)
+
+// compactState drops state entries for containers and images that no longer
+// exist on the host (see state.State.Compact), so a long-running instance's
+// state file does not grow with every container it ever saw. A failed
+// listing skips compaction for this run: dropping entries on incomplete
+// information would lose throttle times and canaries that still matter.
+func compactState(ctx context.Context, cli *client.Client, st *state.State) {
+	if st == nil {
+		return
+	}
+	containers, err := docker.ContainerNames(ctx, cli)
+	if err != nil {
+		log.Printf("[WARN] Skipping state compaction: listing containers failed: %v", err)
+		return
+	}
+	images, err := docker.LocalImageIDs(ctx, cli)
+	if err != nil {
+		log.Printf("[WARN] Skipping state compaction: listing images failed: %v", err)
+		return
+	}
+	if removed := st.Compact(containers, images); removed > 0 {
+		log.Printf("[INFO] Removed %d state entry(ies) for containers and images that no longer exist", removed)
+	}
+}
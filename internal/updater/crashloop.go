@@ -0,0 +1,62 @@
+package updater
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/fanuelsen/repull/internal/notify"
+	"github.com/fanuelsen/repull/internal/state"
+)
+
+// restartLoopWindow is how recent a container's last start must be for its
+// restart count to indicate a loop. RestartCount only resets when a container
+// is recreated, so a long-running container that restarted a few times over
+// months is not looping.
+const restartLoopWindow = 10 * time.Minute
+
+// isRestartLooping reports whether c looks stuck in a restart loop: Docker has
+// restarted it at least threshold times and its current run started within
+// restartLoopWindow. A threshold of 0 disables the check.
+func isRestartLooping(c container.InspectResponse, threshold int, now time.Time) bool {
+	if threshold <= 0 || c.ContainerJSONBase == nil || c.RestartCount < threshold {
+		return false
+	}
+	if c.State == nil {
+		return false
+	}
+	if c.State.Restarting {
+		return true
+	}
+	started, err := time.Parse(time.RFC3339Nano, c.State.StartedAt)
+	if err != nil {
+		return false
+	}
+	return now.Sub(started) < restartLoopWindow
+}
+
+// skipRestartLooping drops restart-looping containers from an update:
+// recreating a crashing container on a new image can make things worse and
+// hide the real problem. Each is logged on every run but notified only once
+// per image, tracked in st; a container seen running steadily again is
+// forgotten, so a later loop is notified anew.
+func skipRestartLooping(groupKey string, containers []container.InspectResponse, threshold int, notifier notify.Reporter, st *state.State, now time.Time) []container.InspectResponse {
+	var healthy []container.InspectResponse
+	for _, c := range containers {
+		name := strings.TrimPrefix(c.Name, "/")
+		if !isRestartLooping(c, threshold, now) {
+			st.ClearRestartLoop(name)
+			healthy = append(healthy, c)
+			continue
+		}
+		msg := fmt.Sprintf("Skipped %s: restart loop detected (%d restarts), manual intervention needed", sanitize(name), c.RestartCount)
+		log.Printf("[WARN] %s: %s", sanitize(groupKey), msg)
+		if !st.RestartLoopReported(name, c.Image) {
+			notifier.SendError(sanitize(groupKey), msg)
+			st.RecordRestartLoop(name, c.Image)
+		}
+	}
+	return healthy
+}
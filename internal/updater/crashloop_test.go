@@ -0,0 +1,91 @@
+package updater
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/fanuelsen/repull/internal/notify"
+	"github.com/fanuelsen/repull/internal/state"
+)
+
+func TestIsRestartLooping(t *testing.T) {
+	now := time.Date(2026, time.June, 11, 12, 0, 0, 0, time.UTC)
+	c := func(restarts int, startedAgo time.Duration, restarting bool) container.InspectResponse {
+		return container.InspectResponse{
+			ContainerJSONBase: &container.ContainerJSONBase{
+				RestartCount: restarts,
+				State: &container.State{
+					Restarting: restarting,
+					StartedAt:  now.Add(-startedAgo).Format(time.RFC3339Nano),
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		c         container.InspectResponse
+		threshold int
+		want      bool
+	}{
+		{name: "healthy", c: c(0, time.Hour, false), threshold: 5, want: false},
+		{name: "many restarts, just started", c: c(12, time.Minute, false), threshold: 5, want: true},
+		{name: "at threshold", c: c(5, time.Minute, false), threshold: 5, want: true},
+		{name: "below threshold", c: c(4, time.Minute, false), threshold: 5, want: false},
+		{name: "old restarts, stable since", c: c(12, 48*time.Hour, false), threshold: 5, want: false},
+		{name: "currently restarting", c: c(6, 48*time.Hour, true), threshold: 5, want: true},
+		{name: "check disabled", c: c(50, time.Minute, false), threshold: 0, want: false},
+		{name: "no state", c: container.InspectResponse{ContainerJSONBase: &container.ContainerJSONBase{RestartCount: 9}}, threshold: 5, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRestartLooping(tt.c, tt.threshold, now); got != tt.want {
+				t.Errorf("isRestartLooping() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSkipRestartLoopingNotifiesOnce verifies a looping container is
+// reported once per image rather than on every run, and again once it has
+// recovered and starts looping anew.
+func TestSkipRestartLoopingNotifiesOnce(t *testing.T) {
+	now := time.Date(2026, time.June, 11, 12, 0, 0, 0, time.UTC)
+	web := func(restarting bool) container.InspectResponse {
+		return container.InspectResponse{ContainerJSONBase: &container.ContainerJSONBase{
+			Name: "/web", Image: "sha256:old", RestartCount: 7,
+			State: &container.State{Restarting: restarting, StartedAt: now.Add(-time.Hour).Format(time.RFC3339Nano)},
+		}}
+	}
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	file, err := notify.NewFileNotifier(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	notifier := (*notify.Notifier)(nil).WithFile(file)
+	st, _ := state.Load("")
+	notified := func() int {
+		data, _ := os.ReadFile(path)
+		return strings.Count(string(data), "\n")
+	}
+
+	for run := range 3 {
+		if got := skipRestartLooping("app:web", []container.InspectResponse{web(true)}, 5, notifier, st, now); len(got) != 0 {
+			t.Fatalf("run %d kept the looping container", run+1)
+		}
+	}
+	if n := notified(); n != 1 {
+		t.Errorf("three runs sent %d notification(s), want 1", n)
+	}
+
+	skipRestartLooping("app:web", []container.InspectResponse{web(false)}, 5, notifier, st, now)
+	skipRestartLooping("app:web", []container.InspectResponse{web(true)}, 5, notifier, st, now)
+	if n := notified(); n != 2 {
+		t.Errorf("a new loop after recovering sent %d notification(s) in total, want 2", n)
+	}
+}
@@ -0,0 +1,34 @@
+package updater
+
+import (
+	"fmt"
+	"log"
+)
+
+// freeSpace returns the bytes available on the filesystem holding path
+// (see diskFree); a variable so tests can fake it.
+var freeSpace = diskFree
+
+// enoughDisk reports whether the filesystem holding the Docker data root
+// has at least opts.MinFreeDisk bytes free, for --min-free-disk: a pull
+// that fills the disk can wedge the whole host. It is true when the check
+// is disabled or the free space cannot be read.
+func enoughDisk(groupKey string, opts Options) bool {
+	if opts.MinFreeDisk <= 0 || opts.dataRoot == "" {
+		return true
+	}
+	free, err := freeSpace(opts.dataRoot)
+	if err != nil {
+		if opts.Debug {
+			log.Printf("[DEBUG] Could not read free space of %s, pulling anyway: %v", sanitize(opts.dataRoot), err)
+		}
+		return true
+	}
+	if free >= opts.MinFreeDisk {
+		return true
+	}
+	msg := fmt.Sprintf("Skipped: only %s free on %s, below the %s minimum", formatSize(free), sanitize(opts.dataRoot), formatSize(opts.MinFreeDisk))
+	log.Printf("[WARN] %s: %s", sanitize(groupKey), msg)
+	opts.notifier().SendError(sanitize(groupKey), msg)
+	return false
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package updater
+
+import "errors"
+
+// diskFree is only implemented on Linux; elsewhere --min-free-disk is a
+// no-op.
+func diskFree(path string) (int64, error) {
+	return 0, errors.New("free disk space is only checked on Linux")
+}
@@ -0,0 +1,46 @@
+package updater
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEnoughDisk(t *testing.T) {
+	const gb = 1_000_000_000
+
+	tests := []struct {
+		name     string
+		min      int64
+		dataRoot string
+		free     int64
+		err      error
+		want     bool
+	}{
+		{"disabled", 0, "/var/lib/docker", 1, nil, true},
+		{"plenty free", 2 * gb, "/var/lib/docker", 50 * gb, nil, true},
+		{"exactly the minimum", 2 * gb, "/var/lib/docker", 2 * gb, nil, true},
+		{"below the minimum", 2 * gb, "/var/lib/docker", 1 * gb, nil, false},
+		{"unknown data root", 2 * gb, "", 1 * gb, nil, true},
+		{"unreadable", 2 * gb, "/var/lib/docker", 0, errors.New("no such file or directory"), true},
+	}
+
+	orig := freeSpace
+	t.Cleanup(func() { freeSpace = orig })
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var asked string
+			freeSpace = func(path string) (int64, error) {
+				asked = path
+				return tt.free, tt.err
+			}
+			opts := Options{MinFreeDisk: tt.min, dataRoot: tt.dataRoot}
+			if got := enoughDisk("app:web", opts); got != tt.want {
+				t.Errorf("enoughDisk() = %v, want %v", got, tt.want)
+			}
+			if asked != "" && asked != tt.dataRoot {
+				t.Errorf("checked free space of %q, want the data root %q", asked, tt.dataRoot)
+			}
+		})
+	}
+}
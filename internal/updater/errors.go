@@ -0,0 +1,23 @@
+package updater
+
+import "errors"
+
+// ErrCircuitOpen is joined into UpdateGroups' error when the run was halted
+// after Options.MaxConsecutiveFailures groups failed in a row.
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// GroupError is the failure of one group in the error UpdateGroups returns
+// (an errors.Join of them), so callers can tell which group failed and, with
+// errors.As, why: e.g. a *docker.PullError or *docker.RecreateError.
+type GroupError struct {
+	Group string
+	Err   error
+}
+
+// Error sanitizes the group key and the error text: pull errors can echo
+// registry-controlled response bodies, and the text is logged as is.
+func (e *GroupError) Error() string {
+	return sanitize(e.Group) + ": " + sanitize(e.Err.Error())
+}
+
+func (e *GroupError) Unwrap() error { return e.Err }
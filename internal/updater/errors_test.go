@@ -0,0 +1,94 @@
+package updater
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/fanuelsen/repull/internal/docker"
+)
+
+// TestUpdateGroupsPullErrorType verifies that a failed pull surfaces from
+// UpdateGroups as a *GroupError naming the group, wrapping a
+// *docker.PullError naming the image.
+func TestUpdateGroupsPullErrorType(t *testing.T) {
+	cli, _ := fakeDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/images/create"):
+			http.Error(w, `{"message":"registry unavailable"}`, http.StatusInternalServerError)
+		case strings.HasSuffix(r.URL.Path, "/json"):
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"Id":"sha256:old"}`))
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	})
+
+	web := container.InspectResponse{
+		ContainerJSONBase: &container.ContainerJSONBase{ID: "c1", Name: "/web", Image: "sha256:old", HostConfig: &container.HostConfig{}},
+		Config:            &container.Config{Image: "nginx:latest"},
+	}
+	err := UpdateGroups(t.Context(), cli, map[string][]container.InspectResponse{"app:web": {web}}, Options{})
+
+	var groupErr *GroupError
+	if !errors.As(err, &groupErr) {
+		t.Fatalf("UpdateGroups() error = %v, want a *GroupError", err)
+	}
+	if groupErr.Group != "app:web" {
+		t.Errorf("GroupError.Group = %q, want app:web", groupErr.Group)
+	}
+	var pullErr *docker.PullError
+	if !errors.As(err, &pullErr) {
+		t.Fatalf("UpdateGroups() error = %v, want a *docker.PullError", err)
+	}
+	if pullErr.Image != "nginx:latest" {
+		t.Errorf("PullError.Image = %q, want nginx:latest", pullErr.Image)
+	}
+}
+
+// TestUpdateGroupSkipsUnresolvableNetworkMode verifies that a group whose
+// container shares the network of a container that no longer exists is
+// skipped, not failed, and left running.
+func TestUpdateGroupSkipsUnresolvableNetworkMode(t *testing.T) {
+	pulled := false
+	cli, calls := fakeDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/images/create"):
+			pulled = true
+			w.WriteHeader(http.StatusOK)
+		case strings.HasSuffix(r.URL.Path, "/containers/json"):
+			w.Write([]byte(`[]`))
+		case strings.HasSuffix(r.URL.Path, "/containers/vpn/json"):
+			http.Error(w, `{"message":"No such container: vpn"}`, http.StatusNotFound)
+		case strings.HasSuffix(r.URL.Path, "/json"):
+			id := "sha256:old"
+			if pulled {
+				id = "sha256:new"
+			}
+			w.Write([]byte(`{"Id":"` + id + `"}`))
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	})
+
+	app := container.InspectResponse{
+		ContainerJSONBase: &container.ContainerJSONBase{ID: "c1", Name: "/app", Image: "sha256:old", HostConfig: &container.HostConfig{NetworkMode: "container:vpn"}},
+		Config:            &container.Config{Image: "app:latest"},
+	}
+
+	result, err := updateGroup(t.Context(), cli, "app", []container.InspectResponse{app}, Options{}, docker.RecreatedContainers{}, nil)
+	if err != nil {
+		t.Fatalf("updateGroup() error = %v", err)
+	}
+	if result != ResultSkipped {
+		t.Errorf("result = %q, want %q", result, ResultSkipped)
+	}
+	for _, c := range *calls {
+		if strings.HasPrefix(c, "POST /containers/") {
+			t.Errorf("skipped group's container was touched: %v", *calls)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package updater
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/distribution/reference"
+	"github.com/docker/docker/api/types/container"
+)
+
+// imagePattern is a compiled --exclude-image glob.
+type imagePattern struct {
+	glob string
+	re   *regexp.Regexp
+}
+
+// compileImagePattern turns a glob into a pattern. "*" matches any run of
+// characters, including "/" and ":", so "postgres:*" covers every tag and
+// "ghcr.io/acme/*" every image under that namespace; "?" matches one
+// character.
+func compileImagePattern(glob string) imagePattern {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, r := range glob {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return imagePattern{glob: glob, re: regexp.MustCompile(b.String())}
+}
+
+// imageRefs returns the forms an image reference is matched in: as written
+// (e.g. "postgres:16") and fully qualified ("docker.io/library/postgres:16").
+// An untagged reference gets the implicit ":latest".
+func imageRefs(imageName string) []string {
+	refs := []string{imageName}
+	named, err := reference.ParseNormalizedNamed(imageName)
+	if err != nil {
+		return refs
+	}
+	named = reference.TagNameOnly(named)
+	if full := named.String(); full != imageName {
+		refs = append(refs, full)
+	}
+	if familiar := reference.FamiliarString(named); familiar != imageName {
+		refs = append(refs, familiar)
+	}
+	return refs
+}
+
+// matchesImage reports whether any pattern matches imageName.
+func matchesImage(imageName string, patterns []imagePattern) (imagePattern, bool) {
+	for _, ref := range imageRefs(imageName) {
+		for _, p := range patterns {
+			if p.re.MatchString(ref) {
+				return p, true
+			}
+		}
+	}
+	return imagePattern{}, false
+}
+
+// imageIn reports whether imageName, in any of the forms imageRefs lists,
+// is one of refs, e.g. fully qualified references such as
+// "docker.io/library/nginx:latest".
+func imageIn(imageName string, refs []string) bool {
+	for _, form := range imageRefs(imageName) {
+		for _, ref := range refs {
+			if form == ref {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// groupRunsImage reports whether a group's image is one of refs.
+func groupRunsImage(containers []container.InspectResponse, refs []string) bool {
+	return len(containers) > 0 && containers[0].Config != nil && imageIn(containers[0].Config.Image, refs)
+}
+
+// ExcludeImages drops the containers whose image matches one of the globs,
+// and the groups left empty, no matter how their containers are labeled. It
+// is a fleet-wide safety net, e.g. --exclude-image 'postgres:*' to keep
+// databases out of automatic updates.
+func ExcludeImages(groups map[string][]container.InspectResponse, globs []string) map[string][]container.InspectResponse {
+	if len(globs) == 0 {
+		return groups
+	}
+	patterns := make([]imagePattern, len(globs))
+	for i, g := range globs {
+		patterns[i] = compileImagePattern(g)
+	}
+
+	return dropContainers(groups, "Excluding", func(c container.InspectResponse) (string, bool) {
+		p, ok := matchesImage(c.Config.Image, patterns)
+		if !ok {
+			return "", false
+		}
+		return fmt.Sprintf("image %s matches --exclude-image %s", sanitize(c.Config.Image), sanitize(p.glob)), true
+	})
+}
+
+// dropContainers removes from each group the containers drop reports, with
+// the reason it gives, and drops the groups left empty. Every container is
+// checked, not only the first one a group takes its image from: a group
+// running mixed images is only split up later, by UpdateGroups (see
+// splitMixedGroups). verb starts the log line, e.g. "Excluding".
+func dropContainers(groups map[string][]container.InspectResponse, verb string, drop func(container.InspectResponse) (string, bool)) map[string][]container.InspectResponse {
+	kept := make(map[string][]container.InspectResponse, len(groups))
+	for key, containers := range groups {
+		var left []container.InspectResponse
+		var reasons []string
+		for _, c := range containers {
+			if c.Config != nil {
+				if reason, ok := drop(c); ok {
+					reasons = append(reasons, reason)
+					if len(containers) > 1 {
+						log.Printf("[INFO] %s %s of %s: %s", verb, sanitize(strings.TrimPrefix(c.Name, "/")), sanitize(key), reason)
+					}
+					continue
+				}
+			}
+			left = append(left, c)
+		}
+		if len(left) == 0 && len(containers) > 0 {
+			if len(containers) == 1 {
+				log.Printf("[INFO] %s %s: %s", verb, sanitize(key), reasons[0])
+			}
+			continue
+		}
+		kept[key] = left
+	}
+	return kept
+}
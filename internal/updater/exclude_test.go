@@ -0,0 +1,94 @@
+package updater
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+func TestMatchesImage(t *testing.T) {
+	tests := []struct {
+		image string
+		globs []string
+		want  bool
+	}{
+		{image: "postgres:16", globs: []string{"postgres:*"}, want: true},
+		{image: "postgres", globs: []string{"postgres:*"}, want: true},
+		{image: "docker.io/library/postgres:16-alpine", globs: []string{"postgres:*"}, want: true},
+		{image: "postgres:16", globs: []string{"docker.io/library/postgres:*"}, want: true},
+		{image: "bitnami/postgresql:16", globs: []string{"postgres:*"}, want: false},
+		{image: "ghcr.io/acme/api:1.2", globs: []string{"ghcr.io/acme/*"}, want: true},
+		{image: "ghcr.io/other/api:1.2", globs: []string{"ghcr.io/acme/*"}, want: false},
+		{image: "redis:7", globs: []string{"postgres:*", "redis:?"}, want: true},
+		{image: "redis:7.2", globs: []string{"redis:?"}, want: false},
+		{image: "nginx:latest", globs: []string{"nginx"}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			var patterns []imagePattern
+			for _, g := range tt.globs {
+				patterns = append(patterns, compileImagePattern(g))
+			}
+			if _, got := matchesImage(tt.image, patterns); got != tt.want {
+				t.Errorf("matchesImage(%q, %v) = %v, want %v", tt.image, tt.globs, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExcludeImages(t *testing.T) {
+	withImage := func(image string) []container.InspectResponse {
+		return []container.InspectResponse{{Config: &container.Config{Image: image}}}
+	}
+	groups := map[string][]container.InspectResponse{
+		"app:db":    withImage("postgres:16"),
+		"app:cache": withImage("redis:7"),
+		"app:web":   withImage("nginx:latest"),
+	}
+
+	got := ExcludeImages(groups, []string{"postgres:*", "redis:*"})
+	if len(got) != 1 || got["app:web"] == nil {
+		t.Errorf("ExcludeImages() kept %v, want only app:web", got)
+	}
+}
+
+// TestExcludeImagesMixedGroup verifies a container matching --exclude-image
+// is dropped even when it is not the first of its group: UpdateGroups would
+// otherwise split it off into a group of its own and update it.
+func TestExcludeImagesMixedGroup(t *testing.T) {
+	groups := map[string][]container.InspectResponse{
+		"app:web": {
+			{ContainerJSONBase: &container.ContainerJSONBase{Name: "/app-web-1"}, Config: &container.Config{Image: "nginx:latest"}},
+			{ContainerJSONBase: &container.ContainerJSONBase{Name: "/app-web-2"}, Config: &container.Config{Image: "postgres:16"}},
+		},
+	}
+
+	got := splitMixedGroups(ExcludeImages(groups, []string{"postgres:*"}), nil)
+	if len(got) != 1 || len(got["app:web"]) != 1 || got["app:web"][0].Name != "/app-web-1" {
+		t.Errorf("groups after exclusion and split = %v, want app:web with app-web-1 only", groupKeys(got))
+	}
+}
+
+func TestImageIn(t *testing.T) {
+	refs := []string{"docker.io/library/nginx:latest", "ghcr.io/acme/api:1.2"}
+
+	tests := []struct {
+		image string
+		want  bool
+	}{
+		{"nginx", true},
+		{"nginx:latest", true},
+		{"docker.io/library/nginx:latest", true},
+		{"nginx:1.27", false},
+		{"ghcr.io/acme/api:1.2", true},
+		{"ghcr.io/acme/api:1.3", false},
+		{"acme/api:1.2", false},
+	}
+
+	for _, tt := range tests {
+		if got := imageIn(tt.image, refs); got != tt.want {
+			t.Errorf("imageIn(%q) = %v, want %v", tt.image, got, tt.want)
+		}
+	}
+}
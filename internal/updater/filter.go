@@ -1,12 +1,19 @@
 package updater
 
 import (
+	"fmt"
+
+	"github.com/distribution/reference"
 	"github.com/docker/docker/api/types/container"
+	"github.com/fanuelsen/repull/internal/docker"
 )
 
 const (
 	// EnableLabel is the label that must be set to "true" for a container to be auto-updated
 	EnableLabel = "io.repull.enable"
+	// TrackLabel names a moving image reference (e.g. nginx:1.27) to follow
+	// for a container whose image is pinned by digest
+	TrackLabel = "io.repull.track"
 )
 
 // FilterOptedInContainers returns only containers that have the io.repull.enable=true label.
@@ -24,8 +31,34 @@ func FilterOptedInContainers(containers []container.InspectResponse) []container
 	return filtered
 }
 
+// FilterByNetwork returns only containers attached to at least one of the
+// named networks, e.g. to update one tier of a stack at a time. A container
+// sharing another's network namespace (network_mode: container:...) has no
+// networks of its own and is never matched; it is recreated along with the
+// container it depends on.
+func FilterByNetwork(containers []container.InspectResponse, networks []string) []container.InspectResponse {
+	var filtered []container.InspectResponse
+	for _, c := range containers {
+		if c.NetworkSettings == nil {
+			continue
+		}
+		for _, name := range networks {
+			if _, ok := c.NetworkSettings.Networks[name]; ok {
+				filtered = append(filtered, c)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
 // filterOutdatedContainers returns the containers whose image ID differs from
 // latestID, i.e. containers not running the image their tag currently points to.
+// RepoDigests play no part: an image ID change alone (e.g. a local rebuild
+// pushed under an unchanged registry digest) marks a container outdated, and
+// a RepoDigests change alone (e.g. the same image served through a
+// pull-through cache) does not. The image ID is the config digest, so the
+// decision is already content-based.
 func filterOutdatedContainers(containers []container.InspectResponse, latestID string) []container.InspectResponse {
 	var outdated []container.InspectResponse
 
@@ -37,3 +70,45 @@ func filterOutdatedContainers(containers []container.InspectResponse, latestID s
 
 	return outdated
 }
+
+// trackedImage returns the image reference to pull for a container. Normally
+// that is the container's own image. A digest-pinned image never changes, so
+// it yields the io.repull.track reference if set, or ok=false if the
+// container has no moving target and should be skipped.
+func trackedImage(c container.InspectResponse) (imageName string, ok bool) {
+	imageName = c.Config.Image
+	if !docker.IsDigestPinned(imageName) {
+		return imageName, true
+	}
+	if track := c.Config.Labels[TrackLabel]; track != "" {
+		return track, true
+	}
+	return imageName, false
+}
+
+// checkImageRef reports whether imageName is a reference repull can pull,
+// using the same normalization as the Docker CLI (so "nginx" means
+// docker.io/library/nginx). A malformed reference — from a hand-written label
+// or odd inspect data, or an image ID where a name belongs — would otherwise
+// reach the daemon and come back as an opaque pull error.
+func checkImageRef(imageName string) error {
+	if imageName == "" {
+		return fmt.Errorf("empty image reference")
+	}
+	if _, err := reference.ParseNormalizedNamed(imageName); err != nil {
+		return fmt.Errorf("invalid image reference %q: %w", imageName, err)
+	}
+	return nil
+}
+
+// withImage returns a copy of c whose config references imageName, so the
+// recreated container follows a tracked reference instead of its old pin.
+func withImage(c container.InspectResponse, imageName string) container.InspectResponse {
+	if c.Config == nil || c.Config.Image == imageName {
+		return c
+	}
+	cfg := *c.Config
+	cfg.Image = imageName
+	c.Config = &cfg
+	return c
+}
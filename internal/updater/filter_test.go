@@ -1,9 +1,11 @@
 package updater
 
 import (
+	"slices"
 	"testing"
 
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
 )
 
 func TestFilterOptedInContainers(t *testing.T) {
@@ -102,6 +104,47 @@ func TestFilterOptedInContainers(t *testing.T) {
 	}
 }
 
+func TestFilterByNetwork(t *testing.T) {
+	onNetworks := func(id string, names ...string) container.InspectResponse {
+		c := container.InspectResponse{ContainerJSONBase: &container.ContainerJSONBase{ID: id}}
+		if names != nil {
+			c.NetworkSettings = &container.NetworkSettings{Networks: make(map[string]*network.EndpointSettings)}
+			for _, name := range names {
+				c.NetworkSettings.Networks[name] = &network.EndpointSettings{}
+			}
+		}
+		return c
+	}
+	containers := []container.InspectResponse{
+		onNetworks("web", "frontend-net"),
+		onNetworks("api", "frontend-net", "backend-net"),
+		onNetworks("db", "backend-net"),
+		onNetworks("sidecar"), // network_mode: container:web
+	}
+
+	tests := []struct {
+		name     string
+		networks []string
+		want     []string
+	}{
+		{name: "one network", networks: []string{"frontend-net"}, want: []string{"web", "api"}},
+		{name: "attached to several", networks: []string{"backend-net"}, want: []string{"api", "db"}},
+		{name: "any of several", networks: []string{"frontend-net", "backend-net"}, want: []string{"web", "api", "db"}},
+		{name: "no match", networks: []string{"bridge"}, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, c := range FilterByNetwork(containers, tt.networks) {
+				got = append(got, c.ID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("FilterByNetwork(%v) = %v, want %v", tt.networks, got, tt.want)
+			}
+		})
+	}
+}
+
 func TestFilterOutdatedContainers(t *testing.T) {
 	latestID := "sha256:new123"
 
@@ -150,3 +193,75 @@ func TestFilterOutdatedContainers(t *testing.T) {
 		})
 	}
 }
+
+func TestTrackedImage(t *testing.T) {
+	pinned := "nginx@sha256:4b1d4ef4b8f0a9e0d3d9a7c3c6e2e9f0b4e6c5d1a3f2b7c8d9e0a1b2c3d4e5f6"
+
+	tests := []struct {
+		name      string
+		image     string
+		labels    map[string]string
+		wantImage string
+		wantOK    bool
+	}{
+		{name: "tag", image: "nginx:1.27", wantImage: "nginx:1.27", wantOK: true},
+		{name: "pinned without track label is skipped", image: pinned, wantImage: pinned, wantOK: false},
+		{name: "pinned with track label follows it", image: pinned, labels: map[string]string{TrackLabel: "nginx:1.27"}, wantImage: "nginx:1.27", wantOK: true},
+		{name: "track label ignored for tags", image: "nginx:latest", labels: map[string]string{TrackLabel: "nginx:1.27"}, wantImage: "nginx:latest", wantOK: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := container.InspectResponse{Config: &container.Config{Image: tt.image, Labels: tt.labels}}
+			gotImage, gotOK := trackedImage(c)
+			if gotImage != tt.wantImage || gotOK != tt.wantOK {
+				t.Errorf("trackedImage() = %q, %v; want %q, %v", gotImage, gotOK, tt.wantImage, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestWithImageDoesNotMutateOriginal(t *testing.T) {
+	orig := container.InspectResponse{Config: &container.Config{Image: "nginx@sha256:abc"}}
+	got := withImage(orig, "nginx:1.27")
+	if got.Config.Image != "nginx:1.27" {
+		t.Errorf("withImage() image = %q, want nginx:1.27", got.Config.Image)
+	}
+	if orig.Config.Image != "nginx@sha256:abc" {
+		t.Errorf("withImage() mutated the original config: %q", orig.Config.Image)
+	}
+}
+
+func TestCheckImageRef(t *testing.T) {
+	tests := []struct {
+		image string
+		valid bool
+	}{
+		{"nginx", true},
+		{"nginx:1.27", true},
+		{"library/nginx:latest", true},
+		{"docker.io/library/nginx:latest", true},
+		{"ghcr.io/fanuelsen/repull:v1.2.3", true},
+		{"registry.example.com:5000/team/app:1.0", true},
+		{"localhost:5000/app", true},
+		{"nginx@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", true},
+		{"nginx:1.27@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", true},
+		{"", false},
+		{"Nginx:latest", false},
+		{"nginx:", false},
+		{"nginx:bad tag", false},
+		{"nginx@sha256:short", false},
+		{"registry.example.com:port/app", false},
+		{"0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", false},
+		{"-leading-dash/app", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			err := checkImageRef(tt.image)
+			if (err == nil) != tt.valid {
+				t.Errorf("checkImageRef(%q) error = %v, want valid=%v", tt.image, err, tt.valid)
+			}
+		})
+	}
+}
@@ -31,6 +31,21 @@ func GroupByComposeService(containers []container.InspectResponse) map[string][]
 	return groups
 }
 
+// GroupIndividually puts every container in its own group, keyed like a
+// standalone container ("standalone:containerID") even when it is managed by
+// Docker Compose. Used for --group-by none, e.g. to update one replica of a
+// scaled service without touching its siblings.
+func GroupIndividually(containers []container.InspectResponse) map[string][]container.InspectResponse {
+	groups := make(map[string][]container.InspectResponse)
+
+	for _, c := range containers {
+		key := fmt.Sprintf("standalone:%s", c.ID)
+		groups[key] = append(groups[key], c)
+	}
+
+	return groups
+}
+
 // getGroupKey returns the group key for a container based on its labels.
 func getGroupKey(c container.InspectResponse) string {
 	if c.Config == nil || c.Config.Labels == nil {
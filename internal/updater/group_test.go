@@ -1,9 +1,13 @@
 package updater
 
 import (
+	"encoding/json"
+	"net/http"
+	"strings"
 	"testing"
 
 	"github.com/docker/docker/api/types/container"
+	"github.com/fanuelsen/repull/internal/docker"
 )
 
 func TestGroupByComposeService(t *testing.T) {
@@ -254,3 +258,95 @@ func TestGetGroupKey(t *testing.T) {
 		})
 	}
 }
+
+func TestGroupIndividually(t *testing.T) {
+	compose := func(id string) container.InspectResponse {
+		return container.InspectResponse{
+			ContainerJSONBase: &container.ContainerJSONBase{ID: id},
+			Config: &container.Config{
+				Labels: map[string]string{
+					ComposeProjectLabel: "myapp",
+					ComposeServiceLabel: "web",
+				},
+			},
+		}
+	}
+
+	groups := GroupIndividually([]container.InspectResponse{compose("abc123"), compose("def456")})
+
+	if len(groups) != 2 {
+		t.Fatalf("GroupIndividually() returned %d groups, want 2 (one per container)", len(groups))
+	}
+	for _, key := range []string{"standalone:abc123", "standalone:def456"} {
+		if len(groups[key]) != 1 {
+			t.Errorf("group %q has %d container(s), want 1", key, len(groups[key]))
+		}
+	}
+}
+
+// TestRecreatePreservesComposeLabels verifies that every com.docker.compose.*
+// label survives a recreate and that the replacement lands in the same group
+// as the container it replaced.
+func TestRecreatePreservesComposeLabels(t *testing.T) {
+	var created container.CreateRequest
+	cli, _ := fakeDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/containers/create"):
+			if err := json.NewDecoder(r.Body).Decode(&created); err != nil {
+				t.Errorf("decoding create request: %v", err)
+			}
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"Id":"new"}`))
+		case strings.HasSuffix(r.URL.Path, "/json"):
+			w.Write([]byte(`{"Id":"new"}`))
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	})
+
+	labels := map[string]string{
+		ComposeProjectLabel:                       "myapp",
+		ComposeServiceLabel:                       "web",
+		"com.docker.compose.config-hash":          "4f1c0e6b",
+		"com.docker.compose.container-number":     "2",
+		"com.docker.compose.depends_on":           "db:service_healthy:false",
+		"com.docker.compose.image":                "sha256:old",
+		"com.docker.compose.oneoff":               "False",
+		"com.docker.compose.project.config_files": "/srv/myapp/compose.yaml",
+		"com.docker.compose.project.working_dir":  "/srv/myapp",
+		"com.docker.compose.version":              "2.29.7",
+		EnableLabel:                               "true",
+	}
+	old := container.InspectResponse{
+		ContainerJSONBase: &container.ContainerJSONBase{
+			ID:         "0123456789abcdef0123456789abcdef",
+			Name:       "/myapp-web-2",
+			Image:      "sha256:old",
+			HostConfig: &container.HostConfig{NetworkMode: "bridge"},
+		},
+		Config: &container.Config{Image: "web:latest", Labels: labels},
+	}
+
+	if _, err := docker.RecreateContainer(t.Context(), cli, old, nil, docker.RecreateOptions{}); err != nil {
+		t.Fatalf("RecreateContainer() error = %v", err)
+	}
+
+	for k, v := range labels {
+		if got := created.Labels[k]; got != v {
+			t.Errorf("label %s = %q after recreate, want %q", k, got, v)
+		}
+	}
+
+	replacement := container.InspectResponse{
+		ContainerJSONBase: &container.ContainerJSONBase{ID: "new"},
+		Config:            &container.Config{Labels: created.Labels},
+	}
+	before := GroupByComposeService([]container.InspectResponse{old})
+	after := GroupByComposeService([]container.InspectResponse{replacement})
+	for key := range before {
+		if _, ok := after[key]; !ok {
+			t.Errorf("replacement grouped under %v, want %s", after, key)
+		}
+	}
+}
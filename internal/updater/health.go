@@ -0,0 +1,83 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+)
+
+// RequireHealthyLabel names a compose service ("project:service") whose
+// containers must all be healthy before the labeled group is updated, e.g.
+// io.repull.require-healthy=myapp:db for a web service that must not update
+// while its database is mid-migration.
+const RequireHealthyLabel = "io.repull.require-healthy"
+
+// requiredService returns the service a group's io.repull.require-healthy
+// label names, if any.
+func requiredService(containers []container.InspectResponse) (string, bool) {
+	if len(containers) == 0 || containers[0].Config == nil {
+		return "", false
+	}
+	key := strings.TrimSpace(containers[0].Config.Labels[RequireHealthyLabel])
+	return key, key != ""
+}
+
+// serviceHealthy checks the running containers of a "project:service" key.
+// A service with no running container is not healthy; a container without a
+// healthcheck counts as healthy while it runs, as there is nothing more to
+// go on. The reason describes the first problem found.
+func serviceHealthy(ctx context.Context, cli *client.Client, key string) (healthy bool, reason string, err error) {
+	project, service, ok := strings.Cut(key, ":")
+	if !ok || project == "" || service == "" {
+		return false, "", fmt.Errorf("invalid %s %q: want project:service", RequireHealthyLabel, key)
+	}
+
+	filter := filters.NewArgs()
+	filter.Add("label", ComposeProjectLabel+"="+project)
+	filter.Add("label", ComposeServiceLabel+"="+service)
+	filter.Add("status", "running")
+	list, err := cli.ContainerList(ctx, container.ListOptions{Filters: filter})
+	if err != nil {
+		return false, "", err
+	}
+	if len(list) == 0 {
+		return false, "no running containers", nil
+	}
+
+	for _, c := range list {
+		inspect, err := cli.ContainerInspect(ctx, c.ID)
+		if err != nil {
+			return false, "", err
+		}
+		if inspect.State == nil || !inspect.State.Running {
+			return false, fmt.Sprintf("%s is not running", strings.TrimPrefix(inspect.Name, "/")), nil
+		}
+		if h := inspect.State.Health; h != nil && h.Status != container.Healthy {
+			return false, fmt.Sprintf("%s is %s", strings.TrimPrefix(inspect.Name, "/"), h.Status), nil
+		}
+	}
+	return true, "", nil
+}
+
+// healthPrecondition reports whether a group may be updated now with respect
+// to its io.repull.require-healthy label. An unhealthy dependency defers the
+// group to a later run; a malformed label or a failed check is an error.
+func healthPrecondition(ctx context.Context, cli *client.Client, groupKey string, containers []container.InspectResponse) (bool, error) {
+	dep, ok := requiredService(containers)
+	if !ok {
+		return true, nil
+	}
+	healthy, reason, err := serviceHealthy(ctx, cli, dep)
+	if err != nil {
+		return false, fmt.Errorf("checking health of %s: %w", sanitize(dep), err)
+	}
+	if !healthy {
+		log.Printf("[INFO] Deferring %s: required service %s is not healthy (%s)", sanitize(groupKey), sanitize(dep), sanitize(reason))
+	}
+	return healthy, nil
+}
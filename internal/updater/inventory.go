@@ -0,0 +1,74 @@
+package updater
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// InventoryEntry describes one opted-in container for --inventory-out, so
+// inventories of different hosts can be diffed to spot configuration drift.
+type InventoryEntry struct {
+	Group    string            `json:"group"`
+	Name     string            `json:"name"`
+	Image    string            `json:"image"`
+	ImageID  string            `json:"image_id"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Networks []string          `json:"networks,omitempty"`
+}
+
+// Inventory lists the containers of groups, ordered by group and name.
+// Only labels repull acts on are included: io.repull.* and the compose
+// project and service labels that decide grouping.
+func Inventory(groups map[string][]container.InspectResponse) []InventoryEntry {
+	var entries []InventoryEntry
+	for key, containers := range groups {
+		for _, c := range containers {
+			e := InventoryEntry{Group: key}
+			if c.ContainerJSONBase != nil {
+				e.Name = strings.TrimPrefix(c.Name, "/")
+				e.ImageID = c.Image
+			}
+			if c.Config != nil {
+				e.Image = c.Config.Image
+				for k, v := range c.Config.Labels {
+					if strings.HasPrefix(k, "io.repull.") || k == ComposeProjectLabel || k == ComposeServiceLabel {
+						if e.Labels == nil {
+							e.Labels = make(map[string]string)
+						}
+						e.Labels[k] = v
+					}
+				}
+			}
+			if c.NetworkSettings != nil {
+				for name := range c.NetworkSettings.Networks {
+					e.Networks = append(e.Networks, name)
+				}
+				sort.Strings(e.Networks)
+			}
+			entries = append(entries, e)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Group != entries[j].Group {
+			return entries[i].Group < entries[j].Group
+		}
+		return entries[i].Name < entries[j].Name
+	})
+	return entries
+}
+
+// WriteInventory writes entries to path as indented JSON.
+func WriteInventory(path string, entries []InventoryEntry) error {
+	if entries == nil {
+		entries = []InventoryEntry{}
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
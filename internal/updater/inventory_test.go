@@ -0,0 +1,58 @@
+package updater
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+)
+
+func TestInventory(t *testing.T) {
+	c := func(name, image string, labels map[string]string, networks ...string) container.InspectResponse {
+		ns := &container.NetworkSettings{Networks: map[string]*network.EndpointSettings{}}
+		for _, n := range networks {
+			ns.Networks[n] = &network.EndpointSettings{}
+		}
+		return container.InspectResponse{
+			ContainerJSONBase: &container.ContainerJSONBase{Name: "/" + name, Image: "sha256:" + name},
+			Config:            &container.Config{Image: image, Labels: labels},
+			NetworkSettings:   ns,
+		}
+	}
+	groups := map[string][]container.InspectResponse{
+		"app:web": {
+			c("app-web-2", "nginx:1.27", map[string]string{EnableLabel: "true", "maintainer": "someone"}, "frontend", "backend"),
+			c("app-web-1", "nginx:1.27", map[string]string{EnableLabel: "true"}, "frontend"),
+		},
+		"app:db": {c("app-db-1", "postgres:16", map[string]string{EnableLabel: "true", ComposeProjectLabel: "app"})},
+	}
+
+	got := Inventory(groups)
+
+	wantOrder := []string{"app-db-1", "app-web-1", "app-web-2"}
+	if len(got) != len(wantOrder) {
+		t.Fatalf("Inventory() returned %d entries, want %d", len(got), len(wantOrder))
+	}
+	for i, name := range wantOrder {
+		if got[i].Name != name {
+			t.Errorf("entry %d = %s, want %s", i, got[i].Name, name)
+		}
+	}
+
+	web2 := got[2]
+	if web2.Group != "app:web" || web2.Image != "nginx:1.27" || web2.ImageID != "sha256:app-web-2" {
+		t.Errorf("entry = %+v", web2)
+	}
+	if _, ok := web2.Labels["maintainer"]; ok {
+		t.Errorf("unrelated label included: %v", web2.Labels)
+	}
+	if web2.Labels[EnableLabel] != "true" {
+		t.Errorf("repull label missing: %v", web2.Labels)
+	}
+	if len(web2.Networks) != 2 || web2.Networks[0] != "backend" {
+		t.Errorf("Networks = %v, want sorted [backend frontend]", web2.Networks)
+	}
+	if got[0].Labels[ComposeProjectLabel] != "app" {
+		t.Errorf("compose label missing: %v", got[0].Labels)
+	}
+}
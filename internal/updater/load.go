@@ -0,0 +1,70 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// loadPollInterval is how often waitForLoad re-reads the load average.
+const loadPollInterval = 15 * time.Second
+
+// parseLoadAvg returns the 1-minute load average from the contents of
+// /proc/loadavg, e.g. "0.52 0.58 0.59 1/467 12345".
+func parseLoadAvg(data string) (float64, error) {
+	fields := strings.Fields(data)
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("empty loadavg")
+	}
+	load, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, fmt.Errorf("parsing loadavg %q: %w", fields[0], err)
+	}
+	return load, nil
+}
+
+// readLoadAvg reads the 1-minute load average of the host. Inside a
+// container /proc/loadavg is not namespaced, so this is the host's load.
+func readLoadAvg() (float64, error) {
+	data, err := os.ReadFile("/proc/loadavg")
+	if err != nil {
+		return 0, err
+	}
+	return parseLoadAvg(string(data))
+}
+
+// waitForLoad blocks until the 1-minute load average drops below max, for
+// --max-load: recreating a container costs CPU, and a burst of recreates on a
+// small host can push it into timeouts. It returns an error if ctx ends
+// first. It is a no-op when max is 0, on systems other than Linux, and when
+// the load cannot be read.
+func waitForLoad(ctx context.Context, max float64) error {
+	if max <= 0 || runtime.GOOS != "linux" {
+		return nil
+	}
+	logged := false
+	for {
+		load, err := readLoadAvg()
+		if err != nil {
+			log.Printf("[WARN] Could not read load average, not waiting: %v", err)
+			return nil
+		}
+		if load < max {
+			return nil
+		}
+		if !logged {
+			log.Printf("[INFO] Load average %.2f is at or above --max-load %.2f, waiting before recreating", load, max)
+			logged = true
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("load average stayed at or above --max-load %.2f: %w", max, ctx.Err())
+		case <-time.After(loadPollInterval):
+		}
+	}
+}
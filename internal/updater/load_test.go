@@ -0,0 +1,28 @@
+package updater
+
+import "testing"
+
+func TestParseLoadAvg(t *testing.T) {
+	tests := []struct {
+		data    string
+		want    float64
+		wantErr bool
+	}{
+		{data: "0.52 0.58 0.59 1/467 12345\n", want: 0.52},
+		{data: "12.00 8.41 4.03 9/1024 99", want: 12},
+		{data: "3 2 1 1/1 1", want: 3},
+		{data: "", wantErr: true},
+		{data: "abc 0.58 0.59 1/467 12345", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseLoadAvg(tt.data)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseLoadAvg(%q) error = %v, wantErr %v", tt.data, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseLoadAvg(%q) = %v, want %v", tt.data, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package updater
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/fanuelsen/repull/internal/notify"
+)
+
+// TestMaintenanceModeNotifiesWithoutRecreating verifies --maintenance-mode
+// sends an "available" notification for an outdated group and never
+// touches its containers.
+func TestMaintenanceModeNotifiesWithoutRecreating(t *testing.T) {
+	tagID := "sha256:old"
+	cli, calls := imageDaemon(t, &tagID, "sha256:new")
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	file, err := notify.NewFileNotifier(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var notifier *notify.Notifier
+
+	groups := map[string][]container.InspectResponse{
+		"app:web": {webContainer("sha256:old")},
+	}
+	opts := Options{DryRun: true, NotifyAvailable: true, Notifier: notifier.WithFile(file)}
+	if err := UpdateGroups(t.Context(), cli, groups, opts); err != nil {
+		t.Fatalf("UpdateGroups() error = %v", err)
+	}
+
+	for _, call := range *calls {
+		if strings.HasPrefix(call, "POST /containers/") || strings.HasPrefix(call, "DELETE /containers/") {
+			t.Errorf("maintenance mode touched a container: %v", *calls)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var e notify.FileEvent
+	if err := json.Unmarshal(data, &e); err != nil {
+		t.Fatalf("events = %q: %v", data, err)
+	}
+	if e.Event != "available" || e.Service != "app:web" || e.NewDigest == "" {
+		t.Errorf("event = %+v, want an available event for app:web", e)
+	}
+}
@@ -0,0 +1,43 @@
+package updater
+
+import (
+	"log"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// splitMixedGroups moves every container that does not run its group's
+// image into a group of its own, keyed "<group>/<container name>". A group
+// takes its image from its first container, so a replica recreated by hand
+// with another image (e.g. a pinned tag while debugging) would otherwise be
+// moved to the group's image. Images are compared as repull would update
+// them, with channels and io.repull.track applied, and in normalized form,
+// so "nginx" and "docker.io/library/nginx:latest" are the same image.
+func splitMixedGroups(groups map[string][]container.InspectResponse, channels Channels) map[string][]container.InspectResponse {
+	split := make(map[string][]container.InspectResponse, len(groups))
+	for groupKey, containers := range groups {
+		if len(containers) < 2 || containers[0].Config == nil {
+			split[groupKey] = containers
+			continue
+		}
+		groupImage, _, _ := targetImage(containers[0], channels)
+		want := imageRefs(groupImage)
+		kept := containers[:1:1]
+		for _, c := range containers[1:] {
+			if c.Config == nil {
+				kept = append(kept, c)
+				continue
+			}
+			if imageName, _, _ := targetImage(c, channels); !imageIn(imageName, want) {
+				key := groupKey + "/" + strings.TrimPrefix(c.Name, "/")
+				log.Printf("[WARN] %s runs %s, not the %s of the rest of %s; updating it on its own as %s", sanitize(strings.TrimPrefix(c.Name, "/")), sanitize(imageName), sanitize(groupImage), sanitize(groupKey), sanitize(key))
+				split[key] = []container.InspectResponse{c}
+				continue
+			}
+			kept = append(kept, c)
+		}
+		split[groupKey] = kept
+	}
+	return split
+}
@@ -0,0 +1,84 @@
+package updater
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+func TestSplitMixedGroups(t *testing.T) {
+	replica := func(name, image string) container.InspectResponse {
+		return container.InspectResponse{
+			ContainerJSONBase: &container.ContainerJSONBase{ID: name, Name: "/" + name, Image: "sha256:old", State: &container.State{Running: true}},
+			Config:            &container.Config{Image: image},
+		}
+	}
+	groups := map[string][]container.InspectResponse{
+		"app:web": {
+			replica("web-1", "nginx:latest"),
+			replica("web-2", "docker.io/library/nginx:latest"),
+			replica("web-3", "nginx:1.27"),
+		},
+		"app:db": {replica("db-1", "postgres:16")},
+	}
+
+	got := splitMixedGroups(groups, nil)
+	want := map[string][]string{
+		"app:web":       {"web-1", "web-2"},
+		"app:web/web-3": {"web-3"},
+		"app:db":        {"db-1"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("splitMixedGroups() = %v, want groups %v", groupKeys(got), want)
+	}
+	for key, names := range want {
+		containers := got[key]
+		if len(containers) != len(names) {
+			t.Errorf("group %s has %d container(s), want %v", key, len(containers), names)
+			continue
+		}
+		for i, c := range containers {
+			if c.ID != names[i] {
+				t.Errorf("group %s container %d = %s, want %s", key, i, c.ID, names[i])
+			}
+		}
+	}
+
+	// A channel moves every tag of the repository to the same image.
+	got = splitMixedGroups(groups, Channels{"docker.io/library/nginx": "1.27"})
+	if len(got["app:web"]) != 3 {
+		t.Errorf("with a channel, app:web kept %d container(s), want 3", len(got["app:web"]))
+	}
+}
+
+// TestUpdateGroupsMismatchedContainer verifies a container running another
+// image than its group is planned against its own image.
+func TestUpdateGroupsMismatchedContainer(t *testing.T) {
+	tagID := "sha256:old"
+	cli, _ := imageDaemon(t, &tagID, "sha256:new")
+
+	other := webContainer("sha256:old")
+	other.ID, other.Name = "c2", "/web-debug"
+	other.Config = &container.Config{Image: "nginx:1.27"}
+	groups := map[string][]container.InspectResponse{
+		"app:web": {webContainer("sha256:old"), other},
+	}
+
+	planned := make(map[string]string)
+	opts := Options{DryRun: true, Planned: func(groupKey, imageName, _ string, _ []container.InspectResponse) {
+		planned[groupKey] = imageName
+	}}
+	if err := UpdateGroups(t.Context(), cli, groups, opts); err != nil {
+		t.Fatalf("UpdateGroups() error = %v", err)
+	}
+
+	want := map[string]string{"app:web": "nginx:latest", "app:web/web-debug": "nginx:1.27"}
+	if len(planned) != len(want) {
+		t.Fatalf("planned %v, want %v", planned, want)
+	}
+	for key, image := range want {
+		if planned[key] != image {
+			t.Errorf("planned %s with %q, want %q", key, planned[key], image)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package updater
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"github.com/docker/docker/client"
+)
+
+const (
+	// DescriptionLabel is the OCI annotation describing an image.
+	DescriptionLabel = "org.opencontainers.image.description"
+	// ChangelogLabel points to the release notes of an image, e.g.
+	// io.repull.changelog-url=https://github.com/acme/app/releases.
+	ChangelogLabel = "io.repull.changelog-url"
+)
+
+// maxDescription bounds the image description quoted in a notification.
+const maxDescription = 200
+
+// imageNotes returns the release notes (see releaseNotes) of the image
+// imageID. Failing to inspect it only loses the notes.
+func imageNotes(ctx context.Context, cli *client.Client, imageID string) string {
+	inspect, err := cli.ImageInspect(ctx, imageID)
+	if err != nil || inspect.Config == nil {
+		return ""
+	}
+	return releaseNotes(inspect.Config.Labels)
+}
+
+// releaseNotes renders what an image's labels say about it for an update
+// notification: its description, on one line and truncated, and its
+// changelog URL. Only http(s) URLs are passed on. Returns "" if the image
+// carries neither.
+func releaseNotes(labels map[string]string) string {
+	var lines []string
+	if desc := strings.Join(strings.Fields(labels[DescriptionLabel]), " "); desc != "" {
+		if runes := []rune(desc); len(runes) > maxDescription {
+			desc = string(runes[:maxDescription]) + "..."
+		}
+		lines = append(lines, sanitize(desc))
+	}
+	if u, err := url.Parse(labels[ChangelogLabel]); err == nil && (u.Scheme == "https" || u.Scheme == "http") && u.Host != "" {
+		lines = append(lines, "Changelog: "+sanitize(u.String()))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,37 @@
+package updater
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReleaseNotes(t *testing.T) {
+	long := strings.Repeat("é", maxDescription+50)
+
+	tests := []struct {
+		name   string
+		labels map[string]string
+		want   string
+	}{
+		{"none", nil, ""},
+		{"description", map[string]string{DescriptionLabel: "A fast web server"}, "A fast web server"},
+		{"description on one line", map[string]string{DescriptionLabel: "A fast\n  web server\n"}, "A fast web server"},
+		{"truncated", map[string]string{DescriptionLabel: long}, strings.Repeat("é", maxDescription) + "..."},
+		{"changelog", map[string]string{ChangelogLabel: "https://github.com/acme/app/releases"}, "Changelog: https://github.com/acme/app/releases"},
+		{
+			"both",
+			map[string]string{DescriptionLabel: "Acme app", ChangelogLabel: "https://acme.example/changes"},
+			"Acme app\nChangelog: https://acme.example/changes",
+		},
+		{"non-http changelog ignored", map[string]string{ChangelogLabel: "javascript:alert(1)"}, ""},
+		{"relative changelog ignored", map[string]string{ChangelogLabel: "/releases"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := releaseNotes(tt.labels); got != tt.want {
+				t.Errorf("releaseNotes() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package updater
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// namespaceRefs returns the containers c joins a namespace of: the targets of
+// network_mode, pid and ipc "container:<ref>" settings. Compose's
+// "service:<name>" is stored by Docker in the same form.
+func namespaceRefs(c container.InspectResponse) []string {
+	if c.HostConfig == nil {
+		return nil
+	}
+	var refs []string
+	for _, mode := range []string{string(c.HostConfig.NetworkMode), string(c.HostConfig.PidMode), string(c.HostConfig.IpcMode)} {
+		if ref, ok := strings.CutPrefix(mode, "container:"); ok && ref != "" {
+			refs = append(refs, ref)
+		}
+	}
+	return refs
+}
+
+// idRef matches a namespace reference that may be a container ID: hex, and
+// at least as long as the short IDs Docker shows. A shorter prefix would
+// match container names such as "db" against IDs that happen to start with
+// them.
+var idRef = regexp.MustCompile(`^[0-9a-f]{12,64}$`)
+
+// refersTo reports whether a namespace reference (full ID, short ID, or
+// name) points at container c. Names must match exactly.
+func refersTo(ref string, c container.InspectResponse) bool {
+	if c.ContainerJSONBase == nil {
+		return false
+	}
+	if strings.TrimPrefix(c.Name, "/") == strings.TrimPrefix(ref, "/") {
+		return true
+	}
+	return c.ID != "" && idRef.MatchString(ref) && strings.HasPrefix(c.ID, ref)
+}
+
+// orderGroups returns the group keys in dependency order: a group whose
+// containers join another group's namespace (network_mode, pid or ipc set to
+// container:X) comes after the group containing X, and a group with
+// io.repull.require-healthy comes after the service it names, so its health
+// is checked once that service's own update is done. Recreating parents first
+// means a dependent is only ever recreated against the parent's new
+// container, instead of losing connectivity while it still points at the old
+// one. Independent groups are ordered by key for deterministic runs; groups
+// caught in a reference cycle are appended in key order.
+func orderGroups(groups map[string][]container.InspectResponse) []string {
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// parents[child] holds the groups child depends on.
+	parents := make(map[string]map[string]bool)
+	for _, child := range keys {
+		for _, c := range groups[child] {
+			for _, ref := range namespaceRefs(c) {
+				for _, parent := range keys {
+					if parent == child {
+						continue
+					}
+					for _, p := range groups[parent] {
+						if refersTo(ref, p) {
+							if parents[child] == nil {
+								parents[child] = make(map[string]bool)
+							}
+							parents[child][parent] = true
+						}
+					}
+				}
+			}
+		}
+	}
+
+	for _, child := range keys {
+		if dep, ok := requiredService(groups[child]); ok && dep != child && groups[dep] != nil {
+			if parents[child] == nil {
+				parents[child] = make(map[string]bool)
+			}
+			parents[child][dep] = true
+		}
+	}
+
+	// Kahn's algorithm, always picking the smallest ready key.
+	ordered := make([]string, 0, len(keys))
+	done := make(map[string]bool)
+	for len(ordered) < len(keys) {
+		progressed := false
+		for _, key := range keys {
+			if done[key] {
+				continue
+			}
+			ready := true
+			for parent := range parents[key] {
+				if !done[parent] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				ordered = append(ordered, key)
+				done[key] = true
+				progressed = true
+				break
+			}
+		}
+		if !progressed {
+			// Cycle: no ordering satisfies every reference.
+			for _, key := range keys {
+				if !done[key] {
+					ordered = append(ordered, key)
+					done[key] = true
+				}
+			}
+		}
+	}
+	return ordered
+}
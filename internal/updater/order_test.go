@@ -0,0 +1,101 @@
+package updater
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// testContainer builds an inspect response with the given ID, name, and
+// namespace modes.
+func testContainer(id, name string, host container.HostConfig) container.InspectResponse {
+	return container.InspectResponse{
+		ContainerJSONBase: &container.ContainerJSONBase{ID: id, Name: "/" + name, HostConfig: &host},
+	}
+}
+
+func TestOrderGroups(t *testing.T) {
+	vpnID := "aaaa1111bbbb2222cccc3333dddd4444"
+
+	tests := []struct {
+		name   string
+		groups map[string][]container.InspectResponse
+		want   []string
+	}{
+		{
+			name: "independent groups sorted by key",
+			groups: map[string][]container.InspectResponse{
+				"b:web": {testContainer("b1", "web", container.HostConfig{})},
+				"a:db":  {testContainer("a1", "db", container.HostConfig{})},
+			},
+			want: []string{"a:db", "b:web"},
+		},
+		{
+			name: "network_mode by short ID puts parent first",
+			groups: map[string][]container.InspectResponse{
+				"media:app": {testContainer("c1", "app", container.HostConfig{NetworkMode: container.NetworkMode("container:" + vpnID[:12])})},
+				"media:vpn": {testContainer(vpnID, "vpn", container.HostConfig{})},
+			},
+			want: []string{"media:vpn", "media:app"},
+		},
+		{
+			name: "pid and ipc modes by name",
+			groups: map[string][]container.InspectResponse{
+				"a:debug": {testContainer("d1", "debug", container.HostConfig{PidMode: "container:target", IpcMode: "container:target"})},
+				"z:main":  {testContainer("t1", "target", container.HostConfig{})},
+			},
+			want: []string{"z:main", "a:debug"},
+		},
+		{
+			name: "chain of three",
+			groups: map[string][]container.InspectResponse{
+				"a": {testContainer("a1", "a", container.HostConfig{NetworkMode: "container:b"})},
+				"b": {testContainer("b1", "b", container.HostConfig{NetworkMode: "container:c"})},
+				"c": {testContainer("c1", "c", container.HostConfig{})},
+			},
+			want: []string{"c", "b", "a"},
+		},
+		{
+			name: "cycle falls back to key order",
+			groups: map[string][]container.InspectResponse{
+				"a": {testContainer("a1", "a", container.HostConfig{NetworkMode: "container:b"})},
+				"b": {testContainer("b1", "b", container.HostConfig{NetworkMode: "container:a"})},
+			},
+			want: []string{"a", "b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := orderGroups(tt.groups); !slices.Equal(got, tt.want) {
+				t.Errorf("orderGroups() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRefersTo verifies that names match exactly and IDs by a prefix of at
+// least 12 hex characters, so a short name is never taken for the start of
+// another container's ID.
+func TestRefersTo(t *testing.T) {
+	cache := testContainer("db0123456789abcdef0123456789abcdef", "cache", container.HostConfig{})
+	tests := []struct {
+		ref  string
+		want bool
+	}{
+		{"cache", true},
+		{"/cache", true},
+		{"cach", false},
+		{"db", false},
+		{"db0123456", false},
+		{"db0123456789", true},
+		{"db0123456789abcdef0123456789abcdef", true},
+		{"db0123456789abcdef0123456789abcdff", false},
+	}
+	for _, tt := range tests {
+		if got := refersTo(tt.ref, cache); got != tt.want {
+			t.Errorf("refersTo(%q) = %v, want %v", tt.ref, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package updater
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/fanuelsen/repull/internal/docker"
+)
+
+// PlanVersion is the version of the plan file format written by WritePlan.
+// Version 2 added the container IDs.
+const PlanVersion = 2
+
+// Plan is the set of updates a dry run intends, written with --plan-out and
+// executed by a later --apply-plan run, e.g. after a change-approval step.
+type Plan struct {
+	Version int         `json:"version"`
+	Created time.Time   `json:"created"`
+	Groups  []PlanGroup `json:"groups"`
+}
+
+// PlanGroup is one group the plan updates. ImageID is the ID the image tag
+// resolved to when the plan was made; applying the plan re-checks it.
+// Containers names the containers to recreate for the reader, and
+// ContainerIDs identifies them for the apply.
+type PlanGroup struct {
+	Group        string   `json:"group"`
+	Image        string   `json:"image"`
+	ImageID      string   `json:"image_id"`
+	Containers   []string `json:"containers"`
+	ContainerIDs []string `json:"container_ids"`
+}
+
+// WritePlan writes p to path as indented JSON.
+func WritePlan(path string, p Plan) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// ReadPlan reads a plan written by WritePlan.
+func ReadPlan(path string) (Plan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Plan{}, err
+	}
+	var p Plan
+	if err := json.Unmarshal(data, &p); err != nil {
+		return Plan{}, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if p.Version != PlanVersion {
+		return Plan{}, fmt.Errorf("%s: unsupported plan version %d (want %d)", path, p.Version, PlanVersion)
+	}
+	return p, nil
+}
+
+// Apply restricts opts to the plan's groups and pins each to the image ID
+// and the containers it was planned with (see Options.ExpectedImages and
+// Options.ExpectedContainers).
+func (p Plan) Apply(opts Options) Options {
+	opts.Groups = make(map[string]bool, len(p.Groups))
+	opts.ExpectedImages = make(map[string]string, len(p.Groups))
+	opts.ExpectedContainers = make(map[string][]string, len(p.Groups))
+	for _, g := range p.Groups {
+		opts.Groups[g.Group] = true
+		opts.ExpectedImages[g.Group] = g.ImageID
+		opts.ExpectedContainers[g.Group] = g.ContainerIDs
+	}
+	return opts
+}
+
+// imageDrifted reports whether a group's image tag now resolves to another
+// image than the one a plan expected. Groups without an expectation never
+// drift.
+func imageDrifted(groupKey, latestID string, expected map[string]string) (want string, drifted bool) {
+	want, ok := expected[groupKey]
+	return want, ok && want != latestID
+}
+
+// plannedOnly narrows outdated, the containers of a group about to be
+// recreated, to the ones the plan for groupKey lists by ID. An outdated
+// container the plan does not list was created or recreated since the plan
+// was made and is skipped; a listed container that is no longer part of the
+// group is reported. Groups without an expectation are returned unchanged.
+func plannedOnly(groupKey string, containers, outdated []container.InspectResponse, expected map[string][]string) []container.InspectResponse {
+	ids, ok := expected[groupKey]
+	if !ok {
+		return outdated
+	}
+	for _, id := range ids {
+		if !slices.ContainsFunc(containers, func(c container.InspectResponse) bool { return c.ID == id }) {
+			log.Printf("[WARN] %s: container %s from the plan no longer exists", sanitize(groupKey), docker.ShortID(id))
+		}
+	}
+	var planned []container.InspectResponse
+	for _, c := range outdated {
+		if !slices.Contains(ids, c.ID) {
+			log.Printf("[WARN] Skipping %s of %s: it is not in the plan (created or recreated since)", sanitize(strings.TrimPrefix(c.Name, "/")), sanitize(groupKey))
+			continue
+		}
+		planned = append(planned, c)
+	}
+	return planned
+}
@@ -0,0 +1,91 @@
+package updater
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+func TestPlanRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plan.json")
+	want := Plan{
+		Version: PlanVersion,
+		Created: time.Date(2026, time.June, 11, 12, 0, 0, 0, time.UTC),
+		Groups: []PlanGroup{
+			{Group: "app:web", Image: "nginx:latest", ImageID: "sha256:new", Containers: []string{"app-web-1"}, ContainerIDs: []string{"c1"}},
+		},
+	}
+
+	if err := WritePlan(path, want); err != nil {
+		t.Fatalf("WritePlan() error = %v", err)
+	}
+	got, err := ReadPlan(path)
+	if err != nil {
+		t.Fatalf("ReadPlan() error = %v", err)
+	}
+	if !got.Created.Equal(want.Created) || len(got.Groups) != 1 || got.Groups[0].ImageID != "sha256:new" || got.Groups[0].Containers[0] != "app-web-1" {
+		t.Errorf("ReadPlan() = %+v, want %+v", got, want)
+	}
+
+	opts := got.Apply(Options{})
+	if !opts.Groups["app:web"] || opts.ExpectedImages["app:web"] != "sha256:new" || !slices.Equal(opts.ExpectedContainers["app:web"], []string{"c1"}) {
+		t.Errorf("Apply() = groups %v, expected %v, %v", opts.Groups, opts.ExpectedImages, opts.ExpectedContainers)
+	}
+}
+
+func TestReadPlanRejectsUnknownVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plan.json")
+	if err := os.WriteFile(path, []byte(`{"version": 99, "groups": []}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadPlan(path); err == nil {
+		t.Error("ReadPlan() error = nil, want unsupported version")
+	}
+}
+
+func TestImageDrifted(t *testing.T) {
+	expected := map[string]string{"app:web": "sha256:planned"}
+
+	tests := []struct {
+		name     string
+		group    string
+		latestID string
+		want     bool
+	}{
+		{name: "matches plan", group: "app:web", latestID: "sha256:planned", want: false},
+		{name: "drifted", group: "app:web", latestID: "sha256:newer", want: true},
+		{name: "not in plan", group: "app:db", latestID: "sha256:anything", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, got := imageDrifted(tt.group, tt.latestID, expected); got != tt.want {
+				t.Errorf("imageDrifted() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestPlannedOnly verifies that applying a plan only recreates the
+// containers it lists by ID: a replica added since is skipped, and one that
+// was removed does not stop the rest.
+func TestPlannedOnly(t *testing.T) {
+	replica := func(id string) container.InspectResponse {
+		return container.InspectResponse{ContainerJSONBase: &container.ContainerJSONBase{ID: id, Name: "/web-" + id}}
+	}
+	// The plan listed c1 and c2; c2 is gone and c3 was scaled up since.
+	live := []container.InspectResponse{replica("c1"), replica("c3")}
+	expected := map[string][]string{"app:web": {"c1", "c2"}}
+
+	got := plannedOnly("app:web", live, live, expected)
+	if len(got) != 1 || got[0].ID != "c1" {
+		t.Errorf("plannedOnly() = %v, want only c1", got)
+	}
+	if got := plannedOnly("app:db", live, live, expected); len(got) != 2 {
+		t.Errorf("plannedOnly() without a plan for the group = %d container(s), want all 2", len(got))
+	}
+}
@@ -0,0 +1,54 @@
+package updater
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/fanuelsen/repull/internal/docker"
+	"github.com/fanuelsen/repull/internal/state"
+)
+
+// stageImage records latestID, just pulled by a --prefetch run, as staged
+// for imageName if any container of the group still runs another image.
+// The next regular run then recreates them from it without pulling, so the
+// expensive pull can happen off-peak and the recreate later.
+func stageImage(groupKey, imageName, latestID string, containers []container.InspectResponse, channeled bool, opts Options) Result {
+	outdated := filterOutdatedContainers(containers, latestID)
+	if channeled {
+		outdated = filterOffChannel(containers, imageName, latestID)
+	}
+	if len(outdated) == 0 {
+		logQuiet(opts, "No new image for %s", sanitize(groupKey))
+		return ResultUpToDate
+	}
+	if opts.DryRun {
+		log.Printf("[DRY-RUN] Would stage %s for %s (%d container(s))", truncateDigest(latestID), sanitize(groupKey), len(outdated))
+		return ResultPending
+	}
+	opts.State.RecordStaged(imageName, state.Staged{ImageID: latestID, Time: time.Now()})
+	log.Printf("[INFO] Staged %s for %s: the next regular run recreates %d container(s) from it", truncateDigest(latestID), sanitize(groupKey), len(outdated))
+	return ResultPulled
+}
+
+// stagedImage returns the image a --prefetch run staged for imageName, as
+// long as the tag still points to it locally. A tag that moved since (a
+// manual pull, or the image was removed) drops the staged entry, and the
+// caller pulls as usual.
+func stagedImage(ctx context.Context, cli *client.Client, imageName string, opts Options) (imageID string, ok bool) {
+	staged, ok := opts.State.StagedImage(imageName)
+	if !ok {
+		return "", false
+	}
+	id, err := docker.GetImageID(ctx, cli, imageName)
+	if err != nil || id != staged.ImageID {
+		log.Printf("[INFO] %s no longer points to the image staged by --prefetch, pulling", sanitize(imageName))
+		if !opts.DryRun {
+			opts.State.ClearStaged(imageName)
+		}
+		return "", false
+	}
+	return id, true
+}
@@ -0,0 +1,122 @@
+package updater
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/fanuelsen/repull/internal/docker"
+	"github.com/fanuelsen/repull/internal/state"
+)
+
+// imageDaemon fakes a daemon on which the tag resolves to *tagID; pulls
+// move it to pulledID.
+func imageDaemon(t *testing.T, tagID *string, pulledID string) (*client.Client, *[]string) {
+	return fakeDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/images/create"):
+			*tagID = pulledID
+			w.WriteHeader(http.StatusOK)
+		case strings.HasSuffix(r.URL.Path, "/json"):
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"Id":"` + *tagID + `"}`))
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	})
+}
+
+func webContainer(imageID string) container.InspectResponse {
+	return container.InspectResponse{
+		ContainerJSONBase: &container.ContainerJSONBase{ID: "c1", Name: "/web", Image: imageID, State: &container.State{Running: true}},
+		Config:            &container.Config{Image: "nginx:latest"},
+	}
+}
+
+func TestPrefetchStagesOutdatedImages(t *testing.T) {
+	tests := []struct {
+		name       string
+		running    string
+		wantResult Result
+		wantStaged bool
+	}{
+		{name: "outdated container", running: "sha256:old", wantResult: ResultPulled, wantStaged: true},
+		{name: "up to date container", running: "sha256:new", wantResult: ResultUpToDate, wantStaged: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tagID := "sha256:old"
+			cli, calls := imageDaemon(t, &tagID, "sha256:new")
+			st, _ := state.Load("")
+
+			opts := Options{PullOnly: true, Prefetch: true, State: st}
+			result, err := pullOnlyGroup(t.Context(), cli, "web", []container.InspectResponse{webContainer(tt.running)}, opts)
+			if err != nil {
+				t.Fatalf("pullOnlyGroup() error = %v", err)
+			}
+			if result != tt.wantResult {
+				t.Errorf("result = %q, want %q", result, tt.wantResult)
+			}
+			staged, ok := st.StagedImage("nginx:latest")
+			if ok != tt.wantStaged || (ok && staged.ImageID != "sha256:new") {
+				t.Errorf("StagedImage() = %+v, %v; want staged %v", staged, ok, tt.wantStaged)
+			}
+			for _, call := range *calls {
+				if strings.HasPrefix(call, "POST /containers/") {
+					t.Errorf("prefetch touched a container: %v", *calls)
+				}
+			}
+		})
+	}
+}
+
+// TestUpdateGroupUsesStagedImage verifies a regular run recreates from the
+// image a prefetch staged without pulling, and pulls as usual once the tag
+// no longer points to it.
+func TestUpdateGroupUsesStagedImage(t *testing.T) {
+	tests := []struct {
+		name     string
+		tagID    string
+		wantPull bool
+	}{
+		{name: "staged image still tagged", tagID: "sha256:new", wantPull: false},
+		{name: "tag moved since", tagID: "sha256:other", wantPull: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tagID := tt.tagID
+			cli, calls := imageDaemon(t, &tagID, "sha256:newer")
+			st, _ := state.Load("")
+			st.RecordStaged("nginx:latest", state.Staged{ImageID: "sha256:new", Time: time.Now()})
+
+			var planned string
+			opts := Options{DryRun: true, State: st, Planned: func(_, _, latestID string, _ []container.InspectResponse) {
+				planned = latestID
+			}}
+			var leftStopped []string
+			result, err := updateGroup(t.Context(), cli, "web", []container.InspectResponse{webContainer("sha256:old")}, opts, make(docker.RecreatedContainers), &leftStopped)
+			if err != nil {
+				t.Fatalf("updateGroup() error = %v", err)
+			}
+			if result != ResultPending {
+				t.Errorf("result = %q, want %q", result, ResultPending)
+			}
+
+			pulled := false
+			for _, call := range *calls {
+				if strings.HasSuffix(call, "/images/create") {
+					pulled = true
+				}
+			}
+			if pulled != tt.wantPull {
+				t.Errorf("pulled = %v, want %v: %v", pulled, tt.wantPull, *calls)
+			}
+			if want := map[bool]string{false: "sha256:new", true: "sha256:newer"}[tt.wantPull]; planned != want {
+				t.Errorf("planned recreate from %q, want %q", planned, want)
+			}
+		})
+	}
+}
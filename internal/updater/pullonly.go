@@ -0,0 +1,91 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/fanuelsen/repull/internal/docker"
+)
+
+// pullOnlyGroup pulls the group's image and reports whether the tag moved to
+// a new image, but never stops or recreates a container. It is the
+// --pull-only counterpart of updateGroup, for hosts where something else
+// restarts containers when a new image lands.
+func pullOnlyGroup(ctx context.Context, cli *client.Client, groupKey string, containers []container.InspectResponse, opts Options) (Result, error) {
+	img, result, err := pullGroupImage(ctx, cli, groupKey, containers, opts)
+	if result != "" {
+		return result, err
+	}
+
+	if opts.Prefetch {
+		return stageImage(groupKey, img.name, img.latestID, containers, img.channeled, opts), nil
+	}
+
+	if img.latestID == img.beforeID {
+		logQuiet(opts, "No new image for %s", sanitize(groupKey))
+		return ResultUpToDate, nil
+	}
+
+	log.Printf("[INFO] New image pulled for %s: %s -> %s (not recreated)", sanitize(groupKey), truncateDigest(img.beforeID), truncateDigest(img.latestID))
+	opts.notifier().SendPulled(sanitize(groupKey), sanitize(img.name), truncateDigest(img.beforeID), truncateDigest(img.latestID))
+	return ResultPulled, nil
+}
+
+// groupImage is a group's image as pullGroupImage found it.
+type groupImage struct {
+	name      string
+	channeled bool
+	// beforeID and latestID are the IDs the tag pointed to before and after
+	// the pull. beforeID is empty if the tag was not local.
+	beforeID, latestID string
+}
+
+// pullGroupImage runs the checks and the pull that pullOnlyGroup and
+// pullRestartGroup share. A non-empty Result ends the group with it.
+func pullGroupImage(ctx context.Context, cli *client.Client, groupKey string, containers []container.InspectResponse, opts Options) (groupImage, Result, error) {
+	notifier := opts.notifier()
+	logQuiet(opts, "Checking %s (%d container(s))", sanitize(groupKey), len(containers))
+
+	imageName, channeled, ok := targetImage(containers[0], opts.Channels)
+	if !ok {
+		log.Printf("[INFO] %s is pinned by digest, skipping %s (set %s to follow a tag)", sanitize(imageName), sanitize(groupKey), TrackLabel)
+		return groupImage{}, ResultSkipped, nil
+	}
+	if err := checkImageRef(imageName); err != nil {
+		log.Printf("[ERROR] Skipping %s: %s", sanitize(groupKey), sanitize(err.Error()))
+		notifier.SendError(sanitize(groupKey), err.Error())
+		return groupImage{}, ResultSkipped, nil
+	}
+
+	if !withinSizeLimit(ctx, cli, groupKey, imageName, opts) || !enoughDisk(groupKey, opts) {
+		return groupImage{}, ResultSkipped, nil
+	}
+
+	// The tag may not exist locally yet (e.g. a container started from a
+	// digest); an empty ID then counts as changed after the pull. The pull
+	// phase looked it up before its own pull.
+	beforeID, _ := docker.GetImageID(ctx, cli, imageName)
+	if p, ok := opts.pulled[imageName]; ok {
+		beforeID = p.beforeID
+	} else {
+		logQuiet(opts, "Pulling image %s", sanitize(imageName))
+	}
+	if err := pullImage(ctx, cli, imageName, opts); err != nil {
+		if opts.SkipMissingImages && docker.IsImageNotFound(err) {
+			log.Printf("[WARN] Image %s no longer exists upstream, skipping %s: %s", sanitize(imageName), sanitize(groupKey), sanitize(err.Error()))
+			return groupImage{}, ResultSkipped, nil
+		}
+		notifier.SendError(sanitize(groupKey), fmt.Sprintf("Failed to pull image %s: %v", sanitize(imageName), err))
+		return groupImage{}, ResultFailed, fmt.Errorf("failed to pull image %s: %w", sanitize(imageName), err)
+	}
+
+	latestID, err := docker.GetImageID(ctx, cli, imageName)
+	if err != nil {
+		notifier.SendError(sanitize(groupKey), fmt.Sprintf("Failed to inspect image %s: %v", sanitize(imageName), err))
+		return groupImage{}, ResultFailed, fmt.Errorf("failed to inspect image %s: %w", sanitize(imageName), err)
+	}
+	return groupImage{name: imageName, channeled: channeled, beforeID: beforeID, latestID: latestID}, "", nil
+}
@@ -0,0 +1,49 @@
+package updater
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// TestPullOnlyGroupNeverRecreates verifies that --pull-only pulls the image
+// and reports the new one without touching the outdated container.
+func TestPullOnlyGroupNeverRecreates(t *testing.T) {
+	pulled := false
+	cli, calls := fakeDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/images/create"):
+			pulled = true
+			w.WriteHeader(http.StatusOK)
+		case strings.HasSuffix(r.URL.Path, "/json"):
+			id := "sha256:old"
+			if pulled {
+				id = "sha256:new"
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"Id":"` + id + `"}`))
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	})
+
+	c := container.InspectResponse{
+		ContainerJSONBase: &container.ContainerJSONBase{ID: "c1", Name: "/web", Image: "sha256:old"},
+		Config:            &container.Config{Image: "nginx:latest"},
+	}
+
+	result, err := pullOnlyGroup(t.Context(), cli, "web", []container.InspectResponse{c}, Options{})
+	if err != nil {
+		t.Fatalf("pullOnlyGroup() error = %v", err)
+	}
+	if result != ResultPulled {
+		t.Errorf("result = %q, want %q", result, ResultPulled)
+	}
+	for _, call := range *calls {
+		if strings.HasPrefix(call, "POST /containers/") {
+			t.Errorf("pull-only touched a container: %v", *calls)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package updater
+
+import (
+	"context"
+	"sync"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/fanuelsen/repull/internal/docker"
+)
+
+// pulledImage is the outcome of pulling one image in the pull phase.
+type pulledImage struct {
+	// beforeID is the image the tag pointed to before the pull, for
+	// pull-only runs; empty if the tag did not exist locally.
+	beforeID string
+	err      error
+}
+
+// pullPhase pulls the images of the given groups up front, at most
+// opts.MaxParallelPulls at a time, for --max-parallel-pulls: pulls are
+// network-bound and gain from running side by side, while recreates stay
+// one group at a time. Each image is pulled once, however many groups run
+// it. Groups that do not pull the usual way (pinned by digest, invalid
+// references, pull-restart, images staged by --prefetch) and images over
+// --max-image-size are left to their group, as are any failures, which the
+// group reports when it reaches its pull.
+func pullPhase(ctx context.Context, cli *client.Client, groups map[string][]container.InspectResponse, keys []string, opts Options) map[string]pulledImage {
+	var images []string
+	seen := make(map[string]bool)
+	for _, groupKey := range keys {
+		containers := groups[groupKey]
+		if !opts.PullOnly {
+			if strategy, err := groupStrategy(containers); err != nil || strategy == StrategyPullRestart {
+				continue
+			}
+		}
+		imageName, _, ok := targetImage(containers[0], opts.Channels)
+		if !ok || seen[imageName] || checkImageRef(imageName) != nil {
+			continue
+		}
+		seen[imageName] = true
+		if !opts.PullOnly {
+			if _, staged := opts.State.StagedImage(imageName); staged {
+				continue
+			}
+		}
+		images = append(images, imageName)
+	}
+
+	pulled := make(map[string]pulledImage, len(images))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, opts.MaxParallelPulls)
+	for _, imageName := range images {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// The slot comes first, so a group queued behind slow pulls
+			// does not spend its timeout waiting for one.
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			pullCtx, cancel := context.WithTimeout(ctx, groupTimeout)
+			defer cancel()
+
+			if opts.MaxImageSize > 0 {
+				size, err := imageSize(pullCtx, cli, opts.Registry, imageName)
+				if err != nil || size > opts.MaxImageSize {
+					return
+				}
+			}
+			var p pulledImage
+			p.beforeID, _ = docker.GetImageID(pullCtx, cli, imageName)
+			logQuiet(opts, "Pulling image %s", sanitize(imageName))
+			p.err = docker.PullImage(pullCtx, cli, imageName)
+
+			mu.Lock()
+			pulled[imageName] = p
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return pulled
+}
+
+// pullImage pulls imageName, unless the pull phase already did; then it
+// returns that pull's error.
+func pullImage(ctx context.Context, cli *client.Client, imageName string, opts Options) error {
+	if p, ok := opts.pulled[imageName]; ok {
+		return p.err
+	}
+	return docker.PullImage(ctx, cli, imageName)
+}
@@ -0,0 +1,122 @@
+package updater
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// inflight tracks how many requests of one kind run at once.
+type inflight struct {
+	mu       sync.Mutex
+	cur, max int
+}
+
+func (f *inflight) do(d time.Duration) {
+	f.mu.Lock()
+	f.cur++
+	f.max = max(f.max, f.cur)
+	f.mu.Unlock()
+	time.Sleep(d)
+	f.mu.Lock()
+	f.cur--
+	f.mu.Unlock()
+}
+
+// TestMaxParallelPulls verifies the pull phase never runs more than
+// MaxParallelPulls pulls at once, pulls each image once however many groups
+// run it, and leaves the recreates serial.
+func TestMaxParallelPulls(t *testing.T) {
+	var pulls, recreates inflight
+	cli, calls := fakeDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/images/create"):
+			pulls.do(20 * time.Millisecond)
+			w.WriteHeader(http.StatusOK)
+		case strings.Contains(r.URL.Path, "/images/") && strings.HasSuffix(r.URL.Path, "/json"):
+			w.Write([]byte(`{"Id":"sha256:new"}`))
+		case r.Method == http.MethodPost && strings.Contains(r.URL.Path, "/containers/"):
+			recreates.do(5 * time.Millisecond)
+			http.Error(w, `{"message":"not in this test"}`, http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	})
+
+	outdated := func(id, image string) []container.InspectResponse {
+		return []container.InspectResponse{{
+			ContainerJSONBase: &container.ContainerJSONBase{ID: id, Name: "/" + id, Image: "sha256:old", State: &container.State{Running: true}, HostConfig: &container.HostConfig{}},
+			Config:            &container.Config{Image: image},
+		}}
+	}
+	groups := map[string][]container.InspectResponse{
+		"a": outdated("a", "acme/a:latest"),
+		"b": outdated("b", "acme/b:latest"),
+		"c": outdated("c", "acme/c:latest"),
+		"d": outdated("d", "acme/d:latest"),
+		"e": outdated("e", "acme/e:latest"),
+		"f": outdated("f", "acme/a:latest"),
+	}
+
+	UpdateGroups(t.Context(), cli, groups, Options{MaxParallelPulls: 2})
+
+	if pulls.max != 2 {
+		t.Errorf("at most %d pulls ran at once, want 2", pulls.max)
+	}
+	if recreates.max != 1 {
+		t.Errorf("at most %d container requests ran at once, want 1", recreates.max)
+	}
+	n := 0
+	for _, call := range *calls {
+		if call == "POST /images/create" {
+			n++
+		}
+	}
+	if n != 5 {
+		t.Errorf("pulled %d time(s), want 5 (one per image)", n)
+	}
+}
+
+// TestPullPhaseTimeoutStartsWithSlot verifies a group's timeout only starts
+// once it holds a pull slot: queued behind slower pulls for longer than the
+// timeout, the last group's pull still succeeds.
+func TestPullPhaseTimeoutStartsWithSlot(t *testing.T) {
+	saved := groupTimeout
+	groupTimeout = 300 * time.Millisecond
+	t.Cleanup(func() { groupTimeout = saved })
+
+	cli, _ := fakeDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/images/create"):
+			time.Sleep(200 * time.Millisecond)
+			w.WriteHeader(http.StatusOK)
+		case strings.HasSuffix(r.URL.Path, "/json"):
+			w.Write([]byte(`{"Id":"sha256:old"}`))
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	})
+
+	groups := make(map[string][]container.InspectResponse)
+	var keys []string
+	for _, name := range []string{"a", "b", "c"} {
+		groups[name] = []container.InspectResponse{{
+			ContainerJSONBase: &container.ContainerJSONBase{ID: name, Name: "/" + name, Image: "sha256:old"},
+			Config:            &container.Config{Image: "acme/" + name + ":latest"},
+		}}
+		keys = append(keys, name)
+	}
+
+	pulled := pullPhase(t.Context(), cli, groups, keys, Options{MaxParallelPulls: 1})
+	for _, name := range keys {
+		if p, ok := pulled["acme/"+name+":latest"]; !ok || p.err != nil {
+			t.Errorf("pull of acme/%s:latest = %v, %v; want it pulled without error", name, ok, p.err)
+		}
+	}
+}
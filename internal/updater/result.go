@@ -0,0 +1,25 @@
+package updater
+
+// Result is the outcome of checking one group in an update cycle.
+type Result string
+
+const (
+	// ResultUpToDate means every container already runs the latest image.
+	ResultUpToDate Result = "up-to-date"
+	// ResultUpdated means the outdated containers were recreated, or
+	// restarted under io.repull.strategy=pull-restart.
+	ResultUpdated Result = "updated"
+	// ResultPulled means --pull-only pulled a new image; no container was
+	// recreated.
+	ResultPulled Result = "pulled"
+	// ResultPending means a dry run found outdated containers.
+	ResultPending Result = "pending"
+	// ResultDeferred means every outdated container was throttled by
+	// io.repull.max-frequency.
+	ResultDeferred Result = "deferred"
+	// ResultSkipped means the group was not checked, e.g. an image pinned by
+	// digest.
+	ResultSkipped Result = "skipped"
+	// ResultFailed means the group failed; an error accompanies it.
+	ResultFailed Result = "failed"
+)
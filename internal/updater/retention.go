@@ -0,0 +1,61 @@
+package updater
+
+import (
+	"context"
+	"log"
+	"time"
+
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/docker/docker/client"
+	"github.com/fanuelsen/repull/internal/docker"
+	"github.com/fanuelsen/repull/internal/state"
+)
+
+// expiredImages returns the images in a newest-first deployment history
+// beyond the keep most recent ones, leaving out protected images (in use by
+// a container, or kept as <repo>:repull-previous).
+func expiredImages(history []state.Deployment, keep int, protected map[string]bool) []string {
+	var expired []string
+	for i, d := range history {
+		if i < keep || protected[d.ImageID] {
+			continue
+		}
+		expired = append(expired, d.ImageID)
+	}
+	return expired
+}
+
+// retainImages records an update of imageName from oldID (deployed at
+// oldDeployed) to latestID and removes the repository's images beyond the
+// opts.KeepImages most recently deployed ones. Images still used by any
+// container, and the one tagged <repo>:repull-previous (backupID), are kept
+// whatever their age. Failures are logged: reclaiming disk is never worth
+// failing an update over.
+func retainImages(ctx context.Context, cli *client.Client, opts Options, imageName, oldID string, oldDeployed time.Time, latestID, backupID string) {
+	repo, err := docker.RepoName(imageName)
+	if err != nil {
+		log.Printf("[WARN] Not pruning images of %s: %v", sanitize(imageName), err)
+		return
+	}
+	opts.State.RecordDeployed(repo, oldID, oldDeployed)
+	opts.State.RecordDeployed(repo, latestID, time.Now())
+
+	protected, err := docker.ImagesInUse(ctx, cli)
+	if err != nil {
+		log.Printf("[WARN] Not pruning images of %s: listing containers failed: %v", sanitize(repo), err)
+		return
+	}
+	protected[latestID] = true
+	if backupID != "" {
+		protected[backupID] = true
+	}
+
+	for _, id := range expiredImages(opts.State.Deployments(repo), opts.KeepImages, protected) {
+		if err := docker.RemoveImage(ctx, cli, id); err != nil && !cerrdefs.IsNotFound(err) {
+			log.Printf("[WARN] Failed to remove old image %s: %v", truncateDigest(id), err)
+			continue
+		}
+		opts.State.ForgetDeployed(repo, id)
+		log.Printf("[INFO] Removed old image %s (--keep-images %d)", truncateDigest(id), opts.KeepImages)
+	}
+}
@@ -0,0 +1,41 @@
+package updater
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/fanuelsen/repull/internal/state"
+)
+
+func TestExpiredImages(t *testing.T) {
+	now := time.Date(2026, time.June, 11, 12, 0, 0, 0, time.UTC)
+	history := []state.Deployment{
+		{ImageID: "sha256:v5", Time: now},
+		{ImageID: "sha256:v4", Time: now.Add(-1 * time.Hour)},
+		{ImageID: "sha256:v3", Time: now.Add(-2 * time.Hour)},
+		{ImageID: "sha256:v2", Time: now.Add(-3 * time.Hour)},
+		{ImageID: "sha256:v1", Time: now.Add(-4 * time.Hour)},
+	}
+
+	tests := []struct {
+		name      string
+		keep      int
+		protected map[string]bool
+		want      []string
+	}{
+		{name: "keep 3", keep: 3, want: []string{"sha256:v2", "sha256:v1"}},
+		{name: "keep all", keep: 5, want: nil},
+		{name: "keep more than known", keep: 10, want: nil},
+		{name: "in use survives", keep: 2, protected: map[string]bool{"sha256:v2": true}, want: []string{"sha256:v3", "sha256:v1"}},
+		{name: "keep 1", keep: 1, protected: map[string]bool{"sha256:v4": true}, want: []string{"sha256:v3", "sha256:v2", "sha256:v1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := expiredImages(history, tt.keep, tt.protected); !slices.Equal(got, tt.want) {
+				t.Errorf("expiredImages() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package updater
+
+import (
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/fanuelsen/repull/internal/notify"
+)
+
+// NotifyKeyLabel tags a group's notifications with a routing key (e.g.
+// team-platform) for a shared notification gateway; see
+// notify.Notifier.WithKey.
+const NotifyKeyLabel = "io.repull.notify-key"
+
+// notifyKey returns the group's io.repull.notify-key, taken from the first
+// container that sets one.
+func notifyKey(containers []container.InspectResponse) string {
+	for _, c := range containers {
+		if c.Config != nil && c.Config.Labels[NotifyKeyLabel] != "" {
+			return c.Config.Labels[NotifyKeyLabel]
+		}
+	}
+	return ""
+}
+
+// notifierFor returns the notifier for a group: the ProjectNotifiers entry
+// for the compose project in the group key ("project:service"), or
+// opts.Notifier if the project has none. Standalone groups always use
+// opts.Notifier.
+func notifierFor(groupKey string, opts Options) notify.Reporter {
+	project, _, ok := strings.Cut(groupKey, ":")
+	if !ok || project == "standalone" {
+		return opts.notifier()
+	}
+	if n, ok := opts.ProjectNotifiers[project]; ok && n != nil {
+		return n
+	}
+	return opts.notifier()
+}
+
+// notifier returns opts.Notifier, or a nil *notify.Notifier, which
+// reports nothing, if there is none.
+func (opts Options) notifier() notify.Reporter {
+	if opts.Notifier == nil {
+		return (*notify.Notifier)(nil)
+	}
+	return opts.Notifier
+}
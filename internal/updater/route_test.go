@@ -0,0 +1,127 @@
+package updater
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/fanuelsen/repull/internal/notify"
+)
+
+func TestNotifierFor(t *testing.T) {
+	global, _ := notify.NewDiscordNotifier("https://discord.com/api/webhooks/1/global")
+	myapp, _ := notify.NewDiscordNotifier("https://discord.com/api/webhooks/2/myapp")
+	opts := Options{
+		Notifier:         global,
+		ProjectNotifiers: map[string]notify.Reporter{"myapp": myapp},
+	}
+
+	tests := []struct {
+		groupKey string
+		want     notify.Reporter
+	}{
+		{"myapp:web", myapp},
+		{"myapp:db", myapp},
+		{"other:web", global},
+		{"standalone:abc123", global},
+		{"myapp", global},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.groupKey, func(t *testing.T) {
+			if got := notifierFor(tt.groupKey, opts); got != tt.want {
+				t.Errorf("notifierFor(%q) = %p, want %p", tt.groupKey, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestNotifyKeyPropagates verifies that a group's io.repull.notify-key
+// reaches its notifications, and an untagged group's do not carry one.
+func TestNotifyKeyPropagates(t *testing.T) {
+	cli, _ := fakeDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+		// Every pull fails, so each group sends an error notification.
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	file, err := notify.NewFileNotifier(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var notifier *notify.Notifier
+
+	group := func(name string, labels map[string]string) []container.InspectResponse {
+		return []container.InspectResponse{{
+			ContainerJSONBase: &container.ContainerJSONBase{ID: name, Name: "/" + name, Image: "sha256:old"},
+			Config:            &container.Config{Image: name + ":latest", Labels: labels},
+		}}
+	}
+	groups := map[string][]container.InspectResponse{
+		"app:tagged":   group("tagged", map[string]string{NotifyKeyLabel: "team-platform"}),
+		"app:untagged": group("untagged", nil),
+	}
+	UpdateGroups(t.Context(), cli, groups, Options{Notifier: notifier.WithFile(file)})
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var e notify.FileEvent
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("bad event line %q: %v", line, err)
+		}
+		keys[e.Service] = e.Key
+	}
+	if keys["app:tagged"] != "team-platform" {
+		t.Errorf("tagged group key = %q, want team-platform (events: %v)", keys["app:tagged"], keys)
+	}
+	if key, ok := keys["app:untagged"]; !ok || key != "" {
+		t.Errorf("untagged group key = %q (sent %v), want an event without a key", key, ok)
+	}
+}
+
+// fakeReporter records the errors reported to it, with the routing key of
+// the reporter they went through. The embedded nil *notify.Notifier
+// ignores everything else.
+type fakeReporter struct {
+	*notify.Notifier
+	key    string
+	errors *[]string
+}
+
+func (f fakeReporter) SendError(service, errorMsg string) {
+	*f.errors = append(*f.errors, f.key+" "+service)
+}
+
+func (f fakeReporter) WithKey(key string) notify.Reporter {
+	f.key = key
+	return f
+}
+
+// TestUpdateGroupsReporter verifies UpdateGroups reports through whatever
+// Reporter it is given, keyed per group.
+func TestUpdateGroupsReporter(t *testing.T) {
+	cli, _ := fakeDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	groups := map[string][]container.InspectResponse{
+		"app:web": {{
+			ContainerJSONBase: &container.ContainerJSONBase{ID: "web", Name: "/web", Image: "sha256:old"},
+			Config:            &container.Config{Image: "web:latest", Labels: map[string]string{NotifyKeyLabel: "team-web"}},
+		}},
+	}
+
+	var errors []string
+	UpdateGroups(t.Context(), cli, groups, Options{Notifier: fakeReporter{errors: &errors}})
+
+	if !slices.Equal(errors, []string{"team-web app:web"}) {
+		t.Errorf("reported errors = %q, want the failed pull of app:web keyed team-web", errors)
+	}
+}
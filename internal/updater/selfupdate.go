@@ -0,0 +1,41 @@
+package updater
+
+import (
+	"time"
+
+	"github.com/fanuelsen/repull/internal/state"
+)
+
+// selfUpdateWindow is how long failed self-update attempts count against
+// --self-update-max-attempts. Once the last failure is older, the image
+// gets another try, in case whatever broke it (a flapping registry, a
+// full disk) has been fixed since.
+const selfUpdateWindow = 24 * time.Hour
+
+// selfUpdateBlocked reports whether updating the named repull instance to
+// latestID has failed maxAttempts times within selfUpdateWindow, so the
+// update is skipped instead of being retried on every run. maxAttempts 0
+// never blocks.
+func selfUpdateBlocked(name, latestID string, st *state.State, maxAttempts int, now time.Time) (state.SelfUpdate, bool) {
+	if maxAttempts <= 0 {
+		return state.SelfUpdate{}, false
+	}
+	su, ok := st.SelfUpdateAttempts(name)
+	if !ok || su.ImageID != latestID || now.Sub(su.Time) > selfUpdateWindow {
+		return su, false
+	}
+	return su, su.Attempts >= maxAttempts
+}
+
+// recordSelfUpdateFailure counts a failed attempt to update the named
+// repull instance to latestID. Attempts for another image, or older than
+// selfUpdateWindow, start the count over.
+func recordSelfUpdateFailure(name, latestID string, st *state.State, now time.Time) {
+	su, ok := st.SelfUpdateAttempts(name)
+	if !ok || su.ImageID != latestID || now.Sub(su.Time) > selfUpdateWindow {
+		su = state.SelfUpdate{ImageID: latestID}
+	}
+	su.Attempts++
+	su.Time = now
+	st.RecordSelfUpdate(name, su)
+}
@@ -0,0 +1,122 @@
+package updater
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/fanuelsen/repull/internal/notify"
+	"github.com/fanuelsen/repull/internal/state"
+)
+
+// TestSelfUpdateNotifiesBeforeRename verifies a self-update reports its
+// start before the old container is renamed, so there is a trace even if
+// the process dies halfway, and reports a failed rename as a self-update
+// failure.
+func TestSelfUpdateNotifiesBeforeRename(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	file, err := notify.NewFileNotifier(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var notifier *notify.Notifier
+	notifier = notifier.WithFile(file)
+
+	var eventsAtRename string
+	cli, _ := fakeDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/rename") {
+			data, _ := os.ReadFile(path)
+			eventsAtRename = string(data)
+			http.Error(w, `{"message":"conflict"}`, http.StatusConflict)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	old := isSelf
+	isSelf = func(container.InspectResponse) bool { return true }
+	t.Cleanup(func() { isSelf = old })
+
+	c := container.InspectResponse{
+		ContainerJSONBase: &container.ContainerJSONBase{ID: "abcdef123456789012345678901234567890", Name: "/repull", Image: "sha256:old"},
+		Config:            &container.Config{Image: "ghcr.io/fanuelsen/repull:latest"},
+	}
+	err = updateRepullInstance(t.Context(), cli, c, "repull", "standalone:repull", "ghcr.io/fanuelsen/repull:latest", "sha256:old", "sha256:new", notifier, 0)
+	if err == nil {
+		t.Fatal("updateRepullInstance() error = nil, want the rename failure")
+	}
+
+	if !strings.Contains(eventsAtRename, `"event":"self-update-start"`) {
+		t.Errorf("events when the rename was attempted = %q, want the self-update start", eventsAtRename)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"event":"self-update-failed"`) {
+		t.Errorf("events = %q, want a self-update failure", data)
+	}
+}
+
+// TestSelfUpdateMaxAttempts verifies failed attempts to update a repull
+// instance to one image are counted, and that the instance is then skipped,
+// with a single notification, instead of being renamed again on every run.
+func TestSelfUpdateMaxAttempts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	file, err := notify.NewFileNotifier(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var notifier *notify.Notifier
+	notifier = notifier.WithFile(file)
+
+	renames := 0
+	cli, _ := fakeDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/rename"):
+			renames++
+			http.Error(w, `{"message":"conflict"}`, http.StatusConflict)
+		case strings.HasSuffix(r.URL.Path, "/json"):
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"Id":"sha256:new"}`))
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	})
+
+	st, _ := state.Load("")
+	groups := map[string][]container.InspectResponse{"standalone:repull": {{
+		ContainerJSONBase: &container.ContainerJSONBase{ID: "abcdef123456789012345678901234567890", Name: "/repull", Image: "sha256:old", State: &container.State{Running: true}, HostConfig: &container.HostConfig{}},
+		Config:            &container.Config{Image: "ghcr.io/fanuelsen/repull:latest", Labels: map[string]string{"io.repull.app": "true"}},
+	}}}
+	opts := Options{Notifier: notifier, State: st, SelfUpdateMaxAttempts: 2}
+	for range 4 {
+		UpdateGroups(t.Context(), cli, groups, opts)
+	}
+
+	if renames != 2 {
+		t.Errorf("renamed %d time(s), want 2 (then skipped)", renames)
+	}
+	su, ok := st.SelfUpdateAttempts("repull")
+	if !ok || su.ImageID != "sha256:new" || su.Attempts != 2 || !su.Notified {
+		t.Errorf("SelfUpdateAttempts(repull) = %+v, %v; want 2 notified attempts for sha256:new", su, ok)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(data), "Not updating repull"); n != 1 {
+		t.Errorf("skip notified %d time(s), want once: %s", n, data)
+	}
+
+	// Attempts recorded for another image do not block this one.
+	st.RecordSelfUpdate("repull", state.SelfUpdate{ImageID: "sha256:older", Attempts: 5, Time: time.Now()})
+	UpdateGroups(t.Context(), cli, groups, opts)
+	if renames != 3 {
+		t.Errorf("renamed %d time(s) with attempts for another image, want 3", renames)
+	}
+}
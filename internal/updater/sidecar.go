@@ -0,0 +1,113 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+	"github.com/fanuelsen/repull/internal/docker"
+)
+
+// SidecarLabel names the container a sidecar belongs to, by container name
+// or, within the same compose project, by service name. Whenever that
+// container is recreated, its sidecars are recreated along with it, and a
+// failure on either side rolls back both.
+const SidecarLabel = "io.repull.sidecar-of"
+
+// findSidecars returns the running containers whose SidecarLabel names app,
+// either by its container name or, if app belongs to a compose project, by
+// its service name within that project.
+func findSidecars(ctx context.Context, cli *client.Client, app container.InspectResponse) ([]container.InspectResponse, error) {
+	filter := filters.NewArgs()
+	filter.Add("status", "running")
+	filter.Add("label", SidecarLabel)
+
+	list, err := cli.ContainerList(ctx, container.ListOptions{Filters: filter})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	name := strings.TrimPrefix(app.Name, "/")
+	var project, service string
+	if app.Config != nil {
+		project = app.Config.Labels[ComposeProjectLabel]
+		service = app.Config.Labels[ComposeServiceLabel]
+	}
+	var sidecars []container.InspectResponse
+	for _, c := range list {
+		if c.ID == app.ID {
+			continue
+		}
+		owner := c.Labels[SidecarLabel]
+		sameService := project != "" && service != "" && owner == service && c.Labels[ComposeProjectLabel] == project
+		if owner != name && !sameService {
+			continue
+		}
+		inspect, err := cli.ContainerInspect(ctx, c.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to inspect sidecar %s: %w", docker.ShortID(c.ID), err)
+		}
+		sidecars = append(sidecars, inspect)
+	}
+	return sidecars, nil
+}
+
+// recreateWithSidecars recreates app and then its sidecars as one unit: the
+// old containers are kept until all replacements run. If any of them fails,
+// every replacement is removed and the old containers are brought back,
+// app first, so a sidecar sharing its network namespace has one to join.
+// Errors are returned like docker.RecreateContainer's, for app.
+func recreateWithSidecars(ctx context.Context, cli *client.Client, app container.InspectResponse, sidecars []container.InspectResponse, recreated docker.RecreatedContainers, ropts docker.RecreateOptions) (string, error) {
+	appName := strings.TrimPrefix(app.Name, "/")
+	appRec, err := docker.RecreateContainerPending(ctx, cli, app, recreated, ropts)
+	if err != nil {
+		return "", err
+	}
+	// Sidecars with network_mode: container:<app> join the replacement.
+	recreated[app.ID] = appRec.NewID
+	pending := []*docker.PendingRecreate{appRec}
+
+	for _, sc := range sidecars {
+		scName := strings.TrimPrefix(sc.Name, "/")
+		log.Printf("[INFO] Recreating sidecar %s of %s", sanitize(scName), sanitize(appName))
+		p, err := docker.RecreateContainerPending(ctx, cli, sc, recreated, ropts)
+		if err != nil {
+			rolledBack := true
+			for _, p := range pending {
+				if !p.Revert(ctx, cli) {
+					rolledBack = false
+				}
+			}
+			delete(recreated, app.ID)
+			for _, other := range sidecars {
+				delete(recreated, other.ID)
+			}
+			// The failed sidecar restores itself, but cannot start again
+			// while the app whose network namespace it shares is down.
+			var recErr *docker.RecreateError
+			if errors.As(err, &recErr) && !recErr.RolledBack {
+				rbCtx, cancel := docker.RollbackContext(ctx)
+				if cli.ContainerStart(rbCtx, sc.ID, container.StartOptions{}) != nil {
+					rolledBack = false
+				}
+				cancel()
+			}
+			return "", &docker.RecreateError{Container: appName, RolledBack: rolledBack, Err: fmt.Errorf("sidecar %s failed, rolled back with it: %w", sanitize(scName), err)}
+		}
+		recreated[sc.ID] = p.NewID
+		pending = append(pending, p)
+	}
+
+	for _, p := range pending {
+		p.Commit(ctx, cli)
+	}
+	for _, sc := range sidecars {
+		log.Printf("[INFO] Successfully recreated sidecar %s", sanitize(strings.TrimPrefix(sc.Name, "/")))
+	}
+	return appRec.NewID, nil
+}
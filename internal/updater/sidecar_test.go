@@ -0,0 +1,91 @@
+package updater
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/fanuelsen/repull/internal/docker"
+)
+
+// sidecarDaemon fakes a daemon running the container "app" with the sidecar
+// "log" (io.repull.sidecar-of=app). Replacements get the ID "new-<name>";
+// starting the one named in failStart fails.
+func sidecarDaemon(t *testing.T, failStart string) (*[]string, func() (string, error)) {
+	cli, calls := fakeDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/containers/json"):
+			w.Write([]byte(`[{"Id":"web","Labels":{}},{"Id":"log","Labels":{"io.repull.sidecar-of":"app"}},{"Id":"other","Labels":{"io.repull.sidecar-of":"db"}}]`))
+		case strings.HasSuffix(r.URL.Path, "/containers/log/json"):
+			w.Write([]byte(`{"Id":"log","Name":"/log","Image":"sha256:fluent","HostConfig":{"NetworkMode":"container:app"},"Config":{"Image":"fluent:latest","Labels":{"io.repull.sidecar-of":"app"}}}`))
+		case strings.HasSuffix(r.URL.Path, "/containers/create"):
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"Id":"new-` + strings.TrimPrefix(r.URL.Query().Get("name"), "/") + `"}`))
+		case failStart != "" && strings.HasSuffix(r.URL.Path, "/containers/new-"+failStart+"/start"):
+			http.Error(w, `{"message":"exec format error"}`, http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	})
+	app := container.InspectResponse{
+		ContainerJSONBase: &container.ContainerJSONBase{ID: "app", Name: "/app", Image: "sha256:old", HostConfig: &container.HostConfig{}},
+		Config:            &container.Config{Image: "app:latest"},
+	}
+	recreate := func() (string, error) {
+		sidecars, err := findSidecars(t.Context(), cli, app)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(sidecars) != 1 || sidecars[0].ID != "log" {
+			t.Fatalf("findSidecars() = %d container(s), want log only", len(sidecars))
+		}
+		return recreateWithSidecars(t.Context(), cli, app, sidecars, make(docker.RecreatedContainers), docker.RecreateOptions{})
+	}
+	return calls, recreate
+}
+
+// TestRecreateWithSidecars verifies an app and its sidecar are recreated as
+// one unit: the old containers are only removed once both replacements run,
+// and the sidecar joins the new app's network namespace.
+func TestRecreateWithSidecars(t *testing.T) {
+	calls, recreate := sidecarDaemon(t, "")
+	newID, err := recreate()
+	if err != nil {
+		t.Fatalf("recreateWithSidecars() error = %v", err)
+	}
+	if newID != "new-app" {
+		t.Errorf("new ID = %q, want new-app", newID)
+	}
+	startLog := slices.Index(*calls, "POST /containers/new-log/start")
+	removeApp := slices.Index(*calls, "DELETE /containers/app")
+	if startLog < 0 || removeApp < 0 || removeApp < startLog {
+		t.Errorf("calls = %v, want the old app removed after the sidecar started", *calls)
+	}
+	if !slices.Contains(*calls, "DELETE /containers/log") {
+		t.Errorf("calls = %v, want the old sidecar removed", *calls)
+	}
+}
+
+// TestRecreateWithSidecarsRollsBack verifies a sidecar failing to start
+// rolls back the already recreated app too: its replacement is removed and
+// the old container renamed back and started, instead of being removed.
+func TestRecreateWithSidecarsRollsBack(t *testing.T) {
+	calls, recreate := sidecarDaemon(t, "log")
+	_, err := recreate()
+	var recErr *docker.RecreateError
+	if !errors.As(err, &recErr) || recErr.Container != "app" || !recErr.RolledBack {
+		t.Fatalf("recreateWithSidecars() error = %v, want a rolled-back RecreateError for app", err)
+	}
+	for _, want := range []string{"DELETE /containers/new-app", "POST /containers/app/rename", "POST /containers/app/start"} {
+		if !slices.Contains(*calls, want) {
+			t.Errorf("calls = %v, missing %q", *calls, want)
+		}
+	}
+	if slices.Contains(*calls, "DELETE /containers/app") {
+		t.Errorf("calls = %v, the old app was removed", *calls)
+	}
+}
@@ -0,0 +1,133 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+
+	"github.com/docker/docker/client"
+	"github.com/fanuelsen/repull/internal/docker"
+	"github.com/fanuelsen/repull/internal/registry"
+)
+
+// armVersion matches the ARM machine names uname reports, e.g. armv7l.
+var armVersion = regexp.MustCompile(`^armv([5-8])`)
+
+// daemonPlatform returns the platform of the daemon's images, with the
+// variant a multi-platform index tells ARM images apart by: v8 for arm64,
+// and the version of the machine for 32-bit arm.
+func daemonPlatform(ctx context.Context, cli *client.Client) (registry.Platform, error) {
+	v, err := cli.ServerVersion(ctx)
+	if err != nil {
+		return registry.Platform{}, fmt.Errorf("querying daemon platform: %w", err)
+	}
+	p := registry.Platform{OS: v.Os, Architecture: v.Arch}
+	switch v.Arch {
+	case "arm64":
+		p.Variant = "v8"
+	case "arm":
+		// Without the machine name any arm image matches, as before.
+		if info, err := cli.Info(ctx); err == nil {
+			if m := armVersion.FindStringSubmatch(info.Architecture); m != nil {
+				p.Variant = "v" + m[1]
+			}
+		}
+	}
+	return p, nil
+}
+
+// imageSize asks the registry for the compressed size of imageName for the
+// daemon's platform, without pulling it.
+func imageSize(ctx context.Context, cli *client.Client, reg *registry.Client, imageName string) (int64, error) {
+	platform, err := daemonPlatform(ctx, cli)
+	if err != nil {
+		return 0, err
+	}
+	auth, _ := docker.CredentialsFor(imageName)
+	return reg.ImageSize(ctx, imageName, platform, auth)
+}
+
+// withinSizeLimit reports whether imageName may be pulled under
+// --max-image-size, notifying when it is over the limit. It checks the size
+// before pulling, e.g. to protect a metered connection. A failed lookup does
+// not block the pull: registries differ in what they expose, and a size
+// guard should not stop all updates.
+func withinSizeLimit(ctx context.Context, cli *client.Client, groupKey, imageName string, opts Options) bool {
+	if opts.MaxImageSize <= 0 {
+		return true
+	}
+	size, err := imageSize(ctx, cli, opts.Registry, imageName)
+	switch {
+	case err != nil:
+		log.Printf("[WARN] Could not determine size of %s, pulling anyway: %v", sanitize(imageName), err)
+	case size > opts.MaxImageSize:
+		msg := fmt.Sprintf("Skipped: image %s is %s, over the %s limit", sanitize(imageName), formatSize(size), formatSize(opts.MaxImageSize))
+		log.Printf("[WARN] %s: %s", sanitize(groupKey), msg)
+		opts.notifier().SendError(sanitize(groupKey), msg)
+		return false
+	}
+	return true
+}
+
+// downloadSize estimates how much a pull of imageName downloads: the
+// compressed size of its layers not in local (see docker.LocalLayers), plus
+// its config. The layers it counts are added to local, so a layer shared by
+// several images of one run is only counted once.
+func downloadSize(ctx context.Context, cli *client.Client, reg *registry.Client, imageName string, local map[string]bool) (int64, error) {
+	platform, err := daemonPlatform(ctx, cli)
+	if err != nil {
+		return 0, err
+	}
+	auth, _ := docker.CredentialsFor(imageName)
+	layers, configSize, err := reg.Layers(ctx, imageName, platform, auth)
+	if err != nil {
+		return 0, err
+	}
+	return missingSize(layers, configSize, local), nil
+}
+
+// missingSize sums configSize and the sizes of the layers not in local,
+// adding those to local.
+func missingSize(layers []registry.Layer, configSize int64, local map[string]bool) int64 {
+	size := configSize
+	for _, l := range layers {
+		if !local[l.DiffID] {
+			size += l.Size
+			local[l.DiffID] = true
+		}
+	}
+	return size
+}
+
+// describeDownload renders the download estimate for the dry-run output.
+// A dry run pulls the image itself, so the estimate is made against the
+// layers present when the run started (opts.localLayers); without those, or
+// if the registry cannot tell, it says so instead of guessing.
+func describeDownload(ctx context.Context, cli *client.Client, opts Options, imageName string) string {
+	if opts.localLayers == nil {
+		return ""
+	}
+	size, err := downloadSize(ctx, cli, opts.Registry, imageName, opts.localLayers)
+	if err != nil {
+		if opts.Debug {
+			log.Printf("[DEBUG] Could not estimate download size of %s: %s", sanitize(imageName), sanitize(err.Error()))
+		}
+		return ", download size unknown"
+	}
+	return fmt.Sprintf(", ~%s to download", formatSize(size))
+}
+
+// formatSize renders a byte count for logs and notifications.
+func formatSize(n int64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "kMGTPE"[exp])
+}
@@ -0,0 +1,94 @@
+package updater
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/fanuelsen/repull/internal/registry"
+	"github.com/fanuelsen/repull/internal/state"
+)
+
+func TestFormatSize(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want string
+	}{
+		{0, "0 B"},
+		{999, "999 B"},
+		{1500, "1.5 kB"},
+		{2_000_000_000, "2.0 GB"},
+		{123_400_000, "123.4 MB"},
+	}
+
+	for _, tt := range tests {
+		if got := formatSize(tt.n); got != tt.want {
+			t.Errorf("formatSize(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestMissingSize(t *testing.T) {
+	base := registry.Layer{DiffID: "sha256:base", Size: 30_000_000}
+	app := registry.Layer{DiffID: "sha256:app", Size: 5_000_000}
+	other := registry.Layer{DiffID: "sha256:other", Size: 7_000_000}
+
+	local := map[string]bool{"sha256:base": true}
+
+	// Only the layer the host lacks counts, plus the config.
+	if got := missingSize([]registry.Layer{base, app}, 1000, local); got != 5_001_000 {
+		t.Errorf("missingSize() = %d, want 5001000", got)
+	}
+	// A second image sharing that layer does not count it again.
+	if got := missingSize([]registry.Layer{base, app, other}, 1000, local); got != 7_001_000 {
+		t.Errorf("missingSize() for an image sharing layers = %d, want 7001000", got)
+	}
+}
+
+// TestPullOnlyMaxImageSize verifies --max-image-size also guards --pull-only
+// and --prefetch: an image over the limit is never pulled.
+func TestPullOnlyMaxImageSize(t *testing.T) {
+	reg := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/vnd.docker.distribution.manifest.v2+json")
+		w.Write([]byte(`{"config":{"size":1000},"layers":[{"size":500000000}]}`))
+	}))
+	defer reg.Close()
+	imageName := strings.TrimPrefix(reg.URL, "http://") + "/acme/app:latest"
+
+	for _, prefetch := range []bool{false, true} {
+		t.Run(fmt.Sprintf("prefetch=%v", prefetch), func(t *testing.T) {
+			cli, calls := fakeDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				if strings.HasSuffix(r.URL.Path, "/version") {
+					w.Write([]byte(`{"Os":"linux","Arch":"amd64"}`))
+					return
+				}
+				w.Write([]byte(`{"Id":"sha256:old"}`))
+			})
+			st, _ := state.Load("")
+			opts := Options{
+				PullOnly:     true,
+				Prefetch:     prefetch,
+				State:        st,
+				MaxImageSize: 100 << 20,
+				Registry:     &registry.Client{HTTP: reg.Client(), Scheme: "http"},
+			}
+			containers := []container.InspectResponse{{
+				ContainerJSONBase: &container.ContainerJSONBase{ID: "app", Name: "/app", Image: "sha256:old"},
+				Config:            &container.Config{Image: imageName},
+			}}
+
+			result, err := pullOnlyGroup(t.Context(), cli, "app:app", containers, opts)
+			if err != nil || result != ResultSkipped {
+				t.Errorf("pullOnlyGroup() = %v, %v; want skipped", result, err)
+			}
+			if slices.Contains(*calls, "POST /images/create") {
+				t.Errorf("calls = %v, pulled an image over the limit", *calls)
+			}
+		})
+	}
+}
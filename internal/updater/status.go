@@ -0,0 +1,27 @@
+package updater
+
+import (
+	"context"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/fanuelsen/repull/internal/docker"
+)
+
+// reportStatus records a checked group in opts.Status. The digest is the
+// image the group runs afterwards: the image its tag now points to once it
+// was updated, the one it ran before otherwise.
+func reportStatus(ctx context.Context, cli *client.Client, opts Options, groupKey string, containers []container.InspectResponse, result Result, errMsg string) {
+	if opts.Status == nil {
+		return
+	}
+	digest := containers[0].Image
+	if result == ResultUpdated {
+		if imageName, _, ok := targetImage(containers[0], opts.Channels); ok {
+			if id, err := docker.GetImageID(ctx, cli, imageName); err == nil {
+				digest = id
+			}
+		}
+	}
+	opts.Status.GroupChecked(groupKey, containers[0].Config.Image, digest, string(result), errMsg)
+}
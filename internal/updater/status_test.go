@@ -0,0 +1,63 @@
+package updater
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/fanuelsen/repull/internal/status"
+)
+
+// TestUpdateGroupsReportsStatus verifies every checked group lands in the
+// status snapshot with its image, digest, result and error.
+func TestUpdateGroupsReportsStatus(t *testing.T) {
+	cli, _ := fakeDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/images/create"):
+			if strings.HasSuffix(r.URL.Query().Get("fromImage"), "/broken") {
+				http.Error(w, `{"message":"registry unavailable"}`, http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		case strings.HasSuffix(r.URL.Path, "/json"):
+			w.Write([]byte(`{"Id":"sha256:new"}`))
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	})
+
+	broken := webContainer("sha256:old")
+	broken.ID, broken.Name = "c2", "/broken"
+	broken.Config = &container.Config{Image: "acme/broken:latest"}
+	groups := map[string][]container.InspectResponse{
+		"app:web":    {webContainer("sha256:old")},
+		"app:broken": {broken},
+	}
+
+	tracker := status.NewTracker()
+	UpdateGroups(t.Context(), cli, groups, Options{DryRun: true, Status: tracker})
+
+	snap := tracker.Snapshot()
+	tests := []struct {
+		group      string
+		wantImage  string
+		wantDigest string
+		wantAction Result
+		wantError  bool
+	}{
+		{"app:web", "nginx:latest", "sha256:old", ResultPending, false},
+		{"app:broken", "acme/broken:latest", "sha256:old", ResultFailed, true},
+	}
+	for _, tt := range tests {
+		g, ok := snap.Groups[tt.group]
+		if !ok {
+			t.Errorf("%s missing from the snapshot: %+v", tt.group, snap.Groups)
+			continue
+		}
+		if g.Image != tt.wantImage || g.Digest != tt.wantDigest || g.LastAction != string(tt.wantAction) || (g.LastError != "") != tt.wantError || g.LastCheck.IsZero() {
+			t.Errorf("%s = %+v, want image %s, digest %s, action %s, error %v", tt.group, g, tt.wantImage, tt.wantDigest, tt.wantAction, tt.wantError)
+		}
+	}
+}
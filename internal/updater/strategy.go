@@ -0,0 +1,203 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/fanuelsen/repull/internal/docker"
+	"github.com/fanuelsen/repull/internal/state"
+)
+
+// StrategyLabel selects how a group is updated, e.g.
+// io.repull.strategy=pull-restart.
+const StrategyLabel = "io.repull.strategy"
+
+// Update strategies.
+const (
+	// StrategyRecreate replaces outdated containers; the default.
+	StrategyRecreate = "recreate"
+	// StrategyPullRestart pulls the new image and restarts the containers
+	// in place (see pullRestartGroup).
+	StrategyPullRestart = "pull-restart"
+)
+
+// groupStrategy returns the update strategy of a group, taken from its first
+// container like the group's image.
+func groupStrategy(containers []container.InspectResponse) (string, error) {
+	if len(containers) == 0 || containers[0].Config == nil {
+		return StrategyRecreate, nil
+	}
+	switch v := containers[0].Config.Labels[StrategyLabel]; v {
+	case "", StrategyRecreate:
+		return StrategyRecreate, nil
+	case StrategyPullRestart:
+		return StrategyPullRestart, nil
+	default:
+		return "", fmt.Errorf("invalid %s=%q: must be %s or %s", StrategyLabel, v, StrategyRecreate, StrategyPullRestart)
+	}
+}
+
+// pullRestartGroup pulls the group's image like pullOnlyGroup and restarts
+// the containers in place instead of recreating them. Apart from that it
+// goes through the same steps as updateGroup: a plan's drift check, restart
+// loops, --min-container-age, io.repull.max-frequency, --one-per-run,
+// canaries, --max-load and dry runs all apply to the restart.
+//
+// A restart does not switch a container to the new image: Docker binds a
+// container to an image ID when it is created, and restarting re-executes
+// that same image. The strategy suits containers whose code lives in bind
+// mounts and that only need a restart to pick up what changed there; the
+// pulled image is used the next time the container is recreated (e.g. by
+// docker compose up). The containers therefore stay outdated, so which of
+// them still need a restart is decided by restartsOwed — not by the image
+// they run.
+func pullRestartGroup(ctx context.Context, cli *client.Client, groupKey string, containers []container.InspectResponse, opts Options) (Result, error) {
+	notifier := opts.notifier()
+
+	// See updateGroup: without a state file the canary is forgotten.
+	if isCanaryGroup(containers) && !opts.State.Persistent() {
+		log.Printf("[WARN] Skipping %s: %s needs --state-file to hold the rest of the group back until --promote", sanitize(groupKey), CanaryLabel)
+		return ResultSkipped, nil
+	}
+
+	img, result, err := pullGroupImage(ctx, cli, groupKey, containers, opts)
+	if result != "" {
+		return result, err
+	}
+	latestID := img.latestID
+
+	if want, drifted := imageDrifted(groupKey, latestID, opts.ExpectedImages); drifted {
+		log.Printf("[WARN] Skipping %s: %s now resolves to %s, but the plan was made for %s", sanitize(groupKey), sanitize(img.name), truncateDigest(latestID), truncateDigest(want))
+		return ResultSkipped, nil
+	}
+
+	owed := restartsOwed(ctx, cli, containers, img)
+	if len(owed) == 0 {
+		if !opts.DryRun {
+			opts.State.ClearCanary(groupKey)
+		}
+		logQuiet(opts, "No new image for %s", sanitize(groupKey))
+		return ResultUpToDate, nil
+	}
+
+	owed = plannedOnly(groupKey, containers, owed, opts.ExpectedContainers)
+	if len(owed) == 0 {
+		return ResultSkipped, nil
+	}
+	owed = skipRestartLooping(groupKey, owed, opts.RestartLoopThreshold, notifier, opts.State, time.Now())
+	if len(owed) == 0 {
+		return ResultSkipped, nil
+	}
+	owed = deferYoung(owed, opts.MinContainerAge, time.Now())
+	if len(owed) == 0 {
+		return ResultDeferred, nil
+	}
+	owed, err = deferThrottled(owed, opts.State, time.Now())
+	if err != nil {
+		notifier.SendError(sanitize(groupKey), err.Error())
+		return ResultFailed, err
+	}
+	if len(owed) == 0 {
+		return ResultDeferred, nil
+	}
+	if opts.deferRecreate {
+		log.Printf("[INFO] Deferring %s: --one-per-run already updated a group this run", sanitize(groupKey))
+		return ResultDeferred, nil
+	}
+	var canary bool
+	owed, canary, err = canaryStep(groupKey, containers, owed, latestID, opts.Promote == groupKey, opts.State)
+	if err != nil {
+		notifier.SendError(sanitize(groupKey), err.Error())
+		return ResultFailed, err
+	}
+	if len(owed) == 0 {
+		return ResultDeferred, nil
+	}
+
+	oldID := owed[0].Image
+	if opts.Planned != nil {
+		opts.Planned(groupKey, img.name, latestID, owed)
+	}
+
+	if opts.DryRun {
+		for _, c := range owed {
+			log.Printf("[DRY-RUN] Would restart %s (%s=%s)", sanitize(strings.TrimPrefix(c.Name, "/")), StrategyLabel, StrategyPullRestart)
+		}
+		if opts.NotifyAvailable {
+			notifier.SendAvailable(sanitize(groupKey), sanitize(img.name), truncateDigest(oldID), truncateDigest(latestID))
+		}
+		return ResultPending, nil
+	}
+
+	for _, c := range owed {
+		name := strings.TrimPrefix(c.Name, "/")
+		if err := waitForLoad(ctx, opts.MaxLoad); err != nil {
+			notifier.SendError(sanitize(groupKey), fmt.Sprintf("Did not restart %s: %v", sanitize(name), err))
+			return ResultFailed, fmt.Errorf("did not restart %s: %w", sanitize(name), err)
+		}
+		log.Printf("[INFO] Restarting %s (%s=%s; it keeps its current image)", sanitize(name), StrategyLabel, StrategyPullRestart)
+		if err := docker.RestartContainer(ctx, cli, c); err != nil {
+			notifier.SendError(sanitize(groupKey), fmt.Sprintf("Failed to restart %s: %v", sanitize(name), err))
+			return ResultFailed, fmt.Errorf("failed to restart %s: %w", sanitize(name), err)
+		}
+		opts.State.RecordRecreated(name, time.Now())
+	}
+
+	if canary {
+		canaryName := strings.TrimPrefix(owed[0].Name, "/")
+		opts.State.RecordCanary(groupKey, state.Canary{Container: canaryName, ImageID: latestID, Time: time.Now()})
+		log.Printf("[INFO] Canary %s of %s restarted; run repull --promote %s to restart the rest", sanitize(canaryName), sanitize(groupKey), sanitize(groupKey))
+		notifier.SendCanary(sanitize(groupKey), sanitize(canaryName), sanitize(img.name), truncateDigest(oldID), truncateDigest(latestID))
+		return ResultUpdated, nil
+	}
+	if opts.Promote == groupKey {
+		opts.State.ClearCanary(groupKey)
+	}
+
+	notifier.SendPulled(sanitize(groupKey), sanitize(img.name), truncateDigest(oldID), truncateDigest(latestID))
+	return ResultUpdated, nil
+}
+
+// restartsOwed returns the containers of a pull-restart group that still
+// need a restart for img: all of them when the pull just moved the tag, and
+// otherwise those started before the latest image was built. The latter
+// catches up on a restart that an earlier run deferred or held back, or
+// whose image a --prefetch run pulled, without restarting a container again
+// once it ran.
+func restartsOwed(ctx context.Context, cli *client.Client, containers []container.InspectResponse, img groupImage) []container.InspectResponse {
+	moved := img.latestID != img.beforeID
+	var built time.Time
+	if !moved {
+		inspect, err := cli.ImageInspect(ctx, img.latestID)
+		if err != nil {
+			log.Printf("[WARN] Failed to inspect image %s, not checking for pending restarts: %v", truncateDigest(img.latestID), err)
+			return nil
+		}
+		if built, err = time.Parse(time.RFC3339Nano, inspect.Created); err != nil {
+			return nil
+		}
+	}
+
+	var owed []container.InspectResponse
+	for _, c := range containers {
+		if c.Image == img.latestID {
+			continue
+		}
+		if moved {
+			owed = append(owed, c)
+			continue
+		}
+		if c.State == nil {
+			continue
+		}
+		if started, err := time.Parse(time.RFC3339Nano, c.State.StartedAt); err == nil && started.Before(built) {
+			owed = append(owed, c)
+		}
+	}
+	return owed
+}
@@ -0,0 +1,222 @@
+package updater
+
+import (
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/fanuelsen/repull/internal/notify"
+	"github.com/fanuelsen/repull/internal/state"
+)
+
+func TestGroupStrategy(t *testing.T) {
+	tests := []struct {
+		label   string
+		want    string
+		wantErr bool
+	}{
+		{"", StrategyRecreate, false},
+		{"recreate", StrategyRecreate, false},
+		{"pull-restart", StrategyPullRestart, false},
+		{"restart", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.label, func(t *testing.T) {
+			c := container.InspectResponse{Config: &container.Config{Labels: map[string]string{}}}
+			if tt.label != "" {
+				c.Config.Labels[StrategyLabel] = tt.label
+			}
+			got, err := groupStrategy([]container.InspectResponse{c})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("groupStrategy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("groupStrategy() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestUpdateGroupsStrategyDispatch verifies that a pull-restart group is
+// restarted in place when its tag moves, while a default group next to it
+// is recreated, and that an unknown strategy skips the group untouched.
+func TestUpdateGroupsStrategyDispatch(t *testing.T) {
+	// Every tag moves to sha256:new once pulled.
+	pulled := make(map[string]bool)
+	cli, calls := fakeDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/images/create"):
+			pulled[path.Base(r.URL.Query().Get("fromImage"))+":"+r.URL.Query().Get("tag")] = true
+			w.WriteHeader(http.StatusOK)
+		case strings.Contains(r.URL.Path, "/images/") && strings.HasSuffix(r.URL.Path, "/json"):
+			name := r.URL.Path[strings.Index(r.URL.Path, "/images/")+len("/images/") : len(r.URL.Path)-len("/json")]
+			id := "sha256:old"
+			if pulled[name] || name == "sha256:new" {
+				id = "sha256:new"
+			}
+			w.Write([]byte(`{"Id":"` + id + `"}`))
+		case strings.HasSuffix(r.URL.Path, "/containers/json"):
+			w.Write([]byte(`[]`))
+		case strings.HasSuffix(r.URL.Path, "/containers/create"):
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"Id":"new-api"}`))
+		case strings.HasSuffix(r.URL.Path, "/json"):
+			w.Write([]byte(`{"Id":"new-api","State":{"Running":true},"Config":{}}`))
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+
+	withStrategy := func(id, image, strategy string) []container.InspectResponse {
+		labels := map[string]string{}
+		if strategy != "" {
+			labels[StrategyLabel] = strategy
+		}
+		return []container.InspectResponse{{
+			ContainerJSONBase: &container.ContainerJSONBase{ID: id, Name: "/" + id, Image: "sha256:old", HostConfig: &container.HostConfig{NetworkMode: "bridge"}},
+			Config:            &container.Config{Image: image, Labels: labels},
+		}}
+	}
+	groups := map[string][]container.InspectResponse{
+		"app:web":   withStrategy("web", "web:latest", StrategyPullRestart),
+		"app:odd":   withStrategy("odd", "odd:latest", "bounce"),
+		"app:other": withStrategy("other", "other:latest", ""),
+	}
+
+	if err := UpdateGroups(t.Context(), cli, groups, Options{}); err != nil {
+		t.Fatalf("UpdateGroups() error = %v", err)
+	}
+
+	if !slices.Contains(*calls, "POST /containers/web/restart") {
+		t.Errorf("pull-restart group not restarted: %v", *calls)
+	}
+	for _, call := range *calls {
+		if strings.HasPrefix(call, "POST /containers/web/") && call != "POST /containers/web/restart" {
+			t.Errorf("pull-restart group touched beyond a restart: %s", call)
+		}
+		if strings.HasPrefix(call, "POST /containers/odd/") {
+			t.Errorf("group with an invalid strategy touched: %s", call)
+		}
+	}
+	if !slices.Contains(*calls, "POST /containers/create") {
+		t.Errorf("default group not recreated: %v", *calls)
+	}
+}
+
+// restartDaemon fakes a daemon for pullRestartGroup: web:latest points at
+// sha256:new, built at built, and with moves it only does so after a pull.
+func restartDaemon(t *testing.T, moves bool, built time.Time) (*client.Client, *[]string) {
+	t.Helper()
+	pulled := false
+	cli, calls := fakeDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/images/create"):
+			pulled = true
+		case strings.HasSuffix(r.URL.Path, "/json"):
+			id := "sha256:new"
+			if moves && !pulled {
+				id = "sha256:old"
+			}
+			w.Write([]byte(`{"Id":"` + id + `","Created":"` + built.Format(time.RFC3339Nano) + `"}`))
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+	return cli, calls
+}
+
+// restartGroup is a pull-restart group of one container, web, running
+// sha256:old since started.
+func restartGroup(started time.Time) []container.InspectResponse {
+	return []container.InspectResponse{{
+		ContainerJSONBase: &container.ContainerJSONBase{ID: "web", Name: "/web", Image: "sha256:old",
+			State: &container.State{Running: true, StartedAt: started.Format(time.RFC3339Nano)}},
+		Config: &container.Config{Image: "web:latest", Labels: map[string]string{StrategyLabel: StrategyPullRestart}},
+	}}
+}
+
+// TestPullRestartGroupNoop verifies that an unchanged tag restarts nothing
+// once the containers were started after the image was built, even though
+// they still run the old image.
+func TestPullRestartGroupNoop(t *testing.T) {
+	now := time.Now()
+	cli, calls := restartDaemon(t, false, now.Add(-2*time.Hour))
+
+	result, err := pullRestartGroup(t.Context(), cli, "app:web", restartGroup(now.Add(-time.Hour)), Options{})
+	if err != nil {
+		t.Fatalf("pullRestartGroup() error = %v", err)
+	}
+	if result != ResultUpToDate {
+		t.Errorf("result = %q, want %q", result, ResultUpToDate)
+	}
+	if slices.Contains(*calls, "POST /containers/web/restart") {
+		t.Errorf("restarted although nothing changed: %v", *calls)
+	}
+}
+
+// TestPullRestartGroupDryRun verifies that a dry run neither restarts nor
+// notifies about the pull, but reports the group as pending.
+func TestPullRestartGroupDryRun(t *testing.T) {
+	now := time.Now()
+	cli, calls := restartDaemon(t, true, now)
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	file, err := notify.NewFileNotifier(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := Options{DryRun: true, Notifier: (*notify.Notifier)(nil).WithFile(file)}
+	result, err := pullRestartGroup(t.Context(), cli, "app:web", restartGroup(now.Add(-time.Hour)), opts)
+	if err != nil {
+		t.Fatalf("pullRestartGroup() error = %v", err)
+	}
+	if result != ResultPending {
+		t.Errorf("result = %q, want %q", result, ResultPending)
+	}
+	if slices.Contains(*calls, "POST /containers/web/restart") {
+		t.Errorf("dry run restarted the container: %v", *calls)
+	}
+	if data, _ := os.ReadFile(path); len(data) > 0 {
+		t.Errorf("dry run notified: %s", data)
+	}
+}
+
+// TestPullRestartGroupDeferred verifies that the guards of a recreate hold a
+// restart back, and that a later run catches up on it although the tag no
+// longer moves by then.
+func TestPullRestartGroupDeferred(t *testing.T) {
+	now := time.Now()
+	st, _ := state.Load("")
+	group := restartGroup(now.Add(-time.Hour))
+
+	cli, calls := restartDaemon(t, true, now)
+	result, err := pullRestartGroup(t.Context(), cli, "app:web", group, Options{State: st, deferRecreate: true})
+	if err != nil || result != ResultDeferred {
+		t.Fatalf("--one-per-run: pullRestartGroup() = %q, %v, want %q", result, err, ResultDeferred)
+	}
+	result, err = pullRestartGroup(t.Context(), cli, "app:web", group, Options{State: st, MinContainerAge: 2 * time.Hour})
+	if err != nil || result != ResultDeferred {
+		t.Fatalf("--min-container-age: pullRestartGroup() = %q, %v, want %q", result, err, ResultDeferred)
+	}
+	if slices.Contains(*calls, "POST /containers/web/restart") {
+		t.Fatalf("deferred group restarted: %v", *calls)
+	}
+
+	result, err = pullRestartGroup(t.Context(), cli, "app:web", group, Options{State: st})
+	if err != nil || result != ResultUpdated {
+		t.Fatalf("pullRestartGroup() = %q, %v, want %q", result, err, ResultUpdated)
+	}
+	if !slices.Contains(*calls, "POST /containers/web/restart") {
+		t.Errorf("deferred restart not caught up on: %v", *calls)
+	}
+}
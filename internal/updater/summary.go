@@ -0,0 +1,42 @@
+package updater
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// summaryOrder is the order results appear in a run summary.
+var summaryOrder = []Result{ResultUpToDate, ResultUpdated, ResultPulled, ResultPending, ResultDeferred, ResultSkipped, ResultFailed}
+
+// summarizeResults renders per-result group counts as one line, e.g.
+// "12 unchanged, 3 updated, 1 failed". Results with no groups are left out.
+func summarizeResults(counts map[Result]int) string {
+	var parts []string
+	for _, r := range summaryOrder {
+		n := counts[r]
+		if n == 0 {
+			continue
+		}
+		label := string(r)
+		if r == ResultUpToDate {
+			label = "unchanged"
+		}
+		parts = append(parts, fmt.Sprintf("%d %s", n, label))
+	}
+	if len(parts) == 0 {
+		return "nothing checked"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// logQuiet logs a per-group line that --summarize-unchanged folds into the
+// run summary: as usual without it, as [DEBUG] with --debug, else not at all.
+func logQuiet(opts Options, format string, args ...any) {
+	switch {
+	case !opts.SummarizeUnchanged:
+		log.Printf("[INFO] "+format, args...)
+	case opts.Debug:
+		log.Printf("[DEBUG] "+format, args...)
+	}
+}
@@ -0,0 +1,23 @@
+package updater
+
+import "testing"
+
+func TestSummarizeResults(t *testing.T) {
+	tests := []struct {
+		name   string
+		counts map[Result]int
+		want   string
+	}{
+		{name: "empty", counts: map[Result]int{}, want: "nothing checked"},
+		{name: "unchanged and updated", counts: map[Result]int{ResultUpToDate: 12, ResultUpdated: 3}, want: "12 unchanged, 3 updated"},
+		{name: "fixed order", counts: map[Result]int{ResultFailed: 1, ResultDeferred: 2, ResultUpToDate: 4}, want: "4 unchanged, 2 deferred, 1 failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := summarizeResults(tt.counts); got != tt.want {
+				t.Errorf("summarizeResults() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package updater
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/distribution/reference"
+	"github.com/docker/docker/api/types/container"
+)
+
+// DefaultMutableTags are the tags OnlyMutableTags acts on when no others
+// are configured.
+var DefaultMutableTags = []string{"latest", "stable", "edge"}
+
+// tagKind is how a tag is expected to behave over time.
+type tagKind int
+
+const (
+	// tagOther is neither a recognized mutable tag nor an immutable-looking
+	// one, e.g. a major-version alias such as "16" or "1.2".
+	tagOther tagKind = iota
+	// tagMutable is one of the configured moving channels, e.g. "latest".
+	tagMutable
+	// tagImmutable looks like a fixed release: a full version such as
+	// "v1.2.3" or "1.2.3-alpine", or a commit hash such as "sha-4f2a9c1".
+	tagImmutable
+)
+
+var (
+	// versionTag matches full major.minor.patch versions, optionally with a
+	// "v" prefix and a pre-release, build or variant suffix.
+	versionTag = regexp.MustCompile(`^v?\d+\.\d+\.\d+([-+._][0-9A-Za-z._+-]*)?$`)
+	// commitTag matches git commit hashes, as CI pipelines commonly tag
+	// images, with or without a "sha-" prefix.
+	commitTag = regexp.MustCompile(`^(sha-)?[0-9a-f]{7,40}$`)
+)
+
+// classifyTag returns the kind of tag, given the tags that count as
+// mutable. Matching is case-sensitive, as tags are.
+func classifyTag(tag string, mutable []string) tagKind {
+	for _, m := range mutable {
+		if tag == m {
+			return tagMutable
+		}
+	}
+	if versionTag.MatchString(tag) || commitTag.MatchString(tag) {
+		return tagImmutable
+	}
+	return tagOther
+}
+
+// imageTag returns the tag of imageName, "latest" if it has none, or "" if
+// it is referenced by digest alone or cannot be parsed.
+func imageTag(imageName string) string {
+	named, err := reference.ParseNormalizedNamed(imageName)
+	if err != nil {
+		return ""
+	}
+	if tagged, ok := reference.TagNameOnly(named).(reference.Tagged); ok {
+		return tagged.Tag()
+	}
+	return ""
+}
+
+// OnlyMutableTags keeps the containers whose image tag is one of mutable
+// (DefaultMutableTags if empty) and drops the rest, and the groups left
+// empty, for --only-mutable-tags: a pinned release such as "v1.2.3" is left
+// alone even when its tag is re-pushed, and so is any tag not known to be a
+// moving channel. A digest-pinned container is judged by the tag it tracks
+// (see TrackLabel).
+func OnlyMutableTags(groups map[string][]container.InspectResponse, mutable []string) map[string][]container.InspectResponse {
+	if len(mutable) == 0 {
+		mutable = DefaultMutableTags
+	}
+
+	return dropContainers(groups, "Skipping", func(c container.InspectResponse) (string, bool) {
+		imageName, _ := trackedImage(c)
+		tag := imageTag(imageName)
+		switch classifyTag(tag, mutable) {
+		case tagImmutable:
+			return fmt.Sprintf("tag %s looks immutable (--only-mutable-tags)", sanitize(tag)), true
+		case tagOther:
+			return fmt.Sprintf("tag %s is not one of the mutable tags %s (--only-mutable-tags)", sanitize(tag), strings.Join(mutable, ",")), true
+		}
+		return "", false
+	})
+}
@@ -0,0 +1,107 @@
+package updater
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+func TestClassifyTag(t *testing.T) {
+	tests := []struct {
+		tag  string
+		want tagKind
+	}{
+		{"latest", tagMutable},
+		{"stable", tagMutable},
+		{"edge", tagMutable},
+		{"Latest", tagOther},
+		{"v1.2.3", tagImmutable},
+		{"1.2.3", tagImmutable},
+		{"1.27.0-alpine", tagImmutable},
+		{"2.0.0-rc.1", tagImmutable},
+		{"1.2.3+build.5", tagImmutable},
+		{"2024.01.15", tagImmutable},
+		{"4f2a9c1", tagImmutable},
+		{"sha-4f2a9c1e8b", tagImmutable},
+		{"16", tagOther},
+		{"1.2", tagOther},
+		{"16-alpine", tagOther},
+		{"mainline", tagOther},
+		{"", tagOther},
+	}
+
+	for _, tt := range tests {
+		if got := classifyTag(tt.tag, DefaultMutableTags); got != tt.want {
+			t.Errorf("classifyTag(%q) = %v, want %v", tt.tag, got, tt.want)
+		}
+	}
+}
+
+func TestImageTag(t *testing.T) {
+	tests := []struct {
+		image string
+		want  string
+	}{
+		{"nginx", "latest"},
+		{"nginx:stable", "stable"},
+		{"registry.example.com:5000/team/app:v1.2.3", "v1.2.3"},
+		{"nginx:1.27@sha256:0000000000000000000000000000000000000000000000000000000000000000", "1.27"},
+		{"nginx@sha256:0000000000000000000000000000000000000000000000000000000000000000", ""},
+		{"Not/Valid", ""},
+	}
+
+	for _, tt := range tests {
+		if got := imageTag(tt.image); got != tt.want {
+			t.Errorf("imageTag(%q) = %q, want %q", tt.image, got, tt.want)
+		}
+	}
+}
+
+func TestOnlyMutableTags(t *testing.T) {
+	withImage := func(image string, labels map[string]string) []container.InspectResponse {
+		return []container.InspectResponse{{Config: &container.Config{Image: image, Labels: labels}}}
+	}
+	pinned := "nginx@sha256:0000000000000000000000000000000000000000000000000000000000000000"
+	groups := map[string][]container.InspectResponse{
+		"app:web":     withImage("nginx", nil),
+		"app:api":     withImage("acme/api:stable", nil),
+		"app:worker":  withImage("acme/worker:v1.2.3", nil),
+		"app:db":      withImage("postgres:16", nil),
+		"app:proxy":   withImage(pinned, map[string]string{TrackLabel: "nginx:edge"}),
+		"app:preview": withImage("acme/web:preview", nil),
+	}
+
+	got := OnlyMutableTags(groups, nil)
+	if len(got) != 3 || got["app:web"] == nil || got["app:api"] == nil || got["app:proxy"] == nil {
+		t.Errorf("OnlyMutableTags() with defaults kept %v, want app:web, app:api and app:proxy", groupKeys(got))
+	}
+
+	got = OnlyMutableTags(groups, []string{"preview"})
+	if len(got) != 1 || got["app:preview"] == nil {
+		t.Errorf("OnlyMutableTags(preview) kept %v, want only app:preview", groupKeys(got))
+	}
+}
+
+// TestOnlyMutableTagsMixedGroup verifies a pinned container is dropped even
+// when the first container of its group runs a mutable tag.
+func TestOnlyMutableTagsMixedGroup(t *testing.T) {
+	groups := map[string][]container.InspectResponse{
+		"app:web": {
+			{ContainerJSONBase: &container.ContainerJSONBase{Name: "/app-web-1"}, Config: &container.Config{Image: "nginx:latest"}},
+			{ContainerJSONBase: &container.ContainerJSONBase{Name: "/app-web-2"}, Config: &container.Config{Image: "nginx:1.27.3"}},
+		},
+	}
+
+	got := splitMixedGroups(OnlyMutableTags(groups, nil), nil)
+	if len(got) != 1 || len(got["app:web"]) != 1 || got["app:web"][0].Name != "/app-web-1" {
+		t.Errorf("groups after filtering and split = %v, want app:web with app-web-1 only", groupKeys(got))
+	}
+}
+
+func groupKeys(groups map[string][]container.InspectResponse) []string {
+	var out []string
+	for k := range groups {
+		out = append(out, k)
+	}
+	return out
+}
@@ -0,0 +1,69 @@
+package updater
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/fanuelsen/repull/internal/state"
+)
+
+const (
+	// MaxFrequencyLabel caps how often a container is recreated, e.g.
+	// io.repull.max-frequency=6h: a newer image is only rolled out once the
+	// last recreate is at least that long ago.
+	MaxFrequencyLabel = "io.repull.max-frequency"
+)
+
+// maxFrequency parses the io.repull.max-frequency label of a container.
+// Returns 0 when the label is unset.
+func maxFrequency(c container.InspectResponse) (time.Duration, error) {
+	if c.Config == nil || c.Config.Labels[MaxFrequencyLabel] == "" {
+		return 0, nil
+	}
+	v := c.Config.Labels[MaxFrequencyLabel]
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid %s %q: must be a positive duration such as 6h", MaxFrequencyLabel, v)
+	}
+	return d, nil
+}
+
+// throttleDelay returns how much longer a container must wait before it may
+// be recreated again, or 0 if it is eligible now.
+func throttleDelay(st *state.State, name string, window time.Duration, now time.Time) time.Duration {
+	if window == 0 {
+		return 0
+	}
+	last, ok := st.LastRecreated(name)
+	if !ok {
+		return 0
+	}
+	if wait := last.Add(window).Sub(now); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// deferThrottled drops the containers that were recreated within their
+// io.repull.max-frequency window, logging each deferral. An invalid label
+// fails the group rather than being ignored.
+func deferThrottled(containers []container.InspectResponse, st *state.State, now time.Time) ([]container.InspectResponse, error) {
+	var eligible []container.InspectResponse
+	for _, c := range containers {
+		window, err := maxFrequency(c)
+		if err != nil {
+			return nil, err
+		}
+		name := strings.TrimPrefix(c.Name, "/")
+		if wait := throttleDelay(st, name, window, now); wait > 0 {
+			log.Printf("[INFO] Deferring %s: recreated within the last %s (%s), eligible again in %s",
+				sanitize(name), window, MaxFrequencyLabel, wait.Round(time.Second))
+			continue
+		}
+		eligible = append(eligible, c)
+	}
+	return eligible, nil
+}
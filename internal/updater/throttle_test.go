@@ -0,0 +1,69 @@
+package updater
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/fanuelsen/repull/internal/state"
+)
+
+func TestMaxFrequency(t *testing.T) {
+	withLabel := func(v string) container.InspectResponse {
+		return container.InspectResponse{Config: &container.Config{Labels: map[string]string{MaxFrequencyLabel: v}}}
+	}
+
+	tests := []struct {
+		name    string
+		c       container.InspectResponse
+		want    time.Duration
+		wantErr bool
+	}{
+		{name: "unset", c: container.InspectResponse{Config: &container.Config{}}, want: 0},
+		{name: "nil config", c: container.InspectResponse{}, want: 0},
+		{name: "six hours", c: withLabel("6h"), want: 6 * time.Hour},
+		{name: "not a duration", c: withLabel("daily"), wantErr: true},
+		{name: "negative", c: withLabel("-1h"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := maxFrequency(tt.c)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("maxFrequency() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("maxFrequency() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestThrottleDelay(t *testing.T) {
+	st, err := state.Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, time.June, 11, 12, 0, 0, 0, time.UTC)
+	st.RecordRecreated("web", now.Add(-2*time.Hour))
+
+	tests := []struct {
+		name   string
+		cname  string
+		window time.Duration
+		want   time.Duration
+	}{
+		{name: "no label", cname: "web", window: 0, want: 0},
+		{name: "within window", cname: "web", window: 6 * time.Hour, want: 4 * time.Hour},
+		{name: "window elapsed", cname: "web", window: time.Hour, want: 0},
+		{name: "never recreated", cname: "db", window: 6 * time.Hour, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := throttleDelay(st, tt.cname, tt.window, now); got != tt.want {
+				t.Errorf("throttleDelay() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
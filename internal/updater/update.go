@@ -12,8 +12,12 @@ import (
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/client"
 	"github.com/fanuelsen/repull/internal/docker"
+	"github.com/fanuelsen/repull/internal/events"
 	"github.com/fanuelsen/repull/internal/notify"
+	"github.com/fanuelsen/repull/internal/registry"
 	sanitizepkg "github.com/fanuelsen/repull/internal/sanitize"
+	"github.com/fanuelsen/repull/internal/state"
+	"github.com/fanuelsen/repull/internal/status"
 )
 
 // sanitize neutralizes control and spoofing characters in strings derived
@@ -26,22 +30,215 @@ func sanitize(s string) string {
 
 // groupTimeout bounds the work for a single group: pulling the image and
 // recreating its containers. Generous enough for large images on slow links.
-const groupTimeout = 10 * time.Minute
+var groupTimeout = 10 * time.Minute
+
+// Options configures an update cycle.
+type Options struct {
+	// DryRun reports what would be updated without changing anything.
+	DryRun bool
+	// NotifyAvailable, in a dry run, sends the Notifier an "update
+	// available" message for every group that would be recreated
+	// (--maintenance-mode).
+	NotifyAvailable bool
+	// Cleanup removes replaced images after a successful update.
+	Cleanup bool
+	// Notifier receives update and error notifications; nil disables them.
+	Notifier notify.Reporter
+	// ProjectNotifiers overrides Notifier for groups of these compose
+	// projects (see notifierFor).
+	ProjectNotifiers map[string]notify.Reporter
+	// State remembers recreate times across runs; nil disables throttling.
+	State *state.State
+	// Planned, if set, is called for every group that has outdated
+	// containers, before they are recreated (or instead, in a dry run).
+	// latestID is the image ID they would be recreated from.
+	Planned func(groupKey, imageName, latestID string, outdated []container.InspectResponse)
+	// Groups, if set, restricts the cycle to these group keys.
+	Groups map[string]bool
+	// Channels pins image repositories to a tag (see ReadChannels):
+	// their containers are moved to that tag, whatever they run now.
+	Channels Channels
+	// Images, if set, restricts the cycle to groups running one of these
+	// fully qualified image references, e.g. the ones a registry webhook
+	// announced.
+	Images []string
+	// ExpectedImages pins groups to the image ID a plan was made with: a
+	// group whose tag now resolves to another image is skipped.
+	ExpectedImages map[string]string
+	// ExpectedContainers pins groups to the container IDs a plan was made
+	// with: other containers of the group are left alone.
+	ExpectedContainers map[string][]string
+	// Events receives run and per-group events; nil disables them.
+	Events *events.Broadcaster
+	// Status records the outcome of every group for GET /status; nil
+	// disables it.
+	Status *status.Tracker
+	// MaxImageSize skips images whose compressed size exceeds it (bytes);
+	// 0 disables the check. Registry is used to query the size.
+	MaxImageSize int64
+	Registry     *registry.Client
+	// MinFreeDisk skips a group instead of pulling while the Docker data
+	// root has less than this many bytes free; 0 disables it.
+	MinFreeDisk int64
+	// RestartLoopThreshold skips containers Docker restarted at least this
+	// many times shortly before the check; 0 disables it.
+	RestartLoopThreshold int
+	// MinContainerAge defers containers that have been running for less
+	// than this; 0 disables it.
+	MinContainerAge time.Duration
+	// PullOnly pulls new images but never stops or recreates containers.
+	PullOnly bool
+	// Prefetch, with PullOnly, records the pulled images in State as staged,
+	// so a later regular run recreates from them without pulling (see
+	// stageImage and stagedImage).
+	Prefetch bool
+	// SkipMissingImages skips a group whose image tag no longer exists
+	// upstream instead of failing it.
+	SkipMissingImages bool
+	// NoStart leaves the replacements stopped (see
+	// docker.RecreateOptions), and the run reports which ones.
+	NoStart bool
+	// SummarizeUnchanged replaces the per-group lines for unchanged images
+	// with one summary line at the end of the run; Debug still logs them.
+	SummarizeUnchanged bool
+	Debug              bool
+	// OnePerRun recreates at most one group per run; later groups with
+	// updates are deferred to the next run.
+	OnePerRun bool
+	// KeepImages keeps the most recently deployed images of each repository
+	// and removes older ones no container uses; 0 disables it. Mutually
+	// exclusive with Cleanup.
+	KeepImages int
+	// SelfStopTimeout is how many seconds a repull instance being replaced
+	// gets to stop gracefully; 0 kills it immediately.
+	SelfStopTimeout int
+	// SelfUpdateMaxAttempts skips updating a repull instance to an image
+	// once this many attempts failed (see selfUpdateBlocked); 0 retries on
+	// every run. Without a state file the count is lost on restart.
+	SelfUpdateMaxAttempts int
+	// MaxLoad delays each recreate until the host's 1-minute load average
+	// is below it (see waitForLoad); 0 disables it.
+	MaxLoad float64
+	// CheckBaseImages reports images whose OCI base image (see
+	// BaseNameLabel) has moved on since they were built; Registry is used
+	// to resolve the base.
+	CheckBaseImages bool
+	// Promote names a group whose pending canary (see CanaryLabel) is
+	// promoted: its remaining outdated containers are recreated.
+	Promote string
+	// MaxConsecutiveFailures halts the run once this many groups failed in
+	// a row: a degraded daemon or an unreachable registry would fail the
+	// rest too, and every failed recreate risks leaving a service down.
+	// 0 disables it.
+	MaxConsecutiveFailures int
+	// MaxParallelPulls, above 1, pulls the images of all selected groups
+	// up front, this many at a time, before the groups are updated one by
+	// one (see pullPhase). Not compatible with MinFreeDisk, which checks
+	// the free space before each pull.
+	MaxParallelPulls int
+
+	// deferRecreate is set for the groups after the one OnePerRun picked.
+	deferRecreate bool
+	// dataRoot is the daemon's data root, looked up once per cycle for
+	// MinFreeDisk.
+	dataRoot string
+	// localLayers holds the layers present before a dry run started
+	// pulling, for its download estimates (see describeDownload).
+	localLayers map[string]bool
+	// pulled holds the images the pull phase pulled (see pullPhase).
+	pulled map[string]pulledImage
+}
 
 // UpdateGroups processes each group of containers and updates them if they are
 // running an outdated image. It updates one group at a time (sequential, not
-// parallel) for safety. Groups are independent: a failure in one group is
-// logged and reported, but the remaining groups are still processed. Returns
-// the combined errors of all failed groups, or nil if every group succeeded.
-// With cleanup enabled, replaced images are removed after a successful update.
-func UpdateGroups(ctx context.Context, cli *client.Client, groups map[string][]container.InspectResponse, dryRun bool, cleanup bool, notifier *notify.Notifier) error {
+// parallel) for safety, parents before the groups that join their network,
+// pid or ipc namespace (see orderGroups). Groups are independent: a failure in
+// one group is logged and reported, but the remaining groups are still
+// processed. Returns the combined errors of all failed groups, or nil if
+// every group succeeded. With cleanup enabled, replaced images are removed
+// after a successful update. With PullOnly, groups only get their image
+// pulled (see pullOnlyGroup).
+func UpdateGroups(ctx context.Context, cli *client.Client, groups map[string][]container.InspectResponse, opts Options) error {
+	// A container that runs another image than its group is updated on
+	// its own rather than moved to the group's image.
+	groups = splitMixedGroups(groups, opts.Channels)
+
 	// Track containers recreated during this update cycle.
 	// This is used to resolve stale network_mode references when containers
 	// use network_mode: service:X (which Docker stores as container:<id>).
 	recreated := make(docker.RecreatedContainers)
+	// Containers created but not started under --no-start.
+	var leftStopped []string
+
+	update := func(ctx context.Context, groupKey string, containers []container.InspectResponse, opts Options) (Result, error) {
+		strategy, err := groupStrategy(containers)
+		if err != nil {
+			log.Printf("[ERROR] Skipping %s: %s", sanitize(groupKey), sanitize(err.Error()))
+			opts.notifier().SendError(sanitize(groupKey), err.Error())
+			return ResultSkipped, nil
+		}
+		if strategy == StrategyPullRestart {
+			return pullRestartGroup(ctx, cli, groupKey, containers, opts)
+		}
+		return updateGroup(ctx, cli, groupKey, containers, opts, recreated, &leftStopped)
+	}
+	if opts.PullOnly {
+		update = func(ctx context.Context, groupKey string, containers []container.InspectResponse, opts Options) (Result, error) {
+			return pullOnlyGroup(ctx, cli, groupKey, containers, opts)
+		}
+	}
+
+	// The data root does not move during a cycle. Without it the free-space
+	// check cannot work, so it is skipped rather than blocking updates.
+	if opts.MinFreeDisk > 0 {
+		if info, err := cli.Info(ctx); err != nil {
+			log.Printf("[WARN] Could not query the Docker data root, not checking free disk space: %v", err)
+		} else {
+			opts.dataRoot = info.DockerRootDir
+		}
+	}
+
+	// A dry run still pulls, so note which layers are present beforehand.
+	if opts.DryRun && !opts.PullOnly && opts.Registry != nil {
+		layers, err := docker.LocalLayers(ctx, cli)
+		if err != nil {
+			log.Printf("[WARN] Could not list local image layers, not estimating download sizes: %v", err)
+		} else {
+			opts.localLayers = layers
+		}
+	}
+
+	opts.Events.Emit(events.Event{Type: events.RunStart, Groups: len(groups)})
+
+	selected := func(groupKey string) bool {
+		if opts.Groups != nil && !opts.Groups[groupKey] {
+			return false
+		}
+		return opts.Images == nil || groupRunsImage(groups[groupKey], opts.Images)
+	}
 
 	var errs []error
-	for groupKey, containers := range groups {
+	counts := make(map[Result]int)
+	// Set once OnePerRun has let a group through.
+	updatedOne := false
+	// Groups failed in a row, for MaxConsecutiveFailures. Skipped and
+	// deferred groups prove nothing either way and leave it as is.
+	failures := 0
+	order := orderGroups(groups)
+	if opts.MaxParallelPulls > 1 {
+		var keys []string
+		for _, groupKey := range order {
+			if selected(groupKey) {
+				keys = append(keys, groupKey)
+			}
+		}
+		opts.pulled = pullPhase(ctx, cli, groups, keys, opts)
+	}
+	for i, groupKey := range order {
+		if !selected(groupKey) {
+			continue
+		}
+		containers := refreshRecreated(ctx, cli, groups[groupKey], recreated)
 		if len(containers) == 0 {
 			continue
 		}
@@ -49,43 +246,190 @@ func UpdateGroups(ctx context.Context, cli *client.Client, groups map[string][]c
 		// Each group gets its own deadline so one slow group (big image, slow
 		// registry, stalled daemon) cannot eat the time budget of the others.
 		groupCtx, cancel := context.WithTimeout(ctx, groupTimeout)
-		err := updateGroup(groupCtx, cli, groupKey, containers, dryRun, cleanup, notifier, recreated)
+		groupOpts := opts
+		groupOpts.Notifier = notifierFor(groupKey, opts).WithKey(notifyKey(containers))
+		groupOpts.deferRecreate = updatedOne
+		// Pull-only never touches a container, so it has nothing to gate.
+		result := ResultDeferred
+		ready, err := true, error(nil)
+		if !opts.PullOnly {
+			ready, err = healthPrecondition(groupCtx, cli, groupKey, containers)
+		}
+		switch {
+		case err != nil:
+			result = ResultFailed
+			groupOpts.Notifier.SendError(sanitize(groupKey), err.Error())
+		case ready:
+			result, err = update(groupCtx, groupKey, containers, groupOpts)
+		}
 		cancel()
+		counts[result]++
+		// A dry run counts its pending group, so it previews the real run.
+		if opts.OnePerRun && (result == ResultUpdated || result == ResultPending) {
+			updatedOne = true
+		}
+		event := events.Event{Type: events.Group, Group: groupKey, Image: containers[0].Config.Image, Result: string(result)}
+		if err != nil {
+			event.Error = sanitize(err.Error())
+		}
+		opts.Events.Emit(event)
+		reportStatus(ctx, cli, opts, groupKey, containers, result, event.Error)
+		switch result {
+		case ResultFailed:
+			failures++
+		case ResultUpdated, ResultUpToDate, ResultPulled, ResultPending:
+			failures = 0
+		}
+		tripped := opts.MaxConsecutiveFailures > 0 && failures >= opts.MaxConsecutiveFailures
 		if err != nil {
-			// Sanitize the error text as well as the group key: pull errors can
-			// echo registry-controlled response bodies, and this error is logged
-			// both here and by main without further escaping. Flattening %w to
-			// %s loses errors.Is/As matching, which nothing relies on — the
-			// joined error is only ever logged.
-			errText := sanitize(err.Error())
-			log.Printf("[ERROR] %s: %s — continuing with remaining groups", sanitize(groupKey), errText)
-			errs = append(errs, fmt.Errorf("%s: %s", sanitize(groupKey), errText))
+			groupErr := &GroupError{Group: groupKey, Err: err}
+			if tripped {
+				log.Printf("[ERROR] %s", groupErr)
+			} else {
+				log.Printf("[ERROR] %s — continuing with remaining groups", groupErr)
+			}
+			errs = append(errs, groupErr)
+		}
+		if tripped {
+			remaining := 0
+			for _, k := range order[i+1:] {
+				if selected(k) {
+					remaining++
+				}
+			}
+			reason := fmt.Sprintf("%d groups failed in a row (--max-consecutive-failures), %d group(s) left unprocessed until the next run", failures, remaining)
+			log.Printf("[ERROR] Circuit breaker open, halting run: %s", reason)
+			opts.notifier().SendHalt(reason)
+			errs = append(errs, fmt.Errorf("%w: %s", ErrCircuitOpen, reason))
+			counts[ResultSkipped] += remaining
+			break
 		}
 	}
 
+	if opts.SummarizeUnchanged {
+		log.Printf("[INFO] Run summary: %s", summarizeResults(counts))
+	}
+
+	if len(leftStopped) > 0 {
+		log.Printf("[INFO] Left %d container(s) stopped (--no-start): %s", len(leftStopped), strings.Join(leftStopped, ", "))
+	}
+
+	if !opts.DryRun {
+		compactState(ctx, cli, opts.State)
+	}
+	if err := opts.State.Save(); err != nil {
+		log.Printf("[WARN] Failed to save state: %v", err)
+	}
+
+	// Backends batching per run, such as email digests, send the batch.
+	opts.notifier().EndRun()
+	for _, n := range opts.ProjectNotifiers {
+		n.EndRun()
+	}
+
+	opts.Events.Emit(events.Event{Type: events.RunEnd, Groups: len(groups), Failed: len(errs)})
+
 	return errors.Join(errs...)
 }
 
+// refreshRecreated replaces containers that were already recreated earlier in
+// this cycle — as network dependents of a parent group — with a fresh inspect
+// of their replacement. The listing taken at the start of the cycle still
+// holds the old, now removed container. A replacement that cannot be
+// inspected is dropped from the group.
+func refreshRecreated(ctx context.Context, cli *client.Client, containers []container.InspectResponse, recreated docker.RecreatedContainers) []container.InspectResponse {
+	refreshed := make([]container.InspectResponse, 0, len(containers))
+	for _, c := range containers {
+		newID, ok := recreated[c.ID]
+		if !ok {
+			refreshed = append(refreshed, c)
+			continue
+		}
+		inspect, err := cli.ContainerInspect(ctx, newID)
+		if err != nil {
+			log.Printf("[WARN] Skipping %s: inspect of its replacement failed: %v", docker.ShortID(newID), err)
+			continue
+		}
+		refreshed = append(refreshed, inspect)
+	}
+	return refreshed
+}
+
 // updateGroup pulls the group's image and recreates any of its containers that
-// are running an outdated image.
-func updateGroup(ctx context.Context, cli *client.Client, groupKey string, containers []container.InspectResponse, dryRun bool, cleanup bool, notifier *notify.Notifier, recreated docker.RecreatedContainers) error {
-	log.Printf("[INFO] Checking %s (%d container(s))", sanitize(groupKey), len(containers))
+// are running an outdated image. The Result says what happened to the group;
+// it is ResultFailed whenever the error is non-nil. With opts.NoStart, the
+// names of the replacements left stopped are appended to leftStopped.
+func updateGroup(ctx context.Context, cli *client.Client, groupKey string, containers []container.InspectResponse, opts Options, recreated docker.RecreatedContainers, leftStopped *[]string) (Result, error) {
+	notifier := opts.notifier()
+	logQuiet(opts, "Checking %s (%d container(s))", sanitize(groupKey), len(containers))
 
 	// Get image name from first container (all containers in a group share the same image)
-	imageName := containers[0].Config.Image
+	imageName, channeled, ok := targetImage(containers[0], opts.Channels)
+	if !ok {
+		log.Printf("[INFO] %s is pinned by digest, skipping %s (set %s to follow a tag)", sanitize(imageName), sanitize(groupKey), TrackLabel)
+		return ResultSkipped, nil
+	}
+	if channeled {
+		logQuiet(opts, "%s follows the channel file: %s", sanitize(groupKey), sanitize(imageName))
+	} else if imageName != containers[0].Config.Image {
+		log.Printf("[INFO] %s is pinned by digest, tracking %s", sanitize(containers[0].Config.Image), sanitize(imageName))
+	}
+	if err := checkImageRef(imageName); err != nil {
+		log.Printf("[ERROR] Skipping %s: %s", sanitize(groupKey), sanitize(err.Error()))
+		notifier.SendError(sanitize(groupKey), err.Error())
+		return ResultSkipped, nil
+	}
 
-	// Pull latest image
-	log.Printf("[INFO] Pulling image %s", sanitize(imageName))
-	if err := docker.PullImage(ctx, cli, imageName); err != nil {
-		notifier.SendError(sanitize(groupKey), fmt.Sprintf("Failed to pull image %s: %v", sanitize(imageName), err))
-		return fmt.Errorf("failed to pull image %s: %w", sanitize(imageName), err)
+	// A pending canary is only remembered in the state file: without one,
+	// every single run would forget it and recreate one more replica, and
+	// the group would roll out without ever being promoted.
+	if isCanaryGroup(containers) && !opts.State.Persistent() {
+		log.Printf("[WARN] Skipping %s: %s needs --state-file to hold the rest of the group back until --promote", sanitize(groupKey), CanaryLabel)
+		return ResultSkipped, nil
 	}
 
-	// Resolve the image ID the tag points to after the pull
-	latestID, err := docker.GetImageID(ctx, cli, imageName)
-	if err != nil {
-		notifier.SendError(sanitize(groupKey), fmt.Sprintf("Failed to inspect image %s: %v", sanitize(imageName), err))
-		return fmt.Errorf("failed to inspect image %s: %w", sanitize(imageName), err)
+	// An image staged by --prefetch is already local: there is nothing to
+	// pull, so the size and disk checks do not apply either.
+	latestID, staged := stagedImage(ctx, cli, imageName, opts)
+
+	if !staged && (!withinSizeLimit(ctx, cli, groupKey, imageName, opts) || !enoughDisk(groupKey, opts)) {
+		return ResultSkipped, nil
+	}
+
+	// Pull latest image, unless a --prefetch run already did
+	var err error
+	if staged {
+		log.Printf("[INFO] Using image %s staged by --prefetch, not pulling", sanitize(imageName))
+	} else {
+		if _, ok := opts.pulled[imageName]; !ok {
+			logQuiet(opts, "Pulling image %s", sanitize(imageName))
+		}
+		if err := pullImage(ctx, cli, imageName, opts); err != nil {
+			if opts.SkipMissingImages && docker.IsImageNotFound(err) {
+				log.Printf("[WARN] Image %s no longer exists upstream, skipping %s: %s", sanitize(imageName), sanitize(groupKey), sanitize(err.Error()))
+				return ResultSkipped, nil
+			}
+			notifier.SendError(sanitize(groupKey), fmt.Sprintf("Failed to pull image %s: %v", sanitize(imageName), err))
+			return ResultFailed, fmt.Errorf("failed to pull image %s: %w", sanitize(imageName), err)
+		}
+
+		// Resolve the image ID the tag points to after the pull
+		latestID, err = docker.GetImageID(ctx, cli, imageName)
+		if err != nil {
+			notifier.SendError(sanitize(groupKey), fmt.Sprintf("Failed to inspect image %s: %v", sanitize(imageName), err))
+			return ResultFailed, fmt.Errorf("failed to inspect image %s: %w", sanitize(imageName), err)
+		}
+	}
+
+	// An applied plan was approved for one specific image; anything pushed
+	// since then was not.
+	if want, drifted := imageDrifted(groupKey, latestID, opts.ExpectedImages); drifted {
+		log.Printf("[WARN] Skipping %s: %s now resolves to %s, but the plan was made for %s", sanitize(groupKey), sanitize(imageName), truncateDigest(latestID), truncateDigest(want))
+		return ResultSkipped, nil
+	}
+
+	if opts.CheckBaseImages {
+		checkBaseImage(ctx, cli, opts, groupKey, imageName, latestID)
 	}
 
 	// Compare each container's image ID against the latest. Unlike comparing
@@ -93,49 +437,173 @@ func updateGroup(ctx context.Context, cli *client.Client, groupKey string, conta
 	// even when the image was already pulled earlier — by a dry run, a manual
 	// docker pull, or a cycle that pulled successfully but failed to recreate.
 	outdated := filterOutdatedContainers(containers, latestID)
+	if channeled {
+		outdated = filterOffChannel(containers, imageName, latestID)
+	}
+	if len(outdated) == 0 {
+		// A canary the whole group has caught up with is settled.
+		if !opts.DryRun {
+			opts.State.ClearCanary(groupKey)
+			opts.State.ClearStaged(imageName)
+		}
+		logQuiet(opts, "Already running latest image, skipping %s", sanitize(groupKey))
+		return ResultUpToDate, nil
+	}
+
+	outdated = plannedOnly(groupKey, containers, outdated, opts.ExpectedContainers)
+	if len(outdated) == 0 {
+		return ResultSkipped, nil
+	}
+
+	// Leave crash-looping containers alone; they need a human.
+	outdated = skipRestartLooping(groupKey, outdated, opts.RestartLoopThreshold, notifier, opts.State, time.Now())
+	if len(outdated) == 0 {
+		return ResultSkipped, nil
+	}
+
+	// Defer containers that were only just (re)started.
+	outdated = deferYoung(outdated, opts.MinContainerAge, time.Now())
 	if len(outdated) == 0 {
-		log.Printf("[INFO] Already running latest image, skipping %s", sanitize(groupKey))
-		return nil
+		return ResultDeferred, nil
+	}
+
+	// Defer containers recreated too recently for their
+	// io.repull.max-frequency; a later run picks them up.
+	outdated, err = deferThrottled(outdated, opts.State, time.Now())
+	if err != nil {
+		notifier.SendError(sanitize(groupKey), err.Error())
+		return ResultFailed, err
+	}
+	if len(outdated) == 0 {
+		return ResultDeferred, nil
+	}
+
+	// --one-per-run: another group was already updated this run. The image
+	// is pulled, so the next run picks this group up straight away.
+	if opts.deferRecreate {
+		log.Printf("[INFO] Deferring %s: --one-per-run already updated a group this run", sanitize(groupKey))
+		return ResultDeferred, nil
+	}
+
+	// io.repull.canary: recreate one container and hold back the rest
+	// until --promote.
+	var canary bool
+	outdated, canary, err = canaryStep(groupKey, containers, outdated, latestID, opts.Promote == groupKey, opts.State)
+	if err != nil {
+		notifier.SendError(sanitize(groupKey), err.Error())
+		return ResultFailed, err
+	}
+	if len(outdated) == 0 {
+		return ResultDeferred, nil
 	}
 
 	oldID := outdated[0].Image
 	log.Printf("[INFO] Image updated: %s -> %s", truncateDigest(oldID), truncateDigest(latestID))
 
-	if dryRun {
-		log.Printf("[DRY-RUN] Would recreate %s (%d container(s))", sanitize(groupKey), len(outdated))
-		return nil
+	if opts.Planned != nil {
+		opts.Planned(groupKey, imageName, latestID, outdated)
 	}
 
+	if opts.DryRun {
+		log.Printf("[DRY-RUN] Would recreate %s (%d container(s)%s)", sanitize(groupKey), len(outdated), describeDownload(ctx, cli, opts, imageName))
+		if opts.NotifyAvailable {
+			notifier.SendAvailable(sanitize(groupKey), sanitize(imageName), truncateDigest(oldID), truncateDigest(latestID))
+		}
+		return ResultPending, nil
+	}
+
+	// Keep the image being replaced as <repo>:repull-previous, for a
+	// rollback. Only now that a recreate is certain: until then the image
+	// stays in use by the containers, so nothing could remove it.
+	backupID := oldID
+	staleID := tagBackup(ctx, cli, imageName, backupID)
+
 	// Recreate the outdated containers in the group
 	log.Printf("[INFO] Recreating %d container(s)", len(outdated))
+	ropts := docker.RecreateOptions{NoStart: opts.NoStart}
+	recreatedAny := false
+	// Network dependents recreated along the way are reported with the
+	// group rather than on their own.
+	var dependents []string
 	for _, c := range outdated {
+		c = withImage(c, imageName)
 		containerName := strings.TrimPrefix(c.Name, "/")
 		if containerName == "" {
 			containerName = docker.ShortID(c.ID)
 		}
 
+		if err := waitForLoad(ctx, opts.MaxLoad); err != nil {
+			notifier.SendError(sanitize(groupKey), fmt.Sprintf("Did not recreate %s: %v", sanitize(containerName), err))
+			return ResultFailed, fmt.Errorf("did not recreate %s: %w", sanitize(containerName), err)
+		}
+
 		// Containers running a repull image need the rename-first flow: such a
 		// container may be this very process, which cannot stop itself before
 		// the replacement exists. The container already passed the
 		// io.repull.enable=true filter, so the user has opted in.
 		if isRepullInstance(c) {
-			if err := updateRepullInstance(ctx, cli, c, containerName, groupKey, imageName, oldID, latestID, notifier); err != nil {
-				return err
+			// A rolled-back self-update would otherwise be retried on every
+			// run while the registry or the new image stays broken.
+			if su, blocked := selfUpdateBlocked(containerName, latestID, opts.State, opts.SelfUpdateMaxAttempts, time.Now()); blocked {
+				msg := fmt.Sprintf("Not updating %s to %s: %d attempt(s) failed, the last at %s", sanitize(containerName), truncateDigest(latestID), su.Attempts, su.Time.Format(time.RFC3339))
+				log.Printf("[WARN] %s", msg)
+				if !su.Notified {
+					notifier.SendError(sanitize(groupKey), msg)
+					su.Notified = true
+					opts.State.RecordSelfUpdate(containerName, su)
+				}
+				if recreatedAny {
+					continue
+				}
+				return ResultSkipped, nil
 			}
+			if err := updateRepullInstance(ctx, cli, c, containerName, groupKey, imageName, oldID, latestID, notifier, opts.SelfStopTimeout); err != nil {
+				recordSelfUpdateFailure(containerName, latestID, opts.State, time.Now())
+				return ResultFailed, err
+			}
+			opts.State.ClearSelfUpdate(containerName)
 			// Another repull instance was updated; this process is unaffected.
 			// (A self-update never reaches this point — the process exits.)
 			continue
 		}
 
 		log.Printf("[INFO] Recreating container %s", sanitize(containerName))
-		newID, err := docker.RecreateContainer(ctx, cli, c, recreated)
+		sidecars, err := findSidecars(ctx, cli, c)
+		if err != nil {
+			log.Printf("[WARN] Failed to find sidecars of %s, recreating it alone: %v", sanitize(containerName), err)
+		}
+		var newID string
+		if len(sidecars) > 0 {
+			newID, err = recreateWithSidecars(ctx, cli, c, sidecars, recreated, ropts)
+		} else {
+			newID, err = docker.RecreateContainer(ctx, cli, c, recreated, ropts)
+		}
+		// A network_mode that points nowhere is caught before the container
+		// is touched. If nothing in the group changed yet, the group is
+		// merely skipped; its containers keep running as they are.
+		var netErr *docker.NetworkResolveError
+		if errors.As(err, &netErr) && !recreatedAny {
+			log.Printf("[WARN] Skipping %s, left running: %s", sanitize(groupKey), sanitize(err.Error()))
+			notifier.SendError(sanitize(groupKey), fmt.Sprintf("Skipped, left running: %v", err))
+			return ResultSkipped, nil
+		}
 		if err != nil {
-			notifier.SendError(sanitize(groupKey), fmt.Sprintf("Failed to recreate container %s: %v", sanitize(containerName), err))
-			return fmt.Errorf("failed to recreate container %s: %w", sanitize(containerName), err)
+			msg := fmt.Sprintf("Failed to recreate container %s: %v", sanitize(containerName), err)
+			var recreateErr *docker.RecreateError
+			if errors.As(err, &recreateErr) && !recreateErr.RolledBack {
+				msg += " (the old container could not be restored and may be stopped)"
+			}
+			notifier.SendError(sanitize(groupKey), msg)
+			return ResultFailed, fmt.Errorf("failed to recreate container %s: %w", sanitize(containerName), err)
 		}
+		recreatedAny = true
 		// Track the old->new ID mapping for resolving network_mode references
 		recreated[c.ID] = newID
+		opts.State.RecordRecreated(containerName, time.Now())
 		log.Printf("[INFO] Successfully recreated %s", sanitize(containerName))
+		if opts.NoStart {
+			*leftStopped = append(*leftStopped, sanitize(containerName))
+		}
 
 		// Recreate containers that share this container's network namespace.
 		// Their network_mode still points to the old (now dead) container ID,
@@ -150,27 +618,53 @@ func updateGroup(ctx context.Context, cli *client.Client, groupKey string, conta
 				depName = docker.ShortID(dep.ID)
 			}
 			log.Printf("[INFO] Recreating network-dependent container %s", sanitize(depName))
-			depNewID, depRecErr := docker.RecreateContainer(ctx, cli, dep, recreated)
+			depNewID, depRecErr := docker.RecreateContainer(ctx, cli, dep, recreated, ropts)
 			if depRecErr != nil {
 				log.Printf("[WARN] Failed to recreate network-dependent container %s: %v", sanitize(depName), depRecErr)
 				continue
 			}
 			recreated[dep.ID] = depNewID
+			dependents = append(dependents, sanitize(depName))
 			log.Printf("[INFO] Successfully recreated network-dependent %s", sanitize(depName))
+			if opts.NoStart {
+				*leftStopped = append(*leftStopped, sanitize(depName))
+			}
 		}
 	}
 
+	// The rest of the group still runs the old image, so there is nothing
+	// to clean up until the canary is promoted.
+	if canary {
+		canaryName := strings.TrimPrefix(outdated[0].Name, "/")
+		opts.State.RecordCanary(groupKey, state.Canary{Container: canaryName, ImageID: latestID, Time: time.Now()})
+		log.Printf("[INFO] Canary %s of %s deployed; run repull --promote %s to roll out the rest", sanitize(canaryName), sanitize(groupKey), sanitize(groupKey))
+		notifier.SendCanary(sanitize(groupKey), sanitize(canaryName), sanitize(imageName), truncateDigest(oldID), truncateDigest(latestID))
+		return ResultUpdated, nil
+	}
+	if opts.Promote == groupKey {
+		opts.State.ClearCanary(groupKey)
+	}
+
+	opts.State.ClearStaged(imageName)
+
 	// Send success notification after all containers in group are recreated
-	notifier.SendUpdate(sanitize(groupKey), sanitize(imageName), truncateDigest(oldID), truncateDigest(latestID))
+	notifier.SendUpdate(sanitize(groupKey), sanitize(imageName), truncateDigest(oldID), truncateDigest(latestID), imageNotes(ctx, cli, latestID), dependents...)
 
 	// Remove the replaced image(s) now that no container in this group uses
 	// them. Not forced: if another container still uses an old image, Docker
 	// refuses and we just log it. Only reached when every recreation above
 	// succeeded — on a partial failure the old image stays available.
-	if cleanup {
+	if opts.Cleanup {
+		// The image kept as <repo>:repull-previous stays; the one it
+		// replaced as the previous image goes.
 		oldImages := make(map[string]struct{})
 		for _, c := range outdated {
-			oldImages[c.Image] = struct{}{}
+			if c.Image != backupID {
+				oldImages[c.Image] = struct{}{}
+			}
+		}
+		if staleID != "" && staleID != backupID {
+			oldImages[staleID] = struct{}{}
 		}
 		for id := range oldImages {
 			if err := docker.RemoveImage(ctx, cli, id); err != nil {
@@ -181,7 +675,27 @@ func updateGroup(ctx context.Context, cli *client.Client, groupKey string, conta
 		}
 	}
 
-	return nil
+	if opts.KeepImages > 0 {
+		created, _ := time.Parse(time.RFC3339Nano, outdated[0].Created)
+		retainImages(ctx, cli, opts, imageName, oldID, created, latestID, backupID)
+	}
+
+	return ResultUpdated, nil
+}
+
+// tagBackup tags currentID as <repo>:repull-previous before the containers
+// running it are recreated. A failed tag is logged but does not block the
+// update. Returns the image ID that lost the repull-previous tag, if any.
+func tagBackup(ctx context.Context, cli *client.Client, imageName, currentID string) (stale string) {
+	if currentID == "" {
+		return ""
+	}
+	stale, err := docker.TagPrevious(ctx, cli, imageName, currentID)
+	if err != nil {
+		log.Printf("[WARN] Failed to tag %s as previous image: %v", truncateDigest(currentID), err)
+		return ""
+	}
+	return stale
 }
 
 // updateRepullInstance updates a container running a repull image via the
@@ -192,20 +706,34 @@ func updateGroup(ctx context.Context, cli *client.Client, groupKey string, conta
 //
 // If the container is this process (self-update), the function never returns:
 // the ContainerStop kills us, with os.Exit(0) as a fallback. For any other
-// repull instance it returns normally and the caller continues.
-func updateRepullInstance(ctx context.Context, cli *client.Client, c container.InspectResponse, containerName, groupKey, imageName, oldID, latestID string, notifier *notify.Notifier) error {
-	hostname, _ := os.Hostname()
-	self := runningInContainer() && isSelfContainer(c, hostname)
+// repull instance it returns normally and the caller continues. stopTimeout
+// is the grace period, in seconds, the old instance gets before SIGKILL.
+func updateRepullInstance(ctx context.Context, cli *client.Client, c container.InspectResponse, containerName, groupKey, imageName, oldID, latestID string, notifier notify.Reporter, stopTimeout int) error {
+	self := isSelf(c)
+	// A failed self-update may leave nothing running to report it later,
+	// so this process reports every stage of its own update right away.
+	fail := func(msg string) {
+		if self {
+			notifier.SendSelfUpdate(notify.SelfUpdateFailed, sanitize(groupKey), sanitize(imageName), truncateDigest(oldID), truncateDigest(latestID), msg)
+		} else {
+			notifier.SendError(sanitize(groupKey), "Self-update failed: "+msg)
+		}
+	}
 	if self {
 		log.Printf("[INFO] Self-update detected for %s", sanitize(containerName))
+		notifier.SendSelfUpdate(notify.SelfUpdateStarting, sanitize(groupKey), sanitize(imageName), truncateDigest(oldID), truncateDigest(latestID), "")
 	} else {
 		log.Printf("[INFO] Updating repull instance %s (not this process)", sanitize(containerName))
 	}
 
 	// Rename current container to allow new container to use the name
-	tempName := containerName + "-old-" + docker.ShortID(c.ID)
+	tempName, err := docker.OldName(c, containerName, time.Now())
+	if err != nil {
+		fail("rename error")
+		return fmt.Errorf("failed to name old container for self-update: %w", err)
+	}
 	if err := cli.ContainerRename(ctx, c.ID, tempName); err != nil {
-		notifier.SendError(sanitize(groupKey), "Self-update failed: rename error")
+		fail("rename error")
 		return fmt.Errorf("failed to rename container for self-update: %w", err)
 	}
 	log.Printf("[INFO] Renamed %s to %s", sanitize(containerName), sanitize(tempName))
@@ -217,7 +745,7 @@ func updateRepullInstance(ctx context.Context, cli *client.Client, c container.I
 		rbCtx, cancel := docker.RollbackContext(ctx)
 		cli.ContainerRename(rbCtx, c.ID, containerName)
 		cancel()
-		notifier.SendError(sanitize(groupKey), "Self-update failed: could not start new container")
+		fail("could not start new container")
 		return fmt.Errorf("failed to create new container for self-update: %w", err)
 	}
 
@@ -226,20 +754,24 @@ func updateRepullInstance(ctx context.Context, cli *client.Client, c container.I
 		// Send before stopping: if the old container is this process,
 		// the stop below kills us and the notification at the end of
 		// the group never runs. Non-self instances are covered by the
-		// group-level notification instead.
-		notifier.SendUpdate(sanitize(groupKey), sanitize(imageName), truncateDigest(oldID), truncateDigest(latestID))
+		// group-level notification instead. The flush delivers anything
+		// still held by --notify-debounce.
+		notifier.SendSelfUpdate(notify.SelfUpdateDone, sanitize(groupKey), sanitize(imageName), truncateDigest(oldID), truncateDigest(latestID), imageNotes(ctx, cli, latestID))
+		notifier.Flush()
 	}
 
 	// Explicitly stop the old (renamed) container via the Docker API so that
 	// restart: unless-stopped does not restart it. A bare os.Exit(0) is treated
 	// by Docker as an unexpected exit, which triggers the restart policy.
 	// ContainerStop marks the container as explicitly stopped, preventing that.
-	// If the old container is this process, timeout=0 makes Docker SIGKILL us
-	// here; execution past this point means it was another instance (or the
-	// stop failed). Uses a detached context so the stop still goes through
-	// if the update's context has expired.
+	// If the old container is this process, the default timeout=0 makes
+	// Docker SIGKILL us here; with --self-stop-timeout we get SIGTERM first
+	// and that long to exit on our own. Either way the stop goes through the
+	// API, so the restart policy stays out of it. Execution past this point
+	// means it was another instance (or the stop failed). Uses a detached
+	// context so the stop still goes through if the update's context has
+	// expired.
 	stopCtx, cancel := docker.RollbackContext(ctx)
-	stopTimeout := 0
 	if err := cli.ContainerStop(stopCtx, c.ID, container.StopOptions{Timeout: &stopTimeout}); err != nil {
 		log.Printf("[WARN] Failed to stop old container: %v", err)
 	}
@@ -253,11 +785,25 @@ func updateRepullInstance(ctx context.Context, cli *client.Client, c container.I
 	return nil
 }
 
+// DefaultDigestLength is how many characters of a digest logs and
+// notifications show by default: "sha256:" and the first 12 hex digits.
+const DefaultDigestLength = 19
+
+// digestLength is the length truncateDigest cuts digests to; 0 keeps them
+// whole.
+var digestLength = DefaultDigestLength
+
+// SetDigestLength sets how many characters of a digest logs and
+// notifications show (--digest-display-length); 0 shows full digests.
+func SetDigestLength(n int) {
+	digestLength = n
+}
+
 // truncateDigest shortens a digest string for logging.
 // Example: sha256:abc123... -> sha256:abc123
 func truncateDigest(digest string) string {
-	if len(digest) > 19 {
-		return digest[:19] + "..."
+	if digestLength > 0 && len(digest) > digestLength {
+		return digest[:digestLength] + "..."
 	}
 	return digest
 }
@@ -290,6 +836,28 @@ func runningInContainer() bool {
 	return false
 }
 
+// isSelf reports whether c is the container this process runs in; a
+// variable so tests can fake a self-update.
+var isSelf = func(c container.InspectResponse) bool {
+	hostname, _ := os.Hostname()
+	return runningInContainer() && isSelfContainer(c, hostname)
+}
+
+// FindSelf returns the container this process runs in, if it is among
+// containers. Always false for a host binary (see runningInContainer).
+func FindSelf(containers []container.InspectResponse) (container.InspectResponse, bool) {
+	if !runningInContainer() {
+		return container.InspectResponse{}, false
+	}
+	hostname, _ := os.Hostname()
+	for _, c := range containers {
+		if isSelfContainer(c, hostname) {
+			return c, true
+		}
+	}
+	return container.InspectResponse{}, false
+}
+
 // isSelfContainer reports whether the given container is the one this process
 // is running in. Inside a container the hostname defaults to the short
 // container ID; if the user set a custom hostname, fall back to matching it
@@ -1,9 +1,23 @@
 package updater
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+	"github.com/fanuelsen/repull/internal/docker"
+	"github.com/fanuelsen/repull/internal/notify"
+	"github.com/fanuelsen/repull/internal/state"
 )
 
 func TestIsRepullInstance(t *testing.T) {
@@ -112,3 +126,443 @@ func TestIsSelfContainer(t *testing.T) {
 		})
 	}
 }
+
+// fakeDaemon starts an HTTP server standing in for the Docker API and returns
+// a client talking to it. Every request is recorded as "METHOD /path" with
+// the API version prefix stripped.
+func fakeDaemon(t *testing.T, handler http.HandlerFunc) (*client.Client, *[]string) {
+	t.Helper()
+	var mu sync.Mutex
+	var calls []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+		if i := strings.Index(path[1:], "/"); strings.HasPrefix(path, "/v") && i > 0 {
+			path = path[i+1:]
+		}
+		mu.Lock()
+		calls = append(calls, r.Method+" "+path)
+		mu.Unlock()
+		handler(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	cli, err := client.NewClientWithOpts(client.WithHost("tcp://"+strings.TrimPrefix(srv.URL, "http://")), client.WithVersion("1.47"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cli.Close() })
+	return cli, &calls
+}
+
+func TestTruncateDigest(t *testing.T) {
+	const digest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	tests := []struct {
+		name   string
+		length int
+		in     string
+		want   string
+	}{
+		{name: "default", length: DefaultDigestLength, in: digest, want: "sha256:0123456789ab..."},
+		{name: "longer", length: 31, in: digest, want: "sha256:0123456789abcdef01234567..."},
+		{name: "full", length: 0, in: digest, want: digest},
+		{name: "already short", length: DefaultDigestLength, in: "sha256:0123", want: "sha256:0123"},
+	}
+	t.Cleanup(func() { SetDigestLength(DefaultDigestLength) })
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetDigestLength(tt.length)
+			if got := truncateDigest(tt.in); got != tt.want {
+				t.Errorf("truncateDigest() with length %d = %q, want %q", tt.length, got, tt.want)
+			}
+		})
+	}
+}
+
+// tagDaemon fakes a daemon on which web:latest moves from sha256:old to
+// sha256:new once pulled, and the recreate of the web container succeeds.
+func tagDaemon(t *testing.T) (*client.Client, *[]string) {
+	t.Helper()
+	pulled := false
+	return fakeDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/images/create"):
+			pulled = true
+		case strings.HasSuffix(r.URL.Path, "/containers/json"):
+			w.Write([]byte(`[]`))
+		case strings.HasSuffix(r.URL.Path, "/containers/create"):
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"Id":"new"}`))
+		case strings.HasSuffix(r.URL.Path, "/web:repull-previous/json"):
+			// No previous tag exists yet.
+			http.Error(w, `{"message":"No such image"}`, http.StatusNotFound)
+		case strings.HasSuffix(r.URL.Path, "/json"):
+			id := "sha256:old"
+			if pulled {
+				id = "sha256:new"
+			}
+			w.Write([]byte(`{"Id":"` + id + `"}`))
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	})
+}
+
+// TestUpdateGroupTagsBackupBeforeRecreate verifies the replaced image is
+// tagged as <repo>:repull-previous once the group is found outdated, after
+// the pull and before the container is recreated.
+func TestUpdateGroupTagsBackupBeforeRecreate(t *testing.T) {
+	cli, calls := tagDaemon(t)
+	web := container.InspectResponse{
+		ContainerJSONBase: &container.ContainerJSONBase{ID: "c1", Name: "/web", Image: "sha256:old", HostConfig: &container.HostConfig{}},
+		Config:            &container.Config{Image: "web:latest"},
+	}
+
+	result, err := updateGroup(t.Context(), cli, "app:web", []container.InspectResponse{web}, Options{}, make(docker.RecreatedContainers), new([]string))
+	if err != nil || result != ResultUpdated {
+		t.Fatalf("updateGroup() = %q, %v; want %q", result, err, ResultUpdated)
+	}
+	pullAt := slices.Index(*calls, "POST /images/create")
+	tagAt := slices.Index(*calls, "POST /images/sha256:old/tag")
+	createAt := slices.Index(*calls, "POST /containers/create")
+	if pullAt < 0 || tagAt < pullAt || createAt < tagAt {
+		t.Errorf("calls = %v, want the pull, then the tag of sha256:old, then the create", *calls)
+	}
+}
+
+// TestUpdateGroupNoRecreateDoesNotTag verifies nothing is tagged when no
+// container is recreated: the group is up to date, or the run is a dry run.
+func TestUpdateGroupNoRecreateDoesNotTag(t *testing.T) {
+	tests := []struct {
+		name    string
+		running string
+		dryRun  bool
+	}{
+		{name: "up to date", running: "sha256:new"},
+		{name: "dry run", running: "sha256:old", dryRun: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cli, calls := tagDaemon(t)
+			web := container.InspectResponse{
+				ContainerJSONBase: &container.ContainerJSONBase{ID: "c1", Name: "/web", Image: tt.running, HostConfig: &container.HostConfig{}},
+				Config:            &container.Config{Image: "web:latest"},
+			}
+
+			if _, err := updateGroup(t.Context(), cli, "app:web", []container.InspectResponse{web}, Options{DryRun: tt.dryRun}, make(docker.RecreatedContainers), new([]string)); err != nil {
+				t.Fatalf("updateGroup() error = %v", err)
+			}
+			for _, c := range *calls {
+				if strings.HasSuffix(c, "/tag") {
+					t.Errorf("tagged an image without recreating: %v", *calls)
+				}
+			}
+		})
+	}
+}
+
+// digestDaemon fakes a daemon on which web:latest resolves to beforeID with
+// beforeDigests until pulled, and to afterID with afterDigests after.
+func digestDaemon(t *testing.T, beforeID, afterID string, beforeDigests, afterDigests []string) (*client.Client, *[]string) {
+	t.Helper()
+	pulled := false
+	return fakeDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/images/create"):
+			pulled = true
+		case strings.HasSuffix(r.URL.Path, "/containers/json"):
+			w.Write([]byte(`[]`))
+		case strings.HasSuffix(r.URL.Path, "/containers/create"):
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"Id":"new"}`))
+		case strings.HasSuffix(r.URL.Path, "/web:repull-previous/json"):
+			http.Error(w, `{"message":"No such image"}`, http.StatusNotFound)
+		case strings.HasSuffix(r.URL.Path, "/json"):
+			img := image.InspectResponse{ID: beforeID, RepoDigests: beforeDigests}
+			if pulled {
+				img = image.InspectResponse{ID: afterID, RepoDigests: afterDigests}
+			}
+			json.NewEncoder(w).Encode(img)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	})
+}
+
+// TestUpdateGroupComparesImageIDs verifies the update decision follows the
+// image ID alone: a moved ID recreates even when RepoDigests are unchanged,
+// and RepoDigests that change under an unchanged ID recreate nothing.
+func TestUpdateGroupComparesImageIDs(t *testing.T) {
+	tests := []struct {
+		name          string
+		afterID       string
+		beforeDigests []string
+		afterDigests  []string
+		want          Result
+	}{
+		{
+			// A local rebuild pushed under the same tag: the registry
+			// digest is unchanged but the image the tag points to is not.
+			name:          "same repo digest, new image ID",
+			afterID:       "sha256:new",
+			beforeDigests: []string{"web@sha256:aaa"},
+			afterDigests:  []string{"web@sha256:aaa"},
+			want:          ResultUpdated,
+		},
+		{
+			// A multi-arch image first pulled by its platform manifest,
+			// then by the index (or through a pull-through cache): the
+			// reported repo digest changes, the image does not.
+			name:          "new repo digest, same image ID",
+			afterID:       "sha256:old",
+			beforeDigests: []string{"web@sha256:amd64manifest"},
+			afterDigests:  []string{"web@sha256:amd64manifest", "web@sha256:index"},
+			want:          ResultUpToDate,
+		},
+		{
+			// The containerd image store may report no RepoDigests at all.
+			name:         "no repo digests, same image ID",
+			afterID:      "sha256:old",
+			afterDigests: nil,
+			want:         ResultUpToDate,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cli, calls := digestDaemon(t, "sha256:old", tt.afterID, tt.beforeDigests, tt.afterDigests)
+			web := container.InspectResponse{
+				ContainerJSONBase: &container.ContainerJSONBase{ID: "c1", Name: "/web", Image: "sha256:old", HostConfig: &container.HostConfig{}},
+				Config:            &container.Config{Image: "web:latest"},
+			}
+
+			result, err := updateGroup(t.Context(), cli, "app:web", []container.InspectResponse{web}, Options{}, make(docker.RecreatedContainers), new([]string))
+			if err != nil || result != tt.want {
+				t.Fatalf("updateGroup() = %q, %v; want %q", result, err, tt.want)
+			}
+			if recreated := slices.Contains(*calls, "POST /containers/create"); recreated != (tt.want == ResultUpdated) {
+				t.Errorf("recreated = %v, want %v; calls = %v", recreated, tt.want == ResultUpdated, *calls)
+			}
+		})
+	}
+}
+
+// TestUpdateGroupsOnePerRun verifies that with OnePerRun only the first of
+// several outdated groups is recreated; the others are deferred.
+func TestUpdateGroupsOnePerRun(t *testing.T) {
+	cli, calls := fakeDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/containers/json"):
+			w.Write([]byte(`[]`))
+		case strings.HasSuffix(r.URL.Path, "/containers/create"):
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"Id":"new"}`))
+		case strings.HasSuffix(r.URL.Path, "/json"):
+			w.Write([]byte(`{"Id":"sha256:new"}`))
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	})
+
+	outdated := func(id, name string) container.InspectResponse {
+		return container.InspectResponse{
+			ContainerJSONBase: &container.ContainerJSONBase{ID: id, Name: "/" + name, Image: "sha256:old", HostConfig: &container.HostConfig{}},
+			Config:            &container.Config{Image: name + ":latest"},
+		}
+	}
+	groups := map[string][]container.InspectResponse{
+		"app:web": {outdated("c1", "web")},
+		"app:api": {outdated("c2", "api")},
+		"app:db":  {outdated("c3", "db")},
+	}
+
+	if err := UpdateGroups(t.Context(), cli, groups, Options{OnePerRun: true}); err != nil {
+		t.Fatalf("UpdateGroups() error = %v", err)
+	}
+
+	creates := 0
+	for _, c := range *calls {
+		if c == "POST /containers/create" {
+			creates++
+		}
+	}
+	if creates != 1 {
+		t.Errorf("recreated %d group(s), want 1: %v", creates, *calls)
+	}
+}
+
+// TestUpdateGroupsDefersOnUnhealthyDependency verifies that a group labeled
+// io.repull.require-healthy is deferred, without even pulling, while the
+// service it names is unhealthy.
+func TestUpdateGroupsDefersOnUnhealthyDependency(t *testing.T) {
+	cli, calls := fakeDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/containers/json"):
+			w.Write([]byte(`[{"Id":"db1"}]`))
+		case strings.HasSuffix(r.URL.Path, "/containers/db1/json"):
+			w.Write([]byte(`{"Id":"db1","Name":"/myapp-db-1","State":{"Running":true,"Health":{"Status":"unhealthy"}}}`))
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	})
+
+	web := container.InspectResponse{
+		ContainerJSONBase: &container.ContainerJSONBase{ID: "web1", Name: "/myapp-web-1", Image: "sha256:old"},
+		Config:            &container.Config{Image: "web:latest", Labels: map[string]string{RequireHealthyLabel: "myapp:db"}},
+	}
+	groups := map[string][]container.InspectResponse{"myapp:web": {web}}
+
+	if err := UpdateGroups(t.Context(), cli, groups, Options{}); err != nil {
+		t.Fatalf("UpdateGroups() error = %v", err)
+	}
+	for _, c := range *calls {
+		if c == "POST /images/create" || strings.HasPrefix(c, "POST /containers/") {
+			t.Errorf("dependent group was updated despite an unhealthy dependency: %v", *calls)
+		}
+	}
+}
+
+// TestUpdateGroupsCanary walks a canary group through a rollout: the first
+// run recreates one container, the next holds the rest back, and --promote
+// recreates them.
+func TestUpdateGroupsCanary(t *testing.T) {
+	cli, calls := fakeDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/containers/json"):
+			w.Write([]byte(`[{"Id":"web-1","Names":["/web-1"]},{"Id":"web-2","Names":["/web-2"]},{"Id":"web-3","Names":["/web-3"]}]`))
+		case strings.HasSuffix(r.URL.Path, "/images/json"):
+			w.Write([]byte(`[{"Id":"sha256:old"},{"Id":"sha256:new"}]`))
+		case strings.HasSuffix(r.URL.Path, "/containers/create"):
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"Id":"new"}`))
+		case strings.HasSuffix(r.URL.Path, "/json"):
+			w.Write([]byte(`{"Id":"sha256:new"}`))
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	})
+
+	replica := func(name, imageID string) container.InspectResponse {
+		return container.InspectResponse{
+			ContainerJSONBase: &container.ContainerJSONBase{ID: name, Name: "/" + name, Image: imageID, HostConfig: &container.HostConfig{}},
+			Config:            &container.Config{Image: "web:latest", Labels: map[string]string{CanaryLabel: "true"}},
+		}
+	}
+	creates := func() int {
+		n := 0
+		for _, c := range *calls {
+			if c == "POST /containers/create" {
+				n++
+			}
+		}
+		*calls = nil
+		return n
+	}
+	groups := map[string][]container.InspectResponse{"app:web": {
+		replica("web-1", "sha256:old"), replica("web-2", "sha256:old"), replica("web-3", "sha256:old"),
+	}}
+
+	// Without a state file the canary would be forgotten between runs.
+	memory, _ := state.Load("")
+	if err := UpdateGroups(t.Context(), cli, groups, Options{State: memory}); err != nil {
+		t.Fatalf("run without a state file: %v", err)
+	}
+	if n := creates(); n != 0 {
+		t.Errorf("run without a state file recreated %d container(s), want the group skipped", n)
+	}
+
+	st, err := state.Load(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := UpdateGroups(t.Context(), cli, groups, Options{State: st}); err != nil {
+		t.Fatalf("canary run: %v", err)
+	}
+	if n := creates(); n != 1 {
+		t.Errorf("canary run recreated %d container(s), want 1", n)
+	}
+	rec, ok := st.Canary("app:web")
+	if !ok || rec.ImageID != "sha256:new" {
+		t.Fatalf("Canary(app:web) = %+v, %v; want a canary on sha256:new", rec, ok)
+	}
+
+	if rec.Container != "web-1" {
+		t.Fatalf("canary = %s, want web-1", rec.Container)
+	}
+	groups["app:web"][0] = replica("web-1", "sha256:new")
+	if err := UpdateGroups(t.Context(), cli, groups, Options{State: st}); err != nil {
+		t.Fatalf("waiting run: %v", err)
+	}
+	if n := creates(); n != 0 {
+		t.Errorf("run before --promote recreated %d container(s), want 0", n)
+	}
+
+	if err := UpdateGroups(t.Context(), cli, groups, Options{State: st, Promote: "app:web"}); err != nil {
+		t.Fatalf("promote run: %v", err)
+	}
+	if n := creates(); n != 2 {
+		t.Errorf("promote run recreated %d container(s), want 2", n)
+	}
+	if _, ok := st.Canary("app:web"); ok {
+		t.Error("canary still pending after --promote")
+	}
+}
+
+// TestUpdateGroupsFoldsDependents verifies the network dependents recreated
+// along with a group are reported in the group's update notification, not
+// in notifications of their own.
+func TestUpdateGroupsFoldsDependents(t *testing.T) {
+	cli, _ := fakeDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/containers/json"):
+			w.Write([]byte(`[{"Id":"vpn"},{"Id":"proxy"},{"Id":"db"}]`))
+		case strings.HasSuffix(r.URL.Path, "/containers/db/json"):
+			w.Write([]byte(`{"Id":"db","Name":"/db","HostConfig":{"NetworkMode":"bridge"},"Config":{"Image":"postgres:16"}}`))
+		case strings.Contains(r.URL.Path, "/containers/") && strings.HasSuffix(r.URL.Path, "/json"):
+			id := path.Base(path.Dir(r.URL.Path))
+			w.Write([]byte(`{"Id":"` + id + `","Name":"/` + id + `","HostConfig":{"NetworkMode":"container:web"},"Config":{"Image":"` + id + `:latest"}}`))
+		case strings.HasSuffix(r.URL.Path, "/containers/create"):
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"Id":"new-` + strings.TrimPrefix(r.URL.Query().Get("name"), "/") + `"}`))
+		case strings.HasSuffix(r.URL.Path, "/json"):
+			w.Write([]byte(`{"Id":"sha256:new"}`))
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	})
+
+	events := filepath.Join(t.TempDir(), "events.jsonl")
+	file, err := notify.NewFileNotifier(events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	groups := map[string][]container.InspectResponse{"app:web": {{
+		ContainerJSONBase: &container.ContainerJSONBase{ID: "web", Name: "/web", Image: "sha256:old", HostConfig: &container.HostConfig{}},
+		Config:            &container.Config{Image: "web:latest"},
+	}}}
+	if err := UpdateGroups(t.Context(), cli, groups, Options{Notifier: (*notify.Notifier)(nil).WithFile(file)}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var updates []notify.FileEvent
+	for line := range strings.Lines(string(data)) {
+		var e notify.FileEvent
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatal(err)
+		}
+		if e.Event == "update" {
+			updates = append(updates, e)
+		}
+	}
+	if len(updates) != 1 || updates[0].Service != "app:web" || !slices.Equal(updates[0].Dependents, []string{"vpn", "proxy"}) {
+		t.Errorf("update events = %+v, want one for app:web with dependents vpn and proxy", updates)
+	}
+}
@@ -0,0 +1,49 @@
+// Package useragent sets the User-Agent header on the HTTP requests repull
+// makes itself — notifications and registry lookups — so registries, WAFs
+// and rate limiters see one consistent, allowlistable identity instead of
+// Go's default.
+package useragent
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+var value atomic.Value // string
+
+func init() {
+	value.Store("repull/dev")
+}
+
+// Set sets the User-Agent sent from now on, e.g. "repull/v1.4.0". An empty
+// string leaves requests with Go's default.
+func Set(ua string) {
+	value.Store(ua)
+}
+
+// Get returns the current User-Agent.
+func Get() string {
+	return value.Load().(string)
+}
+
+// Transport adds the User-Agent to every request that does not already set
+// one, then hands it to Base (http.DefaultTransport if nil).
+type Transport struct {
+	Base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	ua := Get()
+	if ua == "" || req.Header.Get("User-Agent") != "" {
+		return base.RoundTrip(req)
+	}
+	// A RoundTripper must not modify the caller's request.
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", ua)
+	return base.RoundTrip(req)
+}
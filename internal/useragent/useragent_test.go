@@ -0,0 +1,39 @@
+package useragent
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTransportSetsUserAgent(t *testing.T) {
+	t.Cleanup(func() { Set("repull/dev") })
+
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("User-Agent")
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: Transport{}}
+	get := func(ua string) string {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+		if ua != "" {
+			req.Header.Set("User-Agent", ua)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return got
+	}
+
+	Set("repull/v1.2.3")
+	if ua := get(""); ua != "repull/v1.2.3" {
+		t.Errorf("User-Agent = %q, want repull/v1.2.3", ua)
+	}
+	if ua := get("custom/1.0"); ua != "custom/1.0" {
+		t.Errorf("User-Agent = %q, want an explicit header kept", ua)
+	}
+}